@@ -14,6 +14,7 @@ import (
 
 	"github.com/btcsuite/btcutil/base58"
 	sdkv1beta "github.com/yago-123/chainnet-sdk-go/v1beta"
+	"github.com/yago-123/chainnet/config"
 	botconfig "github.com/yago-123/chainnet/config/bot"
 	"github.com/yago-123/chainnet/pkg/kernel"
 	"github.com/yago-123/chainnet/pkg/script"
@@ -41,6 +42,9 @@ var (
 		sign.NewECDSASignature(),
 		hash.NewHasher(sha256.New()),
 	)
+
+	// the bot does not run a node, the consensus limits used for validating txs are the default ones
+	consensusCfg = config.NewConfig()
 )
 
 var logger = logrus.New()
@@ -71,7 +75,7 @@ func main() { //nolint:funlen,gocognit,nolintlint // this is a main function, it
 		hdWallet, err = hd_wallet.NewHDWalletWithKeys(
 			walletCfg,
 			1,
-			validator.NewLightValidator(hash.GetHasher(consensusHasherType)),
+			validator.NewLightValidator(consensusCfg, hash.GetHasher(consensusHasherType)),
 			consensusSigner,
 			hash.GetHasher(consensusHasherType),
 			encoding.NewProtobufEncoder(),
@@ -92,7 +96,7 @@ func main() { //nolint:funlen,gocognit,nolintlint // this is a main function, it
 		hdWallet, err = hd_wallet.NewHDWalletWithMetadata(
 			walletCfg,
 			1,
-			validator.NewLightValidator(hash.GetHasher(consensusHasherType)),
+			validator.NewLightValidator(consensusCfg, hash.GetHasher(consensusHasherType)),
 			consensusSigner,
 			hash.GetHasher(consensusHasherType),
 			encoding.NewProtobufEncoder(),
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/yago-123/chainnet/pkg/monitor"
+	p2p "github.com/yago-123/chainnet/pkg/network"
 	"github.com/yago-123/chainnet/pkg/utxoset"
 
 	expl "github.com/yago-123/chainnet/pkg/chain/explorer"
@@ -65,19 +69,24 @@ func main() {
 	explorer := expl.NewChainExplorer(meteredBoltdb, hash.GetHasher(consensusHasherType))
 
 	// create mempool instance
-	mempool := mempool.NewMemPool(cfg.Chain.MaxTxsMempool)
+	memPool := mempool.NewMemPool(cfg.Chain.MaxMempoolSize, cfg.Chain.MinRelayFeeRate)
 
 	// create utxo set instance
 	utxoSet := utxoset.NewUTXOSet(cfg)
 
-	// create heavy validator
+	// create light and heavy validators
+	lightValidator := validator.NewLightValidator(cfg, hash.GetHasher(consensusHasherType))
 	heavyValidator := validator.NewHeavyValidator(
 		cfg,
-		validator.NewLightValidator(hash.GetHasher(consensusHasherType)),
+		lightValidator,
 		explorer,
+		utxoSet,
+		mempool.NewMemPoolExplorer(memPool),
 		consensusSigner,
 		hash.GetHasher(consensusHasherType),
 	)
+	// transactions returned to the mempool when blocks are disconnected must be validated again
+	memPool.SetTxValidator(heavyValidator)
 
 	// define encoder type
 	encoder := encoding.NewProtobufEncoder()
@@ -86,10 +95,11 @@ func main() {
 	chain, err := blockchain.NewBlockchain(
 		cfg,
 		meteredBoltdb,
-		mempool,
+		memPool,
 		utxoSet,
 		hash.GetHasher(consensusHasherType),
 		heavyValidator,
+		lightValidator,
 		subjectChain,
 		encoder,
 	)
@@ -109,9 +119,14 @@ func main() {
 	// register chain observers
 	subjectChain.Register(mine)
 	subjectChain.Register(meteredBoltdb)
-	subjectChain.Register(mempool)
+	subjectChain.Register(memPool)
 	subjectChain.Register(utxoSet)
 
+	// restore the transactions that were in the mempool before the last shutdown
+	if err = chain.LoadMempool(cfg.MempoolFile); err != nil {
+		cfg.Logger.Errorf("error restoring mempool: %s", err)
+	}
+
 	network, err := chain.InitNetwork(subjectNet)
 	if err != nil {
 		cfg.Logger.Fatalf("error initializing network: %s", err)
@@ -121,7 +136,7 @@ func main() {
 	subjectChain.Register(network)
 
 	// add monitoring via Prometheus
-	monitors := []monitor.Monitor{chain, meteredBoltdb, mempool, utxoSet, network, heavyValidator}
+	monitors := []monitor.Monitor{chain, meteredBoltdb, memPool, utxoSet, network, heavyValidator}
 	prometheusExporter := monitor.NewPrometheusExporter(cfg, monitors)
 
 	if cfg.Prometheus.Enabled {
@@ -132,7 +147,15 @@ func main() {
 		cfg.Logger.Infof("exposing Prometheus metrics in http://localhost:%d%s", cfg.Prometheus.Port, cfg.Prometheus.Path)
 	}
 
-	for {
+	// stop mining when asked to stop, the block being mined is canceled so the loop exits once MineBlock returns
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		mine.CancelMining()
+	}()
+
+	for ctx.Err() == nil {
 		// start mining block
 		block, err = mine.MineBlock()
 		if err != nil {
@@ -155,4 +178,29 @@ func main() {
 			block.Hash, block.Header.PrevBlockHash, len(block.Transactions), miningTime, block.Header.Height, block.Header.Target, block.Header.Nonce,
 		)
 	}
+
+	// the mining loop is stopped at this point, so nothing modifies the chain while shutting down
+	cfg.Logger.Infof("shutting down miner")
+	shutdown(memPool, network, meteredBoltdb)
+}
+
+// shutdown persists the mempool so that pending transactions survive the restart and stops the components that
+// hold resources (network, storage)
+func shutdown(memPool *mempool.MemPool, network *p2p.NodeP2P, store storage.Storage) {
+	if cfg.MempoolFile != "" {
+		persisted, err := memPool.Persist(cfg.MempoolFile)
+		if err != nil {
+			cfg.Logger.Errorf("error persisting mempool: %s", err)
+		} else {
+			cfg.Logger.Infof("persisted %d mempool transactions to %s", persisted, cfg.MempoolFile)
+		}
+	}
+
+	if err := network.Stop(); err != nil {
+		cfg.Logger.Errorf("error stopping network: %s", err)
+	}
+
+	if err := store.Close(); err != nil {
+		cfg.Logger.Errorf("error closing storage: %s", err)
+	}
 }
@@ -59,7 +59,7 @@ var addressesCmd = &cobra.Command{
 				Logger:         cfg.Logger,
 			},
 			1,
-			validator.NewLightValidator(hash.GetHasher(consensusHasherType)),
+			validator.NewLightValidator(cfg, hash.GetHasher(consensusHasherType)),
 			consensusSigner,
 			hash.GetHasher(consensusHasherType),
 			encoding.NewProtobufEncoder(),
@@ -83,6 +83,12 @@ var addressesCmd = &cobra.Command{
 			logger.Fatalf("error extracting pub key hash from P2PKH address: %v", err)
 		}
 		logger.Infof("Hashed-only P2PKH address %s, version: %d", base58.Encode(pubKeyHashedAddr), version)
+
+		p2shAddr, err := wallet.GetP2SHAddress()
+		if err != nil {
+			logger.Fatalf("error getting P2SH address: %v", err)
+		}
+		logger.Infof("P2SH addr: %s", base58.Encode(p2shAddr))
 	},
 }
 
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/spf13/cobra"
+	"github.com/yago-123/chainnet/config"
+	"github.com/yago-123/chainnet/pkg/kernel"
+)
+
+const (
+	FlagTxID = "tx-id"
+)
+
+var bumpFeeCmd = &cobra.Command{
+	Use:   "bump-fee",
+	Short: "Bump transaction fee",
+	Long: `Replace a transaction that has not been confirmed yet with one paying a higher fee (replace-by-fee). The
+fee increase is subtracted from the change output of the original transaction.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		cfg = config.InitConfig(cmd)
+
+		txIDStr, _ := cmd.Flags().GetString(FlagTxID)
+		fee, _ := cmd.Flags().GetFloat64(FlagFee)
+		privKeyCont, _ := cmd.Flags().GetString(FlagPrivKey)
+		privKeyPath, _ := cmd.Flags().GetString(FlagWalletKey)
+
+		txID, err := hex.DecodeString(txIDStr)
+		if err != nil {
+			logger.Fatalf("error decoding transaction ID: %v", err)
+		}
+
+		wallet := setupWallet(privKeyCont, privKeyPath)
+
+		tx, err := wallet.GetTransaction(txID)
+		if err != nil {
+			logger.Fatalf("error getting transaction: %v", err)
+		}
+
+		// the inputs of the original transaction are still unspent until it gets confirmed
+		utxos, err := wallet.GetWalletUTXOS()
+		if err != nil {
+			logger.Fatalf("error getting wallet UTXOS: %v", err)
+		}
+
+		replacement, err := wallet.BumpFee(tx, kernel.ConvertFromCoinsToChannoshis(fee), utxos)
+		if err != nil {
+			logger.Fatalf("error bumping transaction fee: %v", err)
+		}
+
+		context, cancel := context.WithTimeout(context.Background(), cfg.Wallet.RequestTimeout)
+		defer cancel()
+
+		err = wallet.SendTransaction(context, *replacement)
+		if err != nil {
+			logger.Fatalf("error sending transaction: %v", err)
+		}
+
+		logger.Infof("Replaced transaction %x with: %+v", txID, replacement)
+	},
+}
+
+func init() {
+	// main command
+	config.AddConfigFlags(bumpFeeCmd)
+	rootCmd.AddCommand(bumpFeeCmd)
+
+	// sub commands
+	bumpFeeCmd.Flags().String(FlagTxID, "", "ID of the transaction to replace, hex encoded")
+	bumpFeeCmd.Flags().Float64(FlagFee, 0.0, "New total fee of the transaction")
+	bumpFeeCmd.Flags().String(FlagPrivKey, "", "Private key")
+	bumpFeeCmd.Flags().String(FlagWalletKey, "", "Path to private key")
+
+	// required flags
+	_ = bumpFeeCmd.MarkFlagRequired(FlagTxID)
+	_ = bumpFeeCmd.MarkFlagRequired(FlagFee)
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/yago-123/chainnet/config"
+	"github.com/yago-123/chainnet/pkg/kernel"
+)
+
+const (
+	FlagData = "data"
+	FlagFile = "file"
+)
+
+var publishCmd = &cobra.Command{
+	Use:   "publish",
+	Short: "Publish data commitment",
+	Long: `Publish data into the chain via an unspendable OP_RETURN output. When a file is provided, the SHA-256 digest
+of the file is published instead of the content (timestamping of documents).`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		cfg = config.InitConfig(cmd)
+
+		dataStr, _ := cmd.Flags().GetString(FlagData)
+		filePath, _ := cmd.Flags().GetString(FlagFile)
+		fee, _ := cmd.Flags().GetFloat64(FlagFee)
+		privKeyCont, _ := cmd.Flags().GetString(FlagPrivKey)
+		privKeyPath, _ := cmd.Flags().GetString(FlagWalletKey)
+
+		// check if only one source of data is provided
+		if (dataStr == "") == (filePath == "") {
+			logger.Fatalf("specify one argument containing the data to publish: --data or --file")
+		}
+
+		data := []byte(dataStr)
+		if filePath != "" {
+			content, err := os.ReadFile(filePath)
+			if err != nil {
+				logger.Fatalf("error reading file: %v", err)
+			}
+
+			digest := sha256.Sum256(content)
+			data = digest[:]
+		}
+
+		wallet := setupWallet(privKeyCont, privKeyPath)
+
+		utxos, err := wallet.GetWalletUTXOS()
+		if err != nil {
+			logger.Fatalf("error getting wallet UTXOS: %v", err)
+		}
+
+		tx, err := wallet.GenerateDataTransaction(data, kernel.ConvertFromCoinsToChannoshis(fee), utxos)
+		if err != nil {
+			logger.Fatalf("error generating transaction: %v", err)
+		}
+
+		context, cancel := context.WithTimeout(context.Background(), cfg.Wallet.RequestTimeout)
+		defer cancel()
+
+		err = wallet.SendTransaction(context, *tx)
+		if err != nil {
+			logger.Fatalf("error sending transaction: %v", err)
+		}
+
+		logger.Infof("Published data commitment %x in transaction: %+v", data, tx)
+	},
+}
+
+func init() {
+	// main command
+	config.AddConfigFlags(publishCmd)
+	rootCmd.AddCommand(publishCmd)
+
+	// sub commands
+	publishCmd.Flags().String(FlagData, "", "Data to publish")
+	publishCmd.Flags().String(FlagFile, "", "Path to file whose SHA-256 digest is published")
+	publishCmd.Flags().Float64(FlagFee, 0.0, "Amount of fee to send")
+	publishCmd.Flags().String(FlagPrivKey, "", "Private key")
+	publishCmd.Flags().String(FlagWalletKey, "", "Path to private key")
+}
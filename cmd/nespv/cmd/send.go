@@ -43,56 +43,12 @@ var sendCmd = &cobra.Command{
 		privKeyCont, _ := cmd.Flags().GetString(FlagPrivKey)
 		privKeyPath, _ := cmd.Flags().GetString(FlagWalletKey)
 
-		// check if only one private key is provided
-		if (privKeyCont == "") == (privKeyPath == "") {
-			logger.Fatalf("specify one argument containing the private key: --priv-key or --wallet-key-path")
-		}
-
-		var err error
-		var privKey, pubKey []byte
 		var payType script.ScriptType
-
-		// process key from path or from content
-		if privKeyCont != "" {
-			privKey = base58.Decode(privKeyCont)
-		}
-
-		if privKeyPath != "" {
-			privKey, err = util_crypto.ReadECDSAPemToPrivateKeyDerBytes(privKeyPath)
-			if err != nil {
-				logger.Fatalf("error reading private key: %v", err)
-			}
-		}
-
 		if scriptTypeStr != "" {
 			payType = script.ReturnScriptTypeFromStringType(scriptTypeStr)
 		}
 
-		// derive public key from private key
-		pubKey, err = util_crypto.DeriveECDSAPubFromPrivateDERBytes(privKey)
-		if err != nil {
-			logger.Fatalf("%v: %v", cerror.ErrCryptoPublicKeyDerivation, err)
-		}
-
-		// create wallet
-		wallet, err := wallt.NewWalletWithKeys(
-			walletcommon.ClientConfig{
-				ServerAddress:  cfg.Wallet.ServerAddress,
-				ServerPort:     cfg.Wallet.ServerPort,
-				RequestTimeout: cfg.Wallet.RequestTimeout,
-				Logger:         cfg.Logger,
-			},
-			1,
-			validator.NewLightValidator(hash.GetHasher(consensusHasherType)),
-			consensusSigner,
-			hash.GetHasher(consensusHasherType),
-			encoding.NewProtobufEncoder(),
-			privKey,
-			pubKey,
-		)
-		if err != nil {
-			logger.Fatalf("error setting up wallet: %v", err)
-		}
+		wallet := setupWallet(privKeyCont, privKeyPath)
 
 		utxos, err := wallet.GetWalletUTXOS()
 		if err != nil {
@@ -116,6 +72,57 @@ var sendCmd = &cobra.Command{
 	},
 }
 
+// setupWallet creates the wallet from the private key provided either via content or via path
+func setupWallet(privKeyCont, privKeyPath string) *wallt.Wallet {
+	// check if only one private key is provided
+	if (privKeyCont == "") == (privKeyPath == "") {
+		logger.Fatalf("specify one argument containing the private key: --priv-key or --wallet-key-path")
+	}
+
+	var err error
+	var privKey, pubKey []byte
+
+	// process key from path or from content
+	if privKeyCont != "" {
+		privKey = base58.Decode(privKeyCont)
+	}
+
+	if privKeyPath != "" {
+		privKey, err = util_crypto.ReadECDSAPemToPrivateKeyDerBytes(privKeyPath)
+		if err != nil {
+			logger.Fatalf("error reading private key: %v", err)
+		}
+	}
+
+	// derive public key from private key
+	pubKey, err = util_crypto.DeriveECDSAPubFromPrivateDERBytes(privKey)
+	if err != nil {
+		logger.Fatalf("%v: %v", cerror.ErrCryptoPublicKeyDerivation, err)
+	}
+
+	// create wallet
+	wallet, err := wallt.NewWalletWithKeys(
+		walletcommon.ClientConfig{
+			ServerAddress:  cfg.Wallet.ServerAddress,
+			ServerPort:     cfg.Wallet.ServerPort,
+			RequestTimeout: cfg.Wallet.RequestTimeout,
+			Logger:         cfg.Logger,
+		},
+		1,
+		validator.NewLightValidator(cfg, hash.GetHasher(consensusHasherType)),
+		consensusSigner,
+		hash.GetHasher(consensusHasherType),
+		encoding.NewProtobufEncoder(),
+		privKey,
+		pubKey,
+	)
+	if err != nil {
+		logger.Fatalf("error setting up wallet: %v", err)
+	}
+
+	return wallet
+}
+
 func init() {
 	// main command
 	config.AddConfigFlags(sendCmd)
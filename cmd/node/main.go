@@ -2,6 +2,9 @@ package main
 
 import (
 	"crypto/sha256"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/sirupsen/logrus"
 	"github.com/yago-123/chainnet/config"
@@ -14,6 +17,7 @@ import (
 	"github.com/yago-123/chainnet/pkg/encoding"
 	"github.com/yago-123/chainnet/pkg/mempool"
 	"github.com/yago-123/chainnet/pkg/monitor"
+	p2p "github.com/yago-123/chainnet/pkg/network"
 	"github.com/yago-123/chainnet/pkg/observer"
 	"github.com/yago-123/chainnet/pkg/storage"
 	"github.com/yago-123/chainnet/pkg/utxoset"
@@ -59,19 +63,24 @@ func main() {
 	explorer := expl.NewChainExplorer(meteredBoltdb, hash.GetHasher(consensusHasherType))
 
 	// create mempool instance
-	mempool := mempool.NewMemPool(cfg.Chain.MaxTxsMempool)
+	memPool := mempool.NewMemPool(cfg.Chain.MaxMempoolSize, cfg.Chain.MinRelayFeeRate)
 
 	// create utxo set instance
 	utxoSet := utxoset.NewUTXOSet(cfg)
 
-	// create heavy validator
+	// create light and heavy validators
+	lightValidator := validator.NewLightValidator(cfg, hash.GetHasher(consensusHasherType))
 	heavyValidator := validator.NewHeavyValidator(
 		cfg,
-		validator.NewLightValidator(hash.GetHasher(consensusHasherType)),
+		lightValidator,
 		explorer,
+		utxoSet,
+		mempool.NewMemPoolExplorer(memPool),
 		consensusSigner,
 		hash.GetHasher(consensusHasherType),
 	)
+	// transactions returned to the mempool when blocks are disconnected must be validated again
+	memPool.SetTxValidator(heavyValidator)
 
 	// define encoder type
 	encoder := encoding.NewProtobufEncoder()
@@ -80,10 +89,11 @@ func main() {
 	chain, err := blockchain.NewBlockchain(
 		cfg,
 		meteredBoltdb,
-		mempool,
+		memPool,
 		utxoSet,
 		hash.GetHasher(consensusHasherType),
 		heavyValidator,
+		lightValidator,
 		subjectChain,
 		encoder,
 	)
@@ -96,9 +106,14 @@ func main() {
 
 	// register chain observers
 	subjectChain.Register(meteredBoltdb)
-	subjectChain.Register(mempool)
+	subjectChain.Register(memPool)
 	subjectChain.Register(utxoSet)
 
+	// restore the transactions that were in the mempool before the last shutdown
+	if err = chain.LoadMempool(cfg.MempoolFile); err != nil {
+		cfg.Logger.Errorf("error restoring mempool: %s", err)
+	}
+
 	// the chain network is an special case regarding prometheus, see why inside the network module
 	network, err := chain.InitNetwork(netSubject)
 	if err != nil {
@@ -109,7 +124,7 @@ func main() {
 	subjectChain.Register(network)
 
 	// add monitoring via Prometheus
-	monitors := []monitor.Monitor{chain, meteredBoltdb, mempool, utxoSet, network, heavyValidator}
+	monitors := []monitor.Monitor{chain, meteredBoltdb, memPool, utxoSet, network, heavyValidator}
 	prometheusExporter := monitor.NewPrometheusExporter(cfg, monitors)
 
 	if cfg.Prometheus.Enabled {
@@ -120,5 +135,32 @@ func main() {
 		cfg.Logger.Infof("exposing Prometheus metrics in http://localhost:%d%s", cfg.Prometheus.Port, cfg.Prometheus.Path)
 	}
 
-	select {}
+	// wait until the node is asked to stop and shut it down gracefully
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-sigCh
+
+	cfg.Logger.Infof("received signal %s, shutting down node", sig)
+	shutdown(memPool, network, meteredBoltdb)
+}
+
+// shutdown persists the mempool so that pending transactions survive the restart and stops the components that
+// hold resources (network, storage)
+func shutdown(memPool *mempool.MemPool, network *p2p.NodeP2P, store storage.Storage) {
+	if cfg.MempoolFile != "" {
+		persisted, err := memPool.Persist(cfg.MempoolFile)
+		if err != nil {
+			cfg.Logger.Errorf("error persisting mempool: %s", err)
+		} else {
+			cfg.Logger.Infof("persisted %d mempool transactions to %s", persisted, cfg.MempoolFile)
+		}
+	}
+
+	if err := network.Stop(); err != nil {
+		cfg.Logger.Errorf("error stopping network: %s", err)
+	}
+
+	if err := store.Close(); err != nil {
+		cfg.Logger.Errorf("error closing storage: %s", err)
+	}
 }
@@ -16,12 +16,25 @@ const (
 	KeyConfigFile  = "config"
 	KeyNodeSeeds   = "node-seeds"
 	KeyStorageFile = "storage-file"
+	KeyMempoolFile = "mempool-file"
 
 	KeyMiningPubKeyReward       = "miner.pub-key-reward"
 	KeyMiningInterval           = "miner.mining-interval"
 	KeyMiningIntervalAdjustment = "miner.adjustment-interval"
 
-	KeyChainMaxTxsMempool = "chain.max-txs-mempool"
+	KeyChainMaxMempoolSize     = "chain.max-mempool-size"
+	KeyChainMinRelayFeeRate    = "chain.min-relay-fee-rate"
+	KeyChainMempoolMaxAge      = "chain.mempool-max-age"
+	KeyChainMaxReorgDepth      = "chain.max-reorg-depth"
+	KeyChainSyncInterval       = "chain.sync-interval"
+	KeyChainCoinbaseMaturity   = "chain.coinbase-maturity"
+	KeyChainMaxFutureBlockTime = "chain.max-future-block-time"
+	KeyChainMaxBlockSize       = "chain.max-block-size"
+	KeyChainMaxTxsPerBlock     = "chain.max-txs-per-block"
+	KeyChainMaxTxInputs        = "chain.max-tx-inputs"
+	KeyChainMaxTxOutputs       = "chain.max-tx-outputs"
+	KeyChainMaxTxSize          = "chain.max-tx-size"
+	KeyChainMaxDataCarrierSize = "chain.max-data-carrier-size"
 
 	KeyPrometheusEnabled        = "prometheus.enabled"
 	KeyPrometheusPort           = "prometheus.port"
@@ -53,11 +66,24 @@ const (
 	DefaultConfigFile = ""
 
 	DefaultChainnetStorage = "chainnet-storage"
+	DefaultChainnetMempool = "chainnet-mempool"
 
 	DefaultMiningInterval           = 10 * time.Minute
 	DefaultMiningIntervalAdjustment = uint(6)
 
-	DefaultMaxTxsMempool = 10000
+	DefaultMaxMempoolSize     = 300000000
+	DefaultMinRelayFeeRate    = 0
+	DefaultMempoolMaxAge      = 14 * 24 * time.Hour
+	DefaultMaxReorgDepth      = 100
+	DefaultSyncInterval       = 1 * time.Minute
+	DefaultCoinbaseMaturity   = 100
+	DefaultMaxFutureBlockTime = 2 * time.Hour
+	DefaultMaxBlockSize       = 1000000
+	DefaultMaxTxsPerBlock     = 300
+	DefaultMaxTxInputs        = 1000
+	DefaultMaxTxOutputs       = 1000
+	DefaultMaxTxSize          = 100000
+	DefaultMaxDataCarrierSize = 80
 
 	DefaultPrometheusEnabled        = true
 	DefaultPrometheusPort           = 9090
@@ -98,7 +124,19 @@ type Miner struct {
 }
 
 type Chain struct {
-	MaxTxsMempool uint `mapstructure:"max-txs-mempool"`
+	MaxMempoolSize     uint          `mapstructure:"max-mempool-size"`
+	MinRelayFeeRate    uint          `mapstructure:"min-relay-fee-rate"`
+	MempoolMaxAge      time.Duration `mapstructure:"mempool-max-age"`
+	MaxReorgDepth      uint          `mapstructure:"max-reorg-depth"`
+	SyncInterval       time.Duration `mapstructure:"sync-interval"`
+	CoinbaseMaturity   uint          `mapstructure:"coinbase-maturity"`
+	MaxFutureBlockTime time.Duration `mapstructure:"max-future-block-time"`
+	MaxBlockSize       uint          `mapstructure:"max-block-size"`
+	MaxTxsPerBlock     uint          `mapstructure:"max-txs-per-block"`
+	MaxTxInputs        uint          `mapstructure:"max-tx-inputs"`
+	MaxTxOutputs       uint          `mapstructure:"max-tx-outputs"`
+	MaxTxSize          uint          `mapstructure:"max-tx-size"`
+	MaxDataCarrierSize uint          `mapstructure:"max-data-carrier-size"`
 }
 
 type Prometheus struct {
@@ -135,6 +173,7 @@ type Config struct {
 	Logger      *logrus.Logger
 	SeedNodes   []SeedNode   `mapstructure:"seed-nodes"`
 	StorageFile string       `mapstructure:"storage-file"`
+	MempoolFile string       `mapstructure:"mempool-file"`
 	Miner       Miner        `mapstructure:"miner"`
 	Chain       Chain        `mapstructure:"chain"`
 	Prometheus  Prometheus   `mapstructure:"prometheus"`
@@ -148,13 +187,26 @@ func NewConfig() *Config {
 		Logger:      logrus.New(),
 		SeedNodes:   []SeedNode{},
 		StorageFile: DefaultChainnetStorage,
+		MempoolFile: DefaultChainnetMempool,
 		Miner: Miner{
 			PubKey:             "",
 			MiningInterval:     DefaultMiningInterval,
 			AdjustmentInterval: DefaultMiningIntervalAdjustment,
 		},
 		Chain: Chain{
-			MaxTxsMempool: DefaultMaxTxsMempool,
+			MaxMempoolSize:     DefaultMaxMempoolSize,
+			MinRelayFeeRate:    DefaultMinRelayFeeRate,
+			MempoolMaxAge:      DefaultMempoolMaxAge,
+			MaxReorgDepth:      DefaultMaxReorgDepth,
+			SyncInterval:       DefaultSyncInterval,
+			CoinbaseMaturity:   DefaultCoinbaseMaturity,
+			MaxFutureBlockTime: DefaultMaxFutureBlockTime,
+			MaxBlockSize:       DefaultMaxBlockSize,
+			MaxTxsPerBlock:     DefaultMaxTxsPerBlock,
+			MaxTxInputs:        DefaultMaxTxInputs,
+			MaxTxOutputs:       DefaultMaxTxOutputs,
+			MaxTxSize:          DefaultMaxTxSize,
+			MaxDataCarrierSize: DefaultMaxDataCarrierSize,
 		},
 		Prometheus: Prometheus{
 			Enabled:    DefaultPrometheusEnabled,
@@ -220,10 +272,23 @@ func configKeys() []string {
 	return []string{
 		KeyNodeSeeds,
 		KeyStorageFile,
+		KeyMempoolFile,
 		KeyMiningPubKeyReward,
 		KeyMiningInterval,
 		KeyMiningIntervalAdjustment,
-		KeyChainMaxTxsMempool,
+		KeyChainMaxMempoolSize,
+		KeyChainMinRelayFeeRate,
+		KeyChainMempoolMaxAge,
+		KeyChainMaxReorgDepth,
+		KeyChainSyncInterval,
+		KeyChainCoinbaseMaturity,
+		KeyChainMaxFutureBlockTime,
+		KeyChainMaxBlockSize,
+		KeyChainMaxTxsPerBlock,
+		KeyChainMaxTxInputs,
+		KeyChainMaxTxOutputs,
+		KeyChainMaxTxSize,
+		KeyChainMaxDataCarrierSize,
 		KeyPrometheusEnabled,
 		KeyPrometheusPort,
 		KeyPrometheusLibp2pPort,
@@ -300,6 +365,9 @@ func applySeedEnv(v *viper.Viper, cfg *Config) error {
 	if v.IsSet(KeyStorageFile) {
 		cfg.StorageFile = v.GetString(KeyStorageFile)
 	}
+	if v.IsSet(KeyMempoolFile) {
+		cfg.MempoolFile = v.GetString(KeyMempoolFile)
+	}
 
 	return nil
 }
@@ -314,8 +382,44 @@ func applyMinerEnv(v *viper.Viper, cfg *Config) {
 	if v.IsSet(KeyMiningIntervalAdjustment) {
 		cfg.Miner.AdjustmentInterval = v.GetUint(KeyMiningIntervalAdjustment)
 	}
-	if v.IsSet(KeyChainMaxTxsMempool) {
-		cfg.Chain.MaxTxsMempool = v.GetUint(KeyChainMaxTxsMempool)
+	if v.IsSet(KeyChainMaxMempoolSize) {
+		cfg.Chain.MaxMempoolSize = v.GetUint(KeyChainMaxMempoolSize)
+	}
+	if v.IsSet(KeyChainMinRelayFeeRate) {
+		cfg.Chain.MinRelayFeeRate = v.GetUint(KeyChainMinRelayFeeRate)
+	}
+	if v.IsSet(KeyChainMempoolMaxAge) {
+		cfg.Chain.MempoolMaxAge = v.GetDuration(KeyChainMempoolMaxAge)
+	}
+	if v.IsSet(KeyChainMaxReorgDepth) {
+		cfg.Chain.MaxReorgDepth = v.GetUint(KeyChainMaxReorgDepth)
+	}
+	if v.IsSet(KeyChainSyncInterval) {
+		cfg.Chain.SyncInterval = v.GetDuration(KeyChainSyncInterval)
+	}
+	if v.IsSet(KeyChainCoinbaseMaturity) {
+		cfg.Chain.CoinbaseMaturity = v.GetUint(KeyChainCoinbaseMaturity)
+	}
+	if v.IsSet(KeyChainMaxFutureBlockTime) {
+		cfg.Chain.MaxFutureBlockTime = v.GetDuration(KeyChainMaxFutureBlockTime)
+	}
+	if v.IsSet(KeyChainMaxBlockSize) {
+		cfg.Chain.MaxBlockSize = v.GetUint(KeyChainMaxBlockSize)
+	}
+	if v.IsSet(KeyChainMaxTxsPerBlock) {
+		cfg.Chain.MaxTxsPerBlock = v.GetUint(KeyChainMaxTxsPerBlock)
+	}
+	if v.IsSet(KeyChainMaxTxInputs) {
+		cfg.Chain.MaxTxInputs = v.GetUint(KeyChainMaxTxInputs)
+	}
+	if v.IsSet(KeyChainMaxTxOutputs) {
+		cfg.Chain.MaxTxOutputs = v.GetUint(KeyChainMaxTxOutputs)
+	}
+	if v.IsSet(KeyChainMaxTxSize) {
+		cfg.Chain.MaxTxSize = v.GetUint(KeyChainMaxTxSize)
+	}
+	if v.IsSet(KeyChainMaxDataCarrierSize) {
+		cfg.Chain.MaxDataCarrierSize = v.GetUint(KeyChainMaxDataCarrierSize)
 	}
 }
 
@@ -405,12 +509,25 @@ func AddConfigFlags(cmd *cobra.Command) { //nolint:funlen // function is long bu
 	cmd.Flags().String(KeyConfigFile, DefaultConfigFile, "config file (default is $PWD/config.yaml)")
 	cmd.Flags().StringArray(KeyNodeSeeds, []string{}, "Node seeds used to synchronize during startup")
 	cmd.Flags().String(KeyStorageFile, DefaultChainnetStorage, "Storage file name")
+	cmd.Flags().String(KeyMempoolFile, DefaultChainnetMempool, "File used for persisting the mempool across restarts (empty disables it)")
 
 	cmd.Flags().String(KeyMiningPubKeyReward, "", "Public key used for receiving mining rewards")
 	cmd.Flags().Duration(KeyMiningInterval, DefaultMiningInterval, "Mining interval in seconds")
 	cmd.Flags().Uint(KeyMiningIntervalAdjustment, DefaultMiningIntervalAdjustment, "Number of blocks for adjusting difficulty")
 
-	cmd.Flags().Uint(KeyChainMaxTxsMempool, DefaultMaxTxsMempool, "Maximum number of transactions in the mempool")
+	cmd.Flags().Uint(KeyChainMaxMempoolSize, DefaultMaxMempoolSize, "Maximum size in bytes of the transactions held in the mempool")
+	cmd.Flags().Uint(KeyChainMinRelayFeeRate, DefaultMinRelayFeeRate, "Minimum fee rate in channoshis per byte required to accept transactions into the mempool")
+	cmd.Flags().Duration(KeyChainMempoolMaxAge, DefaultMempoolMaxAge, "Maximum time a transaction can stay in the mempool before being expired (0 disables it)")
+	cmd.Flags().Uint(KeyChainMaxReorgDepth, DefaultMaxReorgDepth, "Maximum number of blocks that can be disconnected during a chain reorganization")
+	cmd.Flags().Duration(KeyChainSyncInterval, DefaultSyncInterval, "Interval between periodic synchronizations with connected peers")
+	cmd.Flags().Uint(KeyChainCoinbaseMaturity, DefaultCoinbaseMaturity, "Number of blocks required before coinbase outputs can be spent")
+	cmd.Flags().Duration(KeyChainMaxFutureBlockTime, DefaultMaxFutureBlockTime, "Maximum time a block timestamp can be ahead of the local clock")
+	cmd.Flags().Uint(KeyChainMaxBlockSize, DefaultMaxBlockSize, "Maximum size in bytes of a block")
+	cmd.Flags().Uint(KeyChainMaxTxsPerBlock, DefaultMaxTxsPerBlock, "Maximum number of transactions in a block, including the coinbase")
+	cmd.Flags().Uint(KeyChainMaxTxInputs, DefaultMaxTxInputs, "Maximum number of inputs in a transaction")
+	cmd.Flags().Uint(KeyChainMaxTxOutputs, DefaultMaxTxOutputs, "Maximum number of outputs in a transaction")
+	cmd.Flags().Uint(KeyChainMaxTxSize, DefaultMaxTxSize, "Maximum size in bytes of a transaction")
+	cmd.Flags().Uint(KeyChainMaxDataCarrierSize, DefaultMaxDataCarrierSize, "Maximum size in bytes of the data contained in OP_RETURN outputs")
 
 	cmd.Flags().Bool(KeyPrometheusEnabled, DefaultPrometheusEnabled, "Enable Prometheus metrics endpoint")
 	cmd.Flags().Uint(KeyPrometheusPort, DefaultPrometheusPort, "Port for Prometheus metrics")
@@ -438,12 +555,25 @@ func AddConfigFlags(cmd *cobra.Command) { //nolint:funlen // function is long bu
 	_ = viper.BindPFlag(KeyConfigFile, cmd.Flags().Lookup(KeyConfigFile))
 	_ = viper.BindPFlag(KeyNodeSeeds, cmd.Flags().Lookup(KeyNodeSeeds))
 	_ = viper.BindPFlag(KeyStorageFile, cmd.Flags().Lookup(KeyStorageFile))
+	_ = viper.BindPFlag(KeyMempoolFile, cmd.Flags().Lookup(KeyMempoolFile))
 
 	_ = viper.BindPFlag(KeyMiningPubKeyReward, cmd.Flags().Lookup(KeyMiningPubKeyReward))
 	_ = viper.BindPFlag(KeyMiningInterval, cmd.Flags().Lookup(KeyMiningInterval))
 	_ = viper.BindPFlag(KeyMiningIntervalAdjustment, cmd.Flags().Lookup(KeyMiningIntervalAdjustment))
 
-	_ = viper.BindPFlag(KeyChainMaxTxsMempool, cmd.Flags().Lookup(KeyChainMaxTxsMempool))
+	_ = viper.BindPFlag(KeyChainMaxMempoolSize, cmd.Flags().Lookup(KeyChainMaxMempoolSize))
+	_ = viper.BindPFlag(KeyChainMinRelayFeeRate, cmd.Flags().Lookup(KeyChainMinRelayFeeRate))
+	_ = viper.BindPFlag(KeyChainMempoolMaxAge, cmd.Flags().Lookup(KeyChainMempoolMaxAge))
+	_ = viper.BindPFlag(KeyChainMaxReorgDepth, cmd.Flags().Lookup(KeyChainMaxReorgDepth))
+	_ = viper.BindPFlag(KeyChainSyncInterval, cmd.Flags().Lookup(KeyChainSyncInterval))
+	_ = viper.BindPFlag(KeyChainCoinbaseMaturity, cmd.Flags().Lookup(KeyChainCoinbaseMaturity))
+	_ = viper.BindPFlag(KeyChainMaxFutureBlockTime, cmd.Flags().Lookup(KeyChainMaxFutureBlockTime))
+	_ = viper.BindPFlag(KeyChainMaxBlockSize, cmd.Flags().Lookup(KeyChainMaxBlockSize))
+	_ = viper.BindPFlag(KeyChainMaxTxsPerBlock, cmd.Flags().Lookup(KeyChainMaxTxsPerBlock))
+	_ = viper.BindPFlag(KeyChainMaxTxInputs, cmd.Flags().Lookup(KeyChainMaxTxInputs))
+	_ = viper.BindPFlag(KeyChainMaxTxOutputs, cmd.Flags().Lookup(KeyChainMaxTxOutputs))
+	_ = viper.BindPFlag(KeyChainMaxTxSize, cmd.Flags().Lookup(KeyChainMaxTxSize))
+	_ = viper.BindPFlag(KeyChainMaxDataCarrierSize, cmd.Flags().Lookup(KeyChainMaxDataCarrierSize))
 
 	_ = viper.BindPFlag(KeyPrometheusEnabled, cmd.Flags().Lookup(KeyPrometheusEnabled))
 	_ = viper.BindPFlag(KeyPrometheusPort, cmd.Flags().Lookup(KeyPrometheusPort))
@@ -489,8 +619,44 @@ func applyMiningFlagsToConfig(cmd *cobra.Command, cfg *Config) {
 }
 
 func applyChainFlagsToConfig(cmd *cobra.Command, cfg *Config) {
-	if cmd.Flags().Changed(KeyChainMaxTxsMempool) {
-		cfg.Chain.MaxTxsMempool = viper.GetUint(KeyChainMaxTxsMempool)
+	if cmd.Flags().Changed(KeyChainMaxMempoolSize) {
+		cfg.Chain.MaxMempoolSize = viper.GetUint(KeyChainMaxMempoolSize)
+	}
+	if cmd.Flags().Changed(KeyChainMinRelayFeeRate) {
+		cfg.Chain.MinRelayFeeRate = viper.GetUint(KeyChainMinRelayFeeRate)
+	}
+	if cmd.Flags().Changed(KeyChainMempoolMaxAge) {
+		cfg.Chain.MempoolMaxAge = viper.GetDuration(KeyChainMempoolMaxAge)
+	}
+	if cmd.Flags().Changed(KeyChainMaxReorgDepth) {
+		cfg.Chain.MaxReorgDepth = viper.GetUint(KeyChainMaxReorgDepth)
+	}
+	if cmd.Flags().Changed(KeyChainSyncInterval) {
+		cfg.Chain.SyncInterval = viper.GetDuration(KeyChainSyncInterval)
+	}
+	if cmd.Flags().Changed(KeyChainCoinbaseMaturity) {
+		cfg.Chain.CoinbaseMaturity = viper.GetUint(KeyChainCoinbaseMaturity)
+	}
+	if cmd.Flags().Changed(KeyChainMaxFutureBlockTime) {
+		cfg.Chain.MaxFutureBlockTime = viper.GetDuration(KeyChainMaxFutureBlockTime)
+	}
+	if cmd.Flags().Changed(KeyChainMaxBlockSize) {
+		cfg.Chain.MaxBlockSize = viper.GetUint(KeyChainMaxBlockSize)
+	}
+	if cmd.Flags().Changed(KeyChainMaxTxsPerBlock) {
+		cfg.Chain.MaxTxsPerBlock = viper.GetUint(KeyChainMaxTxsPerBlock)
+	}
+	if cmd.Flags().Changed(KeyChainMaxTxInputs) {
+		cfg.Chain.MaxTxInputs = viper.GetUint(KeyChainMaxTxInputs)
+	}
+	if cmd.Flags().Changed(KeyChainMaxTxOutputs) {
+		cfg.Chain.MaxTxOutputs = viper.GetUint(KeyChainMaxTxOutputs)
+	}
+	if cmd.Flags().Changed(KeyChainMaxTxSize) {
+		cfg.Chain.MaxTxSize = viper.GetUint(KeyChainMaxTxSize)
+	}
+	if cmd.Flags().Changed(KeyChainMaxDataCarrierSize) {
+		cfg.Chain.MaxDataCarrierSize = viper.GetUint(KeyChainMaxDataCarrierSize)
 	}
 }
 
@@ -581,6 +747,9 @@ func ApplyFlagsToConfig(cmd *cobra.Command, cfg *Config) {
 	if cmd.Flags().Changed(KeyStorageFile) {
 		cfg.StorageFile = viper.GetString(KeyStorageFile)
 	}
+	if cmd.Flags().Changed(KeyMempoolFile) {
+		cfg.MempoolFile = viper.GetString(KeyMempoolFile)
+	}
 }
 
 // parseSeedNodes parses seed nodes from a slice of strings and returns a slice of SeedNode structs
@@ -146,3 +146,7 @@ require (
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	lukechampine.com/blake3 v1.4.1 // indirect
 )
+
+// the SDK is generated from api/openapi.yaml and sdk/go (make sdk-go), so the node and the wallets always share the
+// same transaction format (version, lock time and sequences) even before a new SDK version is published
+replace github.com/yago-123/chainnet-sdk-go => ./sdk/go/build
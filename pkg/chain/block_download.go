@@ -0,0 +1,131 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/yago-123/chainnet/pkg/kernel"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// BlockDownloadWindow is the max number of blocks downloaded but not applied yet during a sync. Bounds both the
+	// number of concurrent requests and the number of blocks kept in memory waiting for the previous ones
+	BlockDownloadWindow = 16
+)
+
+// fetchBlockFunc retrieves the block that corresponds to the hash from a specific peer
+type fetchBlockFunc func(ctx context.Context, peerID peer.ID, hash []byte) (*kernel.Block, error)
+
+// applyBlockFunc adds a downloaded block to the chain
+type applyBlockFunc func(block *kernel.Block) error
+
+// blockDownloader downloads blocks from multiple peers in parallel and applies them in order. At most window blocks
+// are in flight at the same time (being downloaded or waiting for the previous blocks to be applied). If a peer fails
+// to deliver a block, the block is requested to the next peer in the list
+type blockDownloader struct {
+	peers  []peer.ID
+	window int
+
+	fetch fetchBlockFunc
+	apply applyBlockFunc
+}
+
+type downloadResult struct {
+	block *kernel.Block
+	err   error
+}
+
+func newBlockDownloader(peers []peer.ID, window int, fetch fetchBlockFunc, apply applyBlockFunc) *blockDownloader {
+	return &blockDownloader{
+		peers:  peers,
+		window: max(window, 1),
+		fetch:  fetch,
+		apply:  apply,
+	}
+}
+
+// download retrieves the blocks that correspond to the hashes provided and applies them in the same order. Returns as
+// soon as a block can't be retrieved from any peer or can't be applied, the blocks applied before remain applied
+func (d *blockDownloader) download(ctx context.Context, hashes [][]byte) error {
+	if len(d.peers) == 0 {
+		return errors.New("no peers available for downloading blocks")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// each block has its own channel so results can be consumed in order regardless of arrival order. The channels
+	// are buffered so the fetchers never block if the consumer returns early
+	results := make([]chan downloadResult, len(hashes))
+	for i := range results {
+		results[i] = make(chan downloadResult, 1)
+	}
+
+	// slots are acquired before starting a download and released once the block has been applied
+	slots := make(chan struct{}, d.window)
+
+	go func() {
+		for i, hash := range hashes {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			go func() {
+				block, err := d.fetchFromAnyPeer(ctx, i, hash)
+				results[i] <- downloadResult{block: block, err: err}
+			}()
+		}
+	}()
+
+	for i, hash := range hashes {
+		var res downloadResult
+		select {
+		case res = <-results[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if res.err != nil {
+			return res.err
+		}
+
+		if err := d.apply(res.block); err != nil {
+			return fmt.Errorf("error adding block %x to the chain: %w", hash, err)
+		}
+
+		<-slots
+	}
+
+	return nil
+}
+
+// fetchFromAnyPeer tries to retrieve the block from each peer until one of them succeeds. The first peer tried
+// depends on the index of the block so that the load is spread among all peers
+func (d *blockDownloader) fetchFromAnyPeer(ctx context.Context, index int, hash []byte) (*kernel.Block, error) {
+	var err error
+	for attempt := range len(d.peers) {
+		peerID := d.peers[(index+attempt)%len(d.peers)]
+
+		var block *kernel.Block
+		block, err = d.fetch(ctx, peerID, hash)
+		if err == nil && !bytes.Equal(block.Hash, hash) {
+			err = fmt.Errorf("peer %s returned block %x instead", peerID, block.Hash)
+		}
+
+		if err == nil {
+			return block, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+
+	return nil, fmt.Errorf("unable to retrieve block %x from %d peers: %w", hash, len(d.peers), err)
+}
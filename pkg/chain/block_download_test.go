@@ -0,0 +1,132 @@
+package blockchain //nolint:testpackage // don't create separate package for tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yago-123/chainnet/pkg/kernel"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDownloadHashes(num int) [][]byte {
+	hashes := make([][]byte, num)
+	for i := range num {
+		hashes[i] = []byte(fmt.Sprintf("block-%d", i))
+	}
+
+	return hashes
+}
+
+func TestBlockDownloader_AppliesBlocksInOrder(t *testing.T) {
+	hashes := newDownloadHashes(20)
+	peers := []peer.ID{"peer-1", "peer-2", "peer-3"}
+
+	index := map[string]int{}
+	for i, hash := range hashes {
+		index[string(hash)] = i
+	}
+
+	var inFlight, maxInFlight atomic.Int32
+	fetch := func(_ context.Context, _ peer.ID, hash []byte) (*kernel.Block, error) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			prev := maxInFlight.Load()
+			if current <= prev || maxInFlight.CompareAndSwap(prev, current) {
+				break
+			}
+		}
+
+		// blocks with smaller index take longer so they arrive out of order
+		time.Sleep(time.Duration(len(hashes)-index[string(hash)]) * time.Millisecond)
+		return &kernel.Block{Hash: hash}, nil
+	}
+
+	var mu sync.Mutex
+	applied := [][]byte{}
+	apply := func(block *kernel.Block) error {
+		mu.Lock()
+		defer mu.Unlock()
+		applied = append(applied, block.Hash)
+		return nil
+	}
+
+	require.NoError(t, newBlockDownloader(peers, 4, fetch, apply).download(context.Background(), hashes))
+	assert.Equal(t, hashes, applied)
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(4))
+}
+
+func TestBlockDownloader_RetriesWithOtherPeer(t *testing.T) {
+	hashes := newDownloadHashes(10)
+	peers := []peer.ID{"peer-ok", "peer-failing", "peer-wrong-block"}
+
+	fetch := func(_ context.Context, peerID peer.ID, hash []byte) (*kernel.Block, error) {
+		switch peerID {
+		case "peer-failing":
+			return nil, errors.New("connection reset")
+		case "peer-wrong-block":
+			return &kernel.Block{Hash: []byte("another-block")}, nil
+		default:
+			return &kernel.Block{Hash: hash}, nil
+		}
+	}
+
+	applied := [][]byte{}
+	apply := func(block *kernel.Block) error {
+		applied = append(applied, block.Hash)
+		return nil
+	}
+
+	require.NoError(t, newBlockDownloader(peers, 3, fetch, apply).download(context.Background(), hashes))
+	assert.Equal(t, hashes, applied)
+}
+
+func TestBlockDownloader_Errors(t *testing.T) {
+	hashes := newDownloadHashes(10)
+
+	// fails if no peer is able to deliver a block
+	fetch := func(_ context.Context, _ peer.ID, hash []byte) (*kernel.Block, error) {
+		if string(hash) == "block-5" {
+			return nil, errors.New("block not found")
+		}
+		return &kernel.Block{Hash: hash}, nil
+	}
+
+	applied := 0
+	apply := func(_ *kernel.Block) error {
+		applied++
+		return nil
+	}
+
+	peers := []peer.ID{"peer-1", "peer-2"}
+	require.Error(t, newBlockDownloader(peers, 3, fetch, apply).download(context.Background(), hashes))
+	assert.Equal(t, 5, applied)
+
+	// stops as soon as a block can't be applied
+	applied = 0
+	failingApply := func(block *kernel.Block) error {
+		if string(block.Hash) == "block-2" {
+			return errors.New("invalid block")
+		}
+		applied++
+		return nil
+	}
+
+	okFetch := func(_ context.Context, _ peer.ID, hash []byte) (*kernel.Block, error) {
+		return &kernel.Block{Hash: hash}, nil
+	}
+	require.Error(t, newBlockDownloader(peers, 3, okFetch, failingApply).download(context.Background(), hashes))
+	assert.Equal(t, 2, applied)
+
+	// fails without peers
+	require.Error(t, newBlockDownloader([]peer.ID{}, 3, okFetch, apply).download(context.Background(), hashes))
+}
@@ -1,10 +1,14 @@
 package blockchain
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"math/big"
 	"sort"
+	"sync"
+	"time"
 
 	"github.com/yago-123/chainnet/pkg/common"
 
@@ -34,14 +38,32 @@ import (
 const (
 	BlockchainObserver = "blockchain"
 	MaxConcurrentSyncs = 1
+	// MaxConcurrentTipRequests is the max number of peers asked for their last header at the same time
+	MaxConcurrentTipRequests = 10
+	// LocatorDenseHashes is the number of most recent blocks added one by one to the locator, older blocks are added
+	// with exponentially bigger steps
+	LocatorDenseHashes = 10
+	// MempoolExpiryInterval is how often the mempool is checked for transactions that exceeded the maximum age, in
+	// addition to the checks done each time a block is added
+	MempoolExpiryInterval = time.Hour
 )
 
 type Blockchain struct {
 	lastBlockHash []byte
 	lastHeight    uint
-	headers       map[string]kernel.BlockHeader
+	// headers contains the headers of the main chain only
+	headers map[string]kernel.BlockHeader
+	// sideBlocks contains the blocks that belong to side branches (not part of the main chain). These blocks are
+	// kept in case the branch they belong to accumulates more work than the main chain
+	sideBlocks map[string]*kernel.Block
+	// chainWork contains the cumulative work of each known block (main chain and side branches), the branch with
+	// the highest cumulative work is the one considered as main chain
+	chainWork map[string]*big.Int
 	// blockTxsBloomFilter map[string]string
 
+	// mu protects the chain state (tip, headers and side branches) while adding blocks or reorganizing
+	mu sync.Mutex
+
 	// todo() may be smarter to have the target as a field of the blockchain (saving the previous header interval
 	// todo() too), but generalSync must be implemented before that to ensure consistency
 
@@ -55,6 +77,8 @@ type Blockchain struct {
 	utxoSet *utxoset.UTXOSet
 
 	validator consensus.HeavyValidator
+	// lightValidator is used for validating remote header chains before downloading the blocks
+	lightValidator consensus.LightValidator
 
 	blockSubject observer.ChainSubject
 
@@ -75,6 +99,7 @@ func NewBlockchain(
 	utxoSet *utxoset.UTXOSet,
 	hasher hash.Hashing,
 	validator consensus.HeavyValidator,
+	lightValidator consensus.LightValidator,
 	subject observer.ChainSubject,
 	p2pEncoder encoding.Encoding,
 ) (*Blockchain, error) {
@@ -83,6 +108,7 @@ func NewBlockchain(
 	var lastBlockHash []byte
 
 	headers := make(map[string]kernel.BlockHeader)
+	chainWork := make(map[string]*big.Int)
 
 	// retrieve the last header stored
 	lastHeader, err := store.GetLastHeader()
@@ -111,26 +137,29 @@ func NewBlockchain(
 		cfg.Logger.Debugf("recovering chain with last hash: %x", lastBlockHash)
 
 		// reload the headers into memory
-		if err = reconstructState(store, utxoSet, headers, lastBlockHash); err != nil {
+		if err = reconstructState(store, utxoSet, headers, chainWork, lastBlockHash); err != nil {
 			return nil, fmt.Errorf("error reconstructing chain state: %w", err)
 		}
 	}
 
 	return &Blockchain{
-		lastBlockHash: lastBlockHash,
-		lastHeight:    lastHeight,
-		headers:       headers,
-		syncMutex:     mutex.NewCtxMutex(MaxConcurrentSyncs),
-		hasher:        hasher,
-		mempool:       mempool,
-		utxoSet:       utxoSet,
-		store:         store,
-		validator:     validator,
-		blockSubject:  subject,
-		p2pActive:     false,
-		p2pEncoder:    p2pEncoder,
-		logger:        cfg.Logger,
-		cfg:           cfg,
+		lastBlockHash:  lastBlockHash,
+		lastHeight:     lastHeight,
+		headers:        headers,
+		sideBlocks:     make(map[string]*kernel.Block),
+		chainWork:      chainWork,
+		syncMutex:      mutex.NewCtxMutex(MaxConcurrentSyncs),
+		hasher:         hasher,
+		mempool:        mempool,
+		utxoSet:        utxoSet,
+		store:          store,
+		validator:      validator,
+		lightValidator: lightValidator,
+		blockSubject:   subject,
+		p2pActive:      false,
+		p2pEncoder:     p2pEncoder,
+		logger:         cfg.Logger,
+		cfg:            cfg,
 	}, nil
 }
 
@@ -176,11 +205,65 @@ func (bc *Blockchain) InitNetwork(netSubject observer.NetSubject) (*network.Node
 	bc.p2pNet = p2pNet
 	bc.p2pActive = true
 
+	// keep the chain in sync with the connected peers periodically
+	go bc.runGeneralSync(p2pCtx)
+
+	// expire old mempool transactions even if no blocks are added for a while
+	go bc.runMempoolExpiry(p2pCtx, MempoolExpiryInterval)
+
 	return p2pNet, nil
 }
 
-// AddBlock adds a new block to the blockchain. The block is validated before being added to the chain
+// AddBlock adds a new block to the blockchain. Blocks extending the current tip are validated and added to the main
+// chain right away. Blocks extending any other known block are kept as part of a side branch, in case the side branch
+// accumulates more work than the main chain the chain is reorganized to follow it
 func (bc *Blockchain) AddBlock(block *kernel.Block) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if bc.isKnownBlock(block.Hash) {
+		return fmt.Errorf("block %x already present in the chain", block.Hash)
+	}
+
+	// the block extends the main chain, validate and connect it directly
+	if bytes.Equal(block.Header.PrevBlockHash, bc.lastBlockHash) {
+		if err := bc.connectBlock(block); err != nil {
+			return err
+		}
+
+		bc.pruneSideBlocks()
+		return nil
+	}
+
+	// the block forks from the main chain or extends a side branch
+	parent, ok := bc.retrieveKnownHeader(block.Header.PrevBlockHash)
+	if !ok {
+		return fmt.Errorf("block %x points to unknown previous block %x", block.Hash, block.Header.PrevBlockHash)
+	}
+
+	// reject forks that would require disconnecting more blocks than allowed
+	if parent.Height+bc.cfg.Chain.MaxReorgDepth+1 < bc.lastHeight {
+		return fmt.Errorf("block %x forks too deep from the main chain (height %d)", block.Hash, block.Header.Height)
+	}
+
+	if err := bc.validateSideBlock(block, parent); err != nil {
+		return fmt.Errorf("side block validation failed: %w", err)
+	}
+
+	bc.sideBlocks[string(block.Hash)] = block
+	bc.chainWork[string(block.Hash)] = bc.calculateChainWork(block.Header)
+
+	// only switch to the side branch if contains more work than the main chain
+	if bc.chainWork[string(block.Hash)].Cmp(bc.chainWork[string(bc.lastBlockHash)]) <= 0 {
+		bc.logger.Debugf("added block %x with height %d to side branch", block.Hash, block.Header.Height)
+		return nil
+	}
+
+	return bc.reorganize(block)
+}
+
+// connectBlock validates the block and adds it on top of the current tip
+func (bc *Blockchain) connectBlock(block *kernel.Block) error {
 	if err := bc.validator.ValidateBlock(block); err != nil {
 		return fmt.Errorf("block validation failed: %w", err)
 	}
@@ -198,15 +281,265 @@ func (bc *Blockchain) AddBlock(block *kernel.Block) error {
 	bc.lastHeight++
 	bc.lastBlockHash = block.Hash
 	bc.headers[string(block.Hash)] = *block.Header
+	bc.chainWork[string(block.Hash)] = bc.calculateChainWork(block.Header)
+	delete(bc.sideBlocks, string(block.Hash))
 
 	// notify observers of a new block added
 	bc.blockSubject.NotifyBlockAdded(block)
 
+	bc.expireMempoolTxs()
+
+	return nil
+}
+
+// disconnectTip removes the current tip from the main chain and keeps it as part of a side branch. The UTXO set and
+// the storage are reverted before notifying the observers, so any failure aborts the disconnection
+func (bc *Blockchain) disconnectTip() (*kernel.Block, error) {
+	block, err := bc.store.RetrieveBlockByHash(bc.lastBlockHash)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving block %x: %w", bc.lastBlockHash, err)
+	}
+
+	if _, ok := bc.headers[string(block.Header.PrevBlockHash)]; !ok {
+		return nil, fmt.Errorf("unable to disconnect block %x, previous block not found", block.Hash)
+	}
+
+	if err = bc.utxoSet.RemoveBlock(block); err != nil {
+		return nil, fmt.Errorf("error removing block %x from UTXO set: %w", block.Hash, err)
+	}
+
+	if err = bc.store.RemoveLastBlock(*block); err != nil {
+		// the block remains part of the chain, so the UTXO set must keep reflecting it
+		if errUTXO := bc.utxoSet.AddBlock(block); errUTXO != nil {
+			bc.logger.Errorf("error adding back block %x to UTXO set: %s", block.Hash, errUTXO)
+		}
+
+		return nil, fmt.Errorf("error removing block %x from storage: %w", block.Hash, err)
+	}
+
+	bc.logger.Debugf("disconnected from the chain block %x with height %d", block.Hash, block.Header.Height)
+
+	bc.lastHeight--
+	bc.lastBlockHash = block.Header.PrevBlockHash
+	delete(bc.headers, string(block.Hash))
+	bc.sideBlocks[string(block.Hash)] = block
+
+	// notify observers so the mempool, network... can react to the block removed
+	bc.blockSubject.NotifyBlockRemoved(block)
+
+	return block, nil
+}
+
+// reorganize switches the main chain to the side branch that ends with newTip. The blocks of the main chain are
+// disconnected until reaching the fork point and the blocks of the side branch are connected (validated) one by one.
+// If any of the side blocks turns out to be invalid, the side branch is discarded and the original chain restored
+func (bc *Blockchain) reorganize(newTip *kernel.Block) error {
+	// collect the blocks of the side branch, from the fork point to the new tip
+	branch := []*kernel.Block{}
+	forkHash := newTip.Hash
+	for {
+		block, ok := bc.sideBlocks[string(forkHash)]
+		if !ok {
+			break
+		}
+
+		branch = append([]*kernel.Block{block}, branch...)
+		forkHash = block.Header.PrevBlockHash
+	}
+
+	forkHeader, ok := bc.headers[string(forkHash)]
+	if !ok {
+		return fmt.Errorf("side branch of block %x does not connect with the main chain", newTip.Hash)
+	}
+
+	if bc.lastHeight-1-forkHeader.Height > bc.cfg.Chain.MaxReorgDepth {
+		return fmt.Errorf("reorganization from height %d exceeds max depth %d", forkHeader.Height, bc.cfg.Chain.MaxReorgDepth)
+	}
+
+	bc.logger.Infof("reorganizing chain from block %x to %x (fork at height %d)", bc.lastBlockHash, newTip.Hash, forkHeader.Height)
+
+	// keep a copy of the mempool so it can be restored if the reorganization fails
+	mempoolTxs := bc.mempool.Snapshot()
+
+	disconnected, err := bc.disconnectUntil(forkHash)
+	if err != nil {
+		// go back to the original chain, connecting again the blocks disconnected so far
+		if errRestore := bc.restoreChain(bc.lastBlockHash, disconnected); errRestore != nil {
+			return fmt.Errorf("error restoring chain after failed reorganization (%w): %w", err, errRestore)
+		}
+
+		bc.restoreMempoolTxs(mempoolTxs)
+
+		return fmt.Errorf("error disconnecting blocks from main chain: %w", err)
+	}
+
+	for i, block := range branch {
+		if err = bc.connectBlock(block); err != nil {
+			// the side branch is not valid, discard the remaining blocks and go back to the original chain
+			for _, invalid := range branch[i:] {
+				delete(bc.sideBlocks, string(invalid.Hash))
+				delete(bc.chainWork, string(invalid.Hash))
+			}
+
+			if errRestore := bc.restoreChain(forkHash, disconnected); errRestore != nil {
+				return fmt.Errorf("error restoring chain after failed reorganization (%w): %w", err, errRestore)
+			}
+
+			bc.restoreMempoolTxs(mempoolTxs)
+
+			return fmt.Errorf("error connecting block %x from side branch: %w", block.Hash, err)
+		}
+	}
+
+	bc.pruneSideBlocks()
+
+	return nil
+}
+
+// disconnectUntil disconnects blocks from the tip until the block with the hash provided becomes the tip. Returns the
+// blocks disconnected, starting with the old tip
+func (bc *Blockchain) disconnectUntil(hash []byte) ([]*kernel.Block, error) {
+	disconnected := []*kernel.Block{}
+	for !bytes.Equal(bc.lastBlockHash, hash) {
+		block, err := bc.disconnectTip()
+		if err != nil {
+			return disconnected, err
+		}
+
+		disconnected = append(disconnected, block)
+	}
+
+	return disconnected, nil
+}
+
+// restoreChain reverts a failed reorganization, disconnects the blocks added on top of the fork point and connects
+// again the blocks that were part of the main chain
+func (bc *Blockchain) restoreChain(forkHash []byte, disconnected []*kernel.Block) error {
+	if _, err := bc.disconnectUntil(forkHash); err != nil {
+		return err
+	}
+
+	for i := len(disconnected) - 1; i >= 0; i-- {
+		if err := bc.connectBlock(disconnected[i]); err != nil {
+			return fmt.Errorf("error connecting back block %x: %w", disconnected[i].Hash, err)
+		}
+	}
+
 	return nil
 }
 
+// validateSideBlock performs the checks that do not depend on the chain tip over a block that belongs to a side
+// branch. The complete validation is performed once (and if) the side branch becomes the main chain, given that
+// the validator checks blocks against the current tip
+func (bc *Blockchain) validateSideBlock(block *kernel.Block, parent *kernel.BlockHeader) error {
+	if block.Header.Height != parent.Height+1 {
+		return fmt.Errorf("block %x has height %d but previous block has height %d", block.Hash, block.Header.Height, parent.Height)
+	}
+
+	if err := util.VerifyBlockHash(block.Header, block.Hash, bc.hasher); err != nil {
+		return fmt.Errorf("block %x hash verification failed: %w", block.Hash, err)
+	}
+
+	// the work of the branch is computed from the targets, make sure that the work has been done
+	if !util.IsProofOfWorkValid(block.Hash, block.Header) {
+		return fmt.Errorf("block %x does not match target %d", block.Hash, block.Header.Target)
+	}
+
+	return nil
+}
+
+// pruneSideBlocks drops the side branch blocks that are too deep to trigger a reorganization
+func (bc *Blockchain) pruneSideBlocks() {
+	for hash, block := range bc.sideBlocks {
+		if block.Header.Height+bc.cfg.Chain.MaxReorgDepth < bc.lastHeight {
+			delete(bc.sideBlocks, hash)
+			delete(bc.chainWork, hash)
+		}
+	}
+}
+
+// calculateChainWork returns the cumulative work of the chain ending with the header provided
+func (bc *Blockchain) calculateChainWork(header *kernel.BlockHeader) *big.Int {
+	work := util.CalculateBlockWork(header)
+	if prevWork, ok := bc.chainWork[string(header.PrevBlockHash)]; ok {
+		work.Add(work, prevWork)
+	}
+
+	return work
+}
+
+// calculateRemoteChainWork returns the cumulative work of a remote branch (sorted by height) that extends a block known
+// locally (or starts with the genesis block)
+func (bc *Blockchain) calculateRemoteChainWork(headers []*kernel.BlockHeader) *big.Int {
+	work := big.NewInt(0)
+	if len(headers) > 0 {
+		if prevWork, ok := bc.knownChainWork(headers[0].PrevBlockHash); ok {
+			work.Set(prevWork)
+		}
+	}
+
+	for _, header := range headers {
+		work.Add(work, util.CalculateBlockWork(header))
+	}
+
+	return work
+}
+
+// knownChainWork returns the cumulative work of a block that is either part of the main chain or of a side branch
+func (bc *Blockchain) knownChainWork(hash []byte) (*big.Int, bool) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	work, ok := bc.chainWork[string(hash)]
+	return work, ok
+}
+
+// localChainWork returns the cumulative work of the main chain
+func (bc *Blockchain) localChainWork() *big.Int {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if work, ok := bc.chainWork[string(bc.lastBlockHash)]; ok {
+		return work
+	}
+
+	return big.NewInt(0)
+}
+
+// retrieveKnownHeader returns the header of a block that is either part of the main chain or of a side branch
+func (bc *Blockchain) retrieveKnownHeader(hash []byte) (*kernel.BlockHeader, bool) {
+	if header, ok := bc.headers[string(hash)]; ok {
+		return &header, true
+	}
+
+	if block, ok := bc.sideBlocks[string(hash)]; ok {
+		return block.Header, true
+	}
+
+	return nil, false
+}
+
+// isKnownBlock checks whether the block is part of the main chain or of a side branch
+func (bc *Blockchain) isKnownBlock(hash []byte) bool {
+	_, ok := bc.retrieveKnownHeader(hash)
+	return ok
+}
+
+// ContainsBlock checks whether the block is already known by the chain (main chain or side branches)
+func (bc *Blockchain) ContainsBlock(hash []byte) bool {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	return bc.isKnownBlock(hash)
+}
+
 // AddTransaction adds a new transaction to the mempool. The transaction is validated before being added to the mempool
 func (bc *Blockchain) AddTransaction(tx *kernel.Transaction) error {
+	return bc.addTransaction(tx, time.Now())
+}
+
+// addTransaction validates and adds the transaction to the mempool, recording addedAt as the time it entered it
+func (bc *Blockchain) addTransaction(tx *kernel.Transaction, addedAt time.Time) error {
 	// make sure that the tx uses proper UTXOs and contains valid signatures
 	if err := bc.validator.ValidateTx(tx); err != nil {
 		return fmt.Errorf("error validating transaction %x: %w", tx.ID, err)
@@ -219,7 +552,7 @@ func (bc *Blockchain) AddTransaction(tx *kernel.Transaction) error {
 	}
 
 	// append the transaction to the mempool
-	if errMempool := bc.mempool.AppendTransaction(tx, fee); errMempool != nil {
+	if errMempool := bc.mempool.AppendTransactionAt(tx, fee, addedAt); errMempool != nil {
 		return fmt.Errorf("error appending transaction %x to mempool: %w", tx.ID, errMempool)
 	}
 
@@ -234,65 +567,266 @@ func (bc *Blockchain) AddTransaction(tx *kernel.Transaction) error {
 	return nil
 }
 
-// todo(): implement generalSync
-// func (bc *Blockchain) generalSync() {
-// 	try to sync regularly with other nodes to see if there is some sort of fork (take into account that +6 blocks of
-// 	difference is already considered kind of a fork)
-// 		1. Ask for the latest header to all peers that the chain is connected to
-// 		2. Once all headers are retrieved check if more or less is in sync with the local node (to be developed what that means)
-// 		3. If it's not in sync ask all the peers for the header number N (where N is the lowest header that has been
-//    	   retrieved, but should be bigger than current height)
-//      		3.1 What happens if after doing that the sync still not works? Maybe we should start erasing local headers and
-//        			reduce the local height?
-// 		4. Once all headers with height N arrive, compare them and choose the header with the hash most popular (the one
-//    	   that most nodes have
-// 		5. Once you have the header most popular, ask one of the peers that contained that header for all headers
-// 		6. Once you have all headers, try to start pulling blocks and adding to the local chain until the header height
-//         that was targeted at the step 3.
-// 		7. Repeat the process until is considered that the local node is in sync (TO BE DEVELOPED WHAT IN SYNC MEANS)
-// }
+// LoadMempool restores the transactions persisted in the file provided (see mempool.Persist). Each transaction is
+// validated again against the current UTXO set given that the chain may have changed since it was persisted, the
+// transactions that are no longer valid or that already expired are discarded. An empty path disables the restore
+func (bc *Blockchain) LoadMempool(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	persisted, err := mempool.LoadPersistedTxs(path)
+	if err != nil {
+		return fmt.Errorf("error loading persisted mempool: %w", err)
+	}
+
+	restored := bc.restoreMempoolTxs(persisted)
+	bc.logger.Infof("restored %d out of %d persisted mempool transactions", restored, len(persisted))
+
+	return nil
+}
+
+// restoreMempoolTxs adds back into the mempool transactions that were part of it before, keeping the time they
+// entered it. The transactions are expected to be sorted with ancestors first (see mempool.Snapshot) and are
+// validated again given that the chain may have changed since. Returns the number of transactions restored
+func (bc *Blockchain) restoreMempoolTxs(txs []mempool.PersistedTx) int {
+	restored := 0
+	for _, ptx := range txs {
+		if bc.mempool.ContainsTx(string(ptx.Transaction.ID)) {
+			continue
+		}
+
+		if bc.isTxExpired(ptx.Time) {
+			bc.logger.Infof("transaction %x expired from mempool, entered at %s", ptx.Transaction.ID, ptx.Time)
+			continue
+		}
+
+		if err := bc.addTransaction(ptx.Transaction, ptx.Time); err != nil {
+			bc.logger.Debugf("unable to restore transaction %x to mempool: %s", ptx.Transaction.ID, err)
+			continue
+		}
+
+		restored++
+	}
+
+	return restored
+}
+
+// expireMempoolTxs removes from the mempool the transactions older than the maximum age allowed. A zero maximum age
+// disables the expiration
+func (bc *Blockchain) expireMempoolTxs() {
+	if bc.cfg.Chain.MempoolMaxAge == 0 {
+		return
+	}
+
+	for _, tx := range bc.mempool.ExpireTransactions(time.Now().Add(-bc.cfg.Chain.MempoolMaxAge)) {
+		bc.logger.Infof("transaction %x expired from mempool", tx.ID)
+	}
+}
+
+// runMempoolExpiry runs expireMempoolTxs periodically until the context is canceled
+func (bc *Blockchain) runMempoolExpiry(ctx context.Context, interval time.Duration) {
+	if bc.cfg.Chain.MempoolMaxAge == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			bc.expireMempoolTxs()
+		}
+	}
+}
+
+// isTxExpired checks whether a transaction that entered the mempool at the time provided has exceeded the maximum age
+func (bc *Blockchain) isTxExpired(addedAt time.Time) bool {
+	return bc.cfg.Chain.MempoolMaxAge != 0 && time.Since(addedAt) > bc.cfg.Chain.MempoolMaxAge
+}
+
+// runGeneralSync runs generalSync periodically until the network context is canceled. A zero interval disables it
+func (bc *Blockchain) runGeneralSync(ctx context.Context) {
+	if bc.cfg.Chain.SyncInterval == 0 {
+		bc.logger.Infof("general sync disabled, sync interval not set")
+		return
+	}
+
+	ticker := time.NewTicker(bc.cfg.Chain.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// each round is bounded by the sync interval so that rounds never overlap
+			roundCtx, cancel := context.WithTimeout(ctx, bc.cfg.Chain.SyncInterval)
+			if err := bc.generalSync(roundCtx); err != nil {
+				bc.logger.Errorf("error during general sync: %s", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// generalSync tries to sync regularly with the connected peers, this covers the cases in which the node misses gossip
+// messages or in which the network has forked. Algorithm:
+//  1. Ask for the latest header to all peers that the node is connected to
+//  2. Ignore the tips that are already known locally, shorter branches may still contain more work
+//  3. Choose the tip that most peers agree on (the highest one in case of tie)
+//  4. Sync with one of the peers that reported that tip. The remote headers are compared with the local chain to find
+//     where both chains diverge, and the missing blocks are downloaded and applied (reorganizing the chain if needed)
+//  5. If the sync with the peer fails, try with the next peer that reported the same tip
+//
+// The sync mutex is shared with the syncs triggered by node discovery, if the mutex can't be acquired before the
+// context expires the round is skipped
+func (bc *Blockchain) generalSync(ctx context.Context) error {
+	if !bc.syncMutex.Lock(ctx) {
+		return fmt.Errorf("unable to acquire sync lock: %w", ctx.Err())
+	}
+	defer bc.syncMutex.Unlock()
+
+	tips := bc.askPeerTips(ctx, bc.p2pNet.ConnectedPeers())
+
+	candidates := selectMostPopularTip(bc.filterSyncableTips(tips))
+	if len(candidates) == 0 {
+		bc.logger.Debugf("general sync: local chain in sync with %d peers", len(tips))
+		return nil
+	}
+
+	var err error
+	for _, tip := range candidates {
+		bc.logger.Debugf("general sync: syncing with %s towards block %x (height %d)", tip.peerID, tip.hash, tip.header.Height)
+
+		if err = bc.syncFromHeaders(ctx, tip.peerID); err == nil {
+			return nil
+		}
+
+		bc.logger.Debugf("general sync: error syncing with %s: %s", tip.peerID, err)
+	}
+
+	return fmt.Errorf("unable to sync with any of the %d peers: %w", len(candidates), err)
+}
+
+// peerTip represents the latest header reported by a peer
+type peerTip struct {
+	peerID peer.ID
+	header *kernel.BlockHeader
+	hash   []byte
+}
+
+// askPeerTips asks concurrently the last header to the peers provided. Peers that fail to reply are ignored
+func (bc *Blockchain) askPeerTips(ctx context.Context, peers []peer.ID) []peerTip {
+	var mu sync.Mutex
+	tips := []peerTip{}
+
+	_ = util.ProcessConcurrently(ctx, peers, MaxConcurrentTipRequests, nil, func(ctx context.Context, peerID peer.ID) error {
+		header, err := bc.p2pNet.AskLastHeader(ctx, peerID)
+		if err != nil {
+			bc.logger.Debugf("general sync: unable to retrieve last header from %s: %s", peerID, err)
+			return nil
+		}
+
+		hash, err := util.CalculateBlockHash(header, bc.hasher)
+		if err != nil {
+			bc.logger.Debugf("general sync: unable to calculate hash of header from %s: %s", peerID, err)
+			return nil
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		tips = append(tips, peerTip{peerID: peerID, header: header, hash: hash})
+
+		return nil
+	})
+
+	return tips
+}
+
+// filterSyncableTips keeps the tips that are unknown locally. Tips are not filtered by height given that a shorter chain
+// can contain more work, the work of the remote chain is compared once its headers are retrieved (see syncFromHeaders)
+func (bc *Blockchain) filterSyncableTips(tips []peerTip) []peerTip {
+	syncable := []peerTip{}
+	for _, tip := range tips {
+		if bc.ContainsBlock(tip.hash) {
+			continue
+		}
+
+		syncable = append(syncable, tip)
+	}
+
+	return syncable
+}
+
+// selectMostPopularTip groups the tips by block hash and returns the group reported by the highest number of peers. In
+// case of tie, the group with the highest tip is chosen
+func selectMostPopularTip(tips []peerTip) []peerTip {
+	groups := map[string][]peerTip{}
+	for _, tip := range tips {
+		groups[string(tip.hash)] = append(groups[string(tip.hash)], tip)
+	}
+
+	var selected []peerTip
+	for _, group := range groups {
+		if len(group) > len(selected) ||
+			(len(group) == len(selected) && group[0].header.Height > selected[0].header.Height) {
+			selected = group
+		}
+	}
+
+	return selected
+}
 
 // syncWithPeer function is in charge of handling all the logic related to node synchronization. Simple algorithm:
 //  1. Ask the remote node for the last header
-//  2. If the local height is smaller or equal than the remote HEADER height, try to synchronize via headers
-//  3. If the local height is bigger than the remote HEADER height, there is nothing to synchronize, just return
+//  2. If the remote header is known locally, compare its cumulative work with the local chain. The main chain is the
+//     one with the most work, so there is nothing to synchronize
+//  3. If the remote header is unknown, try to synchronize via headers. The work of the remote chain can only be
+//     calculated once the headers that link it to the local chain are retrieved (done in syncFromHeaders)
 func (bc *Blockchain) syncWithPeer(ctx context.Context, peerID peer.ID) error {
-	localCurrentHeight := bc.GetLastHeight()
-
 	// ask new peer for last header
 	lastHeaderPeer, err := bc.p2pNet.AskLastHeader(ctx, peerID)
 	if err != nil {
 		return fmt.Errorf("error asking for last header: %w", err)
 	}
 
-	// in case current height is smaller or equal than the remote latest header, try to upgrade local node
-	if localCurrentHeight <= lastHeaderPeer.Height {
-		if err = bc.syncFromHeaders(ctx, peerID, localCurrentHeight); err != nil {
-			return fmt.Errorf("error trying to sync with headers from height %d: %w", localCurrentHeight, err)
-		}
+	lastHashPeer, err := util.CalculateBlockHash(lastHeaderPeer, bc.hasher)
+	if err != nil {
+		return fmt.Errorf("error calculating hash of last header: %w", err)
+	}
+
+	if remoteWork, ok := bc.knownChainWork(lastHashPeer); ok && remoteWork.Cmp(bc.localChainWork()) <= 0 {
+		bc.logger.Debugf("local chain work bigger or equal than remote chain work for %s: nothing to sync", peerID.String())
+		return nil
 	}
 
-	if localCurrentHeight > lastHeaderPeer.Height {
-		bc.logger.Debugf("local height bigger or equal than remote height for %s: nothing to sync", peerID.String())
+	if err = bc.syncFromHeaders(ctx, peerID); err != nil {
+		return fmt.Errorf("error trying to sync with headers from height %d: %w", bc.GetLastHeight(), err)
 	}
 
-	// in case current height is bigger than latest remote header height, there is nothing to sync, just return
 	return nil
 }
 
-// syncFromHeaders is in charge of synchronizing the local node with the remote node via headers. First retrieve all the
-// remote headers and ignore those that are already in the local chain. Then retrieve the block instance for each header
-// and try to add it.
+// syncFromHeaders is in charge of synchronizing the local node with the remote node via headers first:
+//  1. Retrieve the remote headers that follow the local chain (using a locator) and find where the remote chain
+//     diverges from the local chain
+//  2. Validate the unknown part of the header chain (linkage, height, light validation and target) before
+//     downloading any block, so that invalid chains are discarded without wasting bandwidth
+//  3. Download the blocks in parallel from the syncing peer and the rest of connected peers within a bounded window,
+//     retrying against another peer if a download fails
+//  4. Apply the blocks in order. Blocks that fork from the local chain are added as side branches and trigger a
+//     reorganization once they accumulate more work than the local chain
 //
 // If there is some problem while adding the block, return the error (most likely the validator have not accepted the block)
-func (bc *Blockchain) syncFromHeaders(ctx context.Context, peerID peer.ID, localCurrentHeight uint) error {
-	var block *kernel.Block
-	var remoteBlockHash []byte
-
-	// retrieve all headers from the remote node
-	remoteHeaders, err := bc.p2pNet.AskAllHeaders(ctx, peerID)
+func (bc *Blockchain) syncFromHeaders(ctx context.Context, peerID peer.ID) error {
+	// retrieve the remote headers that follow the local chain
+	remoteHeaders, err := bc.askRemoteHeaders(ctx, peerID)
 	if err != nil {
-		return fmt.Errorf("error asking for all headers: %w", err)
+		return fmt.Errorf("error asking for headers: %w", err)
 	}
 
 	// sort headers by height
@@ -300,42 +834,259 @@ func (bc *Blockchain) syncFromHeaders(ctx context.Context, peerID peer.ID, local
 		return remoteHeaders[i].Height < remoteHeaders[j].Height
 	})
 
-	// retrieve the block for each header and try to add it to the chain
-	for _, header := range remoteHeaders {
-		// skip those headers that are already in the local chain
-		if localCurrentHeight > header.Height {
-			continue
+	// find where the remote chain diverges from the local chain (first remote header unknown locally)
+	divergence, remoteHashes, err := bc.findDivergence(remoteHeaders)
+	if err != nil {
+		return err
+	}
+
+	if divergence == len(remoteHeaders) {
+		return nil
+	}
+
+	if remoteHeaders[divergence].Height < bc.GetLastHeight() {
+		bc.logger.Debugf("remote chain of %s diverges from local chain at height %d", peerID, remoteHeaders[divergence].Height)
+	}
+
+	// validate the header chain before downloading any block
+	if err = bc.validateHeaderChain(remoteHeaders, remoteHashes, divergence); err != nil {
+		return fmt.Errorf("invalid header chain from %s: %w", peerID, err)
+	}
+
+	// only download the blocks if the remote chain contains more work than the local chain
+	remoteWork := bc.calculateRemoteChainWork(remoteHeaders[divergence:])
+	if remoteWork.Cmp(bc.localChainWork()) <= 0 {
+		bc.logger.Debugf("remote chain of %s does not contain more work than local chain: nothing to sync", peerID)
+		return nil
+	}
+
+	// download the blocks from multiple peers and apply them in order (blocks are validated inside AddBlock)
+	downloader := newBlockDownloader(bc.downloadPeers(peerID), BlockDownloadWindow, bc.fetchBlock, bc.AddBlock)
+	if err = downloader.download(ctx, remoteHashes[divergence:]); err != nil {
+		// todo(): maybe the node should be blamed and black listed?
+		return fmt.Errorf("error downloading blocks: %w", err)
+	}
+
+	return nil
+}
+
+// askRemoteHeaders retrieves the headers of the remote chain that follow the local chain, paging through the results
+// of AskHeaders until the remote node replies less headers than the limit. Peers that don't support AskHeaders yet
+// are asked for all their headers instead
+func (bc *Blockchain) askRemoteHeaders(ctx context.Context, peerID peer.ID) ([]*kernel.BlockHeader, error) {
+	locator := bc.buildLocator()
+	remoteHeaders := []*kernel.BlockHeader{}
+
+	for {
+		headers, err := bc.p2pNet.AskHeaders(ctx, peerID, locator)
+		if err != nil {
+			if len(remoteHeaders) > 0 {
+				return nil, fmt.Errorf("error asking headers after %d headers retrieved: %w", len(remoteHeaders), err)
+			}
+
+			bc.logger.Debugf("unable to ask headers to %s, asking all headers instead: %s", peerID, err)
+			return bc.p2pNet.AskAllHeaders(ctx, peerID)
 		}
 
-		// validate the header compatibility with the local chain (prevent downloading block if is incompatible)
-		if err = bc.validator.ValidateHeader(header); err != nil {
-			return fmt.Errorf("error validating header %s: %w", header.String(), err)
+		remoteHeaders = append(remoteHeaders, headers...)
+		if len(headers) < network.MaxHeadersPerRequest {
+			return remoteHeaders, nil
 		}
 
-		remoteBlockHash, err = util.CalculateBlockHash(header, bc.hasher)
+		// ask for the next page using the last header retrieved as locator
+		lastHash, err := util.CalculateBlockHash(headers[len(headers)-1], bc.hasher)
 		if err != nil {
-			return fmt.Errorf("error calculating block hash from header: %w", err)
+			return nil, fmt.Errorf("error calculating block hash from header: %w", err)
 		}
 
-		// retrieve complete block from peer
-		block, err = bc.p2pNet.AskSpecificBlock(ctx, peerID, remoteBlockHash)
+		locator = [][]byte{lastHash}
+	}
+}
+
+// buildLocator returns a list of hashes of the main chain (from newest to oldest) that allows remote nodes to find the
+// latest block in common with the local chain. Contains the most recent blocks one by one, then takes exponentially
+// bigger steps back and always finishes with the genesis block
+func (bc *Blockchain) buildLocator() [][]byte {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	locator := [][]byte{}
+	step, next := 1, 0
+
+	var genesis []byte
+	hash := bc.lastBlockHash
+	for distance := 0; len(hash) > 0; distance++ {
+		header, ok := bc.headers[string(hash)]
+		if !ok {
+			break
+		}
+
+		// leave room for the genesis block
+		if distance == next && len(locator) < network.MaxLocatorHashes-1 {
+			locator = append(locator, hash)
+			if len(locator) >= LocatorDenseHashes {
+				step *= 2
+			}
+			next += step
+		}
+
+		genesis = hash
+		hash = header.PrevBlockHash
+	}
+
+	if len(genesis) > 0 && !bytes.Equal(locator[len(locator)-1], genesis) {
+		locator = append(locator, genesis)
+	}
+
+	return locator
+}
+
+// validateHeaderChain validates the remote headers (sorted by height) starting from the divergence index. Each header
+// must point to the previous one, pass the light validation and contain the target expected at its height. The
+// first header must be the genesis header (if the local chain is empty) or extend a block known locally
+func (bc *Blockchain) validateHeaderChain(headers []*kernel.BlockHeader, hashes [][]byte, divergence int) error {
+	// index the remote headers by height so that the targets can be calculated without persisting the headers
+	byHeight := make(map[uint]*kernel.BlockHeader, len(headers))
+	for _, header := range headers {
+		byHeight[header.Height] = header
+	}
+
+	chainExplorer := explorer.NewChainExplorer(bc.store, bc.hasher)
+	headerByHeight := func(height uint) (*kernel.BlockHeader, error) {
+		if header, ok := byHeight[height]; ok {
+			return header, nil
+		}
+		return chainExplorer.GetHeaderByHeight(height)
+	}
+
+	maxTimestamp := time.Now().Add(bc.cfg.Chain.MaxFutureBlockTime).Unix()
+
+	for i := divergence; i < len(headers); i++ {
+		header := headers[i]
+
+		switch {
+		case i > divergence:
+			if !bytes.Equal(header.PrevBlockHash, hashes[i-1]) || header.Height != headers[i-1].Height+1 {
+				return fmt.Errorf("header %x does not extend header %x", hashes[i], hashes[i-1])
+			}
+		case header.IsGenesisHeader():
+			if bc.GetLastHeight() > 0 {
+				return fmt.Errorf("genesis header %x does not match the local genesis block", hashes[i])
+			}
+		default:
+			bc.mu.Lock()
+			parent, ok := bc.retrieveKnownHeader(header.PrevBlockHash)
+			bc.mu.Unlock()
+			if !ok {
+				return fmt.Errorf("header %x does not extend any known block", hashes[i])
+			}
+
+			if header.Height != parent.Height+1 {
+				return fmt.Errorf("header %x height %d does not follow parent height %d", hashes[i], header.Height, parent.Height)
+			}
+		}
+
+		if err := bc.lightValidator.ValidateHeader(header); err != nil {
+			return fmt.Errorf("error validating header %x: %w", hashes[i], err)
+		}
+
+		expectedTarget, err := explorer.GetMiningTargetFromHeaders(
+			header.Height,
+			header.Version,
+			bc.cfg.Miner.AdjustmentInterval,
+			bc.cfg.Miner.MiningInterval,
+			headerByHeight,
+		)
 		if err != nil {
-			return fmt.Errorf("error asking for block %x: %w", remoteBlockHash, err)
+			return fmt.Errorf("error calculating target for header %x: %w", hashes[i], err)
+		}
+
+		if header.Target != expectedTarget {
+			return fmt.Errorf("header %x target %d does not match expected target %d", hashes[i], header.Target, expectedTarget)
 		}
 
-		// try to add block to the chain, if it fails, log it and finish the sync (blocks are validated insiside AddBlock)
-		if err = bc.AddBlock(block); err != nil {
-			// todo(): maybe the node should be blamed and black listed?
-			return fmt.Errorf("error adding block %x to the chain: %w", remoteBlockHash, err)
+		if err = validateHeaderTimestamp(header, maxTimestamp, headerByHeight); err != nil {
+			return fmt.Errorf("error validating timestamp of header %x: %w", hashes[i], err)
 		}
 	}
 
 	return nil
 }
 
-// RetrieveMempoolTxs return an amount of unconfirmed transactions ready to be added to a block
-func (bc *Blockchain) RetrieveMempoolTxs(numTxs uint) ([]*kernel.Transaction, uint) {
-	return bc.mempool.RetrieveTransactions(numTxs)
+// validateHeaderTimestamp checks that the timestamp of the header is greater than the median time past of the previous
+// headers and not greater than maxTimestamp
+func validateHeaderTimestamp(header *kernel.BlockHeader, maxTimestamp int64, headerByHeight func(height uint) (*kernel.BlockHeader, error)) error {
+	if header.Timestamp > maxTimestamp {
+		return fmt.Errorf("timestamp %d is too far in the future, max allowed %d", header.Timestamp, maxTimestamp)
+	}
+
+	if header.IsGenesisHeader() {
+		return nil
+	}
+
+	medianTimePast, err := explorer.GetMedianTimePastFromHeaders(header.Height, headerByHeight)
+	if err != nil {
+		return fmt.Errorf("error calculating median time past: %w", err)
+	}
+
+	if header.Timestamp <= medianTimePast {
+		return fmt.Errorf("timestamp %d is not greater than median time past %d", header.Timestamp, medianTimePast)
+	}
+
+	return nil
+}
+
+// downloadPeers returns the peers used for downloading blocks, starting with the peer the node is syncing with
+func (bc *Blockchain) downloadPeers(syncPeer peer.ID) []peer.ID {
+	peers := []peer.ID{syncPeer}
+	for _, peerID := range bc.p2pNet.ConnectedPeers() {
+		if peerID != syncPeer {
+			peers = append(peers, peerID)
+		}
+	}
+
+	return peers
+}
+
+// fetchBlock retrieves a block from a peer and makes sure that the block header corresponds to the hash requested
+func (bc *Blockchain) fetchBlock(ctx context.Context, peerID peer.ID, hash []byte) (*kernel.Block, error) {
+	block, err := bc.p2pNet.AskSpecificBlock(ctx, peerID, hash)
+	if err != nil {
+		return nil, fmt.Errorf("error asking for block %x to %s: %w", hash, peerID, err)
+	}
+
+	if err = util.VerifyBlockHash(block.Header, hash, bc.hasher); err != nil {
+		return nil, fmt.Errorf("block %x sent by %s does not match its header: %w", hash, peerID, err)
+	}
+
+	return block, nil
+}
+
+// findDivergence calculates the hashes of the remote headers (sorted by height) and returns the index of the first
+// header that is not known locally. If all headers are known, returns the length of the list
+func (bc *Blockchain) findDivergence(remoteHeaders []*kernel.BlockHeader) (int, [][]byte, error) {
+	divergence := len(remoteHeaders)
+	hashes := make([][]byte, len(remoteHeaders))
+
+	for i, header := range remoteHeaders {
+		hash, err := util.CalculateBlockHash(header, bc.hasher)
+		if err != nil {
+			return 0, nil, fmt.Errorf("error calculating block hash from header: %w", err)
+		}
+
+		hashes[i] = hash
+		if divergence == len(remoteHeaders) && !bc.ContainsBlock(hash) {
+			divergence = i
+		}
+	}
+
+	return divergence, hashes, nil
+}
+
+// RetrieveMempoolTxs return an amount of unconfirmed transactions ready to be added to a block, the total size of
+// the transactions does not exceed maxSize
+func (bc *Blockchain) RetrieveMempoolTxs(numTxs, maxSize uint) ([]*kernel.Transaction, uint) {
+	return bc.mempool.RetrieveTransactions(numTxs, maxSize)
 }
 
 // ID returns the observer id
@@ -350,8 +1101,12 @@ func (bc *Blockchain) OnNodeDiscovered(peerID peer.ID) {
 		ctx, cancel := context.WithTimeout(context.Background(), bc.cfg.P2P.ConnTimeout)
 		defer cancel()
 
-		bc.syncMutex.Lock(ctx)
+		if !bc.syncMutex.Lock(ctx) {
+			bc.logger.Errorf("unable to acquire sync lock for syncing with %s: %s", peerID.String(), ctx.Err())
+			return
+		}
 		defer bc.syncMutex.Unlock()
+
 		if err := bc.syncWithPeer(ctx, peerID); err != nil {
 			bc.logger.Errorf("error syncing with %s: %s", peerID.String(), err)
 		}
@@ -429,7 +1184,7 @@ func (bc *Blockchain) RegisterMetrics(registry *prometheus.Registry) { //nolint:
 
 	monitor.NewMetric(registry, monitor.Gauge, "chain_circulating_supply", "Circulating supply of the chain", func() float64 {
 		totalSupply := 0
-		remainingHeight := int(bc.lastHeight)
+		remainingHeight := int(bc.GetLastHeight())
 		reward := common.InitialCoinbaseReward
 
 		for remainingHeight > 0 {
@@ -539,10 +1294,20 @@ func (bc *Blockchain) RegisterMetrics(registry *prometheus.Registry) { //nolint:
 }
 
 func (bc *Blockchain) calculateTxFee(tx *kernel.Transaction) (uint, error) {
-	// calculate the funds provided by the inputs
-	inputBalance, err := bc.utxoSet.RetrieveInputsBalance(tx.Vin)
-	if err != nil {
-		return 0, fmt.Errorf("error retrieving inputs balance: %w", err)
+	// calculate the funds provided by the inputs, which can spend outputs of transactions contained in the mempool
+	inputBalance := uint(0)
+	for _, vin := range tx.Vin {
+		balance, err := bc.utxoSet.RetrieveInputsBalance([]kernel.TxInput{vin})
+		if err != nil {
+			output, errMempool := bc.mempool.RetrieveOutput(vin)
+			if errMempool != nil {
+				return 0, fmt.Errorf("error retrieving inputs balance: %w", err)
+			}
+
+			balance = output.Amount
+		}
+
+		inputBalance += balance
 	}
 
 	// calculate the funds spent by the outputs
@@ -559,16 +1324,29 @@ func (bc *Blockchain) calculateTxFee(tx *kernel.Transaction) (uint, error) {
 
 // GetLastBlockHash returns the latest block hash
 func (bc *Blockchain) GetLastBlockHash() []byte {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
 	return bc.lastBlockHash
 }
 
 // GetLastHeight returns the latest block height
 func (bc *Blockchain) GetLastHeight() uint {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
 	return bc.lastHeight
 }
 
-// reconstructState retrieves all headers from the last block to the genesis block and reconstructs the UTXO set
-func reconstructState(store storage.Storage, utxoSet *utxoset.UTXOSet, headers map[string]kernel.BlockHeader, lastBlockHash []byte) error {
+// reconstructState retrieves all headers from the last block to the genesis block and reconstructs the UTXO set and
+// the cumulative work of the chain (persisting the work of the headers that lack it)
+func reconstructState(
+	store storage.Storage,
+	utxoSet *utxoset.UTXOSet,
+	headers map[string]kernel.BlockHeader,
+	chainWork map[string]*big.Int,
+	lastBlockHash []byte,
+) error {
 	if len(lastBlockHash) == 0 {
 		return fmt.Errorf("last block hash is empty")
 	}
@@ -590,8 +1368,23 @@ func reconstructState(store storage.Storage, utxoSet *utxoset.UTXOSet, headers m
 	}
 
 	// iterate the list of hashes in reverse order to reconstruct the UTXO set
+	work := big.NewInt(0)
 	for i := range listHashes {
 		blockHash := listHashes[len(listHashes)-1-i]
+
+		// accumulate the work of the chain up to this block
+		header := headers[string(blockHash)]
+		work = new(big.Int).Add(work, util.CalculateBlockWork(&header))
+		chainWork[string(blockHash)] = work
+
+		// backfill the work stored if it does not match (headers persisted before the work was tracked). Only the
+		// work is persisted, so the last header keys keep pointing to the tip
+		if storedWork, err := store.RetrieveChainWorkByHash(blockHash); err != nil || storedWork.Cmp(work) != 0 {
+			if err = store.PersistChainWork(blockHash, work); err != nil {
+				return fmt.Errorf("error persisting chain work of block %x: %w", blockHash, err)
+			}
+		}
+
 		block, err := store.RetrieveBlockByHash(blockHash)
 		if err != nil {
 			return fmt.Errorf("error retrieving block %x: %w", blockHash, err)
@@ -1,7 +1,12 @@
 package blockchain //nolint:testpackage // don't create separate package for tests
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
 	"os"
 	"testing"
+	"time"
 
 	cerror "github.com/yago-123/chainnet/pkg/errs"
 
@@ -12,14 +17,17 @@ import (
 	"github.com/yago-123/chainnet/pkg/kernel"
 	"github.com/yago-123/chainnet/pkg/mempool"
 	"github.com/yago-123/chainnet/pkg/observer"
+	"github.com/yago-123/chainnet/pkg/script"
 	"github.com/yago-123/chainnet/pkg/storage"
 	"github.com/yago-123/chainnet/tests/mocks/consensus"
 	mockHash "github.com/yago-123/chainnet/tests/mocks/crypto/hash"
 	mockStorage "github.com/yago-123/chainnet/tests/mocks/storage"
 
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/sirupsen/logrus"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -99,10 +107,11 @@ func TestBlockchain_InitializationFromScratch(t *testing.T) {
 	chain, err := NewBlockchain(
 		cfg,
 		store,
-		mempool.NewMemPool(1000),
+		mempool.NewMemPool(config.DefaultMaxMempoolSize, 0),
 		utxoset.NewUTXOSet(cfg),
 		&mockHash.FakeHashing{},
 		&consensus.MockHeavyValidator{},
+		&consensus.MockLightValidator{},
 		observer.NewChainSubject(),
 		encoding.NewGobEncoder(),
 	)
@@ -141,10 +150,11 @@ func TestBlockchain_InitializationRecovery(t *testing.T) {
 	chain, err := NewBlockchain(
 		cfg,
 		boltdb,
-		mempool.NewMemPool(1000),
+		mempool.NewMemPool(config.DefaultMaxMempoolSize, 0),
 		utxoset.NewUTXOSet(cfg),
 		mockHashing,
 		&consensus.MockHeavyValidator{},
+		&consensus.MockLightValidator{},
 		observer.NewChainSubject(),
 		encoding.NewGobEncoder(),
 	)
@@ -155,4 +165,387 @@ func TestBlockchain_InitializationRecovery(t *testing.T) {
 	assert.Equal(t, uint(4), chain.lastHeight)
 	assert.Len(t, chain.headers, 4)
 	assert.Equal(t, []byte("block-3-hash"), chain.headers["block-4-hash"].PrevBlockHash)
+
+	// the cumulative work persisted matches the work reconstructed (each block contributes 2^1)
+	lastWork, err := boltdb.GetLastChainWork()
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(8), lastWork)
+	assert.Equal(t, lastWork, chain.chainWork["block-4-hash"])
+}
+
+// tests that the chain keeps side branches and switches to the branch with the most work when it overtakes the tip
+func TestBlockchain_Reorganization(t *testing.T) {
+	chain, store, mempoolTxs := newTestChain(t, "temp-file-reorg")
+
+	genesis := newTestBlock(t, nil, 0, newTestCoinbase("coinbase-genesis", "alice"))
+
+	txA1 := kernel.NewTransaction(
+		[]kernel.TxInput{kernel.NewInput([]byte("coinbase-genesis"), 0, "sig", "alice")},
+		[]kernel.TxOutput{kernel.NewOutput(40, script.P2PK, "bob")},
+	)
+	txA1.SetID([]byte("tx-a1"))
+	blockA1 := newTestBlock(t, genesis, 0, newTestCoinbase("coinbase-a1", "alice"), txA1)
+
+	blockB1 := newTestBlock(t, genesis, 1, newTestCoinbase("coinbase-b1", "bob"))
+	blockB2 := newTestBlock(t, blockB1, 1, newTestCoinbase("coinbase-b2", "bob"))
+
+	require.NoError(t, chain.AddBlock(genesis))
+	require.NoError(t, chain.AddBlock(blockA1))
+
+	// unconfirmed transaction spending the output created by the block that will be disconnected
+	txChild := kernel.NewTransaction(
+		[]kernel.TxInput{kernel.NewInput([]byte("tx-a1"), 0, "sig", "bob")},
+		[]kernel.TxOutput{kernel.NewOutput(30, script.P2PK, "carol")},
+	)
+	txChild.SetID([]byte("tx-child"))
+	require.NoError(t, chain.AddTransaction(txChild))
+
+	// the side block contains the same amount of work as the tip, the tip must remain the same
+	require.NoError(t, chain.AddBlock(blockB1))
+	assert.Equal(t, blockA1.Hash, chain.GetLastBlockHash())
+	assert.Equal(t, uint(2), chain.GetLastHeight())
+	assert.Contains(t, chain.sideBlocks, string(blockB1.Hash))
+
+	// once the side branch contains more work, the chain must switch to it
+	require.NoError(t, chain.AddBlock(blockB2))
+	assert.Equal(t, blockB2.Hash, chain.GetLastBlockHash())
+	assert.Equal(t, uint(3), chain.GetLastHeight())
+	assert.Len(t, chain.headers, 3)
+	assert.NotContains(t, chain.headers, string(blockA1.Hash))
+	assert.Contains(t, chain.sideBlocks, string(blockA1.Hash))
+	assert.NotContains(t, chain.sideBlocks, string(blockB2.Hash))
+
+	lastHash, err := store.GetLastBlockHash()
+	require.NoError(t, err)
+	assert.Equal(t, blockB2.Hash, lastHash)
+
+	lastBlock, err := store.GetLastBlock()
+	require.NoError(t, err)
+	assert.Equal(t, blockB2.Hash, lastBlock.Hash)
+
+	// the disconnected block is removed from the storage, so its transactions are not reported as confirmed
+	_, err = store.RetrieveBlockByHash(blockA1.Hash)
+	require.Error(t, err)
+
+	// the output spent by the disconnected block must be available again, and the transaction returned to the mempool
+	balance, err := chain.utxoSet.RetrieveInputsBalance(txA1.Vin)
+	require.NoError(t, err)
+	assert.Equal(t, uint(50), balance)
+	_, err = chain.utxoSet.RetrieveInputsBalance([]kernel.TxInput{kernel.NewInput([]byte("coinbase-a1"), 0, "", "")})
+	require.Error(t, err)
+	assert.True(t, mempoolTxs.ContainsTx("tx-a1"))
+	assert.True(t, mempoolTxs.ContainsTx("tx-child"))
+
+	// extend the original branch until it overtakes the new one again
+	blockA2 := newTestBlock(t, blockA1, 0, newTestCoinbase("coinbase-a2", "alice"))
+	blockA3 := newTestBlock(t, blockA2, 0, newTestCoinbase("coinbase-a3", "alice"))
+
+	require.NoError(t, chain.AddBlock(blockA2))
+	assert.Equal(t, blockB2.Hash, chain.GetLastBlockHash())
+
+	require.NoError(t, chain.AddBlock(blockA3))
+	assert.Equal(t, blockA3.Hash, chain.GetLastBlockHash())
+	assert.Equal(t, uint(4), chain.GetLastHeight())
+	assert.Contains(t, chain.sideBlocks, string(blockB1.Hash))
+	assert.Contains(t, chain.sideBlocks, string(blockB2.Hash))
+	_, err = store.RetrieveBlockByHash(blockA1.Hash)
+	require.NoError(t, err)
+	_, err = store.RetrieveBlockByHash(blockB2.Hash)
+	require.Error(t, err)
+
+	// the transaction has been confirmed again, so it must not be part of the mempool anymore unlike its child
+	assert.False(t, mempoolTxs.ContainsTx("tx-a1"))
+	assert.True(t, mempoolTxs.ContainsTx("tx-child"))
+	_, err = chain.utxoSet.RetrieveInputsBalance(txA1.Vin)
+	require.Error(t, err)
+}
+
+// tests that the tip can be read while blocks are added and the chain reorganizes, must be run with -race
+func TestBlockchain_ConcurrentTipAccess(t *testing.T) {
+	chain, _, _ := newTestChain(t, "temp-file-concurrent")
+
+	genesis := newTestBlock(t, nil, 0, newTestCoinbase("coinbase-genesis", "alice"))
+	require.NoError(t, chain.AddBlock(genesis))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 1000 {
+			_ = chain.GetLastHeight()
+			_ = chain.GetLastBlockHash()
+			_ = chain.localChainWork()
+		}
+	}()
+
+	// build two competing branches so the tip moves back and forth
+	tipA, tipB := genesis, genesis
+	for i := range 5 {
+		tipA = newTestBlock(t, tipA, 0, newTestCoinbase(fmt.Sprintf("coinbase-a%d", i), "alice"))
+		require.NoError(t, chain.AddBlock(tipA))
+
+		tipB = newTestBlock(t, tipB, 1, newTestCoinbase(fmt.Sprintf("coinbase-b%d", i), "bob"))
+		require.NoError(t, chain.AddBlock(tipB))
+	}
+	tipB = newTestBlock(t, tipB, 1, newTestCoinbase("coinbase-b-last", "bob"))
+	require.NoError(t, chain.AddBlock(tipB))
+
+	<-done
+	assert.Equal(t, tipB.Hash, chain.GetLastBlockHash())
+	assert.Equal(t, uint(7), chain.GetLastHeight())
+}
+
+// tests that blocks that do not connect with any known block or are already known are rejected
+func TestBlockchain_AddBlockUnknownOrRepeated(t *testing.T) {
+	chain, _, _ := newTestChain(t, "temp-file-unknown")
+
+	genesis := newTestBlock(t, nil, 0, newTestCoinbase("coinbase-genesis", "alice"))
+	block1 := newTestBlock(t, genesis, 0, newTestCoinbase("coinbase-1", "alice"))
+	block2 := newTestBlock(t, block1, 0, newTestCoinbase("coinbase-2", "alice"))
+
+	require.NoError(t, chain.AddBlock(genesis))
+	require.Error(t, chain.AddBlock(block2))
+	require.NoError(t, chain.AddBlock(block1))
+	require.Error(t, chain.AddBlock(block1))
+	assert.Equal(t, block1.Hash, chain.GetLastBlockHash())
+}
+
+func newTestChain(t *testing.T, file string) (*Blockchain, storage.Storage, *mempool.MemPool) {
+	boltdb, err := storage.NewBoltDB(file, "block-bucket", "header-bucket", encoding.NewGobEncoder())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = boltdb.Close()
+		_ = os.Remove(file)
+	})
+
+	cfg := config.NewConfig()
+	mempoolTxs := mempool.NewMemPool(config.DefaultMaxMempoolSize, 0)
+	mempoolTxs.SetTxValidator(&consensus.MockHeavyValidator{})
+	utxos := utxoset.NewUTXOSet(cfg)
+
+	subject := observer.NewChainSubject()
+	subject.Register(boltdb)
+	subject.Register(mempoolTxs)
+	subject.Register(utxos)
+
+	chain, err := NewBlockchain(
+		cfg,
+		boltdb,
+		mempoolTxs,
+		utxos,
+		&mockHash.FakeHashing{},
+		&consensus.MockHeavyValidator{},
+		&consensus.MockLightValidator{},
+		subject,
+		encoding.NewGobEncoder(),
+	)
+	require.NoError(t, err)
+
+	return chain, boltdb, mempoolTxs
+}
+
+// newTestBlock creates a block on top of prev, the nonce allows creating different blocks at the same height
+func newTestBlock(t *testing.T, prev *kernel.Block, nonce uint, txs ...*kernel.Transaction) *kernel.Block {
+	height := uint(0)
+	prevHash := []byte{}
+	if prev != nil {
+		height = prev.Header.Height + 1
+		prevHash = prev.Hash
+	}
+
+	// timestamps increase with the height so blocks are always newer than the median time past
+	header := kernel.NewBlockHeader([]byte("1"), int64(height), []byte{}, height, prevHash, 1, nonce)
+	blockHash, err := (&mockHash.FakeHashing{}).Hash(header.Assemble())
+	require.NoError(t, err)
+
+	return kernel.NewBlock(header, txs, blockHash)
+}
+
+func newTestCoinbase(id, to string) *kernel.Transaction {
+	tx := kernel.NewCoinbaseTransaction(to, 50, 0)
+	tx.SetID([]byte(id))
+
+	return tx
+}
+
+func TestSelectMostPopularTip(t *testing.T) {
+	headerA := &kernel.BlockHeader{Height: 10}
+	headerB := &kernel.BlockHeader{Height: 12}
+	headerC := &kernel.BlockHeader{Height: 11}
+
+	tips := []peerTip{
+		{peerID: peer.ID("peer-1"), header: headerA, hash: []byte("hash-a")},
+		{peerID: peer.ID("peer-2"), header: headerB, hash: []byte("hash-b")},
+		{peerID: peer.ID("peer-3"), header: headerA, hash: []byte("hash-a")},
+	}
+
+	// the tip reported by most peers is chosen even if is not the highest one
+	selected := selectMostPopularTip(tips)
+	require.Len(t, selected, 2)
+	assert.Equal(t, []byte("hash-a"), selected[0].hash)
+	assert.Equal(t, []byte("hash-a"), selected[1].hash)
+
+	// in case of tie, the highest tip is chosen
+	tips = append(tips, peerTip{peerID: peer.ID("peer-4"), header: headerB, hash: []byte("hash-b")})
+	tips = append(tips, peerTip{peerID: peer.ID("peer-5"), header: headerC, hash: []byte("hash-c")})
+	selected = selectMostPopularTip(tips)
+	require.Len(t, selected, 2)
+	assert.Equal(t, []byte("hash-b"), selected[0].hash)
+
+	assert.Empty(t, selectMostPopularTip([]peerTip{}))
+}
+
+func TestBlockchain_RunMempoolExpiry(t *testing.T) {
+	chain, _, mempoolTxs := newTestChain(t, "temp-file-expiry")
+
+	tx := newTestCoinbase("tx-expired", "alice")
+	require.NoError(t, mempoolTxs.AppendTransactionAt(tx, 1, time.Now().Add(-2*chain.cfg.Chain.MempoolMaxAge)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go chain.runMempoolExpiry(ctx, time.Millisecond)
+
+	// the transaction expires without adding any block
+	assert.Eventually(t, func() bool {
+		return !mempoolTxs.ContainsTx(string(tx.ID))
+	}, time.Second, time.Millisecond)
+}
+
+func TestBlockchain_FilterSyncableTips(t *testing.T) {
+	chain, _, _ := newTestChain(t, "temp-file-syncable")
+
+	genesis := newTestBlock(t, nil, 0, newTestCoinbase("coinbase-genesis", "alice"))
+	block1 := newTestBlock(t, genesis, 0, newTestCoinbase("coinbase-1", "alice"))
+	block2 := newTestBlock(t, block1, 0, newTestCoinbase("coinbase-2", "alice"))
+	require.NoError(t, chain.AddBlock(genesis))
+	require.NoError(t, chain.AddBlock(block1))
+	require.NoError(t, chain.AddBlock(block2))
+
+	// a shorter unknown branch may contain more work, only the known tips are discarded
+	tips := []peerTip{
+		{peerID: peer.ID("peer-1"), header: block2.Header, hash: block2.Hash},
+		{peerID: peer.ID("peer-2"), header: &kernel.BlockHeader{Height: 1}, hash: []byte("unknown-hash")},
+	}
+	syncable := chain.filterSyncableTips(tips)
+	require.Len(t, syncable, 1)
+	assert.Equal(t, []byte("unknown-hash"), syncable[0].hash)
+}
+
+func TestBlockchain_FindDivergence(t *testing.T) {
+	chain, _, _ := newTestChain(t, "temp-file-divergence")
+
+	genesis := newTestBlock(t, nil, 0, newTestCoinbase("coinbase-genesis", "alice"))
+	block1 := newTestBlock(t, genesis, 0, newTestCoinbase("coinbase-1", "alice"))
+	block2 := newTestBlock(t, block1, 0, newTestCoinbase("coinbase-2", "alice"))
+	remote2 := newTestBlock(t, block1, 1, newTestCoinbase("coinbase-remote-2", "bob"))
+	remote3 := newTestBlock(t, remote2, 1, newTestCoinbase("coinbase-remote-3", "bob"))
+
+	require.NoError(t, chain.AddBlock(genesis))
+	require.NoError(t, chain.AddBlock(block1))
+	require.NoError(t, chain.AddBlock(block2))
+
+	// the remote chain forks after block1
+	divergence, hashes, err := chain.findDivergence([]*kernel.BlockHeader{genesis.Header, block1.Header, remote2.Header, remote3.Header})
+	require.NoError(t, err)
+	assert.Equal(t, 2, divergence)
+	assert.Equal(t, [][]byte{genesis.Hash, block1.Hash, remote2.Hash, remote3.Hash}, hashes)
+
+	// all the remote headers are known locally
+	divergence, _, err = chain.findDivergence([]*kernel.BlockHeader{genesis.Header, block1.Header, block2.Header})
+	require.NoError(t, err)
+	assert.Equal(t, 3, divergence)
+}
+
+func TestBlockchain_ValidateHeaderChain(t *testing.T) {
+	chain, _, _ := newTestChain(t, "temp-file-header-chain")
+
+	lightValidator := &consensus.MockLightValidator{}
+	lightValidator.On("ValidateHeader", mock.Anything).Return(nil)
+	chain.lightValidator = lightValidator
+
+	genesis := newTestBlock(t, nil, 0, newTestCoinbase("coinbase-genesis", "alice"))
+	block1 := newTestBlock(t, genesis, 0, newTestCoinbase("coinbase-1", "alice"))
+	block2 := newTestBlock(t, block1, 0, newTestCoinbase("coinbase-2", "alice"))
+	remote2 := newTestBlock(t, block1, 1, newTestCoinbase("coinbase-remote-2", "bob"))
+	remote3 := newTestBlock(t, remote2, 1, newTestCoinbase("coinbase-remote-3", "bob"))
+
+	require.NoError(t, chain.AddBlock(genesis))
+	require.NoError(t, chain.AddBlock(block1))
+	require.NoError(t, chain.AddBlock(block2))
+
+	headers := []*kernel.BlockHeader{genesis.Header, block1.Header, remote2.Header, remote3.Header}
+	hashes := [][]byte{genesis.Hash, block1.Hash, remote2.Hash, remote3.Hash}
+
+	// the remote chain forks after block1 and is valid
+	require.NoError(t, chain.validateHeaderChain(headers, hashes, 2))
+
+	// headers that don't point to the previous header are rejected
+	require.Error(t, chain.validateHeaderChain(
+		[]*kernel.BlockHeader{genesis.Header, block1.Header, remote2.Header, block2.Header},
+		[][]byte{genesis.Hash, block1.Hash, remote2.Hash, block2.Hash},
+		2,
+	))
+
+	// headers that don't extend a known block are rejected
+	require.Error(t, chain.validateHeaderChain(headers[3:], hashes[3:], 0))
+
+	// a different genesis header is rejected when the local chain is not empty
+	otherGenesis := newTestBlock(t, nil, 1, newTestCoinbase("coinbase-other-genesis", "bob"))
+	require.Error(t, chain.validateHeaderChain([]*kernel.BlockHeader{otherGenesis.Header}, [][]byte{otherGenesis.Hash}, 0))
+
+	// headers with unexpected target are rejected
+	wrongTarget := newTestBlock(t, block1, 2, newTestCoinbase("coinbase-wrong-target", "bob"))
+	wrongTarget.Header.Target = 2
+	require.Error(t, chain.validateHeaderChain(
+		[]*kernel.BlockHeader{genesis.Header, block1.Header, wrongTarget.Header},
+		[][]byte{genesis.Hash, block1.Hash, wrongTarget.Hash},
+		2,
+	))
+
+	// headers not newer than the median time past or too far in the future are rejected
+	oldTimestamp := newTestBlock(t, block1, 3, newTestCoinbase("coinbase-old-timestamp", "bob"))
+	oldTimestamp.Header.Timestamp = 0
+	require.Error(t, chain.validateHeaderChain(
+		[]*kernel.BlockHeader{genesis.Header, block1.Header, oldTimestamp.Header},
+		[][]byte{genesis.Hash, block1.Hash, oldTimestamp.Hash},
+		2,
+	))
+
+	futureTimestamp := newTestBlock(t, block1, 4, newTestCoinbase("coinbase-future-timestamp", "bob"))
+	futureTimestamp.Header.Timestamp = time.Now().Add(chain.cfg.Chain.MaxFutureBlockTime + time.Minute).Unix()
+	require.Error(t, chain.validateHeaderChain(
+		[]*kernel.BlockHeader{genesis.Header, block1.Header, futureTimestamp.Header},
+		[][]byte{genesis.Hash, block1.Hash, futureTimestamp.Hash},
+		2,
+	))
+
+	// headers rejected by the light validator are rejected
+	failingValidator := &consensus.MockLightValidator{}
+	failingValidator.On("ValidateHeader", mock.Anything).Return(errors.New("invalid header"))
+	chain.lightValidator = failingValidator
+	require.Error(t, chain.validateHeaderChain(headers, hashes, 2))
+}
+
+func TestBlockchain_BuildLocator(t *testing.T) {
+	chain, _, _ := newTestChain(t, "temp-file-locator")
+
+	// empty chain contains an empty locator
+	assert.Empty(t, chain.buildLocator())
+
+	// populate the main chain headers directly, the hashes don't need to match for building the locator
+	hashes := [][]byte{}
+	prevHash := []byte{}
+	for height := range uint(15) {
+		hash := []byte(fmt.Sprintf("block-%d", height))
+		chain.headers[string(hash)] = *kernel.NewBlockHeader([]byte("1"), 0, []byte{}, height, prevHash, 1, 0)
+		hashes = append(hashes, hash)
+		prevHash = hash
+	}
+	chain.lastBlockHash = prevHash
+
+	// the most recent blocks are added one by one, then the steps are doubled and the genesis block is always included
+	expected := [][]byte{}
+	for _, height := range []int{14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 3, 0} {
+		expected = append(expected, hashes[height])
+	}
+	assert.Equal(t, expected, chain.buildLocator())
 }
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"math/big"
+	"slices"
 	"time"
 
 	cerror "github.com/yago-123/chainnet/pkg/errs"
@@ -18,6 +20,9 @@ import (
 
 const (
 	RetrieveAllElements = -1
+
+	// MedianTimePastBlocks is the number of previous blocks used for calculating the median time past of a block
+	MedianTimePastBlocks = 11
 )
 
 // ChainExplorer is a module that allows to explore the chain, retrieve blocks, headers, etc. It is used to split the
@@ -126,19 +131,45 @@ func (explorer *ChainExplorer) GetLastHeader() (*kernel.BlockHeader, error) {
 	return header, nil
 }
 
-// GetMiningTarget returns the mining target that corresponds to the block height provided. The height should be +1,
-// EQUAL or SMALLER than the latest block height in the chain (don't confuse with the block height argument).
-// This function is used for determining the mining target of the block that is going to be mined or added
+// GetChainWork returns the cumulative work of the chain ending with the block hash provided
+func (explorer *ChainExplorer) GetChainWork(hash []byte) (*big.Int, error) {
+	return explorer.store.RetrieveChainWorkByHash(hash)
+}
+
+// GetLastChainWork returns the cumulative work of the chain ending with the last header persisted
+func (explorer *ChainExplorer) GetLastChainWork() (*big.Int, error) {
+	return explorer.store.GetLastChainWork()
+}
+
+// GetMiningTarget returns the mining target that corresponds to the block height and header version provided. The
+// height should be +1, EQUAL or SMALLER than the latest block height in the chain (don't confuse with the block height
+// argument). This function is used for determining the mining target of the block that is going to be mined or added
 // to the chain. For example when the chain is synchronizing (needs to validate target) or when
 // the miner needs to know the next mining difficulty
-func (explorer *ChainExplorer) GetMiningTarget(height uint, difficultyAdjustmentInterval uint, expectedMiningInterval time.Duration) (uint, error) {
+func (explorer *ChainExplorer) GetMiningTarget(height uint, version []byte, difficultyAdjustmentInterval uint, expectedMiningInterval time.Duration) (uint, error) {
+	return GetMiningTargetFromHeaders(height, version, difficultyAdjustmentInterval, expectedMiningInterval, explorer.GetHeaderByHeight)
+}
+
+// GetMiningTargetFromHeaders returns the mining target that corresponds to the block height and header version
+// provided, relying on headerByHeight for retrieving the previous headers. This allows calculating the targets of
+// header chains that have not been persisted yet (i.e. headers retrieved during synchronization)
+func GetMiningTargetFromHeaders(
+	height uint,
+	version []byte,
+	difficultyAdjustmentInterval uint,
+	expectedMiningInterval time.Duration,
+	headerByHeight func(height uint) (*kernel.BlockHeader, error),
+) (uint, error) {
 	// if height remains smaller than difficulty interval, return initial difficulty
 	if height < difficultyAdjustmentInterval {
+		if kernel.IsCompactTargetVersion(version) {
+			return uint(util.InitialCompactTarget), nil
+		}
 		return util.InitialBlockTarget, nil
 	}
 
 	// retrieve the previous block
-	previousBlock, err := explorer.GetHeaderByHeight(height - 1)
+	previousBlock, err := headerByHeight(height - 1)
 	if err != nil {
 		return 0, err
 	}
@@ -150,10 +181,21 @@ func (explorer *ChainExplorer) GetMiningTarget(height uint, difficultyAdjustment
 		return 0, fmt.Errorf("height mining target is too far from the last block in the chain")
 	}
 
+	// the leading zero bits targets can't be derived from compact targets, once the chain moves to compact targets
+	// it can't go back
+	if !kernel.IsCompactTargetVersion(version) && previousBlock.HasCompactTarget() {
+		return 0, fmt.Errorf("block version %s can't follow a block with compact target", version)
+	}
+
+	// same applies to the canonical serialization, once the chain migrates to it there is no way back
+	if !kernel.IsCanonicalSerializationVersion(version) && previousBlock.HasCanonicalSerialization() {
+		return 0, fmt.Errorf("block version %s can't follow a block with canonical serialization", version)
+	}
+
 	// if height is difficulty adjustment interval height, calculate new target
 	if (height % difficultyAdjustmentInterval) == 0 {
 		// get previous interval header
-		previousIntervalHeader, errHeader := explorer.GetHeaderByHeight(height - difficultyAdjustmentInterval)
+		previousIntervalHeader, errHeader := headerByHeight(height - difficultyAdjustmentInterval)
 		if errHeader != nil {
 			return 0, errHeader
 		}
@@ -163,17 +205,58 @@ func (explorer *ChainExplorer) GetMiningTarget(height uint, difficultyAdjustment
 		expectedBlockDifference := float64(difficultyAdjustmentInterval) * expectedMiningInterval.Seconds()
 
 		// calculate and return new target
-		return util.CalculateMiningTarget(
+		if kernel.IsCompactTargetVersion(version) {
+			return uint(util.CalculateMiningTarget(
+				util.HeaderTarget(previousBlock),
+				expectedBlockDifference,
+				realBlockDifference,
+			)), nil
+		}
+
+		return util.CalculateLegacyMiningTarget(
 			previousBlock.Target,
 			expectedBlockDifference,
 			realBlockDifference,
 		), nil
 	}
 
-	// if block is not an interval block (height % difficultyAdjustmentInterval) > 0, return the previous target
+	// if block is not an interval block (height % difficultyAdjustmentInterval) > 0, return the previous target. If
+	// the previous block contains a leading zero bits target, the compact equivalent is returned
+	if kernel.IsCompactTargetVersion(version) {
+		return uint(util.BigToCompact(util.HeaderTarget(previousBlock))), nil
+	}
+
 	return previousBlock.Target, nil
 }
 
+// GetMedianTimePast returns the median of the timestamps of the MedianTimePastBlocks blocks previous to the height
+// provided. The timestamp of the block with that height must be greater than the value returned
+func (explorer *ChainExplorer) GetMedianTimePast(height uint) (int64, error) {
+	return GetMedianTimePastFromHeaders(height, explorer.GetHeaderByHeight)
+}
+
+// GetMedianTimePastFromHeaders returns the median time past of the height provided, relying on headerByHeight for
+// retrieving the previous headers. The genesis block has no previous blocks, in that case 0 is returned
+func GetMedianTimePastFromHeaders(height uint, headerByHeight func(height uint) (*kernel.BlockHeader, error)) (int64, error) {
+	timestamps := []int64{}
+	for h := height; h > 0 && len(timestamps) < MedianTimePastBlocks; h-- {
+		header, err := headerByHeight(h - 1)
+		if err != nil {
+			return 0, err
+		}
+
+		timestamps = append(timestamps, header.Timestamp)
+	}
+
+	if len(timestamps) == 0 {
+		return 0, nil
+	}
+
+	slices.Sort(timestamps)
+
+	return timestamps[len(timestamps)/2], nil
+}
+
 // GetAllHeaders returns all the block headers added to the chain. This implementation is not efficient, headers should
 // be cached but would introduce a lot of complexity and inconsistency. All the headers persisted are cached in the chain
 // module itself but it is not exposed to the outside and even if it was public, it would require a circular dependency,
@@ -212,6 +295,53 @@ func (explorer *ChainExplorer) GetAllHeaders() ([]*kernel.BlockHeader, error) {
 	return headers, nil
 }
 
+// GetHeadersAfterLocator returns up to maxHeaders headers of the main chain sorted by height, starting right after the
+// most recent block of the locator that is part of the main chain. The locator is a list of hashes known by the
+// requester (usually from newest to oldest). If none of the hashes is part of the main chain, the headers are returned
+// starting from the genesis block
+func (explorer *ChainExplorer) GetHeadersAfterLocator(locator [][]byte, maxHeaders uint) ([]*kernel.BlockHeader, error) {
+	known := make(map[string]struct{}, len(locator))
+	for _, hash := range locator {
+		known[string(hash)] = struct{}{}
+	}
+
+	currentHash, err := explorer.store.GetLastBlockHash()
+	if err != nil {
+		return nil, err
+	}
+
+	it := iterator.NewReverseHeaderIterator(explorer.store)
+	if err = it.Initialize(currentHash); err != nil {
+		return nil, err
+	}
+
+	// iterate backwards from the tip until reaching a block known by the requester. Only the last maxHeaders visited
+	// are kept given that those are the ones closer to the block known by the requester
+	headers := []*kernel.BlockHeader{}
+	for it.HasNext() {
+		if _, ok := known[string(currentHash)]; ok {
+			break
+		}
+
+		header, errHeader := it.GetNextHeader()
+		if errHeader != nil {
+			return nil, errHeader
+		}
+
+		headers = append(headers, header)
+		if uint(len(headers)) > maxHeaders {
+			headers = headers[1:]
+		}
+
+		// the hash of the next header visited is the previous block hash of the current one
+		currentHash = header.PrevBlockHash
+	}
+
+	slices.Reverse(headers)
+
+	return headers, nil
+}
+
 func (explorer *ChainExplorer) FindUnspentTransactions(address string) ([]*kernel.Transaction, error) {
 	return explorer.findUnspentTransactions(address, iterator.NewReverseBlockIterator(explorer.store))
 }
@@ -333,9 +463,11 @@ func (explorer *ChainExplorer) findUnspentOutputs(address string, it iterator.Bl
 				// check if the output can be unlocked with the given address
 				if out.CanBeUnlockedWith(address) {
 					unspentTXOs = append(unspentTXOs, &kernel.UTXO{
-						TxID:   tx.ID,
-						OutIdx: uint(outIdx),
-						Output: out,
+						TxID:     tx.ID,
+						OutIdx:   uint(outIdx),
+						Output:   out,
+						Height:   nextBlock.Header.Height,
+						Coinbase: tx.IsCoinbase(),
 					})
 				}
 			}
@@ -359,6 +491,33 @@ func (explorer *ChainExplorer) findUnspentOutputs(address string, it iterator.Bl
 	return unspentTXOs, nil
 }
 
+// FindSpendableOutputs finds the unspent outputs that can be unlocked with the given address and that can be spent by
+// a transaction included in the next block. Coinbase outputs that have not reached coinbaseMaturity are skipped
+func (explorer *ChainExplorer) FindSpendableOutputs(address string, maxRetrievalNum int, coinbaseMaturity uint) ([]*kernel.UTXO, error) {
+	lastHeader, err := explorer.GetLastHeader()
+	if err != nil {
+		return []*kernel.UTXO{}, err
+	}
+
+	utxos, err := explorer.FindUnspentOutputs(address, RetrieveAllElements)
+	if err != nil {
+		return []*kernel.UTXO{}, err
+	}
+
+	spendable := []*kernel.UTXO{}
+	for _, utxo := range utxos {
+		if maxRetrievalNum != RetrieveAllElements && maxRetrievalNum <= len(spendable) {
+			break
+		}
+
+		if utxo.IsMature(lastHeader.Height+1, coinbaseMaturity) {
+			spendable = append(spendable, utxo)
+		}
+	}
+
+	return spendable, nil
+}
+
 func (explorer *ChainExplorer) CalculateAddressBalance(address string) (uint, error) {
 	unspentTXs, err := explorer.FindUnspentTransactionsOutputs(address)
 	if err != nil {
@@ -1,14 +1,18 @@
 package explorer //nolint:testpackage // don't create separate package for tests
 
 import (
+	"fmt"
+	"math/big"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/yago-123/chainnet/pkg/encoding"
 	cerror "github.com/yago-123/chainnet/pkg/errs"
 	. "github.com/yago-123/chainnet/pkg/kernel" //nolint:revive // it's fine to use dot imports in tests
 	"github.com/yago-123/chainnet/pkg/script"
 	"github.com/yago-123/chainnet/pkg/storage"
+	"github.com/yago-123/chainnet/pkg/util"
 	mockHash "github.com/yago-123/chainnet/tests/mocks/crypto/hash"
 
 	"github.com/sirupsen/logrus"
@@ -343,6 +347,131 @@ func initializeStorage(t *testing.T, blocks []Block) storage.Storage {
 	return boltdb
 }
 
-func TestExplorer_GetMiningTarget(_ *testing.T) {
+func TestGetMiningTargetFromHeaders(t *testing.T) {
+	legacyVersion := []byte(BlockVersionLeadingZerosTarget)
+	compactVersion := []byte(BlockVersionCompactTarget)
+	interval := uint(2)
+	miningInterval := 10 * time.Second
+
+	// legacy chain in which blocks are mined twice as fast as expected
+	headers := []*BlockHeader{
+		{Version: legacyVersion, Height: 0, Timestamp: 0, Target: 1},
+		{Version: legacyVersion, Height: 1, Timestamp: 10, Target: 1},
+		{Version: legacyVersion, Height: 2, Timestamp: 20, Target: 2},
+		{Version: legacyVersion, Height: 3, Timestamp: 30, Target: 2},
+	}
+	headerByHeight := func(height uint) (*BlockHeader, error) {
+		if height >= uint(len(headers)) {
+			return nil, fmt.Errorf("header with height %d not found", height)
+		}
+		return headers[height], nil
+	}
+
+	// first blocks use the initial target
+	target, err := GetMiningTargetFromHeaders(1, legacyVersion, interval, miningInterval, headerByHeight)
+	require.NoError(t, err)
+	assert.Equal(t, util.InitialBlockTarget, target)
+	target, err = GetMiningTargetFromHeaders(1, compactVersion, interval, miningInterval, headerByHeight)
+	require.NoError(t, err)
+	assert.Equal(t, uint(util.InitialCompactTarget), target)
+
+	// legacy retarget increases the number of leading zero bits by one
+	target, err = GetMiningTargetFromHeaders(4, legacyVersion, interval, miningInterval, headerByHeight)
+	require.NoError(t, err)
+	assert.Equal(t, uint(3), target)
+
+	// the first compact block keeps the difficulty of the previous legacy block
+	target, err = GetMiningTargetFromHeaders(3, compactVersion, interval, miningInterval, headerByHeight)
+	require.NoError(t, err)
+	assert.Equal(t, uint(util.BigToCompact(util.HeaderTarget(headers[2]))), target)
+
+	// compact retarget is proportional to the time spent: 10 seconds instead of 20, so the target is halved
+	target, err = GetMiningTargetFromHeaders(4, compactVersion, interval, miningInterval, headerByHeight)
+	require.NoError(t, err)
+	halved := new(big.Int).Div(util.HeaderTarget(headers[3]), big.NewInt(2))
+	assert.Equal(t, uint(util.BigToCompact(halved)), target)
+
+	// once the chain moves to compact targets it can't go back to legacy targets
+	headers = append(headers, &BlockHeader{Version: compactVersion, Height: 4, Timestamp: 40, Target: uint(util.BigToCompact(halved))})
+	_, err = GetMiningTargetFromHeaders(5, legacyVersion, interval, miningInterval, headerByHeight)
+	require.Error(t, err)
+	target, err = GetMiningTargetFromHeaders(5, compactVersion, interval, miningInterval, headerByHeight)
+	require.NoError(t, err)
+	assert.Equal(t, headers[4].Target, target)
+
+	// the canonical serialization keeps the compact targets, and once adopted there is no way back either
+	canonicalVersion := []byte(BlockVersionCanonicalSerialization)
+	target, err = GetMiningTargetFromHeaders(5, canonicalVersion, interval, miningInterval, headerByHeight)
+	require.NoError(t, err)
+	assert.Equal(t, headers[4].Target, target)
+
+	headers = append(headers, &BlockHeader{Version: canonicalVersion, Height: 5, Timestamp: 50, Target: headers[4].Target})
+	_, err = GetMiningTargetFromHeaders(6, compactVersion, interval, miningInterval, headerByHeight)
+	require.Error(t, err)
+	_, err = GetMiningTargetFromHeaders(6, canonicalVersion, interval, miningInterval, headerByHeight)
+	require.NoError(t, err)
+}
+
+func TestExplorer_GetHeadersAfterLocator(t *testing.T) {
+	blocks := []Block{GenesisBlock, Block1, Block2, Block3, Block4}
+	storageInstance := initializeStorage(t, blocks)
+	defer storageInstance.Close()
+
+	for _, block := range blocks {
+		require.NoError(t, storageInstance.PersistHeader(block.Hash, *block.Header))
+	}
+
+	explorer := NewChainExplorer(storageInstance, &mockHash.FakeHashing{})
+
+	// headers after the most recent known block, sorted by height
+	headers, err := explorer.GetHeadersAfterLocator([][]byte{Block2.Hash, Block1.Hash, GenesisBlock.Hash}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []*BlockHeader{Block3.Header, Block4.Header}, headers)
+
+	// unknown hashes are ignored and the batch is capped starting from the known block
+	headers, err = explorer.GetHeadersAfterLocator([][]byte{[]byte("unknown-hash"), GenesisBlock.Hash}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []*BlockHeader{Block1.Header, Block2.Header}, headers)
+
+	// without known blocks the headers start from the genesis block
+	headers, err = explorer.GetHeadersAfterLocator([][]byte{}, 2)
+	require.NoError(t, err)
+	require.Len(t, headers, 2)
+	assert.Empty(t, headers[0].PrevBlockHash)
+	assert.Equal(t, Block1.Header, headers[1])
+
+	// nothing to return if the tip is already known
+	headers, err = explorer.GetHeadersAfterLocator([][]byte{Block4.Hash}, 2)
+	require.NoError(t, err)
+	assert.Empty(t, headers)
+}
+
+func TestGetMedianTimePastFromHeaders(t *testing.T) {
+	// headers with unordered timestamps, the median must be calculated over the sorted timestamps
+	timestamps := []int64{10, 50, 20, 40, 30, 90, 60, 80, 70, 100, 110, 5, 120}
+	headerByHeight := func(height uint) (*BlockHeader, error) {
+		if height >= uint(len(timestamps)) {
+			return nil, fmt.Errorf("header with height %d not found", height)
+		}
+		return &BlockHeader{Height: height, Timestamp: timestamps[height]}, nil
+	}
+
+	// genesis block has no previous blocks
+	mtp, err := GetMedianTimePastFromHeaders(0, headerByHeight)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), mtp)
+
+	// less than MedianTimePastBlocks previous blocks: 10, 20, 50
+	mtp, err = GetMedianTimePastFromHeaders(3, headerByHeight)
+	require.NoError(t, err)
+	assert.Equal(t, int64(20), mtp)
+
+	// only the last MedianTimePastBlocks blocks are taken into account: 5, 20, 30, ..., 110
+	mtp, err = GetMedianTimePastFromHeaders(12, headerByHeight)
+	require.NoError(t, err)
+	assert.Equal(t, int64(60), mtp)
 
+	// missing headers return error
+	_, err = GetMedianTimePastFromHeaders(20, headerByHeight)
+	require.Error(t, err)
 }
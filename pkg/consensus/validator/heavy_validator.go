@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"sync/atomic"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/yago-123/chainnet/pkg/monitor"
@@ -19,7 +20,9 @@ import (
 	"github.com/yago-123/chainnet/pkg/crypto/hash"
 	"github.com/yago-123/chainnet/pkg/crypto/sign"
 	"github.com/yago-123/chainnet/pkg/kernel"
+	"github.com/yago-123/chainnet/pkg/mempool"
 	"github.com/yago-123/chainnet/pkg/util"
+	"github.com/yago-123/chainnet/pkg/utxoset"
 )
 
 const (
@@ -27,17 +30,25 @@ const (
 )
 
 type TxFunc func(tx *kernel.Transaction) error
+type TxInputsFunc func(tx *kernel.Transaction, utxos []kernel.UTXO, height uint) error
 type HeaderFunc func(bh *kernel.BlockHeader) error
 type BlockFunc func(b *kernel.Block) error
 
 type HValidator struct {
 	lv       consensus.LightValidator
 	explorer *explorer.ChainExplorer
-	signer   sign.Signature
-	hasher   hash.Hashing
+	// utxoSet provides the confirmed outputs spent by the inputs, along with their height and type (coinbase or not)
+	utxoSet *utxoset.UTXOSet
+	// mempoolExplorer provides the outputs of unconfirmed transactions, which can be spent by other transactions
+	mempoolExplorer *mempool.MemPoolExplorer
+	signer          sign.Signature
+	hasher          hash.Hashing
 
 	interpreter *interpreter.RPNInterpreter
 
+	// now returns the local time, used for rejecting blocks with timestamps too far in the future
+	now func() time.Time
+
 	metrics *HValidatorMetrics
 
 	cfg *config.Config
@@ -47,15 +58,20 @@ func NewHeavyValidator(
 	cfg *config.Config,
 	lv consensus.LightValidator,
 	explorer *explorer.ChainExplorer,
+	utxoSet *utxoset.UTXOSet,
+	mempoolExplorer *mempool.MemPoolExplorer,
 	signer sign.Signature,
 	hasher hash.Hashing,
 ) *HValidator {
 	return &HValidator{
-		lv:          lv,
-		explorer:    explorer,
-		signer:      signer,
-		hasher:      hasher,
-		interpreter: interpreter.NewScriptInterpreter(signer),
+		lv:              lv,
+		explorer:        explorer,
+		utxoSet:         utxoSet,
+		mempoolExplorer: mempoolExplorer,
+		signer:          signer,
+		hasher:          hasher,
+		interpreter:     interpreter.NewScriptInterpreter(signer),
+		now:             time.Now,
 		metrics: &HValidatorMetrics{
 			txMetrics:     &HValidatorTxMetrics{},
 			headerMetrics: &HValidatorHeaderMetrics{},
@@ -68,17 +84,41 @@ func NewHeavyValidator(
 func (hv *HValidator) ValidateTx(tx *kernel.Transaction) error {
 	defer atomic.AddUint64(&hv.metrics.txMetrics.totalAnalyzed, 1)
 
-	validations := []TxFunc{
-		hv.lv.ValidateTxLight,
+	if err := hv.validateTx(tx); err != nil {
+		atomic.AddUint64(&hv.metrics.txMetrics.totalRejected, 1)
+		return err
+	}
+
+	return nil
+}
+
+// validateTx checks that the transaction can be included in the next block. The outputs spent by the inputs are
+// resolved once and shared by all the checks that depend on them
+func (hv *HValidator) validateTx(tx *kernel.Transaction) error {
+	if err := hv.lv.ValidateTxLight(tx); err != nil {
+		return err
+	}
+
+	lastHeader, err := hv.explorer.GetLastHeader()
+	if err != nil {
+		return fmt.Errorf("error retrieving last header: %w", err)
+	}
+	height := lastHeader.Height + 1
+
+	utxos, err := hv.retrieveSpentUTXOs(tx, height)
+	if err != nil {
+		return err
+	}
+
+	validations := []TxInputsFunc{
 		hv.validateOwnershipAndBalanceOfInputs,
-		// todo(): validate timelock / block height constraints
-		// todo(): maturity checks?
-		// todo(): each input must have at least CPOMNASE_MATURITY(100) confirmations
+		hv.validateCoinbaseMaturity,
+		hv.validateLockTime,
+		hv.validateSequenceLocks,
 	}
 
 	for _, validate := range validations {
-		if err := validate(tx); err != nil {
-			atomic.AddUint64(&hv.metrics.txMetrics.totalRejected, 1)
+		if err = validate(tx, utxos, height); err != nil {
 			return err
 		}
 	}
@@ -95,6 +135,7 @@ func (hv *HValidator) ValidateHeader(bh *kernel.BlockHeader) error {
 		hv.validateHeaderHeight,
 		hv.validateHeaderTarget,
 		hv.validateHeaderPreviousBlock,
+		hv.validateHeaderTimestamp,
 	}
 
 	for _, validate := range validations {
@@ -119,10 +160,6 @@ func (hv *HValidator) ValidateBlock(b *kernel.Block) error {
 	validations := []BlockFunc{
 		hv.validateBlockHash,
 		hv.ValidateBlockWithoutHash,
-
-		// todo(): validate block size limit
-		// todo(): validate coinbase transaction
-		// todo(): validate block timestamp
 	}
 
 	for _, validate := range validations {
@@ -144,12 +181,15 @@ func (hv *HValidator) ValidateBlockWithoutHash(b *kernel.Block) error {
 
 	validations := []BlockFunc{
 		hv.validateNumberOfCoinbaseTxs,
+		hv.validateCoinbaseIsFirstTx,
 		hv.validateNoDoubleSpendingInsideBlock,
 		hv.validateMerkleTree,
-
-		// todo(): validate block size limit
-		// todo(): validate coinbase transaction
-		// todo(): validate block timestamp
+		hv.validateCoinbaseAmount,
+		hv.validateBlockSize,
+		hv.validateNumberOfTxs,
+		hv.validateTxsWithinLimits,
+		hv.validateTxsLockTime,
+		hv.validateTxsScripts,
 	}
 
 	for _, validate := range validations {
@@ -161,37 +201,69 @@ func (hv *HValidator) ValidateBlockWithoutHash(b *kernel.Block) error {
 	return nil
 }
 
+// retrieveSpentUTXOs returns the outputs spent by the inputs of a transaction, in the same order as the inputs. Inputs
+// can spend confirmed outputs (UTXO set) or outputs of transactions contained in the mempool, which are considered to
+// be confirmed at the height provided (next block) at the earliest
+func (hv *HValidator) retrieveSpentUTXOs(tx *kernel.Transaction, height uint) ([]kernel.UTXO, error) {
+	utxos := make([]kernel.UTXO, 0, len(tx.Vin))
+	for _, vin := range tx.Vin {
+		utxo, err := hv.utxoSet.RetrieveUTXO(vin)
+		if err == nil {
+			utxos = append(utxos, utxo)
+			continue
+		}
+
+		output, err := hv.mempoolExplorer.RetrieveOutput(vin)
+		if err != nil {
+			return []kernel.UTXO{}, fmt.Errorf("input %x-%d spends an unknown or already spent output", vin.Txid, vin.Vout)
+		}
+
+		utxos = append(utxos, kernel.UTXO{TxID: vin.Txid, OutIdx: vin.Vout, Output: output, Height: height})
+	}
+
+	return utxos, nil
+}
+
+// CalculateTxFee returns the fee paid by the transaction, the difference between the balance of the outputs spent
+// (confirmed or part of the mempool) and the balance of the outputs created
+func (hv *HValidator) CalculateTxFee(tx *kernel.Transaction) (uint, error) {
+	utxos, err := hv.retrieveSpentUTXOs(tx, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	inputBalance := uint(0)
+	for _, utxo := range utxos {
+		inputBalance += utxo.Output.Amount
+	}
+
+	outputBalance := tx.OutputAmount()
+	if outputBalance > inputBalance {
+		return 0, fmt.Errorf("output balance %d is greater than input balance %d", outputBalance, inputBalance)
+	}
+
+	return inputBalance - outputBalance, nil
+}
+
 // validateOwnershipAndBalanceOfInputs checks that the inputs of a transaction are owned by the spender and that the
-// balance of the outputs is equal or smaller than the balance of the outputs
-func (hv *HValidator) validateOwnershipAndBalanceOfInputs(tx *kernel.Transaction) error {
-	// assume that we only use P2PK for now
+// balance of the outputs is equal or smaller than the balance of the outputs spent
+func (hv *HValidator) validateOwnershipAndBalanceOfInputs(tx *kernel.Transaction, utxos []kernel.UTXO, _ uint) error {
 	inputBalance := uint(0)
 	outputBalance := uint(0)
 
-	for _, vin := range tx.Vin {
-		// fetch the unspent outputs for the input's public key
-		// todo(): would make sense to add a check via UTXO set?
-		utxos, _ := hv.explorer.FindUnspentOutputs(vin.PubKey, explorer.RetrieveAllElements)
-		for _, utxo := range utxos {
-			// if there is match, check that the signature is valid
-			if utxo.EqualInput(vin) {
-				// todo(): assume is P2PK only for now
-
-				// check that the signature is valid for unlocking the UTXO
-				sigCheck, err := hv.interpreter.VerifyScriptPubKey(utxo.Output.ScriptPubKey, vin.ScriptSig, tx)
-				if err != nil {
-					return fmt.Errorf("error verifying signature: %s", err.Error())
-				}
-
-				if !sigCheck {
-					return fmt.Errorf("input with id %x and index %d has invalid signature", vin.Txid, vin.Vout)
-				}
+	for idx, vin := range tx.Vin {
+		// check that the signature is valid for unlocking the output
+		sigCheck, err := hv.interpreter.VerifyScriptPubKey(utxos[idx].Output, vin.ScriptSig, tx, uint(idx))
+		if err != nil {
+			return fmt.Errorf("error verifying signature: %s", err.Error())
+		}
 
-				// append the balance
-				inputBalance += utxo.Output.Amount
-				break
-			}
+		if !sigCheck {
+			return fmt.Errorf("input with id %x and index %d has invalid signature", vin.Txid, vin.Vout)
 		}
+
+		// append the balance
+		inputBalance += utxos[idx].Output.Amount
 	}
 
 	// retrieve the output balance
@@ -207,6 +279,87 @@ func (hv *HValidator) validateOwnershipAndBalanceOfInputs(tx *kernel.Transaction
 	return nil
 }
 
+// validateCoinbaseMaturity checks that the inputs of a transaction do not spend coinbase outputs that have not reached
+// the coinbase maturity at the height of the next block
+func (hv *HValidator) validateCoinbaseMaturity(tx *kernel.Transaction, utxos []kernel.UTXO, height uint) error {
+	for idx, vin := range tx.Vin {
+		if !utxos[idx].IsMature(height, hv.cfg.Chain.CoinbaseMaturity) {
+			return fmt.Errorf("input %x-%d spends immature coinbase output from height %d", vin.Txid, vin.Vout, utxos[idx].Height)
+		}
+	}
+
+	return nil
+}
+
+// validateLockTime checks that the transaction can be included in the next block based on its lock time
+func (hv *HValidator) validateLockTime(tx *kernel.Transaction, _ []kernel.UTXO, height uint) error {
+	return hv.validateTxIsFinal(tx, height)
+}
+
+// validateSequenceLocks checks that the relative lock times of the inputs of a transaction have passed at the height
+// of the next block
+func (hv *HValidator) validateSequenceLocks(tx *kernel.Transaction, utxos []kernel.UTXO, height uint) error {
+	for idx, vin := range tx.Vin {
+		if err := hv.validateSequenceLock(vin, utxos[idx].Height, height); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateSequenceLock checks that the relative lock time of the input has passed at the height provided since the
+// spent output was confirmed at utxoHeight. Lock times in seconds are compared using the median time past of both
+func (hv *HValidator) validateSequenceLock(vin kernel.TxInput, utxoHeight, height uint) error {
+	if !vin.HasRelativeLockTime() {
+		return nil
+	}
+
+	lockTime := kernel.SequenceLockTime(vin.Sequence)
+	if !kernel.IsSequenceLockTimeSeconds(vin.Sequence) {
+		if height < utxoHeight+lockTime {
+			return fmt.Errorf("input %x-%d is locked until height %d", vin.Txid, vin.Vout, utxoHeight+lockTime)
+		}
+
+		return nil
+	}
+
+	utxoMedianTimePast, err := hv.explorer.GetMedianTimePast(utxoHeight)
+	if err != nil {
+		return fmt.Errorf("error retrieving median time past for height %d: %w", utxoHeight, err)
+	}
+
+	medianTimePast, err := hv.explorer.GetMedianTimePast(height)
+	if err != nil {
+		return fmt.Errorf("error retrieving median time past for height %d: %w", height, err)
+	}
+
+	if medianTimePast < utxoMedianTimePast+int64(lockTime) {
+		return fmt.Errorf("input %x-%d is locked until time %d", vin.Txid, vin.Vout, utxoMedianTimePast+int64(lockTime))
+	}
+
+	return nil
+}
+
+// validateTxIsFinal checks that the lock time of the transaction has been reached at the height provided, time based
+// lock times are compared against the median time past of that height
+func (hv *HValidator) validateTxIsFinal(tx *kernel.Transaction, height uint) error {
+	if tx.LockTime == 0 {
+		return nil
+	}
+
+	medianTimePast, err := hv.explorer.GetMedianTimePast(height)
+	if err != nil {
+		return fmt.Errorf("error retrieving median time past for height %d: %w", height, err)
+	}
+
+	if !tx.IsFinal(height, medianTimePast) {
+		return fmt.Errorf("transaction %x is locked until %d (height %d, median time past %d)", tx.ID, tx.LockTime, height, medianTimePast)
+	}
+
+	return nil
+}
+
 // validateHeaderPreviousBlock checks that the previous block hash of the block matches the latest block
 func (hv *HValidator) validateHeaderPreviousBlock(bh *kernel.BlockHeader) error {
 	// if is genesis block and does not contain previous block hash, don't check previous block (does not exist)
@@ -274,7 +427,7 @@ func (hv *HValidator) validateHeaderHeight(bh *kernel.BlockHeader) error {
 
 // validateHeaderTarget checks that the target of the header is correct
 func (hv *HValidator) validateHeaderTarget(bh *kernel.BlockHeader) error {
-	targetExpected, err := hv.explorer.GetMiningTarget(bh.Height, hv.cfg.Miner.AdjustmentInterval, hv.cfg.Miner.MiningInterval)
+	targetExpected, err := hv.explorer.GetMiningTarget(bh.Height, bh.Version, hv.cfg.Miner.AdjustmentInterval, hv.cfg.Miner.MiningInterval)
 	if err != nil {
 		return fmt.Errorf("error while validating target: %w", err)
 	}
@@ -288,6 +441,71 @@ func (hv *HValidator) validateHeaderTarget(bh *kernel.BlockHeader) error {
 	return nil
 }
 
+// validateHeaderTimestamp checks that the timestamp of the header is greater than the median time past and that it is
+// not too far in the future. Otherwise, miners could manipulate the timestamps in order to lower the mining difficulty
+func (hv *HValidator) validateHeaderTimestamp(bh *kernel.BlockHeader) error {
+	maxTimestamp := hv.now().Add(hv.cfg.Chain.MaxFutureBlockTime).Unix()
+	if bh.Timestamp > maxTimestamp {
+		return fmt.Errorf("header timestamp %d is too far in the future, max allowed %d", bh.Timestamp, maxTimestamp)
+	}
+
+	// the genesis block has no previous blocks to compare with
+	if bh.IsGenesisHeader() {
+		return nil
+	}
+
+	medianTimePast, err := hv.explorer.GetMedianTimePast(bh.Height)
+	if err != nil {
+		return fmt.Errorf("error calculating median time past for height %d: %w", bh.Height, err)
+	}
+
+	if bh.Timestamp <= medianTimePast {
+		return fmt.Errorf("header timestamp %d is not greater than median time past %d", bh.Timestamp, medianTimePast)
+	}
+
+	return nil
+}
+
+// validateBlockSize checks that the size of the block does not exceed the consensus limit
+func (hv *HValidator) validateBlockSize(b *kernel.Block) error {
+	if b.Size() > hv.cfg.Chain.MaxBlockSize {
+		return fmt.Errorf("block %x has size %d, max allowed %d", b.Hash, b.Size(), hv.cfg.Chain.MaxBlockSize)
+	}
+
+	return nil
+}
+
+// validateNumberOfTxs checks that the number of transactions (including the coinbase) does not exceed the consensus limit
+func (hv *HValidator) validateNumberOfTxs(b *kernel.Block) error {
+	if uint(len(b.Transactions)) > hv.cfg.Chain.MaxTxsPerBlock {
+		return fmt.Errorf("block %x contains %d transactions, max allowed %d", b.Hash, len(b.Transactions), hv.cfg.Chain.MaxTxsPerBlock)
+	}
+
+	return nil
+}
+
+// validateTxsWithinLimits checks that every transaction in the block is within the consensus limits
+func (hv *HValidator) validateTxsWithinLimits(b *kernel.Block) error {
+	for _, tx := range b.Transactions {
+		if err := validateTxWithinLimits(tx, hv.cfg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateTxsLockTime checks that the lock time of every transaction in the block has been reached
+func (hv *HValidator) validateTxsLockTime(b *kernel.Block) error {
+	for _, tx := range b.Transactions {
+		if err := hv.validateTxIsFinal(tx, b.Header.Height); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // validateNumberOfCoinbaseTxs checks that there is only one coinbase transaction in a block. If there is more than
 // one coinbase transaction it means that there has been an error adding multiple coinbases or that there are
 // transactions with wrong number of inputs todo(): we may want to check this second case as well in the mempool
@@ -310,6 +528,143 @@ func (hv *HValidator) validateNumberOfCoinbaseTxs(b *kernel.Block) error {
 	return nil
 }
 
+// validateCoinbaseIsFirstTx checks that the coinbase transaction is the first transaction of the block
+func (hv *HValidator) validateCoinbaseIsFirstTx(b *kernel.Block) error {
+	if len(b.Transactions) == 0 || !b.Transactions[0].IsCoinbase() {
+		return fmt.Errorf("block %x does not contain the coinbase as first transaction", b.Hash)
+	}
+
+	return nil
+}
+
+// validateCoinbaseAmount checks that the outputs of the coinbase transaction don't exceed the subsidy that corresponds
+// to the block height plus the fees collected from the rest of transactions of the block
+func (hv *HValidator) validateCoinbaseAmount(b *kernel.Block) error {
+	fees, err := hv.calculateBlockFees(b)
+	if err != nil {
+		return fmt.Errorf("error calculating fees of block %x: %w", b.Hash, err)
+	}
+
+	coinbaseAmount := uint(0)
+	for _, vout := range b.Transactions[0].Vout {
+		coinbaseAmount += vout.Amount
+	}
+
+	subsidy := util.CalculateBlockSubsidy(b.Header.Height)
+	if coinbaseAmount > subsidy+fees {
+		return fmt.Errorf(
+			"block %x coinbase pays %d, exceeding subsidy %d plus fees %d by %d",
+			b.Hash, coinbaseAmount, subsidy, fees, coinbaseAmount-subsidy-fees,
+		)
+	}
+
+	return nil
+}
+
+// calculateBlockFees returns the sum of the fees paid by the non-coinbase transactions of the block. The amount of each
+// input is retrieved from the outputs created previously in the same block or from the unspent outputs of the chain
+func (hv *HValidator) calculateBlockFees(b *kernel.Block) (uint, error) {
+	fees := uint(0)
+	blockOutputs := map[string]kernel.TxOutput{}
+
+	for _, tx := range b.Transactions {
+		if tx.IsCoinbase() {
+			continue
+		}
+
+		inputBalance := uint(0)
+		for _, vin := range tx.Vin {
+			amount, err := hv.retrieveSpentAmount(vin, b.Header.Height, blockOutputs)
+			if err != nil {
+				return 0, fmt.Errorf("transaction %x: %w", tx.ID, err)
+			}
+
+			inputBalance += amount
+		}
+
+		outputBalance := uint(0)
+		for idx, vout := range tx.Vout {
+			outputBalance += vout.Amount
+			utxo := kernel.UTXO{TxID: tx.ID, OutIdx: uint(idx), Output: vout}
+			blockOutputs[utxo.UniqueKey()] = vout
+		}
+
+		if inputBalance < outputBalance {
+			return 0, fmt.Errorf("transaction %x spends %d but its inputs only contain %d", tx.ID, outputBalance, inputBalance)
+		}
+
+		fees += inputBalance - outputBalance
+	}
+
+	return fees, nil
+}
+
+// retrieveSpentAmount returns the amount of the output spent by the input, created either previously in the same block
+// or by a block already part of the chain (UTXO set). Coinbase outputs must be mature and the relative lock time of the
+// input must have passed at the height of the block
+func (hv *HValidator) retrieveSpentAmount(vin kernel.TxInput, height uint, blockOutputs map[string]kernel.TxOutput) (uint, error) {
+	if vout, ok := blockOutputs[vin.UniqueTxoKey()]; ok {
+		// outputs created in the same block are confirmed at the height of the block
+		if err := hv.validateSequenceLock(vin, height, height); err != nil {
+			return 0, err
+		}
+		return vout.Amount, nil
+	}
+
+	utxo, err := hv.utxoSet.RetrieveUTXO(vin)
+	if err != nil {
+		return 0, fmt.Errorf("input %x-%d spends an unknown or already spent output", vin.Txid, vin.Vout)
+	}
+
+	if !utxo.IsMature(height, hv.cfg.Chain.CoinbaseMaturity) {
+		return 0, fmt.Errorf("input %x-%d spends immature coinbase output from height %d", vin.Txid, vin.Vout, utxo.Height)
+	}
+
+	if err = hv.validateSequenceLock(vin, utxo.Height, height); err != nil {
+		return 0, err
+	}
+
+	return utxo.Output.Amount, nil
+}
+
+// validateTxsScripts checks that the inputs of the non-coinbase transactions of the block unlock the outputs they
+// spend. The outputs are retrieved from the ones created previously in the same block or from the unspent outputs of
+// the chain, the same way calculateBlockFees does
+func (hv *HValidator) validateTxsScripts(b *kernel.Block) error {
+	blockOutputs := map[string]kernel.TxOutput{}
+
+	for _, tx := range b.Transactions {
+		if !tx.IsCoinbase() {
+			for idx, vin := range tx.Vin {
+				output, ok := blockOutputs[vin.UniqueTxoKey()]
+				if !ok {
+					utxo, err := hv.utxoSet.RetrieveUTXO(vin)
+					if err != nil {
+						return fmt.Errorf("transaction %x: input %x-%d spends an unknown or already spent output", tx.ID, vin.Txid, vin.Vout)
+					}
+					output = utxo.Output
+				}
+
+				sigCheck, err := hv.interpreter.VerifyScriptPubKey(output, vin.ScriptSig, tx, uint(idx))
+				if err != nil {
+					return fmt.Errorf("transaction %x: error verifying signature: %w", tx.ID, err)
+				}
+
+				if !sigCheck {
+					return fmt.Errorf("transaction %x: input with id %x and index %d has invalid signature", tx.ID, vin.Txid, vin.Vout)
+				}
+			}
+		}
+
+		for idx, vout := range tx.Vout {
+			utxo := kernel.UTXO{TxID: tx.ID, OutIdx: uint(idx), Output: vout}
+			blockOutputs[utxo.UniqueKey()] = vout
+		}
+	}
+
+	return nil
+}
+
 // validateNoDoubleSpendingInsideBlock checks that there are no repeated inputs inside a block
 func (hv *HValidator) validateNoDoubleSpendingInsideBlock(b *kernel.Block) error {
 	// match every transaction with every other transaction
@@ -1,7 +1,9 @@
 package validator //nolint:testpackage // don't create separate package for tests
 
 import (
+	"os"
 	"testing"
+	"time"
 
 	"github.com/yago-123/chainnet/pkg/common"
 
@@ -9,9 +11,13 @@ import (
 
 	expl "github.com/yago-123/chainnet/pkg/chain/explorer"
 	"github.com/yago-123/chainnet/pkg/consensus"
+	"github.com/yago-123/chainnet/pkg/encoding"
 	"github.com/yago-123/chainnet/pkg/kernel"
+	"github.com/yago-123/chainnet/pkg/mempool"
 	"github.com/yago-123/chainnet/pkg/script"
+	"github.com/yago-123/chainnet/pkg/storage"
 	"github.com/yago-123/chainnet/pkg/util"
+	"github.com/yago-123/chainnet/pkg/utxoset"
 	mockHash "github.com/yago-123/chainnet/tests/mocks/crypto/hash"
 	mockSign "github.com/yago-123/chainnet/tests/mocks/crypto/sign"
 	mockStorage "github.com/yago-123/chainnet/tests/mocks/storage"
@@ -24,7 +30,7 @@ func TestHValidator_validateOwnershipAndBalanceOfInputs(_ *testing.T) {
 }
 
 func TestHValidator_validateNoCoinbaseAccepted(t *testing.T) {
-	hvalidator := NewHeavyValidator(config.NewConfig(), NewLightValidator(&mockHash.FakeHashing{}), expl.NewChainExplorer(&mockStorage.MockStorage{}, &mockHash.FakeHashing{}), &mockSign.MockSign{}, &mockHash.FakeHashing{})
+	hvalidator := NewHeavyValidator(config.NewConfig(), NewLightValidator(config.NewConfig(), &mockHash.FakeHashing{}), expl.NewChainExplorer(&mockStorage.MockStorage{}, &mockHash.FakeHashing{}), utxoset.NewUTXOSet(config.NewConfig()), emptyMemPoolExplorer(), &mockSign.MockSign{}, &mockHash.FakeHashing{})
 
 	require.Error(t, hvalidator.ValidateTx(kernel.NewCoinbaseTransaction("to", common.InitialCoinbaseReward, 0)))
 }
@@ -52,7 +58,7 @@ func TestHValidator_validateNumberOfCoinbaseTxs(t *testing.T) {
 		},
 	}
 
-	hvalidator := NewHeavyValidator(config.NewConfig(), NewLightValidator(&mockHash.FakeHashing{}), expl.NewChainExplorer(&mockStorage.MockStorage{}, &mockHash.FakeHashing{}), &mockSign.MockSign{}, &mockHash.FakeHashing{})
+	hvalidator := NewHeavyValidator(config.NewConfig(), NewLightValidator(config.NewConfig(), &mockHash.FakeHashing{}), expl.NewChainExplorer(&mockStorage.MockStorage{}, &mockHash.FakeHashing{}), utxoset.NewUTXOSet(config.NewConfig()), emptyMemPoolExplorer(), &mockSign.MockSign{}, &mockHash.FakeHashing{})
 
 	require.Error(t, hvalidator.validateNumberOfCoinbaseTxs(blockWithoutCoinbase))
 	require.Error(t, hvalidator.validateNumberOfCoinbaseTxs(blockWithTwoCoinbase))
@@ -77,7 +83,7 @@ func TestHValidator_validateNoDoubleSpendingInsideBlock(t *testing.T) {
 	}
 
 	fakeHashing := &mockHash.FakeHashing{}
-	hvalidator := NewHeavyValidator(config.NewConfig(), NewLightValidator(fakeHashing), expl.NewChainExplorer(&mockStorage.MockStorage{}, &mockHash.FakeHashing{}), &mockSign.MockSign{}, fakeHashing)
+	hvalidator := NewHeavyValidator(config.NewConfig(), NewLightValidator(config.NewConfig(), fakeHashing), expl.NewChainExplorer(&mockStorage.MockStorage{}, &mockHash.FakeHashing{}), utxoset.NewUTXOSet(config.NewConfig()), emptyMemPoolExplorer(), &mockSign.MockSign{}, fakeHashing)
 	require.Error(t, hvalidator.validateNoDoubleSpendingInsideBlock(blockWithDoubleSpending))
 	require.NoError(t, hvalidator.validateNoDoubleSpendingInsideBlock(blockWithoutDoubleSpending))
 }
@@ -102,7 +108,7 @@ func TestHValidator_validateBlockHash(t *testing.T) {
 	}
 
 	fakeHashing := &mockHash.FakeHashing{}
-	hvalidator := NewHeavyValidator(config.NewConfig(), NewLightValidator(fakeHashing), expl.NewChainExplorer(&mockStorage.MockStorage{}, &mockHash.FakeHashing{}), &mockSign.MockSign{}, fakeHashing)
+	hvalidator := NewHeavyValidator(config.NewConfig(), NewLightValidator(config.NewConfig(), fakeHashing), expl.NewChainExplorer(&mockStorage.MockStorage{}, &mockHash.FakeHashing{}), utxoset.NewUTXOSet(config.NewConfig()), emptyMemPoolExplorer(), &mockSign.MockSign{}, fakeHashing)
 
 	// check that the block hash corresponds to the target
 	require.NoError(t, hvalidator.validateBlockHash(block))
@@ -120,7 +126,7 @@ func TestHValidator_validateHeaderPreviousBlock(t *testing.T) {
 		On("GetLastHeader").
 		Return(mockHeader, nil)
 	fakeHashing := &mockHash.FakeHashing{}
-	hvalidator := NewHeavyValidator(config.NewConfig(), NewLightValidator(fakeHashing), expl.NewChainExplorer(mockStore, fakeHashing), &mockSign.MockSign{}, fakeHashing)
+	hvalidator := NewHeavyValidator(config.NewConfig(), NewLightValidator(config.NewConfig(), fakeHashing), expl.NewChainExplorer(mockStore, fakeHashing), utxoset.NewUTXOSet(config.NewConfig()), emptyMemPoolExplorer(), &mockSign.MockSign{}, fakeHashing)
 
 	// check that the previous block hash of the block matches the latest block
 	require.NoError(t, hvalidator.validateHeaderPreviousBlock(&kernel.BlockHeader{PrevBlockHash: append(mockHeader.Assemble(), []byte("-hashed")...), Height: 1}))
@@ -143,7 +149,7 @@ func TestHValidator_validateGenesisHeader(t *testing.T) {
 		On("GetLastHeader").
 		Return(mockHeader, nil)
 	fakeHashing := &mockHash.FakeHashing{}
-	hvalidator := NewHeavyValidator(config.NewConfig(), NewLightValidator(fakeHashing), expl.NewChainExplorer(mockStore, fakeHashing), &mockSign.MockSign{}, fakeHashing)
+	hvalidator := NewHeavyValidator(config.NewConfig(), NewLightValidator(config.NewConfig(), fakeHashing), expl.NewChainExplorer(mockStore, fakeHashing), utxoset.NewUTXOSet(config.NewConfig()), emptyMemPoolExplorer(), &mockSign.MockSign{}, fakeHashing)
 
 	// check that can be a single genesis block
 	require.Error(t, hvalidator.validateGenesisHeader(&kernel.BlockHeader{Height: 0, PrevBlockHash: []byte{}}))
@@ -156,7 +162,7 @@ func TestHValidator_validateBlockHeight(t *testing.T) {
 		Return(&kernel.BlockHeader{Height: 10}, nil)
 
 	fakeHashing := &mockHash.FakeHashing{}
-	hvalidator := NewHeavyValidator(config.NewConfig(), NewLightValidator(fakeHashing), expl.NewChainExplorer(mockStore, &mockHash.FakeHashing{}), &mockSign.MockSign{}, fakeHashing)
+	hvalidator := NewHeavyValidator(config.NewConfig(), NewLightValidator(config.NewConfig(), fakeHashing), expl.NewChainExplorer(mockStore, &mockHash.FakeHashing{}), utxoset.NewUTXOSet(config.NewConfig()), emptyMemPoolExplorer(), &mockSign.MockSign{}, fakeHashing)
 
 	// check that the block height matches the current chain height
 	require.NoError(t, hvalidator.validateHeaderHeight(&kernel.BlockHeader{Height: 11}))
@@ -206,7 +212,7 @@ func TestHValidator_validateMerkleTree(t *testing.T) {
 		Transactions: txs,
 	}
 
-	hvalidator := NewHeavyValidator(config.NewConfig(), NewLightValidator(&mockHash.FakeHashing{}), expl.NewChainExplorer(&mockStorage.MockStorage{}, &mockHash.FakeHashing{}), &mockSign.MockSign{}, &mockHash.FakeHashing{})
+	hvalidator := NewHeavyValidator(config.NewConfig(), NewLightValidator(config.NewConfig(), &mockHash.FakeHashing{}), expl.NewChainExplorer(&mockStorage.MockStorage{}, &mockHash.FakeHashing{}), utxoset.NewUTXOSet(config.NewConfig()), emptyMemPoolExplorer(), &mockSign.MockSign{}, &mockHash.FakeHashing{})
 
 	// verify correct merkle root does not generate error
 	require.NoError(t, hvalidator.validateMerkleTree(block))
@@ -215,3 +221,456 @@ func TestHValidator_validateMerkleTree(t *testing.T) {
 	block.Transactions[0].Vin[0].Txid = []byte("invalid")
 	require.Error(t, hvalidator.validateMerkleTree(block))
 }
+
+func TestHValidator_validateCoinbaseIsFirstTx(t *testing.T) {
+	coinbase := kernel.NewCoinbaseTransaction("to", common.InitialCoinbaseReward, 0)
+	regularTx := kernel.NewTransaction(
+		[]kernel.TxInput{kernel.NewInput([]byte("txid"), 0, "scriptSig", "pubKey")},
+		[]kernel.TxOutput{kernel.NewOutput(1, script.P2PK, "scriptPubKey")},
+	)
+
+	hvalidator := NewHeavyValidator(config.NewConfig(), NewLightValidator(config.NewConfig(), &mockHash.FakeHashing{}), expl.NewChainExplorer(&mockStorage.MockStorage{}, &mockHash.FakeHashing{}), utxoset.NewUTXOSet(config.NewConfig()), emptyMemPoolExplorer(), &mockSign.MockSign{}, &mockHash.FakeHashing{})
+
+	require.NoError(t, hvalidator.validateCoinbaseIsFirstTx(&kernel.Block{Transactions: []*kernel.Transaction{coinbase, regularTx}}))
+	require.Error(t, hvalidator.validateCoinbaseIsFirstTx(&kernel.Block{Transactions: []*kernel.Transaction{regularTx, coinbase}}))
+	require.Error(t, hvalidator.validateCoinbaseIsFirstTx(&kernel.Block{Transactions: []*kernel.Transaction{}}))
+}
+
+func TestHValidator_validateCoinbaseAmount(t *testing.T) {
+	boltdb, err := storage.NewBoltDB("temp-file-coinbase", "block-bucket", "header-bucket", encoding.NewGobEncoder())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = boltdb.Close()
+		_ = os.Remove("temp-file-coinbase")
+	})
+
+	// persist a chain in which alice owns an unspent output of 10 coins
+	genesis := &kernel.Block{
+		Header:       &kernel.BlockHeader{PrevBlockHash: []byte{}, Height: 0},
+		Transactions: []*kernel.Transaction{{ID: []byte("coinbase-genesis"), Vin: []kernel.TxInput{kernel.NewCoinbaseInput()}, Vout: []kernel.TxOutput{kernel.NewCoinbaseOutput(common.InitialCoinbaseReward, script.P2PK, "bob")}}},
+		Hash:         []byte("genesis-hash"),
+	}
+	block1 := &kernel.Block{
+		Header:       &kernel.BlockHeader{PrevBlockHash: genesis.Hash, Height: 1},
+		Transactions: []*kernel.Transaction{{ID: []byte("coinbase-block-1"), Vin: []kernel.TxInput{kernel.NewCoinbaseInput()}, Vout: []kernel.TxOutput{kernel.NewCoinbaseOutput(10, script.P2PK, "alice")}}},
+		Hash:         []byte("block-1-hash"),
+	}
+	require.NoError(t, boltdb.PersistBlock(*genesis))
+	require.NoError(t, boltdb.PersistBlock(*block1))
+
+	cfg := config.NewConfig()
+	cfg.Chain.CoinbaseMaturity = 1
+	utxos := utxoset.NewUTXOSet(cfg)
+	require.NoError(t, utxos.AddBlock(genesis))
+	require.NoError(t, utxos.AddBlock(block1))
+	hvalidator := NewHeavyValidator(cfg, NewLightValidator(config.NewConfig(), &mockHash.FakeHashing{}), expl.NewChainExplorer(boltdb, &mockHash.FakeHashing{}), utxos, emptyMemPoolExplorer(), &mockSign.MockSign{}, &mockHash.FakeHashing{})
+
+	// alice pays 3 in fees in the first transaction and 1 more in a transaction spending an output of the same block
+	aliceTx := &kernel.Transaction{
+		ID:   []byte("alice-tx"),
+		Vin:  []kernel.TxInput{kernel.NewInput(block1.Transactions[0].ID, 0, "scriptSig", "alice")},
+		Vout: []kernel.TxOutput{kernel.NewOutput(7, script.P2PK, "carol")},
+	}
+	carolTx := &kernel.Transaction{
+		ID:   []byte("carol-tx"),
+		Vin:  []kernel.TxInput{kernel.NewInput(aliceTx.ID, 0, "scriptSig", "carol")},
+		Vout: []kernel.TxOutput{kernel.NewOutput(6, script.P2PK, "dave")},
+	}
+
+	newBlock := func(height, coinbaseAmount uint, txs ...*kernel.Transaction) *kernel.Block {
+		coinbase := kernel.NewCoinbaseTransaction("miner", coinbaseAmount, 0)
+		return &kernel.Block{
+			Header:       &kernel.BlockHeader{Height: height},
+			Transactions: append([]*kernel.Transaction{coinbase}, txs...),
+		}
+	}
+
+	// coinbase can claim the subsidy plus the fees collected
+	require.NoError(t, hvalidator.validateCoinbaseAmount(newBlock(2, common.InitialCoinbaseReward)))
+	require.NoError(t, hvalidator.validateCoinbaseAmount(newBlock(2, common.InitialCoinbaseReward+4, aliceTx, carolTx)))
+	require.NoError(t, hvalidator.validateCoinbaseAmount(newBlock(2, common.InitialCoinbaseReward+3, aliceTx, carolTx)))
+
+	// coinbase can't claim more than the subsidy plus the fees collected
+	require.Error(t, hvalidator.validateCoinbaseAmount(newBlock(2, common.InitialCoinbaseReward+1)))
+	require.Error(t, hvalidator.validateCoinbaseAmount(newBlock(2, common.InitialCoinbaseReward+5, aliceTx, carolTx)))
+
+	// the subsidy is halved after the halving interval
+	require.NoError(t, hvalidator.validateCoinbaseAmount(newBlock(common.HalvingInterval, common.InitialCoinbaseReward/2)))
+	require.Error(t, hvalidator.validateCoinbaseAmount(newBlock(common.HalvingInterval, common.InitialCoinbaseReward/2+1)))
+
+	// transactions spending unknown outputs or more than their inputs are rejected
+	unknownInputTx := &kernel.Transaction{
+		ID:   []byte("unknown-input-tx"),
+		Vin:  []kernel.TxInput{kernel.NewInput([]byte("unknown"), 0, "scriptSig", "alice")},
+		Vout: []kernel.TxOutput{kernel.NewOutput(1, script.P2PK, "carol")},
+	}
+	overspendingTx := &kernel.Transaction{
+		ID:   []byte("overspending-tx"),
+		Vin:  []kernel.TxInput{kernel.NewInput(block1.Transactions[0].ID, 0, "scriptSig", "alice")},
+		Vout: []kernel.TxOutput{kernel.NewOutput(11, script.P2PK, "carol")},
+	}
+	require.Error(t, hvalidator.validateCoinbaseAmount(newBlock(2, common.InitialCoinbaseReward, unknownInputTx)))
+	require.Error(t, hvalidator.validateCoinbaseAmount(newBlock(2, common.InitialCoinbaseReward, overspendingTx)))
+}
+
+func TestHValidator_validateCoinbaseMaturity(t *testing.T) {
+	boltdb, err := storage.NewBoltDB("temp-file-maturity", "block-bucket", "header-bucket", encoding.NewGobEncoder())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = boltdb.Close()
+		_ = os.Remove("temp-file-maturity")
+	})
+
+	// persist a chain in which alice owns the coinbase output of block 1
+	genesis := &kernel.Block{
+		Header:       &kernel.BlockHeader{PrevBlockHash: []byte{}, Height: 0},
+		Transactions: []*kernel.Transaction{{ID: []byte("coinbase-genesis"), Vin: []kernel.TxInput{kernel.NewCoinbaseInput()}, Vout: []kernel.TxOutput{kernel.NewCoinbaseOutput(common.InitialCoinbaseReward, script.P2PK, "bob")}}},
+		Hash:         []byte("genesis-hash"),
+	}
+	block1 := &kernel.Block{
+		Header:       &kernel.BlockHeader{PrevBlockHash: genesis.Hash, Height: 1},
+		Transactions: []*kernel.Transaction{{ID: []byte("coinbase-block-1"), Vin: []kernel.TxInput{kernel.NewCoinbaseInput()}, Vout: []kernel.TxOutput{kernel.NewCoinbaseOutput(10, script.P2PK, "alice")}}},
+		Hash:         []byte("block-1-hash"),
+	}
+	require.NoError(t, boltdb.PersistBlock(*genesis))
+	require.NoError(t, boltdb.PersistBlock(*block1))
+	require.NoError(t, boltdb.PersistHeader(block1.Hash, *block1.Header))
+
+	utxos := utxoset.NewUTXOSet(config.NewConfig())
+	require.NoError(t, utxos.AddBlock(genesis))
+	require.NoError(t, utxos.AddBlock(block1))
+
+	aliceTx := &kernel.Transaction{
+		ID:   []byte("alice-tx"),
+		Vin:  []kernel.TxInput{kernel.NewInput(block1.Transactions[0].ID, 0, "scriptSig", "alice")},
+		Vout: []kernel.TxOutput{kernel.NewOutput(10, script.P2PK, "carol")},
+	}
+
+	newValidator := func(maturity uint) *HValidator {
+		cfg := config.NewConfig()
+		cfg.Chain.CoinbaseMaturity = maturity
+		return NewHeavyValidator(cfg, NewLightValidator(config.NewConfig(), &mockHash.FakeHashing{}), expl.NewChainExplorer(boltdb, &mockHash.FakeHashing{}), utxos, emptyMemPoolExplorer(), &mockSign.MockSign{}, &mockHash.FakeHashing{})
+	}
+
+	// the next block has height 2, so the coinbase of block 1 only has 1 confirmation
+	validateMaturity := func(hvalidator *HValidator, tx *kernel.Transaction) error {
+		spent, err := hvalidator.retrieveSpentUTXOs(tx, 2)
+		require.NoError(t, err)
+		return hvalidator.validateCoinbaseMaturity(tx, spent, 2)
+	}
+
+	newBlock := func(height uint, txs ...*kernel.Transaction) *kernel.Block {
+		coinbase := kernel.NewCoinbaseTransaction("miner", util.CalculateBlockSubsidy(height), 0)
+		return &kernel.Block{
+			Header:       &kernel.BlockHeader{Height: height},
+			Transactions: append([]*kernel.Transaction{coinbase}, txs...),
+		}
+	}
+
+	require.NoError(t, validateMaturity(newValidator(1), aliceTx))
+	require.Error(t, validateMaturity(newValidator(2), aliceTx))
+
+	// same checks when the transaction is included in a block
+	require.NoError(t, newValidator(1).validateCoinbaseAmount(newBlock(2, aliceTx)))
+	require.Error(t, newValidator(2).validateCoinbaseAmount(newBlock(2, aliceTx)))
+	require.NoError(t, newValidator(2).validateCoinbaseAmount(newBlock(3, aliceTx)))
+}
+
+func TestHValidator_validateHeaderTimestamp(t *testing.T) {
+	boltdb, err := storage.NewBoltDB("temp-file-timestamp", "block-bucket", "header-bucket", encoding.NewGobEncoder())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = boltdb.Close()
+		_ = os.Remove("temp-file-timestamp")
+	})
+
+	// persist a chain with timestamps 100, 200, 300, 400 and 500
+	prevHash := []byte{}
+	for height := range uint(5) {
+		block := &kernel.Block{
+			Header: &kernel.BlockHeader{PrevBlockHash: prevHash, Height: height, Timestamp: int64(100 * (height + 1))},
+			Hash:   []byte{byte(height)},
+		}
+		require.NoError(t, boltdb.PersistBlock(*block))
+		require.NoError(t, boltdb.PersistHeader(block.Hash, *block.Header))
+		prevHash = block.Hash
+	}
+
+	cfg := config.NewConfig()
+	cfg.Chain.MaxFutureBlockTime = time.Hour
+	hvalidator := NewHeavyValidator(cfg, NewLightValidator(config.NewConfig(), &mockHash.FakeHashing{}), expl.NewChainExplorer(boltdb, &mockHash.FakeHashing{}), utxoset.NewUTXOSet(config.NewConfig()), emptyMemPoolExplorer(), &mockSign.MockSign{}, &mockHash.FakeHashing{})
+
+	// fake clock so the future drift limit is deterministic
+	now := time.Unix(1000, 0)
+	hvalidator.now = func() time.Time { return now }
+
+	newHeader := func(timestamp int64) *kernel.BlockHeader {
+		return &kernel.BlockHeader{PrevBlockHash: prevHash, Height: 5, Timestamp: timestamp}
+	}
+
+	// the median time past of the next block is 300
+	require.Error(t, hvalidator.validateHeaderTimestamp(newHeader(250)))
+	require.Error(t, hvalidator.validateHeaderTimestamp(newHeader(300)))
+	require.NoError(t, hvalidator.validateHeaderTimestamp(newHeader(301)))
+
+	// timestamps can't be further than one hour ahead of the local clock
+	require.NoError(t, hvalidator.validateHeaderTimestamp(newHeader(now.Add(time.Hour).Unix())))
+	require.Error(t, hvalidator.validateHeaderTimestamp(newHeader(now.Add(time.Hour).Unix()+1)))
+
+	// the limit moves along with the clock
+	now = now.Add(time.Minute)
+	require.NoError(t, hvalidator.validateHeaderTimestamp(newHeader(now.Add(time.Hour).Unix())))
+
+	// the genesis block only checks the future drift limit
+	require.NoError(t, hvalidator.validateHeaderTimestamp(&kernel.BlockHeader{PrevBlockHash: []byte{}, Height: 0, Timestamp: 0}))
+	require.Error(t, hvalidator.validateHeaderTimestamp(&kernel.BlockHeader{PrevBlockHash: []byte{}, Height: 0, Timestamp: now.Add(2 * time.Hour).Unix()}))
+}
+
+func TestHValidator_validateLockTime(t *testing.T) {
+	boltdb, err := storage.NewBoltDB("temp-file-locktime", "block-bucket", "header-bucket", encoding.NewGobEncoder())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = boltdb.Close()
+		_ = os.Remove("temp-file-locktime")
+	})
+
+	// persist a chain with 5 blocks, the timestamps are above the lock time threshold so can be used as Unix times
+	prevHash := []byte{}
+	for height := range uint(5) {
+		block := &kernel.Block{
+			Header: &kernel.BlockHeader{PrevBlockHash: prevHash, Height: height, Timestamp: int64(kernel.LockTimeThreshold + 100*(height+1))},
+			Hash:   []byte{byte(height)},
+		}
+		require.NoError(t, boltdb.PersistBlock(*block))
+		require.NoError(t, boltdb.PersistHeader(block.Hash, *block.Header))
+		prevHash = block.Hash
+	}
+
+	hvalidator := NewHeavyValidator(config.NewConfig(), NewLightValidator(config.NewConfig(), &mockHash.FakeHashing{}), expl.NewChainExplorer(boltdb, &mockHash.FakeHashing{}), utxoset.NewUTXOSet(config.NewConfig()), emptyMemPoolExplorer(), &mockSign.MockSign{}, &mockHash.FakeHashing{})
+
+	newTx := func(lockTime uint) *kernel.Transaction {
+		return kernel.NewTransactionWithLockTime(
+			[]kernel.TxInput{kernel.NewInput([]byte("tx-id"), 0, "scriptSig", "alice")},
+			[]kernel.TxOutput{kernel.NewOutput(1, script.P2PK, "bob")},
+			lockTime,
+		)
+	}
+
+	// the next block has height 5 and median time past of threshold + 300
+	require.NoError(t, hvalidator.validateLockTime(newTx(0), nil, 5))
+	require.NoError(t, hvalidator.validateLockTime(newTx(5), nil, 5))
+	require.Error(t, hvalidator.validateLockTime(newTx(6), nil, 5))
+	require.NoError(t, hvalidator.validateLockTime(newTx(kernel.LockTimeThreshold+300), nil, 5))
+	require.Error(t, hvalidator.validateLockTime(newTx(kernel.LockTimeThreshold+301), nil, 5))
+
+	// transactions inside blocks are checked against the height of the block
+	newBlock := func(height uint, tx *kernel.Transaction) *kernel.Block {
+		return &kernel.Block{Header: &kernel.BlockHeader{Height: height}, Transactions: []*kernel.Transaction{tx}}
+	}
+	require.NoError(t, hvalidator.validateTxsLockTime(newBlock(5, newTx(5))))
+	require.Error(t, hvalidator.validateTxsLockTime(newBlock(4, newTx(5))))
+	require.Error(t, hvalidator.validateTxsLockTime(newBlock(5, newTx(kernel.LockTimeThreshold+301))))
+}
+
+func TestHValidator_validateSequenceLocks(t *testing.T) {
+	boltdb, err := storage.NewBoltDB("temp-file-sequence", "block-bucket", "header-bucket", encoding.NewGobEncoder())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = boltdb.Close()
+		_ = os.Remove("temp-file-sequence")
+	})
+
+	cfg := config.NewConfig()
+	cfg.Chain.CoinbaseMaturity = 1
+	utxos := utxoset.NewUTXOSet(cfg)
+
+	// persist a chain with timestamps 100, 200, 300 and 400 in which alice owns the coinbase output of block 1
+	prevHash := []byte{}
+	for height := range uint(4) {
+		owner := "bob"
+		if height == 1 {
+			owner = "alice"
+		}
+		block := &kernel.Block{
+			Header:       &kernel.BlockHeader{PrevBlockHash: prevHash, Height: height, Timestamp: int64(100 * (height + 1))},
+			Transactions: []*kernel.Transaction{{ID: []byte{'c', byte(height)}, Vin: []kernel.TxInput{kernel.NewCoinbaseInput()}, Vout: []kernel.TxOutput{kernel.NewCoinbaseOutput(10, script.P2PK, owner)}}},
+			Hash:         []byte{byte(height)},
+		}
+		require.NoError(t, boltdb.PersistBlock(*block))
+		require.NoError(t, boltdb.PersistHeader(block.Hash, *block.Header))
+		require.NoError(t, utxos.AddBlock(block))
+		prevHash = block.Hash
+	}
+
+	hvalidator := NewHeavyValidator(cfg, NewLightValidator(config.NewConfig(), &mockHash.FakeHashing{}), expl.NewChainExplorer(boltdb, &mockHash.FakeHashing{}), utxos, emptyMemPoolExplorer(), &mockSign.MockSign{}, &mockHash.FakeHashing{})
+
+	newTx := func(sequence uint) *kernel.Transaction {
+		return &kernel.Transaction{
+			ID:   []byte("alice-tx"),
+			Vin:  []kernel.TxInput{kernel.NewInputWithSequence([]byte{'c', 1}, 0, "scriptSig", "alice", sequence)},
+			Vout: []kernel.TxOutput{kernel.NewOutput(10, script.P2PK, "carol")},
+		}
+	}
+
+	validateSequenceLocks := func(tx *kernel.Transaction) error {
+		spent, err := hvalidator.retrieveSpentUTXOs(tx, 4)
+		require.NoError(t, err)
+		return hvalidator.validateSequenceLocks(tx, spent, 4)
+	}
+
+	// the output was confirmed at height 1 and the next block has height 4
+	require.NoError(t, validateSequenceLocks(newTx(0)))
+	require.NoError(t, validateSequenceLocks(newTx(3)))
+	require.Error(t, validateSequenceLocks(newTx(4)))
+
+	// the median time past of the output block is 100 and the one of the next block is 300
+	require.NoError(t, validateSequenceLocks(newTx(kernel.SequenceLockTimeTypeFlag|200)))
+	require.Error(t, validateSequenceLocks(newTx(kernel.SequenceLockTimeTypeFlag|201)))
+
+	// same checks when the transaction is included in a block
+	newBlock := func(height uint, txs ...*kernel.Transaction) *kernel.Block {
+		coinbase := kernel.NewCoinbaseTransaction("miner", util.CalculateBlockSubsidy(height), 0)
+		return &kernel.Block{
+			Header:       &kernel.BlockHeader{Height: height},
+			Transactions: append([]*kernel.Transaction{coinbase}, txs...),
+		}
+	}
+	require.NoError(t, hvalidator.validateCoinbaseAmount(newBlock(4, newTx(3))))
+	require.Error(t, hvalidator.validateCoinbaseAmount(newBlock(4, newTx(4))))
+
+	// outputs created in the same block have no confirmations
+	childTx := &kernel.Transaction{
+		ID:   []byte("child-tx"),
+		Vin:  []kernel.TxInput{kernel.NewInputWithSequence([]byte("alice-tx"), 0, "scriptSig", "carol", 1)},
+		Vout: []kernel.TxOutput{kernel.NewOutput(10, script.P2PK, "dave")},
+	}
+	require.Error(t, hvalidator.validateCoinbaseAmount(newBlock(4, newTx(0), childTx)))
+}
+
+func TestHValidator_validateBlockLimits(t *testing.T) {
+	cfg := config.NewConfig()
+	hvalidator := NewHeavyValidator(cfg, NewLightValidator(cfg, &mockHash.FakeHashing{}), expl.NewChainExplorer(&mockStorage.MockStorage{}, &mockHash.FakeHashing{}), utxoset.NewUTXOSet(config.NewConfig()), emptyMemPoolExplorer(), &mockSign.MockSign{}, &mockHash.FakeHashing{})
+
+	coinbase := kernel.NewCoinbaseTransaction("pubkey", 50, 0)
+	tx := kernel.NewTransaction(
+		[]kernel.TxInput{kernel.NewInput([]byte("tx-id"), 0, "scriptSig", "pubkey")},
+		[]kernel.TxOutput{kernel.NewOutput(1, script.P2PK, "pubkey-2"), kernel.NewOutput(1, script.P2PK, "pubkey-3")},
+	)
+	block := &kernel.Block{
+		Header:       &kernel.BlockHeader{Version: []byte("1"), MerkleRoot: []byte("merkle-root")},
+		Transactions: []*kernel.Transaction{coinbase, tx},
+		Hash:         []byte("block-hash"),
+	}
+
+	// block size
+	cfg.Chain.MaxBlockSize = block.Size()
+	require.NoError(t, hvalidator.validateBlockSize(block))
+	cfg.Chain.MaxBlockSize = block.Size() - 1
+	require.Error(t, hvalidator.validateBlockSize(block))
+
+	// number of transactions, including the coinbase
+	cfg.Chain.MaxTxsPerBlock = 2
+	require.NoError(t, hvalidator.validateNumberOfTxs(block))
+	cfg.Chain.MaxTxsPerBlock = 1
+	require.Error(t, hvalidator.validateNumberOfTxs(block))
+
+	// transactions inside the block are checked against the transaction limits too
+	require.NoError(t, hvalidator.validateTxsWithinLimits(block))
+	cfg.Chain.MaxTxOutputs = 1
+	require.Error(t, hvalidator.validateTxsWithinLimits(block))
+}
+
+func TestHValidator_retrieveSpentUTXOs(t *testing.T) {
+	cfg := config.NewConfig()
+	utxos := utxoset.NewUTXOSet(cfg)
+	block := &kernel.Block{
+		Header:       &kernel.BlockHeader{Height: 3},
+		Transactions: []*kernel.Transaction{{ID: []byte("coinbase"), Vin: []kernel.TxInput{kernel.NewCoinbaseInput()}, Vout: []kernel.TxOutput{kernel.NewCoinbaseOutput(10, script.P2PK, "alice")}}},
+		Hash:         []byte("block-hash"),
+	}
+	require.NoError(t, utxos.AddBlock(block))
+
+	memPool := mempool.NewMemPool(config.DefaultMaxMempoolSize, 0)
+	unconfirmedTx := &kernel.Transaction{
+		ID:   []byte("unconfirmed-tx"),
+		Vin:  []kernel.TxInput{kernel.NewInput([]byte("coinbase"), 0, "scriptSig", "alice")},
+		Vout: []kernel.TxOutput{kernel.NewOutput(9, script.P2PK, "bob")},
+	}
+	require.NoError(t, memPool.AppendTransaction(unconfirmedTx, 1))
+
+	hvalidator := NewHeavyValidator(cfg, NewLightValidator(cfg, &mockHash.FakeHashing{}), expl.NewChainExplorer(&mockStorage.MockStorage{}, &mockHash.FakeHashing{}), utxos, mempool.NewMemPoolExplorer(memPool), &mockSign.MockSign{}, &mockHash.FakeHashing{})
+
+	// confirmed outputs keep the height and type from the UTXO set, unconfirmed ones are confirmed in the next block
+	tx := &kernel.Transaction{
+		Vin: []kernel.TxInput{
+			kernel.NewInput([]byte("coinbase"), 0, "scriptSig", "alice"),
+			kernel.NewInput([]byte("unconfirmed-tx"), 0, "scriptSig", "bob"),
+		},
+	}
+	spent, err := hvalidator.retrieveSpentUTXOs(tx, 5)
+	require.NoError(t, err)
+	require.Len(t, spent, 2)
+	require.Equal(t, uint(3), spent[0].Height)
+	require.True(t, spent[0].Coinbase)
+	require.Equal(t, uint(5), spent[1].Height)
+	require.False(t, spent[1].Coinbase)
+	require.Equal(t, uint(9), spent[1].Output.Amount)
+
+	tx.Vin = append(tx.Vin, kernel.NewInput([]byte("unknown"), 0, "scriptSig", "alice"))
+	_, err = hvalidator.retrieveSpentUTXOs(tx, 5)
+	require.Error(t, err)
+}
+
+func TestHValidator_validateTxsScripts(t *testing.T) {
+	cfg := config.NewConfig()
+	utxos := utxoset.NewUTXOSet(cfg)
+
+	// the output can't be spent until height 100
+	lockedOutput := kernel.TxOutput{Amount: 50, ScriptPubKey: script.NewTimeLockScript(100, []byte("alice")), PubKey: "alice"}
+	require.NoError(t, utxos.AddBlock(&kernel.Block{
+		Header:       &kernel.BlockHeader{Height: 3},
+		Transactions: []*kernel.Transaction{{ID: []byte("coinbase"), Vin: []kernel.TxInput{kernel.NewCoinbaseInput()}, Vout: []kernel.TxOutput{lockedOutput}}},
+		Hash:         []byte("block-hash"),
+	}))
+
+	hvalidator := NewHeavyValidator(cfg, NewLightValidator(cfg, &mockHash.FakeHashing{}), expl.NewChainExplorer(&mockStorage.MockStorage{}, &mockHash.FakeHashing{}), utxos, emptyMemPoolExplorer(), &mockSign.MockSign{}, &mockHash.FakeHashing{})
+
+	newBlock := func(lockTime uint) *kernel.Block {
+		tx := kernel.NewTransactionWithLockTime(
+			[]kernel.TxInput{kernel.NewInput([]byte("coinbase"), 0, "", "alice")},
+			[]kernel.TxOutput{kernel.NewOutput(40, script.P2PK, "bob")},
+			lockTime,
+		)
+		scriptSig, err := hvalidator.interpreter.GenerateScriptSig(lockedOutput, []byte("alice"), []byte("alice-priv"), tx, 0, kernel.SighashAll)
+		require.NoError(t, err)
+		tx.Vin[0].ScriptSig = scriptSig
+		tx.SetID([]byte("spend-locked"))
+
+		// spends the output created previously in the same block
+		child := kernel.NewTransaction(
+			[]kernel.TxInput{kernel.NewInput([]byte("spend-locked"), 0, "", "bob")},
+			[]kernel.TxOutput{kernel.NewOutput(30, script.P2PK, "carol")},
+		)
+		scriptSig, err = hvalidator.interpreter.GenerateScriptSig(tx.Vout[0], []byte("bob"), []byte("bob-priv"), child, 0, kernel.SighashAll)
+		require.NoError(t, err)
+		child.Vin[0].ScriptSig = scriptSig
+		child.SetID([]byte("child"))
+
+		return &kernel.Block{Header: &kernel.BlockHeader{Height: 100}, Transactions: []*kernel.Transaction{tx, child}}
+	}
+
+	require.NoError(t, hvalidator.validateTxsScripts(newBlock(100)))
+
+	// the block spends the locked output before its lock time
+	require.Error(t, hvalidator.validateTxsScripts(newBlock(99)))
+
+	// the scriptSig does not unlock the output spent
+	block := newBlock(100)
+	block.Transactions[1].Vin[0].ScriptSig = block.Transactions[0].Vin[0].ScriptSig
+	require.Error(t, hvalidator.validateTxsScripts(block))
+}
+
+// emptyMemPoolExplorer returns the explorer of an empty mempool, so that inputs can only spend confirmed outputs
+func emptyMemPoolExplorer() *mempool.MemPoolExplorer {
+	return mempool.NewMemPoolExplorer(mempool.NewMemPool(config.DefaultMaxMempoolSize, 0))
+}
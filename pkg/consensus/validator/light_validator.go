@@ -4,18 +4,24 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/yago-123/chainnet/config"
+
 	"github.com/yago-123/chainnet/pkg/crypto/hash"
 	"github.com/yago-123/chainnet/pkg/kernel"
+	"github.com/yago-123/chainnet/pkg/script"
 	"github.com/yago-123/chainnet/pkg/util"
 )
 
 type LValidator struct {
 	hasher hash.Hashing
+
+	cfg *config.Config
 }
 
-func NewLightValidator(hasher hash.Hashing) *LValidator {
+func NewLightValidator(cfg *config.Config, hasher hash.Hashing) *LValidator {
 	return &LValidator{
 		hasher: hasher,
+		cfg:    cfg,
 	}
 }
 
@@ -31,11 +37,11 @@ func (lv *LValidator) ValidateTxLight(tx *kernel.Transaction) error {
 	}
 
 	validations := []TxFunc{
+		lv.validateTxVersion,
 		lv.validateInputsDontMatch,
 		lv.validateTxID,
 		lv.validateAllOutputsContainNonZeroAmounts,
-		// todo(): set limit to the number of inputs and outputs
-		// todo(): make sure that transaction size is within limits
+		lv.validateTxWithinLimits,
 		// todo(): make sure number of sigops is within limits
 	}
 
@@ -84,16 +90,40 @@ func (lv *LValidator) validateHeaderHash(bh *kernel.BlockHeader) error {
 		return fmt.Errorf("error calculating header headerHash: %w", err)
 	}
 
-	if !util.IsFirstNBitsZero(headerHash, bh.Target) {
+	// compact targets can't be easier than the pow limit, otherwise the work would be close to none
+	if bh.HasCompactTarget() {
+		target := util.HeaderTarget(bh)
+		if target.Sign() <= 0 || target.Cmp(util.PowLimit) > 0 {
+			return fmt.Errorf("block %x has target %x out of range", headerHash, bh.Target)
+		}
+	}
+
+	if !util.IsProofOfWorkValid(headerHash, bh) {
 		return fmt.Errorf("block %x has invalid target %d", headerHash, bh.Target)
 	}
 
 	return nil
 }
 
-// validateVersion makes sure that the header version is correct (to be developed)
-func (lv *LValidator) validateVersion(_ *kernel.BlockHeader) error {
-	return nil
+// validateVersion makes sure that the header version is known, the version determines how the target is interpreted
+func (lv *LValidator) validateVersion(bh *kernel.BlockHeader) error {
+	switch string(bh.Version) {
+	case kernel.BlockVersionLeadingZerosTarget, kernel.BlockVersionCompactTarget, kernel.BlockVersionCanonicalSerialization:
+		return nil
+	default:
+		return fmt.Errorf("unknown block version %q", bh.Version)
+	}
+}
+
+// validateTxVersion makes sure that the transaction version is known, the version determines how the transaction is
+// serialized for calculating the ID
+func (lv *LValidator) validateTxVersion(tx *kernel.Transaction) error {
+	switch tx.Version {
+	case kernel.TxVersionLegacy, kernel.TxVersionCanonicalSerialization:
+		return nil
+	default:
+		return fmt.Errorf("transaction %x has unknown version %d", tx.ID, tx.Version)
+	}
 }
 
 // validateInputsDontMatch checks that the inputs don't match creating double spending problems
@@ -114,13 +144,68 @@ func (lv *LValidator) validateTxID(tx *kernel.Transaction) error {
 	return util.VerifyTxHash(tx, tx.ID, lv.hasher)
 }
 
-// validateAllOutputsContainNonZeroAmounts make sure that no empty outputs can be accepted
+// validateAllOutputsContainNonZeroAmounts make sure that no empty outputs can be accepted. Data carrier outputs
+// (OP_RETURN) are the exception given that they never enter the UTXO set
 func (lv *LValidator) validateAllOutputsContainNonZeroAmounts(tx *kernel.Transaction) error {
 	for i, out := range tx.Vout {
-		if out.Amount == 0 {
+		if out.Amount == 0 && !script.IsUnspendable(out.ScriptPubKey) {
 			return fmt.Errorf("transaction %x contain output %d empty", tx.ID, i)
 		}
 	}
 
 	return nil
 }
+
+// validateTxWithinLimits makes sure that the transaction does not exceed the consensus limits
+func (lv *LValidator) validateTxWithinLimits(tx *kernel.Transaction) error {
+	return validateTxWithinLimits(tx, lv.cfg)
+}
+
+// validateTxWithinLimits checks the number of inputs, the number of outputs, the size of the transaction and the size
+// of the data carried against the consensus limits. Shared by both validators so that transactions inside blocks are
+// checked too
+func validateTxWithinLimits(tx *kernel.Transaction, cfg *config.Config) error {
+	if uint(len(tx.Vin)) > cfg.Chain.MaxTxInputs {
+		return fmt.Errorf("transaction %x has %d inputs, max allowed %d", tx.ID, len(tx.Vin), cfg.Chain.MaxTxInputs)
+	}
+
+	if uint(len(tx.Vout)) > cfg.Chain.MaxTxOutputs {
+		return fmt.Errorf("transaction %x has %d outputs, max allowed %d", tx.ID, len(tx.Vout), cfg.Chain.MaxTxOutputs)
+	}
+
+	if tx.Size() > cfg.Chain.MaxTxSize {
+		return fmt.Errorf("transaction %x has size %d, max allowed %d", tx.ID, tx.Size(), cfg.Chain.MaxTxSize)
+	}
+
+	for i, out := range tx.Vout {
+		if err := validateDataCarrierOutput(out, cfg.Chain.MaxDataCarrierSize); err != nil {
+			return fmt.Errorf("transaction %x output %d: %w", tx.ID, i, err)
+		}
+	}
+
+	return nil
+}
+
+// validateDataCarrierOutput makes sure that outputs marked as unspendable follow the OP_RETURN <data> structure and
+// that the data carried does not exceed the max size allowed
+func validateDataCarrierOutput(out kernel.TxOutput, maxDataCarrierSize uint) error {
+	if !script.IsUnspendable(out.ScriptPubKey) {
+		return nil
+	}
+
+	scriptTokens, scriptString, err := script.StringToScript(out.ScriptPubKey)
+	if err != nil {
+		return fmt.Errorf("unable to parse data carrier script: %w", err)
+	}
+
+	data, err := script.ExtractNullData(scriptTokens, scriptString)
+	if err != nil {
+		return err
+	}
+
+	if uint(len(data)) > maxDataCarrierSize {
+		return fmt.Errorf("data carrier of %d bytes, max allowed %d", len(data), maxDataCarrierSize)
+	}
+
+	return nil
+}
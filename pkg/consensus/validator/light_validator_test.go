@@ -1,10 +1,14 @@
 package validator //nolint:testpackage // don't create separate package for tests
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/yago-123/chainnet/config"
+
 	"github.com/yago-123/chainnet/pkg/kernel"
 	"github.com/yago-123/chainnet/pkg/script"
+	util_script "github.com/yago-123/chainnet/pkg/util/script"
 	"github.com/yago-123/chainnet/tests/mocks/crypto/hash"
 
 	"github.com/stretchr/testify/require"
@@ -41,3 +45,73 @@ func TestLValidator_validateTxID(t *testing.T) {
 	tx.SetID(txHash)
 	require.Error(t, lv.validateTxID(tx))
 }
+
+func TestLValidator_validateTxWithinLimits(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Chain.MaxTxInputs = 2
+	cfg.Chain.MaxTxOutputs = 2
+	lv := NewLightValidator(cfg, &hash.FakeHashing{})
+
+	input := func(id string) kernel.TxInput { return kernel.NewInput([]byte(id), 0, "scriptsig", "pubkey") }
+	output := kernel.NewOutput(1, script.P2PK, "pubkey2")
+
+	tx := kernel.NewTransaction([]kernel.TxInput{input("tx-1"), input("tx-2")}, []kernel.TxOutput{output, output})
+	require.NoError(t, lv.validateTxWithinLimits(tx))
+
+	// too many inputs
+	tx = kernel.NewTransaction([]kernel.TxInput{input("tx-1"), input("tx-2"), input("tx-3")}, []kernel.TxOutput{output})
+	require.Error(t, lv.validateTxWithinLimits(tx))
+
+	// too many outputs
+	tx = kernel.NewTransaction([]kernel.TxInput{input("tx-1")}, []kernel.TxOutput{output, output, output})
+	require.Error(t, lv.validateTxWithinLimits(tx))
+
+	// too big
+	tx = kernel.NewTransaction([]kernel.TxInput{kernel.NewInput([]byte("tx-1"), 0, strings.Repeat("sig", 100), "pubkey")}, []kernel.TxOutput{output})
+	cfg.Chain.MaxTxSize = tx.Size() - 1
+	require.Error(t, lv.validateTxWithinLimits(tx))
+	cfg.Chain.MaxTxSize = tx.Size()
+	require.NoError(t, lv.validateTxWithinLimits(tx))
+}
+
+func TestLValidator_validateVersion(t *testing.T) {
+	lv := NewLightValidator(config.NewConfig(), &hash.FakeHashing{})
+
+	require.NoError(t, lv.validateVersion(&kernel.BlockHeader{Version: []byte(kernel.BlockVersionLeadingZerosTarget)}))
+	require.NoError(t, lv.validateVersion(&kernel.BlockHeader{Version: []byte(kernel.BlockVersionCompactTarget)}))
+	require.NoError(t, lv.validateVersion(&kernel.BlockHeader{Version: []byte(kernel.BlockVersionCanonicalSerialization)}))
+	require.Error(t, lv.validateVersion(&kernel.BlockHeader{Version: []byte("4")}))
+	require.Error(t, lv.validateVersion(&kernel.BlockHeader{}))
+}
+
+func TestLValidator_validateTxVersion(t *testing.T) {
+	lv := NewLightValidator(config.NewConfig(), &hash.FakeHashing{})
+
+	require.NoError(t, lv.validateTxVersion(&kernel.Transaction{Version: kernel.TxVersionLegacy}))
+	require.NoError(t, lv.validateTxVersion(&kernel.Transaction{Version: kernel.TxVersionCanonicalSerialization}))
+	require.Error(t, lv.validateTxVersion(&kernel.Transaction{Version: 2}))
+}
+
+func TestLValidator_validateDataCarrierOutputs(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Chain.MaxDataCarrierSize = 4
+	lv := NewLightValidator(cfg, &hash.FakeHashing{})
+
+	input := kernel.NewInput([]byte("tx-1"), 0, "scriptsig", "pubkey")
+
+	// data carrier outputs are allowed to contain zero amounts, the rest of outputs are not
+	tx := kernel.NewTransaction([]kernel.TxInput{input}, []kernel.TxOutput{kernel.NewDataOutput([]byte("data"))})
+	require.NoError(t, lv.validateAllOutputsContainNonZeroAmounts(tx))
+	require.NoError(t, lv.validateTxWithinLimits(tx))
+
+	tx = kernel.NewTransaction([]kernel.TxInput{input}, []kernel.TxOutput{kernel.NewOutput(0, script.P2PK, "pubkey2")})
+	require.Error(t, lv.validateAllOutputsContainNonZeroAmounts(tx))
+
+	// data bigger than the max data carrier size
+	tx = kernel.NewTransaction([]kernel.TxInput{input}, []kernel.TxOutput{kernel.NewDataOutput([]byte("data-2"))})
+	require.Error(t, lv.validateTxWithinLimits(tx))
+
+	// unspendable outputs must follow the null data structure
+	tx = kernel.NewTransaction([]kernel.TxInput{input}, []kernel.TxOutput{{Amount: 0, ScriptPubKey: util_script.BinaryFormatMarker + string([]byte{byte(script.OpReturn)})}})
+	require.Error(t, lv.validateTxWithinLimits(tx))
+}
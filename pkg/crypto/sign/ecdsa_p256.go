@@ -9,6 +9,11 @@ import (
 	util_crypto "github.com/yago-123/chainnet/pkg/util/crypto"
 )
 
+const (
+	// ecdsaP256ScalarLength is the length in bytes of r and s in P256 signatures
+	ecdsaP256ScalarLength = 32
+)
+
 type ECDSAP256Signer struct {
 }
 
@@ -41,9 +46,10 @@ func (ecdsaSign *ECDSAP256Signer) Sign(payload []byte, privKey []byte) ([]byte,
 		return []byte{}, err
 	}
 
-	// consolidate signature
-	signature := r.Bytes()
-	signature = append(signature, s.Bytes()...)
+	// consolidate signature, r and s are padded to the same length so that can be split apart when verifying
+	signature := make([]byte, 2*ecdsaP256ScalarLength)
+	r.FillBytes(signature[:ecdsaP256ScalarLength])
+	s.FillBytes(signature[ecdsaP256ScalarLength:])
 
 	return signature, nil
 }
@@ -40,3 +40,21 @@ func TestECDSASigner_Verify(t *testing.T) {
 	require.NoError(t, err)
 	assert.False(t, verified)
 }
+
+func TestECDSASigner_SignatureLength(t *testing.T) {
+	ecdsa := NewECDSASignature()
+
+	// r and s may contain leading zeros, the signature must keep a fixed length so that can always be verified
+	for range 500 {
+		pubKey, privKey, err := ecdsa.NewKeyPair()
+		require.NoError(t, err)
+
+		signature, err := ecdsa.Sign([]byte("payload"), privKey)
+		require.NoError(t, err)
+		require.Len(t, signature, 2*ecdsaP256ScalarLength)
+
+		verified, err := ecdsa.Verify(signature, []byte("payload"), pubKey)
+		require.NoError(t, err)
+		require.True(t, verified)
+	}
+}
@@ -21,6 +21,7 @@ type Encoding interface {
 	SerializeUTXO(utxo kernel.UTXO) ([]byte, error)
 	SerializeUTXOs(utxos []*kernel.UTXO) ([]byte, error)
 	SerializeBool(b bool) ([]byte, error)
+	SerializeHashes(hashes [][]byte) ([]byte, error)
 
 	DeserializeBlock(data []byte) (*kernel.Block, error)
 	DeserializeHeader(data []byte) (*kernel.BlockHeader, error)
@@ -30,4 +31,5 @@ type Encoding interface {
 	DeserializeUTXO(data []byte) (*kernel.UTXO, error)
 	DeserializeUTXOs(data []byte) ([]*kernel.UTXO, error)
 	DeserializeBool(data []byte) (bool, error)
+	DeserializeHashes(data []byte) ([][]byte, error)
 }
@@ -132,3 +132,16 @@ func (gobenc *GobEncoder) DeserializeBool(data []byte) (bool, error) {
 
 	return b, nil
 }
+
+func (gobenc *GobEncoder) SerializeHashes(hashes [][]byte) ([]byte, error) {
+	return gobenc.serialize(hashes)
+}
+
+func (gobenc *GobEncoder) DeserializeHashes(data []byte) ([][]byte, error) {
+	var hashes [][]byte
+	if err := gobenc.deserialize(data, &hashes); err != nil {
+		return nil, fmt.Errorf("error deserializing hashes: %w", err)
+	}
+
+	return hashes, nil
+}
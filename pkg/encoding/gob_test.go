@@ -43,6 +43,8 @@ var testBlock = &kernel.Block{ //nolint:gochecknoglobals // data that is used ac
 				{Amount: 20, ScriptPubKey: "scriptpubkey3", PubKey: "pubkey3"},
 				{Amount: 10, ScriptPubKey: "scriptpubkey4", PubKey: "pubkey4"},
 			},
+			LockTime: 150,
+			Version:  kernel.TxVersionCanonicalSerialization,
 		},
 	},
 	Hash: []byte("blockhash"),
@@ -13,9 +13,11 @@ type JSON struct {
 
 // Notice: added these structs to avoid polluting the core components. This might change in the future
 type jsonTransaction struct {
-	ID   string         `json:"id"`
-	Vin  []jsonTxInput  `json:"vin"`
-	Vout []jsonTxOutput `json:"vout"`
+	ID       string         `json:"id"`
+	Vin      []jsonTxInput  `json:"vin"`
+	Vout     []jsonTxOutput `json:"vout"`
+	LockTime uint           `json:"lock_time"`
+	Version  uint           `json:"version"`
 }
 
 type jsonTxInput struct {
@@ -23,6 +25,7 @@ type jsonTxInput struct {
 	Vout      uint   `json:"vout"`
 	ScriptSig string `json:"script_sig"`
 	PubKey    string `json:"pub_key"`
+	Sequence  uint   `json:"sequence"`
 }
 
 type jsonTxOutput struct {
@@ -32,9 +35,11 @@ type jsonTxOutput struct {
 }
 
 type jsonUTXO struct {
-	TxID   string       `json:"txid"`
-	OutIdx uint         `json:"vout"`
-	Output jsonTxOutput `json:"output"`
+	TxID     string       `json:"txid"`
+	OutIdx   uint         `json:"vout"`
+	Output   jsonTxOutput `json:"output"`
+	Height   uint         `json:"height"`
+	Coinbase bool         `json:"coinbase"`
 }
 
 type jsonBlockHeader struct {
@@ -230,6 +235,34 @@ func (j *JSON) DeserializeBool(data []byte) (bool, error) {
 	return ret, nil
 }
 
+func (j *JSON) SerializeHashes(hashes [][]byte) ([]byte, error) {
+	encoded := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		encoded = append(encoded, hex.EncodeToString(hash))
+	}
+
+	return json.Marshal(encoded)
+}
+
+func (j *JSON) DeserializeHashes(data []byte) ([][]byte, error) {
+	var encoded []string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, fmt.Errorf("error deserializing hashes: %w", err)
+	}
+
+	ret := make([][]byte, 0, len(encoded))
+	for _, hash := range encoded {
+		decoded, err := hex.DecodeString(hash)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding hash %s: %w", hash, err)
+		}
+
+		ret = append(ret, decoded)
+	}
+
+	return ret, nil
+}
+
 func convertToJSONBlock(b kernel.Block) jsonBlock {
 	var header *jsonBlockHeader
 	if b.Header != nil {
@@ -310,9 +343,11 @@ func convertFromJSONBlockHeader(header jsonBlockHeader) (*kernel.BlockHeader, er
 
 func convertToJSONTransaction(tx kernel.Transaction) jsonTransaction {
 	return jsonTransaction{
-		ID:   hex.EncodeToString(tx.ID),
-		Vin:  convertToJSONTxInputs(tx.Vin),
-		Vout: convertToJSONTxOutputs(tx.Vout),
+		ID:       hex.EncodeToString(tx.ID),
+		Vin:      convertToJSONTxInputs(tx.Vin),
+		Vout:     convertToJSONTxOutputs(tx.Vout),
+		LockTime: tx.LockTime,
+		Version:  tx.Version,
 	}
 }
 
@@ -322,17 +357,22 @@ func convertFromJSONTransaction(tx jsonTransaction) (kernel.Transaction, error)
 		return kernel.Transaction{}, err
 	}
 
-	inputs, err := convertFromJSONTxInputs(tx.Vin)
+	inputs, err := convertFromJSONTxInputs(tx.Vin, isLegacyJSONTransaction(tx))
 	if err != nil {
 		return kernel.Transaction{}, err
 	}
 
-	outputs := convertFromJSONTxOutputs(tx.Vout)
+	outputs, err := convertFromJSONTxOutputs(tx.Vout)
+	if err != nil {
+		return kernel.Transaction{}, err
+	}
 
 	return kernel.Transaction{
-		ID:   id,
-		Vin:  inputs,
-		Vout: outputs,
+		ID:       id,
+		Vin:      inputs,
+		Vout:     outputs,
+		LockTime: tx.LockTime,
+		Version:  tx.Version,
 	}, nil
 }
 
@@ -340,46 +380,71 @@ func convertToJSONTxInput(input kernel.TxInput) jsonTxInput {
 	return jsonTxInput{
 		Txid:      hex.EncodeToString(input.Txid),
 		Vout:      input.Vout,
-		ScriptSig: input.ScriptSig,
+		ScriptSig: hex.EncodeToString([]byte(input.ScriptSig)),
 		PubKey:    input.PubKey,
+		Sequence:  input.Sequence,
 	}
 }
 
-func convertFromJSONTxInput(input jsonTxInput) (kernel.TxInput, error) {
+// convertFromJSONTxInput converts the JSON input provided, legacyScripts indicates whether the scriptSig is contained
+// as is instead of hex-encoded (see isLegacyJSONTransaction)
+func convertFromJSONTxInput(input jsonTxInput, legacyScripts bool) (kernel.TxInput, error) {
 	txID, err := decodeHexField("txid", input.Txid)
 	if err != nil {
 		return kernel.TxInput{}, err
 	}
 
+	scriptSig := []byte(input.ScriptSig)
+	if !legacyScripts {
+		scriptSig, err = decodeHexField("script_sig", input.ScriptSig)
+		if err != nil {
+			return kernel.TxInput{}, err
+		}
+	}
+
 	return kernel.TxInput{
 		Txid:      txID,
 		Vout:      input.Vout,
-		ScriptSig: input.ScriptSig,
+		ScriptSig: string(scriptSig),
 		PubKey:    input.PubKey,
+		Sequence:  input.Sequence,
 	}, nil
 }
 
 func convertToJSONTxOutput(output kernel.TxOutput) jsonTxOutput {
 	return jsonTxOutput{
 		Amount:       output.Amount,
-		ScriptPubKey: output.ScriptPubKey,
+		ScriptPubKey: hex.EncodeToString([]byte(output.ScriptPubKey)),
 		PubKey:       output.PubKey,
 	}
 }
 
-func convertFromJSONTxOutput(output jsonTxOutput) kernel.TxOutput {
+// convertFromJSONTxOutput converts the JSON output provided. Older versions did not hex-encode the scriptPubKey, in
+// that case it is contained as is
+func convertFromJSONTxOutput(output jsonTxOutput) (kernel.TxOutput, error) {
+	scriptPubKey := []byte(output.ScriptPubKey)
+	if !isLegacyJSONScriptPubKey(output.ScriptPubKey) {
+		decoded, err := decodeHexField("script_pub_key", output.ScriptPubKey)
+		if err != nil {
+			return kernel.TxOutput{}, err
+		}
+		scriptPubKey = decoded
+	}
+
 	return kernel.TxOutput{
 		Amount:       output.Amount,
-		ScriptPubKey: output.ScriptPubKey,
+		ScriptPubKey: string(scriptPubKey),
 		PubKey:       output.PubKey,
-	}
+	}, nil
 }
 
 func convertToJSONUTXO(utxo kernel.UTXO) jsonUTXO {
 	return jsonUTXO{
-		TxID:   hex.EncodeToString(utxo.TxID),
-		OutIdx: utxo.OutIdx,
-		Output: convertToJSONTxOutput(utxo.Output),
+		TxID:     hex.EncodeToString(utxo.TxID),
+		OutIdx:   utxo.OutIdx,
+		Output:   convertToJSONTxOutput(utxo.Output),
+		Height:   utxo.Height,
+		Coinbase: utxo.Coinbase,
 	}
 }
 
@@ -389,10 +454,17 @@ func convertFromJSONUTXO(utxo jsonUTXO) (kernel.UTXO, error) {
 		return kernel.UTXO{}, err
 	}
 
+	output, err := convertFromJSONTxOutput(utxo.Output)
+	if err != nil {
+		return kernel.UTXO{}, err
+	}
+
 	return kernel.UTXO{
-		TxID:   txID,
-		OutIdx: utxo.OutIdx,
-		Output: convertFromJSONTxOutput(utxo.Output),
+		TxID:     txID,
+		OutIdx:   utxo.OutIdx,
+		Output:   output,
+		Height:   utxo.Height,
+		Coinbase: utxo.Coinbase,
 	}, nil
 }
 
@@ -424,10 +496,10 @@ func convertToJSONTxInputs(inputs []kernel.TxInput) []jsonTxInput {
 	return ret
 }
 
-func convertFromJSONTxInputs(inputs []jsonTxInput) ([]kernel.TxInput, error) {
+func convertFromJSONTxInputs(inputs []jsonTxInput, legacyScripts bool) ([]kernel.TxInput, error) {
 	ret := make([]kernel.TxInput, 0, len(inputs))
 	for _, input := range inputs {
-		converted, err := convertFromJSONTxInput(input)
+		converted, err := convertFromJSONTxInput(input, legacyScripts)
 		if err != nil {
 			return nil, err
 		}
@@ -444,12 +516,35 @@ func convertToJSONTxOutputs(outputs []kernel.TxOutput) []jsonTxOutput {
 	return ret
 }
 
-func convertFromJSONTxOutputs(outputs []jsonTxOutput) []kernel.TxOutput {
+func convertFromJSONTxOutputs(outputs []jsonTxOutput) ([]kernel.TxOutput, error) {
 	ret := make([]kernel.TxOutput, 0, len(outputs))
 	for _, output := range outputs {
-		ret = append(ret, convertFromJSONTxOutput(output))
+		converted, err := convertFromJSONTxOutput(output)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, converted)
 	}
-	return ret
+	return ret, nil
+}
+
+// isLegacyJSONTransaction checks whether the transaction was encoded by older versions, which did not hex-encode the
+// scripts. The scriptSigs can't tell by themselves (coinbase scriptSigs may be valid hex), but the scriptPubKeys can
+func isLegacyJSONTransaction(tx jsonTransaction) bool {
+	for _, output := range tx.Vout {
+		if isLegacyJSONScriptPubKey(output.ScriptPubKey) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isLegacyJSONScriptPubKey checks whether the scriptPubKey is contained as is instead of hex-encoded. Scripts in the
+// legacy format always contain operator names or literal type prefixes, so they are never valid hex
+func isLegacyJSONScriptPubKey(scriptPubKey string) bool {
+	_, err := hex.DecodeString(scriptPubKey)
+	return err != nil
 }
 
 func decodeHexField(field string, value string) ([]byte, error) {
@@ -10,9 +10,10 @@ import (
 
 func TestJSONSerializeDeserializeTransaction(t *testing.T) {
 	encoder := NewJSONEncoder()
-	tx := kernel.NewTransaction(
-		[]kernel.TxInput{kernel.NewInput([]byte("tx-id"), 1, "script-sig", "pub-key")},
+	tx := kernel.NewTransactionWithLockTime(
+		[]kernel.TxInput{kernel.NewInputWithSequence([]byte("tx-id"), 1, "script-sig", "pub-key", 10)},
 		[]kernel.TxOutput{{Amount: 10, ScriptPubKey: "script", PubKey: "pub-key"}},
+		150,
 	)
 	tx.SetID([]byte("tx-id"))
 
@@ -20,8 +21,10 @@ func TestJSONSerializeDeserializeTransaction(t *testing.T) {
 	require.NoError(t, err)
 	assert.JSONEq(t, `{
 		"id":"74782d6964",
-		"vin":[{"txid":"74782d6964","vout":1,"script_sig":"script-sig","pub_key":"pub-key"}],
-		"vout":[{"amount":10,"script_pub_key":"script","pub_key":"pub-key"}]
+		"vin":[{"txid":"74782d6964","vout":1,"script_sig":"7363726970742d736967","pub_key":"pub-key","sequence":10}],
+		"vout":[{"amount":10,"script_pub_key":"736372697074","pub_key":"pub-key"}],
+		"lock_time":150,
+		"version":1
 	}`, string(data))
 
 	decoded, err := encoder.DeserializeTransaction(data)
@@ -77,3 +80,15 @@ func TestJSONSerializeDeserializeBool(t *testing.T) {
 	require.NoError(t, err)
 	assert.True(t, decoded)
 }
+
+func TestJSONSerializeDeserializeHashes(t *testing.T) {
+	encoder := NewJSONEncoder()
+	hashes := [][]byte{[]byte("hash-1"), []byte("hash-2")}
+
+	data, err := encoder.SerializeHashes(hashes)
+	require.NoError(t, err)
+
+	decoded, err := encoder.DeserializeHashes(data)
+	require.NoError(t, err)
+	assert.Equal(t, hashes, decoded)
+}
@@ -0,0 +1,100 @@
+package encoding_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yago-123/chainnet/pkg/crypto/hash"
+	"github.com/yago-123/chainnet/pkg/encoding"
+	"github.com/yago-123/chainnet/pkg/kernel"
+	"github.com/yago-123/chainnet/pkg/script"
+	rpnInter "github.com/yago-123/chainnet/pkg/script/interpreter"
+	"github.com/yago-123/chainnet/pkg/util"
+	util_script "github.com/yago-123/chainnet/pkg/util/script"
+	mockSign "github.com/yago-123/chainnet/tests/mocks/crypto/sign"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the blocks in testdata were encoded by versions previous to the binary script format, the scripts of the
+// transactions are serialized in the legacy format (base58 values separated by spaces). The block contains a coinbase
+// transaction and a transaction that spends it into a P2PK, a P2PKH and a OP_RETURN output of the key below
+const legacyPubKey = "pubkey-legacy"
+
+func TestEncoders_LegacyBlock(t *testing.T) {
+	encoders := map[string]encoding.Encoding{
+		"legacy_block.gob":  encoding.NewGobEncoder(),
+		"legacy_block.json": encoding.NewJSONEncoder(),
+		"legacy_block.pb":   encoding.NewProtobufEncoder(),
+	}
+
+	for file, encoder := range encoders {
+		t.Run(file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", file))
+			require.NoError(t, err)
+
+			block, err := encoder.DeserializeBlock(data)
+			require.NoError(t, err)
+			require.Len(t, block.Transactions, 2)
+
+			// the scripts are kept as they were persisted, so the transaction hashes don't change
+			for _, tx := range block.Transactions {
+				txHash, errHash := util.CalculateTxHash(tx, hash.NewHasher(sha256.New()))
+				require.NoError(t, errHash)
+				assert.Equal(t, tx.ID, txHash)
+			}
+
+			// the legacy scriptSig is still decoded
+			tx := block.Transactions[1]
+			scriptSig, err := util_script.DecodeScriptSig(tx.Vin[0].ScriptSig)
+			require.NoError(t, err)
+			require.Len(t, scriptSig, 1)
+			assert.True(t, bytes.HasSuffix(scriptSig[0], []byte("-signed")))
+
+			// and so are the legacy scriptPubKeys
+			for i, scriptType := range []script.ScriptType{script.P2PK, script.P2PKH, script.NullData} {
+				tokens, _, errScript := script.StringToScript(tx.Vout[i].ScriptPubKey)
+				require.NoError(t, errScript)
+				assert.Equal(t, scriptType, script.DetermineScriptType(tokens))
+			}
+			assert.False(t, script.IsUnspendable(tx.Vout[0].ScriptPubKey))
+			assert.True(t, script.IsUnspendable(tx.Vout[2].ScriptPubKey))
+
+			spendLegacyOutputs(t, tx)
+		})
+	}
+}
+
+// spendLegacyOutputs spends the P2PK and P2PKH outputs of the legacy transaction with a new transaction
+func spendLegacyOutputs(t *testing.T, legacyTx *kernel.Transaction) {
+	interpreter := rpnInter.NewScriptInterpreter(&mockSign.MockSign{})
+
+	tx := kernel.NewTransaction(
+		[]kernel.TxInput{
+			kernel.NewInput(legacyTx.ID, 0, "", legacyPubKey),
+			kernel.NewInput(legacyTx.ID, 1, "", legacyPubKey),
+		},
+		[]kernel.TxOutput{kernel.NewOutput(48, script.P2PK, "pubkey-2")},
+	)
+
+	for i := range tx.Vin {
+		scriptSig, err := interpreter.GenerateScriptSig(legacyTx.Vout[i], []byte(legacyPubKey), []byte("privkey-legacy"), tx, uint(i), kernel.SighashAll)
+		require.NoError(t, err)
+		assert.True(t, util_script.IsBinaryFormat(scriptSig))
+		tx.Vin[i].UnlockWith(scriptSig)
+	}
+
+	for i, input := range tx.Vin {
+		valid, err := interpreter.VerifyScriptPubKey(legacyTx.Vout[i], input.ScriptSig, tx, uint(i))
+		require.NoError(t, err)
+		assert.True(t, valid)
+
+		// the scriptSig does not unlock outputs of other keys
+		valid, err = interpreter.VerifyScriptPubKey(kernel.NewOutput(legacyTx.Vout[i].Amount, script.P2PK, "pubkey-3"), input.ScriptSig, tx, uint(i))
+		assert.False(t, err == nil && valid)
+	}
+}
@@ -275,6 +275,26 @@ func (p *Protobuf) DeserializeBool(data []byte) (bool, error) {
 	}
 }
 
+// SerializeHashes serializes a list of hashes into a Protobuf byte array
+func (p *Protobuf) SerializeHashes(hashes [][]byte) ([]byte, error) {
+	data, err := proto.Marshal(&pb.Hashes{Hashes: hashes})
+	if err != nil {
+		return nil, fmt.Errorf("error serializing hashes: %w", err)
+	}
+
+	return data, nil
+}
+
+// DeserializeHashes deserializes a Protobuf byte array into a list of hashes
+func (p *Protobuf) DeserializeHashes(data []byte) ([][]byte, error) {
+	var pbHashes pb.Hashes
+	if err := proto.Unmarshal(data, &pbHashes); err != nil {
+		return nil, fmt.Errorf("error deserializing hashes: %w", err)
+	}
+
+	return pbHashes.GetHashes(), nil
+}
+
 func convertToProtobufBlock(b kernel.Block) (*pb.Block, error) {
 	if b.Header == nil {
 		return &pb.Block{}, fmt.Errorf("empty header, not safe to serialize")
@@ -326,9 +346,11 @@ func convertFromProtobufBlockHeader(pbHeader *pb.BlockHeader) *kernel.BlockHeade
 
 func convertToProtobufTransaction(tx kernel.Transaction) *pb.Transaction {
 	return &pb.Transaction{
-		Id:   tx.ID,
-		Vin:  convertToProtobufTxInputs(tx.Vin),
-		Vout: convertToProtobufTxOutputs(tx.Vout),
+		Id:       tx.ID,
+		Vin:      convertToProtobufTxInputs(tx.Vin),
+		Vout:     convertToProtobufTxOutputs(tx.Vout),
+		LockTime: uint64(tx.LockTime),
+		Version:  uint64(tx.Version),
 	}
 }
 
@@ -344,9 +366,11 @@ func convertFromProtobufTransaction(pbTransaction *pb.Transaction) (kernel.Trans
 	}
 
 	return kernel.Transaction{
-		ID:   pbTransaction.GetId(),
-		Vin:  txInput,
-		Vout: txOutput,
+		ID:       pbTransaction.GetId(),
+		Vin:      txInput,
+		Vout:     txOutput,
+		LockTime: uint(pbTransaction.GetLockTime()),
+		Version:  uint(pbTransaction.GetVersion()),
 	}, nil
 }
 
@@ -354,8 +378,9 @@ func convertToProtobufTxInput(txin kernel.TxInput) *pb.TxInput {
 	return &pb.TxInput{
 		Txid:      txin.Txid,
 		Vout:      uint64(txin.Vout),
-		ScriptSig: txin.ScriptSig,
+		ScriptSig: []byte(txin.ScriptSig),
 		PubKey:    fmt.Sprintf("%x", txin.PubKey),
+		Sequence:  uint64(txin.Sequence),
 	}
 }
 
@@ -368,15 +393,16 @@ func convertFromProtobufTxInput(pbInput *pb.TxInput) (kernel.TxInput, error) {
 	return kernel.TxInput{
 		Txid:      pbInput.GetTxid(),
 		Vout:      uint(pbInput.GetVout()),
-		ScriptSig: pbInput.GetScriptSig(),
+		ScriptSig: string(pbInput.GetScriptSig()),
 		PubKey:    string(decodedPubKey),
+		Sequence:  uint(pbInput.GetSequence()),
 	}, nil
 }
 
 func convertToProtobufTxOutput(txout kernel.TxOutput) *pb.TxOutput {
 	return &pb.TxOutput{
 		Amount:       uint64(txout.Amount),
-		ScriptPubKey: txout.ScriptPubKey,
+		ScriptPubKey: []byte(txout.ScriptPubKey),
 		PubKey:       fmt.Sprintf("%x", txout.PubKey),
 	}
 }
@@ -389,7 +415,7 @@ func convertFromProtobufTxOutput(pbOutput *pb.TxOutput) (kernel.TxOutput, error)
 
 	return kernel.TxOutput{
 		Amount:       uint(pbOutput.GetAmount()),
-		ScriptPubKey: pbOutput.GetScriptPubKey(),
+		ScriptPubKey: string(pbOutput.GetScriptPubKey()),
 		PubKey:       string(decodedPubKey),
 	}, nil
 }
@@ -457,9 +483,11 @@ func convertFromProtobufTxOutputs(pbs []*pb.TxOutput) ([]kernel.TxOutput, error)
 
 func convertToProtobufUTXO(utxo kernel.UTXO) *pb.UTXO {
 	return &pb.UTXO{
-		Txid:   utxo.TxID,
-		Vout:   uint64(utxo.OutIdx),
-		Output: convertToProtobufTxOutput(utxo.Output),
+		Txid:     utxo.TxID,
+		Vout:     uint64(utxo.OutIdx),
+		Output:   convertToProtobufTxOutput(utxo.Output),
+		Height:   uint64(utxo.Height),
+		Coinbase: utxo.Coinbase,
 	}
 }
 
@@ -470,8 +498,10 @@ func convertFromProtobufUTXO(pbUTXO *pb.UTXO) (kernel.UTXO, error) {
 	}
 
 	return kernel.UTXO{
-		TxID:   pbUTXO.GetTxid(),
-		OutIdx: uint(pbUTXO.GetVout()),
-		Output: txOutput,
+		TxID:     pbUTXO.GetTxid(),
+		OutIdx:   uint(pbUTXO.GetVout()),
+		Output:   txOutput,
+		Height:   uint(pbUTXO.GetHeight()),
+		Coinbase: pbUTXO.GetCoinbase(),
 	}, nil
 }
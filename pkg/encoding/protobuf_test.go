@@ -32,6 +32,7 @@ var testBlock = kernel.Block{ //nolint:gochecknoglobals // ignore linter in this
 					Vout:      0,
 					ScriptSig: "sig1",
 					PubKey:    "pubkey1",
+					Sequence:  10,
 				},
 			},
 			Vout: []kernel.TxOutput{
@@ -41,6 +42,8 @@ var testBlock = kernel.Block{ //nolint:gochecknoglobals // ignore linter in this
 					PubKey:       "pubkey1",
 				},
 			},
+			LockTime: 150,
+			Version:  kernel.TxVersionCanonicalSerialization,
 		},
 	},
 	Hash: []byte("blockhash"),
@@ -63,17 +66,20 @@ var expectedPbBlock = &pb.Block{ //nolint:gochecknoglobals // ignore linter in t
 				{
 					Txid:      []byte("txid0"),
 					Vout:      0,
-					ScriptSig: "sig1",
+					ScriptSig: []byte("sig1"),
 					PubKey:    "7075626b657931", // hexadecimal encoded to prevent UTF-8 issues
+					Sequence:  10,
 				},
 			},
 			Vout: []*pb.TxOutput{
 				{
 					Amount:       100,
-					ScriptPubKey: "scriptpubkey1",
+					ScriptPubKey: []byte("scriptpubkey1"),
 					PubKey:       "7075626b657931", // hexadecimal encoded to prevent UTF-8 issues
 				},
 			},
+			LockTime: 150,
+			Version:  uint64(kernel.TxVersionCanonicalSerialization),
 		},
 	},
 	Hash: []byte("blockhash"),
@@ -134,17 +140,20 @@ var expectedPbTransactions = []*pb.Transaction{ //nolint:gochecknoglobals // dat
 			{
 				Txid:      []byte("txid0"),
 				Vout:      0,
-				ScriptSig: "sig1",
+				ScriptSig: []byte("sig1"),
 				PubKey:    "7075626b657931", // Hex encoded to avoid UTF-8 issues
+				Sequence:  10,
 			},
 		},
 		Vout: []*pb.TxOutput{
 			{
 				Amount:       100,
-				ScriptPubKey: "scriptpubkey1",
+				ScriptPubKey: []byte("scriptpubkey1"),
 				PubKey:       "7075626b657931", // Hex encoded to avoid UTF-8 issues
 			},
 		},
+		LockTime: 150,
+		Version:  uint64(kernel.TxVersionCanonicalSerialization),
 	},
 }
 
@@ -153,7 +162,7 @@ var expectedPbUTXO = &pb.UTXO{ //nolint:gochecknoglobals // data that is used ac
 	Vout: 0,
 	Output: &pb.TxOutput{
 		Amount:       50,
-		ScriptPubKey: "scriptpubkey1",
+		ScriptPubKey: []byte("scriptpubkey1"),
 		PubKey:       "7075626b657931", // Hex encoded PubKey
 	},
 }
@@ -185,7 +194,7 @@ var expectedPbUTXOs = &pb.UTXOs{ //nolint:gochecknoglobals // data that is used
 			Vout: 0,
 			Output: &pb.TxOutput{
 				Amount:       50,
-				ScriptPubKey: "scriptpubkey1",
+				ScriptPubKey: []byte("scriptpubkey1"),
 				PubKey:       "7075626b657931", // Hex encoded PubKey
 			},
 		},
@@ -194,7 +203,7 @@ var expectedPbUTXOs = &pb.UTXOs{ //nolint:gochecknoglobals // data that is used
 			Vout: 1,
 			Output: &pb.TxOutput{
 				Amount:       100,
-				ScriptPubKey: "scriptpubkey2",
+				ScriptPubKey: []byte("scriptpubkey2"),
 				PubKey:       "7075626b657932", // Hex encoded PubKey
 			},
 		},
@@ -208,7 +217,7 @@ var expectedPbUTXOs2 = &pb.UTXOs{ //nolint:gochecknoglobals // data that is used
 			Vout: 0,
 			Output: &pb.TxOutput{
 				Amount:       50,
-				ScriptPubKey: "scriptpubkey1",
+				ScriptPubKey: []byte("scriptpubkey1"),
 				PubKey:       "7075626b657931", // Hex encoded PubKey
 			},
 		},
@@ -217,7 +226,7 @@ var expectedPbUTXOs2 = &pb.UTXOs{ //nolint:gochecknoglobals // data that is used
 			Vout: 1,
 			Output: &pb.TxOutput{
 				Amount:       100,
-				ScriptPubKey: "scriptpubkey2",
+				ScriptPubKey: []byte("scriptpubkey2"),
 				PubKey:       "7075626b657932", // Hex encoded PubKey
 			},
 		},
@@ -250,7 +259,7 @@ var expectedPbUTXO2 = &pb.UTXO{ //nolint:gochecknoglobals // data that is used a
 	Vout: 0,
 	Output: &pb.TxOutput{
 		Amount:       50,
-		ScriptPubKey: "sampleScriptPubKey",
+		ScriptPubKey: []byte("sampleScriptPubKey"),
 		PubKey:       "7075626b657931", // hex encoded
 	},
 }
@@ -324,6 +333,18 @@ func TestDeserializeHeaders(t *testing.T) {
 	assert.ElementsMatch(t, testBlockHeaders, headers)
 }
 
+func TestSerializeDeserializeHashes(t *testing.T) {
+	p := NewProtobufEncoder()
+	hashes := [][]byte{[]byte("hash-1"), []byte("hash-2")}
+
+	data, err := p.SerializeHashes(hashes)
+	require.NoError(t, err)
+
+	decoded, err := p.DeserializeHashes(data)
+	require.NoError(t, err)
+	assert.Equal(t, hashes, decoded)
+}
+
 func TestSerializeTransaction(t *testing.T) {
 	p := NewProtobufEncoder()
 	data, err := p.SerializeTransaction(*testBlock.Transactions[0])
@@ -519,17 +540,20 @@ func TestConvertTopbTransaction(t *testing.T) {
 			{
 				Txid:      []byte("txid0"),
 				Vout:      0,
-				ScriptSig: "sig1",
+				ScriptSig: []byte("sig1"),
 				PubKey:    "7075626b657931",
+				Sequence:  10,
 			},
 		},
 		Vout: []*pb.TxOutput{
 			{
 				Amount:       100,
-				ScriptPubKey: "scriptpubkey1",
+				ScriptPubKey: []byte("scriptpubkey1"),
 				PubKey:       "7075626b657931",
 			},
 		},
+		LockTime: 150,
+		Version:  uint64(kernel.TxVersionCanonicalSerialization),
 	}
 	result := convertToProtobufTransaction(tx)
 
@@ -550,8 +574,9 @@ func TestConvertTopbTxInput(t *testing.T) {
 	expected := &pb.TxInput{
 		Txid:      []byte("txid0"),
 		Vout:      0,
-		ScriptSig: "sig1",
+		ScriptSig: []byte("sig1"),
 		PubKey:    "7075626b657931",
+		Sequence:  10,
 	}
 	result := convertToProtobufTxInput(input)
 
@@ -570,7 +595,7 @@ func TestConvertTopbTxOutput(t *testing.T) {
 
 	expected := &pb.TxOutput{
 		Amount:       100,
-		ScriptPubKey: "scriptpubkey1",
+		ScriptPubKey: []byte("scriptpubkey1"),
 		PubKey:       "7075626b657931",
 	}
 	result := convertToProtobufTxOutput(output)
@@ -593,8 +618,9 @@ func TestConvertTopbTxInputs(t *testing.T) {
 		{
 			Txid:      []byte("txid0"),
 			Vout:      0,
-			ScriptSig: "sig1",
+			ScriptSig: []byte("sig1"),
 			PubKey:    "7075626b657931",
+			Sequence:  10,
 		},
 	}
 	result := convertToProtobufTxInputs(inputs)
@@ -617,7 +643,7 @@ func TestConvertTopbTxOutputs(t *testing.T) {
 	expected := []*pb.TxOutput{
 		{
 			Amount:       100,
-			ScriptPubKey: "scriptpubkey1",
+			ScriptPubKey: []byte("scriptpubkey1"),
 			PubKey:       "7075626b657931",
 		},
 	}
@@ -661,7 +687,7 @@ func TestConvertFromProtobufUTXO(t *testing.T) {
 		Vout: 0,
 		Output: &pb.TxOutput{
 			Amount:       50,
-			ScriptPubKey: "sampleScriptPubKey",
+			ScriptPubKey: []byte("sampleScriptPubKey"),
 			PubKey:       "7075626b657931", // hex encoded
 		},
 	}
@@ -19,5 +19,9 @@ var (
 
 // Errors used in the mempool package
 var (
-	ErrMemPoolFull = errors.New("mempool does not have enough space")
+	ErrMemPoolFull                 = errors.New("mempool does not have enough space")
+	ErrMemPoolFeeTooLow            = errors.New("transaction fee rate below mempool minimum")
+	ErrMemPoolTxAlreadyExists      = errors.New("transaction already in mempool")
+	ErrMemPoolReplacementFeeTooLow = errors.New("replacement transaction does not pay enough fee")
+	ErrMemPoolChainTooLong         = errors.New("too many unconfirmed transactions chained")
 )
@@ -2,14 +2,22 @@ package kernel
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"unsafe"
 )
 
 const (
 	// ChainnetCoinAmount number of smaller units (Channoshis) that represent 1 Chainnet coin
-	ChainnetCoinAmount   = 100000000
-	MaxNumberTxsPerBlock = 300
+	ChainnetCoinAmount = 100000000
+
+	// BlockVersionLeadingZerosTarget headers contain a target that represents the number of leading zero bits of the hash
+	BlockVersionLeadingZerosTarget = "1"
+	// BlockVersionCompactTarget headers contain a 256-bit target encoded in compact form (like Bitcoin's nBits)
+	BlockVersionCompactTarget = "2"
+	// BlockVersionCanonicalSerialization headers contain a compact target and are hashed using the canonical binary
+	// serialization instead of the legacy text based one
+	BlockVersionCanonicalSerialization = "3"
 )
 
 type BlockHeader struct {
@@ -20,7 +28,8 @@ type BlockHeader struct {
 	// todo(): use timestamp to determine the difficulty, in a 2 weeks period, if the number of blocks was
 	// todo(): created too quick, it means that the difficult must be increased
 	Timestamp int64
-	// todo(): target could be removed now, mining difficulty can already be determined dynamically
+	// Target interpretation depends on the version: number of leading zero bits (BlockVersionLeadingZerosTarget) or
+	// compact encoding of a 256-bit target (BlockVersionCompactTarget and later versions)
 	Target uint
 	Nonce  uint
 }
@@ -45,6 +54,27 @@ func (bh *BlockHeader) SetTimestamp(timestamp int64) {
 	bh.Timestamp = timestamp
 }
 
+// HasCompactTarget returns whether the target of the header is encoded in compact form
+func (bh *BlockHeader) HasCompactTarget() bool {
+	return IsCompactTargetVersion(bh.Version)
+}
+
+// HasCanonicalSerialization returns whether the header is hashed using the canonical binary serialization
+func (bh *BlockHeader) HasCanonicalSerialization() bool {
+	return IsCanonicalSerializationVersion(bh.Version)
+}
+
+// IsCompactTargetVersion returns whether the headers with the version provided encode the target in compact form
+func IsCompactTargetVersion(version []byte) bool {
+	return string(version) == BlockVersionCompactTarget || IsCanonicalSerializationVersion(version)
+}
+
+// IsCanonicalSerializationVersion returns whether the headers with the version provided are hashed using the canonical
+// binary serialization
+func IsCanonicalSerializationVersion(version []byte) bool {
+	return string(version) == BlockVersionCanonicalSerialization
+}
+
 func (bh *BlockHeader) IsGenesisHeader() bool {
 	return len(bh.PrevBlockHash) == 0 && bh.Height == 0
 }
@@ -54,7 +84,13 @@ func (bh *BlockHeader) String() string {
 		bh.Version, bh.PrevBlockHash, bh.MerkleRoot, bh.Height, bh.Timestamp, bh.Target, bh.Nonce)
 }
 
+// Assemble retrieves the data of the header used for calculating the block hash. The serialization used depends on
+// the version of the header, legacy headers keep the text based serialization so that existing chains remain valid
 func (bh *BlockHeader) Assemble() []byte {
+	if bh.HasCanonicalSerialization() {
+		return bh.assembleCanonical()
+	}
+
 	data := [][]byte{
 		[]byte(fmt.Sprintf("version %s", bh.Version)),
 		[]byte(fmt.Sprintf("prev block hash %x", bh.PrevBlockHash)),
@@ -68,6 +104,20 @@ func (bh *BlockHeader) Assemble() []byte {
 	return bytes.Join(data, []byte{})
 }
 
+// assembleCanonical serializes the header fields in order: variable length fields are prefixed by their length and
+// numbers are encoded as varints, so different headers can't produce the same data
+func (bh *BlockHeader) assembleCanonical() []byte {
+	var data []byte
+
+	data = appendLengthPrefixed(data, bh.Version)
+	data = appendLengthPrefixed(data, bh.PrevBlockHash)
+	data = appendLengthPrefixed(data, bh.MerkleRoot)
+	data = binary.AppendUvarint(data, uint64(bh.Height))
+	data = binary.AppendVarint(data, bh.Timestamp)
+	data = binary.AppendUvarint(data, uint64(bh.Target))
+	return binary.AppendUvarint(data, uint64(bh.Nonce))
+}
+
 func (bh *BlockHeader) Size() uint {
 	return uint(len(bh.Version) + len(bh.PrevBlockHash) + len(bh.MerkleRoot) + int(unsafe.Sizeof(uint(0)))*3 + int(unsafe.Sizeof(int64(0))))
 }
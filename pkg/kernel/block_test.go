@@ -0,0 +1,22 @@
+package kernel //nolint:testpackage // don't create separate package for tests
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockHeader_AssembleCanonical(t *testing.T) {
+	// test vector of the canonical serialization, must not change unless a new version is introduced
+	bh := NewBlockHeader([]byte(BlockVersionCanonicalSerialization), 1700000000, []byte{0x01, 0x02}, 5, []byte{0x03}, 0x1d00ffff, 42)
+	assert.True(t, bh.HasCanonicalSerialization())
+	assert.True(t, bh.HasCompactTarget())
+	assert.Equal(t, "013301030201020580c49fd50cffff83e8012a", hex.EncodeToString(bh.Assemble()))
+
+	// previous versions keep the text based serialization
+	bh.Version = []byte(BlockVersionCompactTarget)
+	assert.False(t, bh.HasCanonicalSerialization())
+	assert.True(t, bh.HasCompactTarget())
+	assert.Equal(t, "version 2prev block hash 03merkle root 0102height 5timestamp 1700000000target 486604799nonce 42", string(bh.Assemble()))
+}
@@ -2,6 +2,8 @@ package kernel
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
 	"fmt"
 	"math/rand/v2"
 	"unsafe"
@@ -15,11 +17,54 @@ import (
 type SignatureType byte
 
 const (
-	// sign everything
-	SighashAll SignatureType = iota
-	// SIGHASH_NONE
-	// SIGHASH_SINGLE
-	// SIGHASH_ANYONECANPAY
+	// SighashAll signs all the inputs and outputs of the transaction
+	SighashAll SignatureType = 0x01
+	// SighashNone signs all the inputs but none of the outputs, anyone can decide where the funds go
+	SighashNone SignatureType = 0x02
+	// SighashSingle signs all the inputs and only the output with the same index as the input being signed
+	SighashSingle SignatureType = 0x03
+	// SighashAnyoneCanPay can be combined with the rest of types so that only the input being signed is committed,
+	// which allows others to add their own inputs (crowdfunding)
+	SighashAnyoneCanPay SignatureType = 0x80
+)
+
+// BaseType returns the signature type without the SighashAnyoneCanPay modifier
+func (st SignatureType) BaseType() SignatureType {
+	return st &^ SighashAnyoneCanPay
+}
+
+// IsAnyoneCanPay checks if the signature only commits to the input being signed
+func (st SignatureType) IsAnyoneCanPay() bool {
+	return st&SighashAnyoneCanPay != 0
+}
+
+// IsValid checks if the signature type is one of the types supported
+func (st SignatureType) IsValid() bool {
+	switch st.BaseType() {
+	case SighashAll, SighashNone, SighashSingle:
+		return true
+	default:
+		return false
+	}
+}
+
+const (
+	// TxVersionLegacy transactions are hashed using the legacy text based serialization
+	TxVersionLegacy uint = 0
+	// TxVersionCanonicalSerialization transactions are hashed using the canonical binary serialization, the legacy
+	// serialization is kept so that the transactions of existing chains remain valid
+	TxVersionCanonicalSerialization uint = 1 << 0
+)
+
+// LockTimeThreshold is the value from which the lock time of a transaction is interpreted as a Unix time instead of
+// as a block height
+const LockTimeThreshold = 500000000
+
+const (
+	// SequenceLockTimeTypeFlag marks the relative lock time of an input as a number of seconds instead of blocks
+	SequenceLockTimeTypeFlag uint = 1 << 22
+	// SequenceLockTimeMask extracts the relative lock time from the sequence of an input
+	SequenceLockTimeMask = SequenceLockTimeTypeFlag - 1
 )
 
 // Transaction represents the atomic unit of the blockchain
@@ -33,12 +78,17 @@ type Transaction struct {
 	// Vout are the destination of the funds
 	Vout []TxOutput
 
-	// Version, lock time...
+	// LockTime is the block height or Unix time (if bigger or equal than LockTimeThreshold) from which the transaction
+	// can be included in a block. A lock time of 0 means that the transaction is not locked
+	LockTime uint
+
+	// Version determines how the transaction is serialized for hashing (see TxVersionCanonicalSerialization)
+	Version uint
 }
 
 // NewTransaction creates a new transaction with the given inputs and outputs
 func NewTransaction(inputs []TxInput, outputs []TxOutput) *Transaction {
-	return &Transaction{ID: nil, Vin: inputs, Vout: outputs}
+	return &Transaction{ID: nil, Vin: inputs, Vout: outputs, Version: TxVersionCanonicalSerialization}
 }
 
 // NewCoinbaseTransaction creates a new transaction that pays the miners for their work
@@ -57,15 +107,32 @@ func NewCoinbaseTransaction(to string, reward, txFee uint) *Transaction {
 	return NewTransaction([]TxInput{input}, []TxOutput{rewardOutput})
 }
 
+// NewTransactionWithLockTime creates a new transaction that can't be included in a block before the lock time
+func NewTransactionWithLockTime(inputs []TxInput, outputs []TxOutput, lockTime uint) *Transaction {
+	tx := NewTransaction(inputs, outputs)
+	tx.LockTime = lockTime
+
+	return tx
+}
+
 func (tx *Transaction) SetID(hash []byte) {
 	tx.ID = hash
 }
 
+// HasCanonicalSerialization returns whether the transaction is hashed using the canonical binary serialization
+func (tx *Transaction) HasCanonicalSerialization() bool {
+	return tx.Version&TxVersionCanonicalSerialization != 0
+}
+
 // Assemble retrieves all the data from the transaction in order to perform operations
-// like extracting the tx ID
+// like extracting the tx ID. The serialization used depends on the version of the transaction
 func (tx *Transaction) Assemble() []byte {
 	var data []byte
 
+	if tx.HasCanonicalSerialization() {
+		return tx.assembleCanonical()
+	}
+
 	if len(tx.Vin) > 0 {
 		// add some static data to prevent hash collisions
 		data = append(data, []byte("Inputs:")...)
@@ -76,6 +143,7 @@ func (tx *Transaction) Assemble() []byte {
 		data = append(data, []byte(fmt.Sprintf("%d", input.Vout))...)
 		data = append(data, []byte(input.ScriptSig)...)
 		data = append(data, []byte(input.PubKey)...)
+		data = append(data, input.assembleSequence()...)
 	}
 
 	if len(tx.Vout) > 0 {
@@ -89,37 +157,134 @@ func (tx *Transaction) Assemble() []byte {
 		data = append(data, []byte(output.PubKey)...)
 	}
 
+	// only include the lock time when set, so the IDs of the transactions that are not locked don't change
+	if tx.LockTime > 0 {
+		data = append(data, []byte(fmt.Sprintf("LockTime:%d", tx.LockTime))...)
+	}
+
 	return data
 }
 
-// AssembleForSigning retrieves all data from the transaction to perform operations
-// like generating the signature for unlocking outputs. Differs from Assemble because
-// the input.ScriptSig field must be not included (otherwise the transaction can't be verified)
-func (tx *Transaction) AssembleForSigning() []byte {
+// assembleCanonical serializes the transaction fields in order: lists and variable length fields are prefixed by their
+// length and numbers are encoded as varints, so different transactions can't produce the same data. The ID is not
+// included because is the hash of this data
+func (tx *Transaction) assembleCanonical() []byte {
 	var data []byte
 
-	if len(tx.Vin) > 0 {
-		// add some static data to prevent hash collisions
-		data = append(data, []byte("Inputs:")...)
-	}
+	data = binary.AppendUvarint(data, uint64(tx.Version))
 
+	data = binary.AppendUvarint(data, uint64(len(tx.Vin)))
 	for _, input := range tx.Vin {
-		data = append(data, input.Txid...)
-		data = append(data, []byte(fmt.Sprintf("%d", input.Vout))...)
+		data = appendOutpoint(data, input)
+		data = appendLengthPrefixed(data, []byte(input.ScriptSig))
+		data = appendLengthPrefixed(data, []byte(input.PubKey))
+		data = binary.AppendUvarint(data, uint64(input.Sequence))
 	}
 
-	if len(tx.Vout) > 0 {
-		// add some static data to prevent hash collisions
-		data = append(data, []byte("Outputs:")...)
+	data = binary.AppendUvarint(data, uint64(len(tx.Vout)))
+	for _, output := range tx.Vout {
+		data = appendOutput(data, output)
 	}
 
-	for _, output := range tx.Vout {
-		data = append(data, []byte(fmt.Sprintf("%d", output.Amount))...)
-		data = append(data, []byte(output.ScriptPubKey)...)
-		data = append(data, []byte(output.PubKey)...)
+	return binary.AppendUvarint(data, uint64(tx.LockTime))
+}
+
+// AssembleForSigning retrieves the data committed by the signature of the input with index inputIdx, which spends
+// spentOutput. Follows the same approach as BIP143: the payload is different for each input and contains the script
+// and amount of the output being spent, so signatures can't be replayed across inputs and offline signers can know
+// the fee without looking up the previous transactions. The signature type decides which inputs and outputs are
+// committed:
+//   - SighashAll: all the inputs and outputs
+//   - SighashNone: all the inputs and none of the outputs
+//   - SighashSingle: all the inputs and the output with the same index as the input being signed
+//   - SighashAnyoneCanPay: only the input being signed (combined with any of the types above)
+//
+// The version of the transaction and the signature type are committed as well, so they can't be modified once the
+// input is signed
+func (tx *Transaction) AssembleForSigning(inputIdx uint, spentOutput TxOutput, sigType SignatureType) ([]byte, error) {
+	var data []byte
+
+	if !sigType.IsValid() {
+		return nil, fmt.Errorf("invalid signature type %d", sigType)
 	}
 
-	return data
+	if inputIdx >= uint(len(tx.Vin)) {
+		return nil, fmt.Errorf("input %d does not exist in the transaction", inputIdx)
+	}
+
+	// the rest of inputs are only committed if the signature is not SighashAnyoneCanPay, the owners of the rest of
+	// inputs can update their sequence unless all the outputs are committed
+	var prevOuts, sequences []byte
+	if !sigType.IsAnyoneCanPay() {
+		for _, input := range tx.Vin {
+			prevOuts = appendOutpoint(prevOuts, input)
+			if sigType.BaseType() == SighashAll {
+				sequences = binary.AppendUvarint(sequences, uint64(input.Sequence))
+			}
+		}
+	}
+
+	var outputs []byte
+	switch sigType.BaseType() { //nolint:exhaustive // SighashNone does not commit outputs
+	case SighashAll:
+		for _, output := range tx.Vout {
+			outputs = appendOutput(outputs, output)
+		}
+	case SighashSingle:
+		if inputIdx >= uint(len(tx.Vout)) {
+			return nil, fmt.Errorf("there is no output with the same index as input %d", inputIdx)
+		}
+		outputs = appendOutput(outputs, tx.Vout[inputIdx])
+	}
+
+	// the position of the input can change when others add their inputs (SighashAnyoneCanPay)
+	position := inputIdx
+	if sigType.IsAnyoneCanPay() {
+		position = 0
+	}
+
+	input := tx.Vin[inputIdx]
+	data = binary.AppendUvarint(data, uint64(tx.Version))
+	data = appendDigest(data, prevOuts)
+	data = appendDigest(data, sequences)
+	data = binary.AppendUvarint(data, uint64(position))
+	data = appendOutpoint(data, input)
+	data = appendLengthPrefixed(data, []byte(spentOutput.ScriptPubKey))
+	data = binary.AppendUvarint(data, uint64(spentOutput.Amount))
+	data = binary.AppendUvarint(data, uint64(input.Sequence))
+	data = appendDigest(data, outputs)
+	data = binary.AppendUvarint(data, uint64(tx.LockTime))
+
+	return append(data, byte(sigType)), nil
+}
+
+// appendOutpoint appends the reference to the output spent by the input
+func appendOutpoint(data []byte, input TxInput) []byte {
+	data = appendLengthPrefixed(data, input.Txid)
+	return binary.AppendUvarint(data, uint64(input.Vout))
+}
+
+// appendOutput appends the amount and the scriptPubKey of the output
+func appendOutput(data []byte, output TxOutput) []byte {
+	data = binary.AppendUvarint(data, uint64(output.Amount))
+	data = appendLengthPrefixed(data, []byte(output.ScriptPubKey))
+	return appendLengthPrefixed(data, []byte(output.PubKey))
+}
+
+// appendDigest appends the SHA-256 of the value, or zeroes if the value is empty (not committed)
+func appendDigest(data, value []byte) []byte {
+	if len(value) == 0 {
+		return append(data, make([]byte, sha256.Size)...)
+	}
+
+	digest := sha256.Sum256(value)
+	return append(data, digest[:]...)
+}
+
+// appendLengthPrefixed appends the value prefixed by its length, so that consecutive values can't be confused
+func appendLengthPrefixed(data, value []byte) []byte {
+	data = binary.AppendUvarint(data, uint64(len(value)))
+	return append(data, value...)
 }
 
 // HaveInputs checks if the transaction has any inputs
@@ -138,6 +303,25 @@ func (tx *Transaction) IsCoinbase() bool {
 	return len(tx.Vin) == 1 && len(tx.Vin[0].Txid) == 0
 }
 
+// IsFinal checks if the transaction can be included in a block with the height provided. Lock times based on Unix
+// time are compared against the median time past of the block instead of its timestamp, so miners can't move it
+func (tx *Transaction) IsFinal(height uint, medianTimePast int64) bool {
+	if tx.LockTime == 0 {
+		return true
+	}
+
+	if IsLockTimeHeight(tx.LockTime) {
+		return tx.LockTime <= height
+	}
+
+	return int64(tx.LockTime) <= medianTimePast //nolint:gosec // lock times are way below the int64 limit
+}
+
+// IsLockTimeHeight checks if the lock time represents a block height instead of a Unix time
+func IsLockTimeHeight(lockTime uint) bool {
+	return lockTime < LockTimeThreshold
+}
+
 func (tx *Transaction) OutputAmount() uint {
 	var amount uint
 	for _, out := range tx.Vout {
@@ -161,6 +345,7 @@ func (tx *Transaction) String() string {
 	msg := fmt.Sprintf("ID: %x\n", tx.ID)
 	msg = fmt.Sprintf("%s%s", msg, inputs)
 	msg = fmt.Sprintf("%s%s", msg, outputs)
+	msg = fmt.Sprintf("%sLockTime: %d\n", msg, tx.LockTime)
 
 	return msg
 }
@@ -176,6 +361,9 @@ func (tx *Transaction) Size() uint {
 		size += out.Size()
 	}
 
+	size += uint(unsafe.Sizeof(tx.LockTime))
+	size += uint(unsafe.Sizeof(tx.Version))
+
 	return size
 }
 
@@ -187,12 +375,13 @@ type TxInput struct {
 	// Vout is the index of the unspent transaction output (UTXO) that is going to be unlocked
 	Vout uint
 
-	// SignType is the type of signature required
-	// SignType SignatureType
-
 	// ScriptSig is the solved challenge presented by the output in order to unlock the funds
 	ScriptSig string
 
+	// Sequence is the relative lock time of the input: the number of blocks (or seconds if SequenceLockTimeTypeFlag
+	// is set) that must pass since the spent output was confirmed. A relative lock time of 0 means no lock
+	Sequence uint
+
 	// PubKey is the public key that unlocked the ScriptSig
 	// todo() eventually remove once we clear extracting addresses from ScriptSig
 	PubKey string
@@ -219,6 +408,40 @@ func NewInput(txid []byte, vout uint, scriptSig string, pubKey string) TxInput {
 	}
 }
 
+// NewInputWithSequence represents the source of the transactions that can't be spent until the relative lock time
+// contained in the sequence has passed since the output was confirmed
+func NewInputWithSequence(txid []byte, vout uint, scriptSig string, pubKey string, sequence uint) TxInput {
+	input := NewInput(txid, vout, scriptSig, pubKey)
+	input.Sequence = sequence
+
+	return input
+}
+
+// HasRelativeLockTime checks if the input can only be spent once the relative lock time has passed
+func (in *TxInput) HasRelativeLockTime() bool {
+	return SequenceLockTime(in.Sequence) > 0
+}
+
+// IsSequenceLockTimeSeconds checks if the relative lock time of the sequence represents seconds instead of blocks
+func IsSequenceLockTimeSeconds(sequence uint) bool {
+	return sequence&SequenceLockTimeTypeFlag != 0
+}
+
+// SequenceLockTime returns the relative lock time contained in the sequence
+func SequenceLockTime(sequence uint) uint {
+	return sequence & SequenceLockTimeMask
+}
+
+// assembleSequence returns the sequence data used for hashing the input. Only included when set, so the IDs of the
+// transactions without relative lock times don't change
+func (in *TxInput) assembleSequence() []byte {
+	if in.Sequence == 0 {
+		return []byte{}
+	}
+
+	return []byte(fmt.Sprintf("Sequence:%d", in.Sequence))
+}
+
 // CanUnlockOutputWith checks if the input can unlock the output
 // todo() eventually remove once we clear extracting addresses from ScriptSig
 func (in *TxInput) CanUnlockOutputWith(pubKey string) bool {
@@ -237,7 +460,7 @@ func (in *TxInput) UniqueTxoKey() string {
 }
 
 func (in *TxInput) Size() uint {
-	return uint(len(in.Txid) + int(unsafe.Sizeof(uint(0))) + len(in.ScriptSig) + len(in.PubKey))
+	return uint(len(in.Txid) + int(unsafe.Sizeof(uint(0))) + len(in.ScriptSig) + len(in.PubKey) + int(unsafe.Sizeof(in.Sequence)))
 }
 
 // EqualInput checks if the input is the same as the given input
@@ -247,11 +470,12 @@ func (in *TxInput) EqualInput(input TxInput) bool {
 
 func (in *TxInput) String() string {
 	return fmt.Sprintf(
-		"TxInput: id %x-%d from %s, scriptSig: %s",
+		"TxInput: id %x-%d from %s, scriptSig: %x, sequence: %d",
 		in.Txid,
 		in.Vout,
 		base58.Encode([]byte(in.PubKey)),
 		in.ScriptSig,
+		in.Sequence,
 	)
 }
 
@@ -282,6 +506,16 @@ func NewOutput(amount uint, scriptType script.ScriptType, pubKey string) TxOutpu
 	}
 }
 
+// NewDataOutput creates a zero amount output that carries the data provided (OP_RETURN <data>). The output is
+// unspendable so does not contain any public key
+func NewDataOutput(data []byte) TxOutput {
+	return TxOutput{
+		Amount:       0,
+		ScriptPubKey: script.NewNullDataScript(data),
+		PubKey:       "",
+	}
+}
+
 // CanBeUnlockedWith checks if the output can be unlocked with the given public key
 // todo() eventually remove once we clear extracting addresses from ScriptPubKey
 func (out *TxOutput) CanBeUnlockedWith(pubKey string) bool {
@@ -293,7 +527,7 @@ func (out *TxOutput) String() string {
 		"TxOutput: %f to %s, unlocking script %s",
 		ConvertFromChannoshisToCoins(out.Amount),
 		base58.Encode([]byte(out.PubKey)),
-		out.ScriptPubKey,
+		script.Disassemble(out.ScriptPubKey),
 	)
 }
 
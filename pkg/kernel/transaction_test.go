@@ -0,0 +1,66 @@
+package kernel //nolint:testpackage // don't create separate package for tests
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransaction_AssembleCanonical(t *testing.T) {
+	// test vectors of the canonical serialization, must not change unless a new version is introduced
+	tx := NewTransactionWithLockTime(
+		[]TxInput{NewInputWithSequence([]byte{0xaa, 0xbb}, 1, "sig", "pk", 10)},
+		[]TxOutput{{Amount: 300, ScriptPubKey: "spk", PubKey: "pk"}},
+		150,
+	)
+	assert.Equal(t, "010102aabb010373696702706b0a01ac020373706b02706b9601", hex.EncodeToString(tx.Assemble()))
+
+	// the ID is not part of the serialization
+	tx.SetID([]byte("id"))
+	assert.Equal(t, "010102aabb010373696702706b0a01ac020373706b02706b9601", hex.EncodeToString(tx.Assemble()))
+
+	empty := &Transaction{Version: TxVersionCanonicalSerialization}
+	assert.Equal(t, "01000000", hex.EncodeToString(empty.Assemble()))
+}
+
+func TestTransaction_AssembleCollisions(t *testing.T) {
+	// txid "tx" with vout 11 and txid "tx1" with vout 1 produce the same legacy serialization
+	tx1 := NewTransaction([]TxInput{NewInput([]byte("tx"), 11, "", "")}, []TxOutput{})
+	tx2 := NewTransaction([]TxInput{NewInput([]byte("tx1"), 1, "", "")}, []TxOutput{})
+	assert.NotEqual(t, tx1.Assemble(), tx2.Assemble())
+
+	tx1.Version = TxVersionLegacy
+	tx2.Version = TxVersionLegacy
+	assert.Equal(t, tx1.Assemble(), tx2.Assemble())
+}
+
+func TestTransaction_AssembleLegacy(t *testing.T) {
+	// legacy transactions keep the text based serialization so that existing chains remain valid
+	tx := &Transaction{
+		Vin:      []TxInput{NewInput([]byte("tx"), 1, "sig", "pk")},
+		Vout:     []TxOutput{{Amount: 300, ScriptPubKey: "spk", PubKey: "pk"}},
+		LockTime: 150,
+	}
+	require.False(t, tx.HasCanonicalSerialization())
+	assert.Equal(t, "Inputs:tx1sigpkOutputs:300spkpkLockTime:150", string(tx.Assemble()))
+}
+
+func TestTransaction_AssembleForSigningCommitsVersion(t *testing.T) {
+	tx := NewTransaction(
+		[]TxInput{NewInput([]byte("tx"), 1, "", "pk")},
+		[]TxOutput{{Amount: 300, ScriptPubKey: "spk", PubKey: "pk"}},
+	)
+	spent := TxOutput{Amount: 500, ScriptPubKey: "spk", PubKey: "pk"}
+
+	payload, err := tx.AssembleForSigning(0, spent, SighashAll)
+	require.NoError(t, err)
+	assert.Equal(t, byte(TxVersionCanonicalSerialization), payload[0])
+
+	// changing the version invalidates the signatures of the inputs
+	tx.Version = TxVersionLegacy
+	legacyPayload, err := tx.AssembleForSigning(0, spent, SighashAll)
+	require.NoError(t, err)
+	assert.NotEqual(t, payload, legacyPayload)
+}
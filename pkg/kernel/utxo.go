@@ -10,6 +10,16 @@ type UTXO struct {
 	TxID   []byte
 	OutIdx uint
 	Output TxOutput
+	// Height of the block that contains the transaction that created the output
+	Height uint
+	// Coinbase is set if the output was created by a coinbase transaction
+	Coinbase bool
+}
+
+// IsMature checks whether the output can be spent by a transaction included in a block with the height provided.
+// Coinbase outputs can only be spent once coinbaseMaturity blocks have been added on top of the block that created them
+func (utxo *UTXO) IsMature(height, coinbaseMaturity uint) bool {
+	return !utxo.Coinbase || height >= utxo.Height+coinbaseMaturity
 }
 
 // EqualInput checks if the input is the same as the given input
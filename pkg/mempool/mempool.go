@@ -1,8 +1,14 @@
 package mempool
 
 import (
+	"fmt"
+	"maps"
+	"math"
+	"math/bits"
+	"slices"
 	"sort"
 	"sync"
+	"time"
 	"unsafe"
 
 	cerror "github.com/yago-123/chainnet/pkg/errs"
@@ -15,15 +21,59 @@ import (
 
 const MemPoolObserverID = "mempool-observer"
 
+const (
+	// IncrementalRelayFeeRate is the fee rate (channoshis per byte) added on top of the fee rate of the last evicted
+	// transaction when raising the dynamic minimum fee rate. Prevents cheap replays of the evicted transactions
+	IncrementalRelayFeeRate = 1
+	// RollingMinFeeRateHalfLife is the time it takes for the dynamic minimum fee rate to halve once the mempool
+	// stops evicting transactions
+	RollingMinFeeRateHalfLife = 12 * time.Hour
+	// MaxAncestors is the maximum number of unconfirmed ancestors a transaction can have, itself included
+	MaxAncestors = 25
+	// MaxDescendants is the maximum number of unconfirmed descendants a transaction can have, itself included
+	MaxDescendants = 25
+)
+
 type TxFeePair struct {
 	Transaction *kernel.Transaction
 	Fee         uint
+	// Time is the moment the transaction entered the mempool, used for expiring transactions that never confirm
+	Time time.Time
 }
 
 func (t TxFeePair) Size() uint {
 	return t.Transaction.Size() + uint(unsafe.Sizeof(t.Fee))
 }
 
+// FeeRate returns the fee paid per byte of transaction
+func (t TxFeePair) FeeRate() float64 {
+	if t.Transaction.Size() == 0 {
+		return 0
+	}
+
+	return float64(t.Fee) / float64(t.Transaction.Size())
+}
+
+// hasHigherFeeRate compares the fee rates of both pairs without resorting to floating point divisions. The products
+// are calculated with 128 bits so they can't overflow
+func hasHigherFeeRate(a, b TxFeePair) bool {
+	aHi, aLo := bits.Mul64(uint64(a.Fee), uint64(b.Transaction.Size()))
+	bHi, bLo := bits.Mul64(uint64(b.Fee), uint64(a.Transaction.Size()))
+
+	if aHi != bHi {
+		return aHi > bHi
+	}
+
+	return aLo > bLo
+}
+
+// TxValidator validates again the transactions returned to the mempool when a block is disconnected from the chain,
+// and calculates the fee they pay
+type TxValidator interface {
+	ValidateTx(tx *kernel.Transaction) error
+	CalculateTxFee(tx *kernel.Transaction) (uint, error)
+}
+
 type MemPool struct {
 	// pairs is a slice of transactions and their corresponding fees
 	pairs []TxFeePair
@@ -33,36 +83,116 @@ type MemPool struct {
 	// transactions that are going to be invalid after a block addition. The key is the STXO key and the value is
 	// the transaction ID that is spending it
 	inputSet map[string][]string
-	// maxNumberTxs is the maximum number of transactions the mempool can hold
-	maxNumberTxs uint
+	// parents keeps track of the transactions contained in the mempool whose outputs are spent by each transaction,
+	// the key is the ID of the child transaction
+	parents map[string][]string
+	// children keeps track of the transactions contained in the mempool that spend outputs of each transaction, the
+	// key is the ID of the parent transaction
+	children map[string][]string
+	// size is the sum of the sizes of the transactions contained in the mempool
+	size uint
+	// maxSize is the maximum number of bytes the transactions contained in the mempool can add up to
+	maxSize uint
+	// minRelayFeeRate is the static minimum fee rate (channoshis per byte) required to accept transactions
+	minRelayFeeRate uint
+	// rollingMinFeeRate is the dynamic minimum fee rate, raised each time transactions are evicted due to lack
+	// of space and decayed over time once the pressure is gone
+	rollingMinFeeRate float64
+	// lastRollingFeeUpdate is the last time rollingMinFeeRate was raised or decayed
+	lastRollingFeeUpdate time.Time
+	// validator is used for returning transactions to the mempool when a block is disconnected, if not set those
+	// transactions are discarded
+	validator TxValidator
 
 	mu sync.Mutex
 }
 
-func NewMemPool(maxNumberTxs uint) *MemPool {
+func NewMemPool(maxSize, minRelayFeeRate uint) *MemPool {
 	return &MemPool{
-		pairs:        make([]TxFeePair, 0, maxNumberTxs),
-		txIDs:        make(map[string]*kernel.Transaction),
-		inputSet:     make(map[string][]string),
-		maxNumberTxs: maxNumberTxs,
+		pairs:           []TxFeePair{},
+		txIDs:           make(map[string]*kernel.Transaction),
+		inputSet:        make(map[string][]string),
+		parents:         make(map[string][]string),
+		children:        make(map[string][]string),
+		maxSize:         maxSize,
+		minRelayFeeRate: minRelayFeeRate,
 	}
 }
 
+// SetTxValidator sets the validator used for returning transactions to the mempool when a block is disconnected. It
+// can't be provided to NewMemPool because the validator itself depends on the mempool (see MemPoolExplorer)
+func (m *MemPool) SetTxValidator(validator TxValidator) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.validator = validator
+}
+
 func (m *MemPool) Len() int           { return len(m.pairs) }
 func (m *MemPool) Swap(i, j int)      { m.pairs[i], m.pairs[j] = m.pairs[j], m.pairs[i] }
-func (m *MemPool) Less(i, j int) bool { return m.pairs[i].Fee > m.pairs[j].Fee }
+func (m *MemPool) Less(i, j int) bool { return hasHigherFeeRate(m.pairs[i], m.pairs[j]) }
 
-// AppendTransaction adds a transaction to the MemPool sorting by highest transaction fee first
+// AppendTransaction adds a transaction to the MemPool sorting by highest fee rate first. The transaction can spend
+// outputs of other transactions contained in the mempool as long as the chain of unconfirmed transactions stays within
+// the MaxAncestors and MaxDescendants limits. Transactions spending the same inputs are replaced by the new one if it
+// pays enough fee (see replacedTxs). If there is not enough space left, transactions with lower fee rate are evicted
+// to make room for the new one
 func (m *MemPool) AppendTransaction(tx *kernel.Transaction, fee uint) error {
+	return m.AppendTransactionAt(tx, fee, time.Now())
+}
+
+// AppendTransactionAt works like AppendTransaction but records the time provided as the moment in which the
+// transaction entered the mempool. Used for restoring persisted transactions so that they still expire on time
+func (m *MemPool) AppendTransactionAt(tx *kernel.Transaction, fee uint, addedAt time.Time) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if uint(len(m.pairs)) >= m.maxNumberTxs {
-		return cerror.ErrMemPoolFull
+	if _, ok := m.txIDs[string(tx.ID)]; ok {
+		return cerror.ErrMemPoolTxAlreadyExists
+	}
+
+	pair := TxFeePair{Transaction: tx, Fee: fee, Time: addedAt}
+	if minFeeRate := m.minFeeRate(); pair.FeeRate() < minFeeRate {
+		return fmt.Errorf("%w: fee rate %.2f lower than %.2f", cerror.ErrMemPoolFeeTooLow, pair.FeeRate(), minFeeRate)
+	}
+
+	replaced, err := m.replacedTxs(pair)
+	if err != nil {
+		return err
+	}
+
+	parents := m.inMempoolParents(tx)
+	ancestors, err := m.checkChainLimits(parents)
+	if err != nil {
+		return err
+	}
+
+	for _, replacedPair := range replaced {
+		if ancestors[string(replacedPair.Transaction.ID)] {
+			return fmt.Errorf("transaction %x spends outputs of transaction %x, which is replaced by it", tx.ID, replacedPair.Transaction.ID)
+		}
+	}
+
+	evicted, err := m.makeRoom(pair, replaced, ancestors)
+	if err != nil {
+		return err
+	}
+
+	for _, replacedPair := range replaced {
+		m.removeTx(replacedPair.Transaction)
+	}
+
+	for _, evictedPair := range evicted {
+		m.removeTx(evictedPair.Transaction)
+
+		// raise the dynamic minimum fee rate so that transactions similar to the evicted ones are not accepted
+		m.rollingMinFeeRate = math.Max(m.rollingMinFeeRate, evictedPair.FeeRate()+IncrementalRelayFeeRate)
+		m.lastRollingFeeUpdate = time.Now()
 	}
 
 	// append the transaction to the mempool
-	m.pairs = append(m.pairs, TxFeePair{Transaction: tx, Fee: fee})
+	m.pairs = append(m.pairs, pair)
+	m.size += tx.Size()
 
 	// append the inputs to inputSet to keep track of which inputs are being spent in which txs
 	// this is useful for removing txs that are going to be invalid after a block addition
@@ -77,12 +207,257 @@ func (m *MemPool) AppendTransaction(tx *kernel.Transaction, fee uint) error {
 
 	m.txIDs[string(tx.ID)] = tx
 
+	// link the transaction with the parents it depends on, so that it's not retrieved or kept without them
+	if len(parents) > 0 {
+		m.parents[string(tx.ID)] = parents
+	}
+	for _, parentID := range parents {
+		m.children[parentID] = append(m.children[parentID], string(tx.ID))
+	}
+
 	// ensure MemPool is sorted after adding (may be faster ways, but this is fine for now)
 	sort.Sort(m)
 
 	return nil
 }
 
+// MinFeeRate returns the minimum fee rate (channoshis per byte) required for a transaction to enter the mempool
+func (m *MemPool) MinFeeRate() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.minFeeRate()
+}
+
+// minFeeRate returns the highest between the static and the dynamic minimum fee rate, decaying the dynamic
+// one based on the time passed since its last update
+func (m *MemPool) minFeeRate() float64 {
+	if m.rollingMinFeeRate > 0 {
+		elapsed := time.Since(m.lastRollingFeeUpdate)
+		m.rollingMinFeeRate /= math.Pow(2, elapsed.Hours()/RollingMinFeeRateHalfLife.Hours())
+		m.lastRollingFeeUpdate = time.Now()
+
+		// once decayed enough, the dynamic minimum fee rate is not relevant anymore
+		if m.rollingMinFeeRate < IncrementalRelayFeeRate/2.0 {
+			m.rollingMinFeeRate = 0
+		}
+	}
+
+	return math.Max(float64(m.minRelayFeeRate), m.rollingMinFeeRate)
+}
+
+// replacedTxs returns the transactions that are replaced by the pair provided: the ones spending any of its inputs
+// along with all their descendants. The replacement must pay a strictly higher absolute fee than all the replaced
+// transactions together and a strictly higher fee rate than each one of the transactions it conflicts with
+func (m *MemPool) replacedTxs(pair TxFeePair) ([]TxFeePair, error) {
+	conflicts := map[string]bool{}
+	for _, txInput := range pair.Transaction.Vin {
+		for _, txID := range m.inputSet[txInput.UniqueTxoKey()] {
+			conflicts[txID] = true
+		}
+	}
+
+	if len(conflicts) == 0 {
+		return []TxFeePair{}, nil
+	}
+
+	// the descendants of the conflicting transactions are spending outputs that will not exist anymore
+	replacedIDs := map[string]bool{}
+	for txID := range conflicts {
+		replacedIDs[txID] = true
+		for _, descendant := range m.descendants(txID) {
+			replacedIDs[descendant] = true
+		}
+	}
+
+	replaced := []TxFeePair{}
+	replacedFee := uint(0)
+	for _, p := range m.pairs {
+		txID := string(p.Transaction.ID)
+		if !replacedIDs[txID] {
+			continue
+		}
+
+		if conflicts[txID] && !hasHigherFeeRate(pair, p) {
+			return []TxFeePair{}, fmt.Errorf("%w: fee rate %.2f not higher than %.2f of conflicting transaction %x",
+				cerror.ErrMemPoolReplacementFeeTooLow, pair.FeeRate(), p.FeeRate(), p.Transaction.ID)
+		}
+
+		replaced = append(replaced, p)
+		replacedFee += p.Fee
+	}
+
+	if pair.Fee <= replacedFee {
+		return []TxFeePair{}, fmt.Errorf("%w: fee %d not higher than %d paid by the %d transactions replaced",
+			cerror.ErrMemPoolReplacementFeeTooLow, pair.Fee, replacedFee, len(replaced))
+	}
+
+	return replaced, nil
+}
+
+// inMempoolParents returns the IDs of the transactions contained in the mempool whose outputs are spent by tx
+func (m *MemPool) inMempoolParents(tx *kernel.Transaction) []string {
+	parents := []string{}
+	for _, txInput := range tx.Vin {
+		parentID := string(txInput.Txid)
+		if _, ok := m.txIDs[parentID]; ok && !slices.Contains(parents, parentID) {
+			parents = append(parents, parentID)
+		}
+	}
+
+	return parents
+}
+
+// checkChainLimits returns the ancestors of a transaction spending outputs of the parents provided, making sure that
+// neither the transaction nor its ancestors go beyond the limits of chained unconfirmed transactions
+func (m *MemPool) checkChainLimits(parents []string) (map[string]bool, error) {
+	ancestors := map[string]bool{}
+	for _, parentID := range parents {
+		ancestors[parentID] = true
+		for _, ancestorID := range m.ancestors(parentID) {
+			ancestors[ancestorID] = true
+		}
+	}
+
+	if len(ancestors)+1 > MaxAncestors {
+		return map[string]bool{}, fmt.Errorf("%w: %d unconfirmed ancestors, limit is %d",
+			cerror.ErrMemPoolChainTooLong, len(ancestors), MaxAncestors-1)
+	}
+
+	for ancestorID := range ancestors {
+		// the ancestor itself and the new transaction count towards the limit too
+		if len(m.descendants(ancestorID))+2 > MaxDescendants {
+			return map[string]bool{}, fmt.Errorf("%w: transaction %x already has the maximum number of descendants %d",
+				cerror.ErrMemPoolChainTooLong, ancestorID, MaxDescendants-1)
+		}
+	}
+
+	return ancestors, nil
+}
+
+// ancestors returns the IDs of the transactions contained in the mempool whose outputs are spent, directly or
+// indirectly, by the transaction provided
+func (m *MemPool) ancestors(txID string) []string {
+	return m.walkLinks(txID, m.parents)
+}
+
+// descendants returns the IDs of the transactions contained in the mempool that spend, directly or indirectly,
+// outputs of the transaction provided
+func (m *MemPool) descendants(txID string) []string {
+	return m.walkLinks(txID, m.children)
+}
+
+// walkLinks returns the IDs of the transactions reachable from txID following the links provided (parents or
+// children), txID itself not included
+func (m *MemPool) walkLinks(txID string, links map[string][]string) []string {
+	reached := []string{}
+	visited := map[string]bool{txID: true}
+	pending := []string{txID}
+
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+
+		for _, linkedID := range links[current] {
+			if visited[linkedID] {
+				continue
+			}
+
+			visited[linkedID] = true
+			reached = append(reached, linkedID)
+			pending = append(pending, linkedID)
+		}
+	}
+
+	return reached
+}
+
+// makeRoom returns the transactions with the lowest fee rate that must be evicted so there is enough space for the
+// pair provided, taking into account the space released by the transactions replaced. The descendants of the evicted
+// transactions are evicted too, while the ancestors of the pair are never evicted. Nothing is evicted if the pair
+// would need to evict transactions with equal or higher fee rate than its own
+func (m *MemPool) makeRoom(pair TxFeePair, replaced []TxFeePair, ancestors map[string]bool) ([]TxFeePair, error) {
+	if pair.Transaction.Size() > m.maxSize {
+		return []TxFeePair{}, cerror.ErrMemPoolFull
+	}
+
+	excluded := map[string]bool{}
+	for ancestorID := range ancestors {
+		excluded[ancestorID] = true
+	}
+
+	size := m.size
+	for _, p := range replaced {
+		excluded[string(p.Transaction.ID)] = true
+		size -= p.Transaction.Size()
+	}
+
+	// find the transactions that must be evicted, starting by the lowest fee rate
+	evicted := []TxFeePair{}
+	for i := len(m.pairs) - 1; i >= 0 && size+pair.Transaction.Size() > m.maxSize; i-- {
+		txID := string(m.pairs[i].Transaction.ID)
+		if excluded[txID] {
+			continue
+		}
+
+		if !hasHigherFeeRate(pair, m.pairs[i]) {
+			return []TxFeePair{}, fmt.Errorf("%w: fee rate %.2f too low to evict other transactions", cerror.ErrMemPoolFull, pair.FeeRate())
+		}
+
+		// descendants can't be kept once the transaction they depend on is evicted
+		for _, id := range append([]string{txID}, m.descendants(txID)...) {
+			if excluded[id] {
+				continue
+			}
+
+			excluded[id] = true
+			evictedPair := m.pair(id)
+			evicted = append(evicted, evictedPair)
+			size -= evictedPair.Transaction.Size()
+		}
+	}
+
+	return evicted, nil
+}
+
+// pair returns the transaction and fee pair contained in the mempool for the transaction ID provided
+func (m *MemPool) pair(txID string) TxFeePair {
+	for _, p := range m.pairs {
+		if string(p.Transaction.ID) == txID {
+			return p
+		}
+	}
+
+	return TxFeePair{}
+}
+
+// removeTx removes the transaction from the mempool along with the inputs and links tracked for it
+func (m *MemPool) removeTx(tx *kernel.Transaction) {
+	txID := string(tx.ID)
+	for i := len(m.pairs) - 1; i >= 0; i-- {
+		if string(m.pairs[i].Transaction.ID) == txID {
+			m.pairs = append(m.pairs[:i], m.pairs[i+1:]...)
+			m.size -= tx.Size()
+		}
+	}
+
+	for _, txInput := range tx.Vin {
+		removeLink(m.inputSet, txInput.UniqueTxoKey(), txID)
+	}
+
+	for _, parentID := range m.parents[txID] {
+		removeLink(m.children, parentID, txID)
+	}
+
+	for _, childID := range m.children[txID] {
+		removeLink(m.parents, childID, txID)
+	}
+
+	delete(m.parents, txID)
+	delete(m.children, txID)
+	delete(m.txIDs, txID)
+}
+
 // ContainsTx checks if the MemPool contains a transaction with the given txID
 func (m *MemPool) ContainsTx(txID string) bool {
 	m.mu.Lock()
@@ -92,8 +467,30 @@ func (m *MemPool) ContainsTx(txID string) bool {
 	return ok
 }
 
-// RetrieveTransactions retrieves the transactions from the MemPool with the highest fee
-func (m *MemPool) RetrieveTransactions(maxNumberTxs uint) ([]*kernel.Transaction, uint) {
+// RetrieveOutput returns the output spent by the input provided when it belongs to a transaction contained in the
+// mempool, which allows transactions to spend outputs that have not been confirmed yet
+func (m *MemPool) RetrieveOutput(input kernel.TxInput) (kernel.TxOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx, ok := m.txIDs[string(input.Txid)]
+	if !ok {
+		return kernel.TxOutput{}, fmt.Errorf("transaction %x not found in mempool", input.Txid)
+	}
+
+	if input.Vout >= uint(len(tx.Vout)) {
+		return kernel.TxOutput{}, fmt.Errorf("transaction %x does not contain output %d", input.Txid, input.Vout)
+	}
+
+	return tx.Vout[input.Vout], nil
+}
+
+// RetrieveTransactions retrieves the transactions from the MemPool with the highest ancestor package fee rate. The
+// package of a transaction contains the transaction itself and the ancestors that have not been retrieved yet, so
+// children paying high fees pull their parents into the block (CPFP). Ancestors are always retrieved before their
+// descendants. The total size of the transactions retrieved does not exceed maxSize, packages that don't fit
+// are skipped
+func (m *MemPool) RetrieveTransactions(maxNumberTxs, maxSize uint) ([]*kernel.Transaction, uint) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -102,90 +499,291 @@ func (m *MemPool) RetrieveTransactions(maxNumberTxs uint) ([]*kernel.Transaction
 	}
 
 	totalFee := uint(0)
+	totalSize := uint(0)
 	txs := make([]*kernel.Transaction, 0, maxNumberTxs)
+	retrieved := map[string]bool{}
+	skipped := map[string]bool{}
 	retrievedInputs := map[string]bool{}
 
+	pairs := make(map[string]TxFeePair, len(m.pairs))
 	for _, pair := range m.pairs {
-		// make sure that the transactions retrieved do not contain other txs having same inputs. Otherwise the
-		// miner will be mining blocks that will be discarded by the validator
-		transaction := pair.Transaction
-		hasConflictingInputs := false
-
-		// check if the transaction has conflicting inputs
-		for _, input := range transaction.Vin {
-			// if input have already been added, mark transaction as having conflicting inputs
-			if _, ok := retrievedInputs[input.UniqueTxoKey()]; ok {
-				hasConflictingInputs = true
-				break // exit the inner loop if a conflict is found
+		pairs[string(pair.Transaction.ID)] = pair
+	}
+
+	for uint(len(txs)) < maxNumberTxs {
+		// find the package with the highest fee rate among the transactions not retrieved nor skipped yet
+		var best []TxFeePair
+		bestFee, bestSize := uint(0), uint(0)
+		for _, pair := range m.pairs {
+			txID := string(pair.Transaction.ID)
+			if retrieved[txID] || skipped[txID] {
+				continue
+			}
+
+			pkg := m.ancestorPackage(txID, pairs, retrieved)
+			fee, size := packageFeeAndSize(pkg)
+			if best == nil || fee*bestSize > bestFee*size {
+				best, bestFee, bestSize = pkg, fee, size
 			}
 		}
 
-		// if there are conflicting inputs, skip adding this transaction
-		if hasConflictingInputs {
+		if best == nil {
+			break
+		}
+
+		// skip the package if it does not fit in the remaining space, smaller ones may still fit. Make sure that the
+		// transactions retrieved do not contain other txs having same inputs. Otherwise the miner will be mining
+		// blocks that will be discarded by the validator
+		if totalSize+bestSize > maxSize || uint(len(txs)+len(best)) > maxNumberTxs || hasConflictingInputs(best, retrievedInputs) {
+			skipped[string(best[len(best)-1].Transaction.ID)] = true
 			continue
 		}
 
-		// add the transaction to the list
-		txs = append(txs, transaction)
-		totalFee += pair.Fee
+		for _, pair := range best {
+			txs = append(txs, pair.Transaction)
+			retrieved[string(pair.Transaction.ID)] = true
 
-		// mark the inputs as used
-		for _, input := range transaction.Vin {
-			retrievedInputs[input.UniqueTxoKey()] = true
+			// mark the inputs as used
+			for _, input := range pair.Transaction.Vin {
+				retrievedInputs[input.UniqueTxoKey()] = true
+			}
 		}
 
-		// stop looking for txs if already reached the goal
-		if uint(len(txs)) == maxNumberTxs {
-			break
-		}
+		totalFee += bestFee
+		totalSize += bestSize
 	}
 
 	return txs, totalFee
 }
 
+// ancestorPackage returns the transaction along with its ancestors that have not been retrieved yet, sorted so
+// that ancestors always come before their descendants
+func (m *MemPool) ancestorPackage(txID string, pairs map[string]TxFeePair, retrieved map[string]bool) []TxFeePair {
+	pkg := []TxFeePair{}
+	visited := map[string]bool{}
+
+	var visit func(id string)
+	visit = func(id string) {
+		if visited[id] || retrieved[id] {
+			return
+		}
+
+		visited[id] = true
+		for _, parentID := range m.parents[id] {
+			visit(parentID)
+		}
+
+		pkg = append(pkg, pairs[id])
+	}
+
+	visit(txID)
+
+	return pkg
+}
+
+// packageFeeAndSize returns the total fee and size of the transactions contained in the package
+func packageFeeAndSize(pkg []TxFeePair) (uint, uint) {
+	fee, size := uint(0), uint(0)
+	for _, pair := range pkg {
+		fee += pair.Fee
+		size += pair.Transaction.Size()
+	}
+
+	return fee, size
+}
+
+// hasConflictingInputs checks if any of the transactions of the package spends inputs already used
+func hasConflictingInputs(pkg []TxFeePair, usedInputs map[string]bool) bool {
+	for _, pair := range pkg {
+		for _, input := range pair.Transaction.Vin {
+			if usedInputs[input.UniqueTxoKey()] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ExpireTransactions removes the transactions that entered the mempool before the time provided, along with their
+// descendants. The transactions removed are returned
+func (m *MemPool) ExpireTransactions(before time.Time) []*kernel.Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expired := map[string]bool{}
+	for _, pair := range m.pairs {
+		if !pair.Time.Before(before) {
+			continue
+		}
+
+		expired[string(pair.Transaction.ID)] = true
+		for _, descendant := range m.descendants(string(pair.Transaction.ID)) {
+			expired[descendant] = true
+		}
+	}
+
+	txs := []*kernel.Transaction{}
+	for txID := range expired {
+		txs = append(txs, m.txIDs[txID])
+		m.removeTx(m.txIDs[txID])
+	}
+
+	return txs
+}
+
 // ID returns the observer id
 func (m *MemPool) ID() string {
 	return MemPoolObserverID
 }
 
-// OnBlockAddition is called when a new block is added to the blockchain via the observer pattern
+// OnBlockAddition is called when a new block is added to the blockchain via the observer pattern. The transactions
+// confirmed by the block are removed, along with the ones spending the same inputs (double spends) and their
+// descendants. The descendants of the confirmed transactions are kept, given that their inputs are confirmed now
 func (m *MemPool) OnBlockAddition(block *kernel.Block) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	removeTx := map[string]bool{}
+	confirmed := map[string]bool{}
+	removeTx := map[string]*kernel.Transaction{}
 	for _, tx := range block.Transactions {
+		confirmed[string(tx.ID)] = true
+
 		// iterate over the inputs contained in the transaction
 		for _, txInput := range tx.Vin {
 			// if the input is in the inputSet, remove the txs that are spending it by adding them
 			// into the map removeTx
-			if txIDs, ok := m.inputSet[txInput.UniqueTxoKey()]; ok {
-				// add the all txs that are spending the input to the removeTx map
-				for _, txID := range txIDs {
-					removeTx[txID] = true
-				}
+			for _, txID := range m.inputSet[txInput.UniqueTxoKey()] {
+				removeTx[txID] = m.txIDs[txID]
 			}
+		}
+	}
 
-			// remove the input from the inputSet
-			delete(m.inputSet, txInput.UniqueTxoKey())
+	// the descendants of double spends are spending outputs that will never exist
+	doubleSpends := []string{}
+	for txID := range removeTx {
+		if !confirmed[txID] {
+			doubleSpends = append(doubleSpends, txID)
 		}
 	}
 
-	// remove txs that contain inputs in the block that are spent in the block, do it in reverse
-	// so that there are not out of bounds errors
-	for i := len(m.pairs) - 1; i >= 0; i-- {
-		tx := m.pairs[i]
-		if _, ok := removeTx[string(tx.Transaction.ID)]; ok {
-			m.pairs = append(m.pairs[:i], m.pairs[i+1:]...)
+	for _, txID := range doubleSpends {
+		for _, descendant := range m.descendants(txID) {
+			removeTx[descendant] = m.txIDs[descendant]
 		}
 	}
 
-	// remove the txs from the txIDs map
-	for k := range removeTx {
-		delete(m.txIDs, k)
+	// remove txs that contain inputs in the block that are spent in the block, along with the inputs tracked for them
+	for _, tx := range removeTx {
+		m.removeTx(tx)
 	}
 }
 
+// OnBlockRemoval is called when a block is disconnected from the blockchain via the observer pattern. Transactions
+// spending outputs created by the block are removed along with their descendants, given that those outputs are no
+// longer confirmed. Then the transactions of the block (except the coinbase) are validated again and returned to the
+// mempool, followed by the transactions removed here. Transactions that are no longer valid are discarded
+func (m *MemPool) OnBlockRemoval(block *kernel.Block) {
+	removed, validator := m.removeSpendersOf(block)
+	if validator == nil {
+		return
+	}
+
+	// the mutex can't be held from now on, the validator retrieves outputs of mempool transactions (MemPoolExplorer)
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() {
+			continue
+		}
+
+		_ = m.revalidateTx(validator, tx, time.Now())
+	}
+
+	// the transactions removed keep the time they entered the mempool originally
+	for _, ptx := range removed {
+		_ = m.revalidateTx(validator, ptx.Transaction, ptx.Time)
+	}
+}
+
+// removeSpendersOf removes the transactions spending outputs created by the block along with their descendants.
+// Returns the transactions removed (ancestors first) and the validator set, read while holding the mutex
+func (m *MemPool) removeSpendersOf(block *kernel.Block) ([]PersistedTx, TxValidator) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	createdTxs := map[string]bool{}
+	for _, tx := range block.Transactions {
+		createdTxs[string(tx.ID)] = true
+	}
+
+	removeTx := map[string]bool{}
+	for _, pair := range m.pairs {
+		for _, txInput := range pair.Transaction.Vin {
+			if _, ok := createdTxs[string(txInput.Txid)]; ok {
+				removeTx[string(pair.Transaction.ID)] = true
+				break
+			}
+		}
+	}
+
+	// the descendants of the txs removed can't be kept without them
+	for txID := range maps.Clone(removeTx) {
+		for _, descendant := range m.descendants(txID) {
+			removeTx[descendant] = true
+		}
+	}
+
+	// keep the txs removed in the order they must be added back before removing them
+	removed := []PersistedTx{}
+	for _, ptx := range m.snapshot() {
+		if removeTx[string(ptx.Transaction.ID)] {
+			removed = append(removed, ptx)
+		}
+	}
+
+	// remove the txs and the inputs tracked for them
+	for txID := range removeTx {
+		m.removeTx(m.txIDs[txID])
+	}
+
+	return removed, m.validator
+}
+
+// revalidateTx validates the transaction and adds it back to the mempool, recording addedAt as the time it entered it
+func (m *MemPool) revalidateTx(validator TxValidator, tx *kernel.Transaction, addedAt time.Time) error {
+	if m.ContainsTx(string(tx.ID)) {
+		return nil
+	}
+
+	if err := validator.ValidateTx(tx); err != nil {
+		return fmt.Errorf("error validating transaction %x: %w", tx.ID, err)
+	}
+
+	fee, err := validator.CalculateTxFee(tx)
+	if err != nil {
+		return fmt.Errorf("error calculating transaction fee for %x: %w", tx.ID, err)
+	}
+
+	return m.AppendTransactionAt(tx, fee, addedAt)
+}
+
+// removeLink stops tracking txID as one of the transactions linked to the key provided (spenders of an input,
+// parents or children of a transaction)
+func removeLink(links map[string][]string, key, txID string) {
+	linked := links[key]
+	for i := len(linked) - 1; i >= 0; i-- {
+		if linked[i] == txID {
+			linked = append(linked[:i], linked[i+1:]...)
+		}
+	}
+
+	if len(linked) == 0 {
+		delete(links, key)
+		return
+	}
+
+	links[key] = linked
+}
+
 // OnTxAddition is called when a new tx is added to the mempool via the observer pattern
 func (m *MemPool) OnTxAddition(_ *kernel.Transaction) {
 	// do nothing
@@ -215,9 +813,18 @@ func (m *MemPool) RegisterMetrics(register *prometheus.Registry) {
 		},
 	)
 
-	monitor.NewMetric(register, monitor.Gauge, "mempool_max_size", "Maximum number of transactions the mempool can hold",
+	monitor.NewMetric(register, monitor.Gauge, "mempool_max_size", "Maximum size in bytes of the transactions the mempool can hold",
 		func() float64 {
-			return float64(m.maxNumberTxs)
+			return float64(m.maxSize)
+		},
+	)
+
+	monitor.NewMetric(register, monitor.Gauge, "mempool_min_fee_rate", "Minimum fee rate in channoshis per byte required to enter the mempool",
+		func() float64 {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			return m.minFeeRate()
 		},
 	)
 
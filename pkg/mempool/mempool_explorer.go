@@ -29,3 +29,9 @@ func (me *MemPoolExplorer) RetrieveTx(txID string) (*kernel.Transaction, error)
 
 	return me.mempool.txIDs[txID], nil
 }
+
+// RetrieveOutput returns the output spent by the input provided when it belongs to a transaction contained in the
+// mempool (see MemPool.RetrieveOutput)
+func (me *MemPoolExplorer) RetrieveOutput(input kernel.TxInput) (kernel.TxOutput, error) {
+	return me.mempool.RetrieveOutput(input)
+}
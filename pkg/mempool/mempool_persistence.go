@@ -0,0 +1,90 @@
+package mempool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/yago-123/chainnet/pkg/kernel"
+)
+
+const mempoolFileMode = 0o600
+
+// PersistedTx is a transaction persisted to disk along with the time it entered the mempool
+type PersistedTx struct {
+	Transaction *kernel.Transaction
+	Time        time.Time
+}
+
+// Snapshot returns the transactions contained in the mempool along with the time they entered it. Ancestors are
+// always returned before their descendants, so the transactions can be added back in the same order
+func (m *MemPool) Snapshot() []PersistedTx {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.snapshot()
+}
+
+// snapshot works like Snapshot, the caller must hold the mempool mutex
+func (m *MemPool) snapshot() []PersistedTx {
+	pairs := make(map[string]TxFeePair, len(m.pairs))
+	for _, pair := range m.pairs {
+		pairs[string(pair.Transaction.ID)] = pair
+	}
+
+	snapshot := []PersistedTx{}
+	added := map[string]bool{}
+	for _, pair := range m.pairs {
+		for _, p := range m.ancestorPackage(string(pair.Transaction.ID), pairs, added) {
+			snapshot = append(snapshot, PersistedTx{Transaction: p.Transaction, Time: p.Time})
+			added[string(p.Transaction.ID)] = true
+		}
+	}
+
+	return snapshot
+}
+
+// Persist writes the transactions contained in the mempool to the file provided, so they can be restored after a
+// restart (see LoadPersistedTxs). The file is replaced atomically so a crash in the middle does not corrupt the
+// previous version. Returns the number of transactions persisted
+func (m *MemPool) Persist(path string) (int, error) {
+	persisted := m.Snapshot()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(persisted); err != nil {
+		return 0, fmt.Errorf("error encoding mempool transactions: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), mempoolFileMode); err != nil {
+		return 0, fmt.Errorf("error writing mempool file %s: %w", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return 0, fmt.Errorf("error replacing mempool file %s: %w", path, err)
+	}
+
+	return len(persisted), nil
+}
+
+// LoadPersistedTxs reads the transactions persisted by Persist. A missing file is not considered an error, there is
+// nothing to restore in that case
+func LoadPersistedTxs(path string) ([]PersistedTx, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []PersistedTx{}, nil
+	}
+	if err != nil {
+		return []PersistedTx{}, fmt.Errorf("error reading mempool file %s: %w", path, err)
+	}
+
+	persisted := []PersistedTx{}
+	if err = gob.NewDecoder(bytes.NewReader(data)).Decode(&persisted); err != nil {
+		return []PersistedTx{}, fmt.Errorf("error decoding mempool file %s: %w", path, err)
+	}
+
+	return persisted, nil
+}
@@ -2,10 +2,15 @@ package mempool //nolint:testpackage // don't create separate package for tests
 
 import (
 	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
+	cerror "github.com/yago-123/chainnet/pkg/errs"
 	"github.com/yago-123/chainnet/pkg/kernel"
 	"github.com/yago-123/chainnet/pkg/script"
 
@@ -79,7 +84,7 @@ var txIncompatibleWithTx1 = TxFeePair{ //nolint: gochecknoglobals // no need to
 var txFeePairs = []TxFeePair{tx1, tx2, tx3, tx4, tx5, tx6} //nolint: gochecknoglobals // no need to lint this global variable
 
 func TestRetrieveTxsWithoutIncompatibilities(t *testing.T) {
-	mempool := NewMemPool(100)
+	mempool := NewMemPool(100000, 0)
 	// add 6 txs to the mempool
 	for _, v := range txFeePairs {
 		require.NoError(t, mempool.AppendTransaction(v.Transaction, v.Fee))
@@ -88,47 +93,48 @@ func TestRetrieveTxsWithoutIncompatibilities(t *testing.T) {
 	assert.Equal(t, 6, mempool.Len())
 
 	// checks for RetrieveTransactions
-	txs, _ := mempool.RetrieveTransactions(0)
+	txs, _ := mempool.RetrieveTransactions(0, math.MaxUint)
 	assert.Empty(t, txs)
 
-	txs, fee := mempool.RetrieveTransactions(1)
+	txs, fee := mempool.RetrieveTransactions(1, math.MaxUint)
 	assert.Len(t, txs, 1)
 	assert.Equal(t, uint(10), fee)
 	assert.Equal(t, []byte("id1"), txs[0].Vin[0].Txid)
 
-	txs, fee = mempool.RetrieveTransactions(2)
+	txs, fee = mempool.RetrieveTransactions(2, math.MaxUint)
 	assert.Len(t, txs, 2)
 	assert.Equal(t, uint(19), fee)
 	assert.Equal(t, []byte("id1"), txs[0].Vin[0].Txid)
 	assert.Equal(t, []byte("id5"), txs[1].Vin[0].Txid)
 
-	txs, fee = mempool.RetrieveTransactions(3)
+	txs, fee = mempool.RetrieveTransactions(3, math.MaxUint)
 	assert.Len(t, txs, 3)
 	assert.Equal(t, uint(25), fee)
 	assert.Equal(t, []byte("id1"), txs[0].Vin[0].Txid)
 	assert.Equal(t, []byte("id5"), txs[1].Vin[0].Txid)
 	assert.Equal(t, []byte("id6"), txs[2].Vin[0].Txid)
 
-	txs, fee = mempool.RetrieveTransactions(1)
+	txs, fee = mempool.RetrieveTransactions(1, math.MaxUint)
 	assert.Len(t, txs, 1)
 	assert.Equal(t, uint(10), fee)
 	assert.Equal(t, []byte("id1"), txs[0].Vin[0].Txid)
 
-	txs, fee = mempool.RetrieveTransactions(10)
+	txs, fee = mempool.RetrieveTransactions(10, math.MaxUint)
 	assert.Equal(t, uint(31), fee)
 	assert.Len(t, txs, 6)
 }
 
 func TestRetrieveTxsWithIncompatibilities(t *testing.T) {
-	mempool := NewMemPool(100)
+	mempool := NewMemPool(100000, 0)
 
 	for _, v := range txFeePairs {
 		require.NoError(t, mempool.AppendTransaction(v.Transaction, v.Fee))
 	}
 
-	require.NoError(t, mempool.AppendTransaction(txIncompatibleWithTx1.Transaction, txIncompatibleWithTx1.Fee))
+	// the conflicting transaction does not pay enough fee to replace tx1
+	require.ErrorIs(t, mempool.AppendTransaction(txIncompatibleWithTx1.Transaction, txIncompatibleWithTx1.Fee), cerror.ErrMemPoolReplacementFeeTooLow)
 
-	txs, fee := mempool.RetrieveTransactions(3)
+	txs, fee := mempool.RetrieveTransactions(3, math.MaxUint)
 	assert.Len(t, txs, 3)
 	assert.Equal(t, uint(25), fee)
 	assert.Equal(t, []byte("id1"), txs[0].Vin[0].Txid)
@@ -136,22 +142,51 @@ func TestRetrieveTxsWithIncompatibilities(t *testing.T) {
 	assert.Equal(t, []byte("id6"), txs[2].Vin[0].Txid)
 }
 
+func TestRetrieveTxsWithinSize(t *testing.T) {
+	mempool := NewMemPool(100000, 0)
+
+	for _, v := range txFeePairs {
+		require.NoError(t, mempool.AppendTransaction(v.Transaction, v.Fee))
+	}
+
+	// transaction with the highest fee but too big to fit together with the rest
+	bigTx := &kernel.Transaction{
+		ID:   []byte("big-tx"),
+		Vin:  []kernel.TxInput{kernel.NewInput([]byte("id-big"), 1, strings.Repeat("sig", 100), "pubkey-big")},
+		Vout: []kernel.TxOutput{kernel.NewOutput(1, script.P2PK, "pubkey-big")},
+	}
+	require.NoError(t, mempool.AppendTransaction(bigTx, 100))
+
+	txSize := tx1.Transaction.Size()
+
+	// the big transaction doesn't fit, the transactions with the next highest fee are retrieved instead
+	txs, fee := mempool.RetrieveTransactions(10, 2*txSize)
+	assert.Len(t, txs, 2)
+	assert.Equal(t, uint(19), fee)
+	assert.Equal(t, []byte("id1"), txs[0].Vin[0].Txid)
+	assert.Equal(t, []byte("id5"), txs[1].Vin[0].Txid)
+
+	// the big transaction fits if there is enough space
+	txs, fee = mempool.RetrieveTransactions(10, bigTx.Size()+txSize)
+	assert.Len(t, txs, 2)
+	assert.Equal(t, uint(110), fee)
+	assert.Equal(t, []byte("big-tx"), txs[0].ID)
+
+	// no transaction fits
+	txs, _ = mempool.RetrieveTransactions(10, txSize-1)
+	assert.Empty(t, txs)
+}
+
 func TestMemPoolInputSet(t *testing.T) {
-	mempool := NewMemPool(100)
+	mempool := NewMemPool(100000, 0)
 
 	// add 6 txs to the mempool
 	for _, v := range txFeePairs {
 		require.NoError(t, mempool.AppendTransaction(v.Transaction, v.Fee))
 	}
 
-	// add tx that shares input with tx1
-	require.NoError(t, mempool.AppendTransaction(txIncompatibleWithTx1.Transaction, txIncompatibleWithTx1.Fee))
-
 	expectedInputSet := map[string][]string{
-		fmt.Sprintf("%x-%d", "id1", 1): []string{
-			"tx1",
-			"txIncompatibleWithTx1",
-		},
+		fmt.Sprintf("%x-%d", "id1", 1): []string{"tx1"},
 		fmt.Sprintf("%x-%d", "id2", 1): []string{"tx2"},
 		fmt.Sprintf("%x-%d", "id3", 1): []string{"tx3"},
 		fmt.Sprintf("%x-%d", "id4", 1): []string{"tx4"},
@@ -162,27 +197,24 @@ func TestMemPoolInputSet(t *testing.T) {
 	assert.Equal(t, expectedInputSet, mempool.inputSet)
 
 	expectedTxIDs := map[string]*kernel.Transaction{
-		"tx1":                   tx1.Transaction,
-		"tx2":                   tx2.Transaction,
-		"tx3":                   tx3.Transaction,
-		"tx4":                   tx4.Transaction,
-		"tx5":                   tx5.Transaction,
-		"tx6":                   tx6.Transaction,
-		"txIncompatibleWithTx1": txIncompatibleWithTx1.Transaction,
+		"tx1": tx1.Transaction,
+		"tx2": tx2.Transaction,
+		"tx3": tx3.Transaction,
+		"tx4": tx4.Transaction,
+		"tx5": tx5.Transaction,
+		"tx6": tx6.Transaction,
 	}
 
 	assert.Equal(t, expectedTxIDs, mempool.txIDs)
 }
 
 func TestMemPoolOnBlockAddition(t *testing.T) {
-	mempool := NewMemPool(100)
+	mempool := NewMemPool(100000, 0)
 
 	for _, v := range txFeePairs {
 		require.NoError(t, mempool.AppendTransaction(v.Transaction, v.Fee))
 	}
 
-	require.NoError(t, mempool.AppendTransaction(txIncompatibleWithTx1.Transaction, txIncompatibleWithTx1.Fee))
-
 	mempool.OnBlockAddition(
 		&kernel.Block{
 			Transactions: []*kernel.Transaction{
@@ -210,9 +242,383 @@ func TestMemPoolOnBlockAddition(t *testing.T) {
 	assert.Equal(t, expectedTxIDs, mempool.txIDs)
 }
 
-func TestMemPoolMaxNumberTxsMempool(t *testing.T) {
-	mempool := NewMemPool(1)
+func TestMemPoolSortedByFeeRate(t *testing.T) {
+	mempool := NewMemPool(100000, 0)
+
+	// pays the highest fee, but requires way more space than the rest
+	bigTx := &kernel.Transaction{
+		ID:   []byte("big-tx"),
+		Vin:  []kernel.TxInput{kernel.NewInput([]byte("id-big"), 1, strings.Repeat("sig", 1000), "pubkey-big")},
+		Vout: []kernel.TxOutput{kernel.NewOutput(1, script.P2PK, "pubkey-big")},
+	}
+	require.NoError(t, mempool.AppendTransaction(bigTx, 20))
+	require.NoError(t, mempool.AppendTransaction(tx1.Transaction, tx1.Fee))
+	require.NoError(t, mempool.AppendTransaction(tx2.Transaction, tx2.Fee))
+
+	txs, fee := mempool.RetrieveTransactions(10, math.MaxUint)
+	assert.Equal(t, uint(32), fee)
+	require.Len(t, txs, 3)
+	assert.Equal(t, []byte("tx1"), txs[0].ID)
+	assert.Equal(t, []byte("tx2"), txs[1].ID)
+	assert.Equal(t, []byte("big-tx"), txs[2].ID)
+}
+
+func TestHasHigherFeeRate(t *testing.T) {
+	bigTx := &kernel.Transaction{
+		ID:   []byte("big-tx"),
+		Vin:  []kernel.TxInput{kernel.NewInput([]byte("id-big"), 1, strings.Repeat("sig", 1000), "pubkey-big")},
+		Vout: []kernel.TxOutput{kernel.NewOutput(1, script.P2PK, "pubkey-big")},
+	}
+
+	// the products of the fees and the sizes overflow 64 bits, the comparison must still hold
+	high := TxFeePair{Transaction: tx1.Transaction, Fee: math.MaxUint}
+	low := TxFeePair{Transaction: bigTx, Fee: math.MaxUint}
+	assert.True(t, hasHigherFeeRate(high, low))
+	assert.False(t, hasHigherFeeRate(low, high))
+	assert.False(t, hasHigherFeeRate(high, high))
+
+	low.Fee = math.MaxUint / 2
+	assert.True(t, hasHigherFeeRate(high, low))
+	assert.False(t, hasHigherFeeRate(low, high))
+}
+
+func TestMemPoolEvictsLowestFeeRate(t *testing.T) {
+	txSize := tx1.Transaction.Size()
+	mempool := NewMemPool(2*txSize, 0)
+
+	require.NoError(t, mempool.AppendTransaction(tx2.Transaction, tx2.Fee))
+	require.NoError(t, mempool.AppendTransaction(tx4.Transaction, tx4.Fee))
+	assert.InDelta(t, 0, mempool.MinFeeRate(), 0)
+
+	// tx with lower fee rate than the ones contained can't evict them
+	require.ErrorIs(t, mempool.AppendTransaction(tx3.Transaction, 0), cerror.ErrMemPoolFull)
+	assert.Equal(t, 2, mempool.Len())
+
+	// tx bigger than the mempool itself can't be accepted
+	bigTx := &kernel.Transaction{
+		ID:   []byte("big-tx"),
+		Vin:  []kernel.TxInput{kernel.NewInput([]byte("id-big"), 1, strings.Repeat("sig", 100), "pubkey-big")},
+		Vout: []kernel.TxOutput{kernel.NewOutput(1, script.P2PK, "pubkey-big")},
+	}
+	require.ErrorIs(t, mempool.AppendTransaction(bigTx, 1000), cerror.ErrMemPoolFull)
+
+	// tx with higher fee rate evicts the one with the lowest fee rate
+	require.NoError(t, mempool.AppendTransaction(tx1.Transaction, tx1.Fee))
+	assert.True(t, mempool.ContainsTx("tx1"))
+	assert.True(t, mempool.ContainsTx("tx2"))
+	assert.False(t, mempool.ContainsTx("tx4"))
+	assert.Equal(t, 2*txSize, mempool.size)
+	assert.NotContains(t, mempool.inputSet, fmt.Sprintf("%x-%d", "id4", 1))
+
+	// the minimum fee rate has been raised above the fee rate of the evicted tx
+	assert.InDelta(t, tx4.FeeRate()+IncrementalRelayFeeRate, mempool.MinFeeRate(), 0.001)
+	require.ErrorIs(t, mempool.AppendTransaction(tx5.Transaction, tx5.Fee), cerror.ErrMemPoolFeeTooLow)
+
+	// once the pressure is gone, the minimum fee rate decays until it's not relevant anymore
+	mempool.lastRollingFeeUpdate = time.Now().Add(-RollingMinFeeRateHalfLife)
+	assert.InDelta(t, (tx4.FeeRate()+IncrementalRelayFeeRate)/2, mempool.MinFeeRate(), 0.001)
+
+	mempool.lastRollingFeeUpdate = time.Now().Add(-RollingMinFeeRateHalfLife)
+	assert.InDelta(t, 0, mempool.MinFeeRate(), 0)
+}
+
+func TestMemPoolMinRelayFeeRate(t *testing.T) {
+	mempool := NewMemPool(100000, 1)
+
+	require.ErrorIs(t, mempool.AppendTransaction(tx1.Transaction, tx1.Fee), cerror.ErrMemPoolFeeTooLow)
+	require.NoError(t, mempool.AppendTransaction(tx1.Transaction, tx1.Transaction.Size()))
+}
+
+func TestMemPoolOnBlockRemoval(t *testing.T) {
+	mempool := NewMemPool(100000, 0)
+
+	for _, v := range txFeePairs {
+		require.NoError(t, mempool.AppendTransaction(v.Transaction, v.Fee))
+	}
+
+	// the block removed created the outputs spent by tx1 and tx3
+	mempool.OnBlockRemoval(
+		&kernel.Block{
+			Transactions: []*kernel.Transaction{
+				{ID: []byte("id1")},
+				{ID: []byte("id3")},
+			},
+		},
+	)
+
+	expectedInputSet := map[string][]string{
+		fmt.Sprintf("%x-%d", "id2", 1): []string{"tx2"},
+		fmt.Sprintf("%x-%d", "id4", 1): []string{"tx4"},
+		fmt.Sprintf("%x-%d", "id5", 1): []string{"tx5"},
+		fmt.Sprintf("%x-%d", "id6", 1): []string{"tx6"},
+	}
+
+	assert.Equal(t, expectedInputSet, mempool.inputSet)
+
+	expectedTxIDs := map[string]*kernel.Transaction{
+		"tx2": tx2.Transaction,
+		"tx4": tx4.Transaction,
+		"tx5": tx5.Transaction,
+		"tx6": tx6.Transaction,
+	}
+
+	assert.Equal(t, expectedTxIDs, mempool.txIDs)
+	assert.Len(t, mempool.pairs, 4)
+	assert.Equal(t, 4*tx2.Transaction.Size(), mempool.size)
+}
+
+// fakeTxValidator accepts all transactions except the ones provided, all of them paying the same fee
+type fakeTxValidator struct {
+	invalid map[string]bool
+}
+
+func (f *fakeTxValidator) ValidateTx(tx *kernel.Transaction) error {
+	if f.invalid[string(tx.ID)] {
+		return fmt.Errorf("transaction %s is not valid", tx.ID)
+	}
+
+	return nil
+}
+
+func (f *fakeTxValidator) CalculateTxFee(_ *kernel.Transaction) (uint, error) {
+	return 5, nil
+}
+
+func TestMemPoolOnBlockRemovalReturnsTxs(t *testing.T) {
+	mempool := NewMemPool(100000, 0)
+	mempool.SetTxValidator(&fakeTxValidator{invalid: map[string]bool{"grandchild": true}})
+
+	confirmed := &kernel.Transaction{
+		ID:   []byte("confirmed"),
+		Vin:  []kernel.TxInput{kernel.NewInput([]byte("id7"), 1, "sig", "pubkey7")},
+		Vout: []kernel.TxOutput{kernel.NewOutput(1, script.P2PK, "pubkey7")},
+	}
+	coinbase := kernel.NewCoinbaseTransaction("pubkey", 50, 0)
+	coinbase.SetID([]byte("coinbase"))
+
+	// the child spends the output of the confirmed transaction, it will be evicted and added back
+	childAddedAt := time.Now().Add(-time.Hour)
+	require.NoError(t, mempool.AppendTransaction(tx2.Transaction, tx2.Fee))
+	require.NoError(t, mempool.AppendTransactionAt(chainedTx("child", "confirmed"), 5, childAddedAt))
+	require.NoError(t, mempool.AppendTransaction(chainedTx("grandchild", "child"), 5))
+
+	mempool.OnBlockRemoval(&kernel.Block{Transactions: []*kernel.Transaction{coinbase, confirmed}})
+
+	// the transactions of the block (except the coinbase) are returned, followed by the ones evicted that are valid
+	assert.True(t, mempool.ContainsTx("confirmed"))
+	assert.False(t, mempool.ContainsTx("coinbase"))
+	assert.True(t, mempool.ContainsTx("tx2"))
+	assert.True(t, mempool.ContainsTx("child"))
+	assert.False(t, mempool.ContainsTx("grandchild"))
+	assert.Equal(t, []string{"confirmed"}, mempool.parents["child"])
+
+	for _, ptx := range mempool.Snapshot() {
+		if string(ptx.Transaction.ID) == "child" {
+			assert.True(t, childAddedAt.Equal(ptx.Time))
+		}
+	}
+}
+
+func TestMemPoolReplaceByFee(t *testing.T) {
+	mempool := NewMemPool(100000, 0)
+
+	for _, v := range txFeePairs {
+		require.NoError(t, mempool.AppendTransaction(v.Transaction, v.Fee))
+	}
+
+	require.ErrorIs(t, mempool.AppendTransaction(tx1.Transaction, tx1.Fee), cerror.ErrMemPoolTxAlreadyExists)
+
+	// pays higher absolute fee than tx1 but lower fee rate
+	bigReplacement := &kernel.Transaction{
+		ID:   []byte("big-replacement"),
+		Vin:  []kernel.TxInput{kernel.NewInput([]byte("id1"), 1, strings.Repeat("sig", 100), "pubkey1")},
+		Vout: []kernel.TxOutput{kernel.NewOutput(1, script.P2PK, "pubkey1")},
+	}
+	require.ErrorIs(t, mempool.AppendTransaction(bigReplacement, tx1.Fee+1), cerror.ErrMemPoolReplacementFeeTooLow)
+
+	// pays higher fee and fee rate than tx1
+	replacement := &kernel.Transaction{
+		ID:   []byte("rbf"),
+		Vin:  []kernel.TxInput{kernel.NewInput([]byte("id1"), 1, "sig", "pubkey1")},
+		Vout: []kernel.TxOutput{kernel.NewOutput(1, script.P2PK, "pubkey1")},
+	}
+	require.NoError(t, mempool.AppendTransaction(replacement, tx1.Fee+1))
+
+	assert.False(t, mempool.ContainsTx("tx1"))
+	assert.True(t, mempool.ContainsTx("rbf"))
+	assert.Equal(t, []string{"rbf"}, mempool.inputSet[fmt.Sprintf("%x-%d", "id1", 1)])
+	assert.Equal(t, 6, mempool.Len())
+
+	txs, fee := mempool.RetrieveTransactions(1, math.MaxUint)
+	assert.Equal(t, uint(11), fee)
+	assert.Equal(t, []*kernel.Transaction{replacement}, txs)
+}
+
+func TestMemPoolReplaceByFeeWithDescendants(t *testing.T) {
+	mempool := NewMemPool(100000, 0)
+
+	// child spends the output of tx2, so it's replaced along with its parent
+	child := &kernel.Transaction{
+		ID:   []byte("child"),
+		Vin:  []kernel.TxInput{kernel.NewInput([]byte("tx2"), 0, "sig", "pubkey2")},
+		Vout: []kernel.TxOutput{kernel.NewOutput(1, script.P2PK, "pubkey2")},
+	}
+	grandchild := &kernel.Transaction{
+		ID:   []byte("grandchild"),
+		Vin:  []kernel.TxInput{kernel.NewInput([]byte("child"), 0, "sig", "pubkey2")},
+		Vout: []kernel.TxOutput{kernel.NewOutput(1, script.P2PK, "pubkey2")},
+	}
+	require.NoError(t, mempool.AppendTransaction(tx2.Transaction, tx2.Fee))
+	require.NoError(t, mempool.AppendTransaction(tx3.Transaction, tx3.Fee))
+	require.NoError(t, mempool.AppendTransaction(child, 5))
+	require.NoError(t, mempool.AppendTransaction(grandchild, 5))
+
+	replacement := &kernel.Transaction{
+		ID:   []byte("rbf"),
+		Vin:  []kernel.TxInput{kernel.NewInput([]byte("id2"), 1, "sig", "pubkey2")},
+		Vout: []kernel.TxOutput{kernel.NewOutput(1, script.P2PK, "pubkey2")},
+	}
+
+	// must pay more than the sum of the fees of the parent and all its descendants
+	require.ErrorIs(t, mempool.AppendTransaction(replacement, 12), cerror.ErrMemPoolReplacementFeeTooLow)
+	require.NoError(t, mempool.AppendTransaction(replacement, 13))
+
+	assert.False(t, mempool.ContainsTx("tx2"))
+	assert.False(t, mempool.ContainsTx("child"))
+	assert.False(t, mempool.ContainsTx("grandchild"))
+	assert.True(t, mempool.ContainsTx("tx3"))
+	assert.True(t, mempool.ContainsTx("rbf"))
+	assert.Equal(t, tx3.Transaction.Size()+replacement.Size(), mempool.size)
+	assert.NotContains(t, mempool.inputSet, fmt.Sprintf("%x-%d", "tx2", 0))
+	assert.NotContains(t, mempool.inputSet, fmt.Sprintf("%x-%d", "child", 0))
+}
+
+// chainedTx returns a transaction spending the first output of the transaction with ID parentID
+func chainedTx(id, parentID string) *kernel.Transaction {
+	return &kernel.Transaction{
+		ID:   []byte(id),
+		Vin:  []kernel.TxInput{kernel.NewInput([]byte(parentID), 0, "sig", "pubkey")},
+		Vout: []kernel.TxOutput{kernel.NewOutput(1, script.P2PK, "pubkey")},
+	}
+}
+
+func TestRetrieveTxsByAncestorPackageFeeRate(t *testing.T) {
+	mempool := NewMemPool(100000, 0)
 
+	// the child pays for its parent, which has the lowest fee rate of the mempool
+	parent := chainedTx("parent", "confirmed")
+	child := chainedTx("child", "parent")
+	require.NoError(t, mempool.AppendTransaction(parent, 1))
+	require.NoError(t, mempool.AppendTransaction(child, 30))
 	require.NoError(t, mempool.AppendTransaction(tx1.Transaction, tx1.Fee))
-	require.Error(t, mempool.AppendTransaction(tx2.Transaction, tx2.Fee))
+	require.NoError(t, mempool.AppendTransaction(tx5.Transaction, tx5.Fee))
+
+	assert.Equal(t, map[string][]string{"child": {"parent"}}, mempool.parents)
+	assert.Equal(t, map[string][]string{"parent": {"child"}}, mempool.children)
+
+	// the parent is always retrieved before the child
+	txs, fee := mempool.RetrieveTransactions(10, math.MaxUint)
+	assert.Equal(t, uint(50), fee)
+	assert.Equal(t, []*kernel.Transaction{parent, child, tx1.Transaction, tx5.Transaction}, txs)
+
+	txs, fee = mempool.RetrieveTransactions(2, math.MaxUint)
+	assert.Equal(t, uint(31), fee)
+	assert.Equal(t, []*kernel.Transaction{parent, child}, txs)
+
+	// the package does not fit, the next best transaction is retrieved instead
+	txs, fee = mempool.RetrieveTransactions(1, math.MaxUint)
+	assert.Equal(t, uint(10), fee)
+	assert.Equal(t, []*kernel.Transaction{tx1.Transaction}, txs)
+
+	// removing the parent removes the links, the child is left alone until the next block
+	mempool.OnBlockAddition(&kernel.Block{Transactions: []*kernel.Transaction{parent}})
+	assert.True(t, mempool.ContainsTx("child"))
+	assert.Empty(t, mempool.parents)
+	assert.Empty(t, mempool.children)
+}
+
+func TestMemPoolChainLimits(t *testing.T) {
+	mempool := NewMemPool(100000, 0)
+
+	parentID := "confirmed"
+	for i := range MaxAncestors {
+		txID := fmt.Sprintf("tx-%d", i)
+		require.NoError(t, mempool.AppendTransaction(chainedTx(txID, parentID), 1))
+		parentID = txID
+	}
+
+	// the new transaction would have too many ancestors
+	require.ErrorIs(t, mempool.AppendTransaction(chainedTx("too-deep", parentID), 1), cerror.ErrMemPoolChainTooLong)
+
+	// the root of the chain would have too many descendants
+	sibling := &kernel.Transaction{
+		ID:   []byte("sibling"),
+		Vin:  []kernel.TxInput{kernel.NewInput([]byte("tx-0"), 1, "sig", "pubkey")},
+		Vout: []kernel.TxOutput{kernel.NewOutput(1, script.P2PK, "pubkey")},
+	}
+	require.ErrorIs(t, mempool.AppendTransaction(sibling, 1), cerror.ErrMemPoolChainTooLong)
+	assert.Equal(t, MaxAncestors, mempool.Len())
+}
+
+func TestMemPoolOnBlockAdditionRemovesDescendantsOfDoubleSpends(t *testing.T) {
+	mempool := NewMemPool(100000, 0)
+
+	parent := chainedTx("parent", "confirmed")
+	require.NoError(t, mempool.AppendTransaction(parent, 1))
+	require.NoError(t, mempool.AppendTransaction(chainedTx("child", "parent"), 1))
+	require.NoError(t, mempool.AppendTransaction(chainedTx("grandchild", "child"), 1))
+	require.NoError(t, mempool.AppendTransaction(tx1.Transaction, tx1.Fee))
+
+	// the block contains a different transaction spending the same input as the parent
+	mempool.OnBlockAddition(&kernel.Block{Transactions: []*kernel.Transaction{chainedTx("double-spend", "confirmed")}})
+
+	assert.Equal(t, 1, mempool.Len())
+	assert.True(t, mempool.ContainsTx("tx1"))
+	assert.Equal(t, tx1.Transaction.Size(), mempool.size)
+	assert.Empty(t, mempool.parents)
+	assert.Empty(t, mempool.children)
+}
+
+func TestMemPoolPersistAndLoad(t *testing.T) {
+	mempool := NewMemPool(100000, 0)
+	addedAt := time.Now().Add(-time.Hour)
+
+	// the child sorts first due to its fee rate, but must be persisted after its parent
+	require.NoError(t, mempool.AppendTransactionAt(chainedTx("parent", "confirmed"), 1, addedAt))
+	require.NoError(t, mempool.AppendTransaction(chainedTx("child", "parent"), 30))
+	require.NoError(t, mempool.AppendTransaction(tx1.Transaction, tx1.Fee))
+
+	path := filepath.Join(t.TempDir(), "mempool")
+	persistedCount, err := mempool.Persist(path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, persistedCount)
+
+	persisted, err := LoadPersistedTxs(path)
+	require.NoError(t, err)
+	require.Len(t, persisted, 3)
+	assert.Equal(t, []byte("parent"), persisted[0].Transaction.ID)
+	assert.Equal(t, []byte("child"), persisted[1].Transaction.ID)
+	assert.Equal(t, tx1.Transaction.ID, persisted[2].Transaction.ID)
+	assert.True(t, addedAt.Equal(persisted[0].Time))
+
+	// a missing file means there is nothing to restore
+	persisted, err = LoadPersistedTxs(filepath.Join(t.TempDir(), "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, persisted)
+}
+
+func TestMemPoolExpireTransactions(t *testing.T) {
+	mempool := NewMemPool(100000, 0)
+
+	require.NoError(t, mempool.AppendTransactionAt(chainedTx("parent", "confirmed"), 1, time.Now().Add(-2*time.Hour)))
+	require.NoError(t, mempool.AppendTransaction(chainedTx("child", "parent"), 1))
+	require.NoError(t, mempool.AppendTransaction(tx1.Transaction, tx1.Fee))
+
+	// the child is recent but can't stay in the mempool without its parent
+	expired := mempool.ExpireTransactions(time.Now().Add(-time.Hour))
+	assert.Len(t, expired, 2)
+	assert.Equal(t, 1, mempool.Len())
+	assert.True(t, mempool.ContainsTx(string(tx1.Transaction.ID)))
+	assert.Empty(t, mempool.parents)
+	assert.Empty(t, mempool.children)
 }
@@ -3,10 +3,10 @@ package miner
 import (
 	"context"
 	"fmt"
+	"math"
+	"strconv"
 	"time"
 
-	"github.com/yago-123/chainnet/pkg/common"
-
 	"github.com/yago-123/chainnet/config"
 	blockchain "github.com/yago-123/chainnet/pkg/chain"
 	"github.com/yago-123/chainnet/pkg/chain/explorer"
@@ -19,7 +19,9 @@ import (
 )
 
 const (
-	BlockVersion = "1"
+	// BlockVersion is the version of the blocks mined, determines how the target is encoded and how the header is
+	// serialized for hashing
+	BlockVersion = kernel.BlockVersionCanonicalSerialization
 
 	MinerObserverID = "miner-observer"
 )
@@ -79,13 +81,23 @@ func (m *Miner) MineBlock() (*kernel.Block, error) {
 	m.ctx, m.cancel = context.WithCancel(context.Background())
 
 	// calculate mining target (leading zeroes in block hash) for the block that is going to be mined
-	target, err := m.explorer.GetMiningTarget(m.chain.GetLastHeight(), m.cfg.Miner.AdjustmentInterval, m.cfg.Miner.MiningInterval)
+	target, err := m.explorer.GetMiningTarget(m.chain.GetLastHeight(), []byte(BlockVersion), m.cfg.Miner.AdjustmentInterval, m.cfg.Miner.MiningInterval)
 	if err != nil {
 		return nil, fmt.Errorf("unable to get mining target: %w", err)
 	}
 
-	// retrieve transactions that are going to be placed inside the block
-	collectedTxs, collectedFee := m.chain.RetrieveMempoolTxs(kernel.MaxNumberTxsPerBlock)
+	// block timestamps must be greater than the median time past of the previous blocks
+	medianTimePast, err := m.explorer.GetMedianTimePast(m.chain.GetLastHeight())
+	if err != nil {
+		return nil, fmt.Errorf("unable to get median time past: %w", err)
+	}
+
+	// retrieve transactions that are going to be placed inside the block, leaving room for the coinbase and the header
+	maxNumberTxs, maxSize, err := m.mempoolLimits(m.chain.GetLastHeight(), m.chain.GetLastBlockHash())
+	if err != nil {
+		return nil, fmt.Errorf("unable to calculate block limits: %w", err)
+	}
+	collectedTxs, collectedFee := m.chain.RetrieveMempoolTxs(maxNumberTxs, maxSize)
 
 	// generate the coinbase transaction and add to the list of transactions
 	coinbaseTx, err := m.createCoinbaseTransaction(collectedFee, m.chain.GetLastHeight())
@@ -95,7 +107,7 @@ func (m *Miner) MineBlock() (*kernel.Block, error) {
 	txs := append([]*kernel.Transaction{coinbaseTx}, collectedTxs...)
 
 	// create block header
-	blockHeader, err := m.createBlockHeader(txs, m.chain.GetLastHeight(), m.chain.GetLastBlockHash(), target)
+	blockHeader, err := m.createBlockHeader(txs, m.chain.GetLastHeight(), m.chain.GetLastBlockHash(), target, nextTimestamp(medianTimePast))
 	if err != nil {
 		return nil, fmt.Errorf("unable to create block header: %w", err)
 	}
@@ -119,7 +131,7 @@ func (m *Miner) MineBlock() (*kernel.Block, error) {
 				// if no nonce was found, readjust the timestamp and try again
 				m.cfg.Logger.Errorf("didn't find hash matching target: %v", errPow)
 				m.cfg.Logger.Debugf("updating timestamp and starting mining process again for block with height: %d", blockHeader.Height)
-				blockHeader.SetTimestamp(time.Now().Unix())
+				blockHeader.SetTimestamp(nextTimestamp(medianTimePast))
 				continue
 			}
 
@@ -148,6 +160,12 @@ func (m *Miner) OnBlockAddition(_ *kernel.Block) {
 	m.CancelMining()
 }
 
+// OnBlockRemoval is called when a block is disconnected from the chain via the observer pattern
+func (m *Miner) OnBlockRemoval(_ *kernel.Block) {
+	// the tip has changed, cancel previous mining
+	m.CancelMining()
+}
+
 // OnTxAddition is triggered when a new transaction is added into the MemPool
 func (m *Miner) OnTxAddition(_ *kernel.Transaction) {
 	// do nothing
@@ -155,16 +173,8 @@ func (m *Miner) OnTxAddition(_ *kernel.Transaction) {
 
 // createCoinbaseTransaction creates a new coinbase transaction with the reward and collected fees
 func (m *Miner) createCoinbaseTransaction(collectedFee, height uint) (*kernel.Transaction, error) {
-	reward := uint(0)
-	// calculate reward based on block height and halving interval. If height greater than 64 halvings, reward is 0
-	// to avoid dealing with bugs
-	halvings := height / common.HalvingInterval
-	if halvings < common.MaxNumberHalvings {
-		reward = uint(common.InitialCoinbaseReward >> halvings)
-	}
-
-	// creates transaction and calculate hash
-	tx := kernel.NewCoinbaseTransaction(string(m.minerPubKey), reward, collectedFee)
+	// creates transaction with the reward that corresponds to the height and calculate hash
+	tx := kernel.NewCoinbaseTransaction(string(m.minerPubKey), util.CalculateBlockSubsidy(height), collectedFee)
 	txHash, err := util.CalculateTxHash(tx, hash.GetHasher(m.hasherType))
 	if err != nil {
 		return nil, fmt.Errorf("unable to calculate transaction hash: %w", err)
@@ -174,7 +184,31 @@ func (m *Miner) createCoinbaseTransaction(collectedFee, height uint) (*kernel.Tr
 	return tx, nil
 }
 
-func (m *Miner) createBlockHeader(txs []*kernel.Transaction, height uint, prevBlockHash []byte, target uint) (*kernel.BlockHeader, error) {
+// mempoolLimits returns the max number of transactions and the max size in bytes that can be retrieved from the mempool
+// so that the block, once the coinbase transaction and the header are added, remains within the consensus limits
+func (m *Miner) mempoolLimits(height uint, prevBlockHash []byte) (uint, uint, error) {
+	// the size of the coinbase does not depend on the amount of fees collected, only on whether there are fees or not
+	// (extra output). Assume the worst case so the coinbase always fits
+	coinbaseTx, err := m.createCoinbaseTransaction(1, height)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// the scriptSig of the coinbase is a random number, assume the longest one
+	coinbaseTx.Vin[0].ScriptSig = strconv.FormatInt(math.MaxInt64, 10)
+
+	// the merkle root and the block hash have the same length as the coinbase ID (any hash)
+	placeholderHash := coinbaseTx.ID
+	header := kernel.NewBlockHeader([]byte(BlockVersion), 0, placeholderHash, height, prevBlockHash, 0, 0)
+	reservedSize := kernel.NewBlock(header, []*kernel.Transaction{coinbaseTx}, placeholderHash).Size()
+	if reservedSize > m.cfg.Chain.MaxBlockSize || m.cfg.Chain.MaxTxsPerBlock == 0 {
+		return 0, 0, nil
+	}
+
+	return m.cfg.Chain.MaxTxsPerBlock - 1, m.cfg.Chain.MaxBlockSize - reservedSize, nil
+}
+
+func (m *Miner) createBlockHeader(txs []*kernel.Transaction, height uint, prevBlockHash []byte, target uint, timestamp int64) (*kernel.BlockHeader, error) {
 	merkleTree, err := consensus.NewMerkleTreeFromTxs(txs, hash.GetHasher(m.hasherType))
 	if err != nil {
 		return nil, fmt.Errorf("unable to create Merkle tree from transactions: %w", err)
@@ -182,7 +216,7 @@ func (m *Miner) createBlockHeader(txs []*kernel.Transaction, height uint, prevBl
 
 	return kernel.NewBlockHeader(
 		[]byte(BlockVersion),
-		time.Now().Unix(),
+		timestamp,
 		merkleTree.RootHash(),
 		height,
 		prevBlockHash,
@@ -190,3 +224,9 @@ func (m *Miner) createBlockHeader(txs []*kernel.Transaction, height uint, prevBl
 		0,
 	), nil
 }
+
+// nextTimestamp returns the current time, or the earliest timestamp allowed if the current time is not greater than
+// the median time past (i.e. when blocks are mined faster than one per second)
+func nextTimestamp(medianTimePast int64) int64 {
+	return max(time.Now().Unix(), medianTimePast+1)
+}
@@ -3,6 +3,8 @@ package miner //nolint:testpackage // don't create separate package for tests
 import (
 	"context"
 	"crypto/sha256"
+	"math"
+	"strconv"
 	"testing"
 
 	"github.com/yago-123/chainnet/pkg/common"
@@ -94,7 +96,7 @@ func TestMiner_MineBlock(t *testing.T) {
 
 	explorer := expl.NewChainExplorer(store, hash.GetHasher(hash.SHA256))
 
-	mempool := mempool.NewMemPool(1000)
+	mempool := mempool.NewMemPool(config.DefaultMaxMempoolSize, 0)
 	for _, v := range txs {
 		txID, err := util.CalculateTxHash(v.Transaction, hash.NewHasher(sha256.New()))
 		require.NoError(t, err)
@@ -111,6 +113,7 @@ func TestMiner_MineBlock(t *testing.T) {
 		utxoset.NewUTXOSet(cfg),
 		hash.NewHasher(sha256.New()),
 		consensus.NewMockHeavyValidator(),
+		&consensus.MockLightValidator{},
 		observer.NewChainSubject(),
 		encoding.NewGobEncoder(),
 	)
@@ -140,6 +143,72 @@ func TestMiner_MineBlock(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestMiner_MineBlockWithinLimits(t *testing.T) {
+	store := &mockStorage.MockStorage{}
+	store.
+		On("GetLastHeader").
+		Return(&kernel.BlockHeader{}, cerror.ErrStorageElementNotFound)
+	store.
+		On("GetLastBlockHash").
+		Return([]byte{}, cerror.ErrStorageElementNotFound)
+
+	explorer := expl.NewChainExplorer(store, hash.GetHasher(hash.SHA256))
+
+	mempool := mempool.NewMemPool(config.DefaultMaxMempoolSize, 0)
+	for _, v := range txs {
+		txID, err := util.CalculateTxHash(v.Transaction, hash.NewHasher(sha256.New()))
+		require.NoError(t, err)
+
+		v.Transaction.SetID(txID)
+		require.NoError(t, mempool.AppendTransaction(v.Transaction, v.Fee))
+	}
+
+	cfg := config.NewConfig()
+	chain, err := blockchain.NewBlockchain(
+		cfg,
+		store,
+		mempool,
+		utxoset.NewUTXOSet(cfg),
+		hash.NewHasher(sha256.New()),
+		consensus.NewMockHeavyValidator(),
+		&consensus.MockLightValidator{},
+		observer.NewChainSubject(),
+		encoding.NewGobEncoder(),
+	)
+	require.NoError(t, err)
+
+	miner := Miner{
+		hasherType:  hash.SHA256,
+		minerPubKey: []byte("minerPubKey"),
+		chain:       chain,
+		explorer:    explorer,
+		cfg:         cfg,
+	}
+
+	// the coinbase counts towards the max number of transactions
+	cfg.Chain.MaxTxsPerBlock = 2
+	block, err := miner.MineBlock()
+	require.NoError(t, err)
+	assert.Len(t, block.Transactions, 2)
+	assert.True(t, block.Transactions[0].IsCoinbase())
+
+	// the block size limit leaves room for the coinbase and the header only
+	cfg.Chain.MaxTxsPerBlock = config.DefaultMaxTxsPerBlock
+	block.Transactions[0].Vin[0].ScriptSig = strconv.FormatInt(math.MaxInt64, 10)
+	cfg.Chain.MaxBlockSize = block.Size() - block.Transactions[1].Size()
+	maxNumberTxs, maxSize, err := miner.mempoolLimits(0, []byte{})
+	require.NoError(t, err)
+	assert.Equal(t, uint(config.DefaultMaxTxsPerBlock-1), maxNumberTxs)
+	assert.Equal(t, uint(0), maxSize)
+
+	// no room for transactions at all if the coinbase and the header don't fit
+	cfg.Chain.MaxBlockSize = 1
+	maxNumberTxs, maxSize, err = miner.mempoolLimits(0, []byte{})
+	require.NoError(t, err)
+	assert.Equal(t, uint(0), maxNumberTxs)
+	assert.Equal(t, uint(0), maxSize)
+}
+
 func TestMiner_createCoinbaseTransaction(t *testing.T) {
 	store := &mockStorage.MockStorage{}
 	store.
@@ -155,10 +224,11 @@ func TestMiner_createCoinbaseTransaction(t *testing.T) {
 	chain, err := blockchain.NewBlockchain(
 		cfg,
 		store,
-		mempool.NewMemPool(1000),
+		mempool.NewMemPool(config.DefaultMaxMempoolSize, 0),
 		utxoset.NewUTXOSet(cfg),
 		hash.NewHasher(sha256.New()),
 		consensus.NewMockHeavyValidator(),
+		&consensus.MockLightValidator{},
 		observer.NewChainSubject(),
 		encoding.NewGobEncoder(),
 	)
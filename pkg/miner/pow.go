@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"math/big"
 	"runtime"
 	"sync"
 
@@ -31,10 +30,9 @@ type miningResult struct {
 
 // ProofOfWork holds the components needed for mining
 type ProofOfWork struct {
-	externalCtx    context.Context
-	results        chan miningResult
-	wg             sync.WaitGroup
-	hashDifficulty *big.Int
+	externalCtx context.Context
+	results     chan miningResult
+	wg          sync.WaitGroup
 
 	hasherType hash.HasherType
 
@@ -43,26 +41,22 @@ type ProofOfWork struct {
 
 // NewProofOfWork creates a new ProofOfWork instance
 func NewProofOfWork(ctx context.Context, bh *kernel.BlockHeader, hasherType hash.HasherType) (*ProofOfWork, error) {
-	if bh.Target >= HashLength {
-		return nil, errors.New("target is bigger than the hash length")
+	if err := checkTarget(bh); err != nil {
+		return nil, err
 	}
 
-	hashDifficulty := big.NewInt(1)
-	hashDifficulty.Lsh(hashDifficulty, HashLength-bh.Target)
-
 	return &ProofOfWork{
-		externalCtx:    ctx,
-		results:        make(chan miningResult),
-		hashDifficulty: hashDifficulty,
-		hasherType:     hasherType,
-		bh:             bh,
+		externalCtx: ctx,
+		results:     make(chan miningResult),
+		hasherType:  hasherType,
+		bh:          bh,
 	}, nil
 }
 
 // CalculateBlockHash calculates the hash of a block in parallel
 func (pow *ProofOfWork) CalculateBlockHash() ([]byte, uint, error) {
-	if pow.bh.Target >= HashLength {
-		return nil, 0, errors.New("target is bigger than the hash length")
+	if err := checkTarget(pow.bh); err != nil {
+		return nil, 0, err
 	}
 
 	// calculate the number of goroutines to use. We use half of the available CPUs because in our case the miner
@@ -131,10 +125,23 @@ func (pow *ProofOfWork) startMining(blockMinedCtx context.Context, bh kernel.Blo
 				return
 			}
 
-			if util.IsFirstNBitsZero(blockHash, bh.Target) {
+			if util.IsProofOfWorkValid(blockHash, &bh) {
 				pow.results <- miningResult{blockHash, nonce, nil}
 				return
 			}
 		}
 	}
 }
+
+// checkTarget makes sure that the target of the header can be met by a hash
+func checkTarget(bh *kernel.BlockHeader) error {
+	if !bh.HasCompactTarget() && bh.Target >= HashLength {
+		return errors.New("target is bigger than the hash length")
+	}
+
+	if util.HeaderTarget(bh).Sign() <= 0 {
+		return errors.New("target can't be met by any hash")
+	}
+
+	return nil
+}
@@ -39,6 +39,19 @@ func TestProofOfWork_CalculateBlockHash(t *testing.T) {
 	assert.Equal(t, []byte{0x0, 0x0}, blockHash[:2])
 	assert.NotEqual(t, []byte{0x0}, blockHash[2:3])
 
+	// compact target, the hash must be smaller or equal than 0x00ffff00...
+	bh = kernel.NewBlockHeader([]byte(kernel.BlockVersionCompactTarget), 1, []byte("merkle-root"), 1, []byte("prev-block-hash"), 0x1f00ffff, 0)
+	pow, err = NewProofOfWork(ctx, bh, hash.SHA256)
+	require.NoError(t, err)
+	blockHash, _, err = pow.CalculateBlockHash()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x0}, blockHash[:1])
+
+	// compact targets that can't be met return error
+	bh = kernel.NewBlockHeader([]byte(kernel.BlockVersionCompactTarget), 1, []byte("merkle-root"), 1, []byte("prev-block-hash"), 0, 0)
+	_, err = NewProofOfWork(ctx, bh, hash.SHA256)
+	require.Error(t, err)
+
 	// make suire that proof of work can be cancelled
 	bh = kernel.NewBlockHeader([]byte("1"), 1, []byte("merkle-root"), 1, []byte("prev-block-hash"), 200, 0)
 	ctx, cancel := context.WithCancel(context.Background())
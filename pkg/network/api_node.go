@@ -19,6 +19,7 @@ import (
 	"github.com/yago-123/chainnet/pkg/encoding"
 	cerror "github.com/yago-123/chainnet/pkg/errs"
 	"github.com/yago-123/chainnet/pkg/kernel"
+	"github.com/yago-123/chainnet/pkg/mempool"
 	"github.com/yago-123/chainnet/pkg/observer"
 )
 
@@ -26,8 +27,9 @@ type HTTPRouter struct {
 	r          *httprouter.Router
 	apiEncoder encoding.Encoding
 
-	explorer   *explorer.ChainExplorer
-	netSubject observer.NetSubject
+	explorer        *explorer.ChainExplorer
+	mempoolExplorer *mempool.MemPoolExplorer
+	netSubject      observer.NetSubject
 
 	isActive bool
 	srv      *http.Server
@@ -44,16 +46,18 @@ const (
 func NewHTTPRouter(
 	cfg *config.Config,
 	explorer *explorer.ChainExplorer,
+	mempoolExplorer *mempool.MemPoolExplorer,
 	netSubject observer.NetSubject,
 ) *HTTPRouter {
 	router := &HTTPRouter{
 		r: httprouter.New(),
 		// by default the API encoder must be JSON due to OpenAPI spec generation.
-		apiEncoder: encoding.NewJSONEncoder(),
-		explorer:   explorer,
-		netSubject: netSubject,
-		logger:     cfg.Logger,
-		cfg:        cfg,
+		apiEncoder:      encoding.NewJSONEncoder(),
+		explorer:        explorer,
+		mempoolExplorer: mempoolExplorer,
+		netSubject:      netSubject,
+		logger:          cfg.Logger,
+		cfg:             cfg,
 	}
 
 	router.r.GET(RouterV1BetaLatestChain, router.getLatestChain)
@@ -76,11 +80,18 @@ func (router *HTTPRouter) getLatestChain(w http.ResponseWriter, _ *http.Request,
 		return
 	}
 
+	chainWork, err := router.explorer.GetChainWork(block.Hash)
+	if err != nil {
+		router.handleExplorerError(w, fmt.Sprintf("Failed to retrieve chain work of latest chain tip: %s", err.Error()), err)
+		return
+	}
+
 	data := []byte(fmt.Sprintf(
-		`{"height":%d,"hash":"%s","timestamp":%d}`,
+		`{"height":%d,"hash":"%s","timestamp":%d,"chain_work":"%s"}`,
 		block.Header.Height,
 		hex.EncodeToString(block.Hash),
 		block.Header.Timestamp,
+		chainWork.Text(16),
 	))
 
 	router.writeResponse(w, data)
@@ -191,7 +202,7 @@ func (router *HTTPRouter) listUTXOs(w http.ResponseWriter, _ *http.Request, ps h
 		return
 	}
 
-	utxos, err := router.explorer.FindUnspentOutputs(addr, MaxNumberRetrievals)
+	utxos, err := router.explorer.FindSpendableOutputs(addr, MaxNumberRetrievals, router.cfg.Chain.CoinbaseMaturity)
 	if err != nil {
 		router.handleError(w, fmt.Sprintf("Failed to retrieve UTXOs: %s", err.Error()), http.StatusInternalServerError, err)
 		return
@@ -232,7 +243,13 @@ func (router *HTTPRouter) getTransactionByID(w http.ResponseWriter, _ *http.Requ
 		return
 	}
 
+	// transactions not confirmed yet are looked up in the mempool, wallets need them in order to bump their fees
 	tx, err := router.explorer.GetTransactionByID(txID)
+	if errors.Is(err, cerror.ErrStorageElementNotFound) {
+		if mempoolTx, errMempool := router.mempoolExplorer.RetrieveTx(string(txID)); errMempool == nil {
+			tx, err = mempoolTx, nil
+		}
+	}
 	if err != nil {
 		router.handleExplorerError(w, fmt.Sprintf("Failed to retrieve transaction: %s", err.Error()), err)
 		return
@@ -46,7 +46,15 @@ const (
 	AskLastHeaderProtocol    = "/askLastHeader/0.1.0"
 	AskSpecificBlockProtocol = "/askSpecificBlock/0.1.0"
 	AskSpecificTxProtocol    = "/askSpecificTx/0.1.0"
-	AskAllHeaders            = "/askAllHeaders/0.1.0"
+	// AskAllHeaders is kept for compatibility with nodes that don't support AskHeadersProtocol yet
+	AskAllHeaders      = "/askAllHeaders/0.1.0"
+	AskHeadersProtocol = "/askHeaders/0.1.0"
+
+	// MaxHeadersPerRequest is the max number of headers replied to a single AskHeaders request, the requester must
+	// page through the results if the reply contains this number of headers
+	MaxHeadersPerRequest = 2000
+	// MaxLocatorHashes is the max number of hashes accepted in the locator of an AskHeaders request
+	MaxLocatorHashes = 64
 
 	ServerAPIShutdownTimeout = 10 * time.Second
 
@@ -231,6 +239,57 @@ func (h *nodeP2PHandler) handleAskAllHeaders(stream network.Stream) {
 	}
 }
 
+// handleAskHeaders handler that replies to the requests from AskHeaders
+func (h *nodeP2PHandler) handleAskHeaders(stream network.Stream) {
+	// open stream with timeout
+	timeoutStream := AddTimeoutToStream(stream, h.cfg)
+	defer timeoutStream.Close()
+
+	// read the locator sent by the requester
+	data, err := timeoutStream.ReadWithTimeout()
+	if err != nil {
+		h.logger.Errorf("error reading locator from stream %s: %s", stream.ID(), err)
+		return
+	}
+
+	locator, err := h.encoder.DeserializeHashes(data)
+	if err != nil {
+		h.logger.Errorf("error deserializing locator from stream %s: %s", stream.ID(), err)
+		return
+	}
+
+	if len(locator) > MaxLocatorHashes {
+		h.logger.Errorf("locator with %d hashes received from stream %s exceeds the limit", len(locator), stream.ID())
+		return
+	}
+
+	// retrieve headers after the locator from explorer
+	headers, err := h.explorer.GetHeadersAfterLocator(locator, MaxHeadersPerRequest)
+	if err != nil {
+		if errors.Is(err, cerror.ErrStorageElementNotFound) {
+			h.logger.Infof("unable to retrieve headers for stream %s: no headers in the chain", stream.ID())
+			return
+		}
+
+		h.logger.Errorf("error getting headers for stream %s: %s", stream.ID(), err)
+		return
+	}
+
+	// encode headers
+	data, err = h.encoder.SerializeHeaders(headers)
+	if err != nil {
+		h.logger.Errorf("error serializing headers for stream %s: %s", stream.ID(), err)
+		return
+	}
+
+	// send headers encoded to the peer
+	_, err = timeoutStream.WriteWithTimeout(data)
+	if err != nil {
+		h.logger.Errorf("error writing headers for stream %s: %s", stream.ID(), err)
+		return
+	}
+}
+
 type NodeP2P struct {
 	cfg  *config.Config
 	host host.Host
@@ -343,7 +402,7 @@ func NewNodeP2P(
 	}
 
 	// initialize HTTP router for handling HTTP requests (wallet, information requests...)
-	router := NewHTTPRouter(cfg, explorer, netSubject)
+	router := NewHTTPRouter(cfg, explorer, mempoolExplorer, netSubject)
 
 	// initialize handlers
 	handler := newNodeP2PHandler(cfg, encoder, explorer, mempoolExplorer, netSubject)
@@ -351,6 +410,7 @@ func NewNodeP2P(
 	host.SetStreamHandler(AskSpecificBlockProtocol, handler.handleAskSpecificBlock)
 	host.SetStreamHandler(AskSpecificTxProtocol, handler.handleAskSpecificTx)
 	host.SetStreamHandler(AskAllHeaders, handler.handleAskAllHeaders)
+	host.SetStreamHandler(AskHeadersProtocol, handler.handleAskHeaders)
 
 	return &NodeP2P{
 		cfg:             cfg,
@@ -406,6 +466,11 @@ func (n *NodeP2P) ConnectToSeeds() error {
 	return connectToSeeds(n.cfg, n.host)
 }
 
+// ConnectedPeers returns the list of peers the node is currently connected to
+func (n *NodeP2P) ConnectedPeers() []peer.ID {
+	return n.host.Network().Peers()
+}
+
 // AskLastHeader sends a request to a specific peer to get the last block header
 func (n *NodeP2P) AskLastHeader(ctx context.Context, peerID peer.ID) (*kernel.BlockHeader, error) {
 	// open stream to peer with timeout
@@ -482,7 +547,7 @@ func (n *NodeP2P) AskSpecificTx(ctx context.Context, peerID peer.ID, txID []byte
 }
 
 // AskAllHeaders sends a request to a specific peer to get all headers from the remote chain. The reply contains
-// a list of headers unsorted
+// a list of headers unsorted. Deprecated: use AskHeaders instead, kept for syncing with nodes that don't support it
 func (n *NodeP2P) AskAllHeaders(ctx context.Context, peerID peer.ID) ([]*kernel.BlockHeader, error) {
 	// open stream to peer with timeout
 	timeoutStream, err := NewTimeoutStream(ctx, n.cfg, n.host, peerID, AskAllHeaders)
@@ -500,6 +565,51 @@ func (n *NodeP2P) AskAllHeaders(ctx context.Context, peerID peer.ID) ([]*kernel.
 	return n.encoder.DeserializeHeaders(data)
 }
 
+// AskHeaders sends a request to a specific peer to get the headers that follow the most recent block of the locator
+// that is part of the remote main chain. The reply contains at most MaxHeadersPerRequest headers sorted by height,
+// if the limit is reached the caller must ask again using the last header received as locator
+func (n *NodeP2P) AskHeaders(ctx context.Context, peerID peer.ID, locator [][]byte) ([]*kernel.BlockHeader, error) {
+	// open stream to peer with timeout
+	timeoutStream, err := NewTimeoutStream(ctx, n.cfg, n.host, peerID, AskHeadersProtocol)
+	if err != nil {
+		return nil, err
+	}
+	defer timeoutStream.Close()
+
+	// write locator to stream
+	data, err := n.encoder.SerializeHashes(locator)
+	if err != nil {
+		return nil, fmt.Errorf("error serializing locator: %w", err)
+	}
+
+	_, err = timeoutStream.WriteWithTimeout(data)
+	if err != nil {
+		return nil, fmt.Errorf("error writing locator to stream: %w", err)
+	}
+	// close write side of the stream so the peer knows we are done writing
+	err = timeoutStream.stream.CloseWrite()
+	if err != nil {
+		return nil, fmt.Errorf("error closing write side of the stream: %w", err)
+	}
+
+	// read and decode block headers retrieved
+	data, err = timeoutStream.ReadWithTimeout()
+	if err != nil {
+		return nil, fmt.Errorf("error reading data from stream: %w", err)
+	}
+
+	headers, err := n.encoder.DeserializeHeaders(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(headers) > MaxHeadersPerRequest {
+		return nil, fmt.Errorf("peer %s replied %d headers, exceeding the limit of %d", peerID, len(headers), MaxHeadersPerRequest)
+	}
+
+	return headers, nil
+}
+
 func (n *NodeP2P) ID() string {
 	return P2PObserverID
 }
@@ -516,6 +626,24 @@ func (n *NodeP2P) OnBlockAddition(block *kernel.Block) {
 	}
 }
 
+// OnBlockRemoval is triggered when a block is disconnected from the chain during a reorganization. The transactions
+// of the block (except the coinbase) are unconfirmed again, so they are announced to the peers that may have missed
+// them. Peers validate them again against their own chain before accepting them
+func (n *NodeP2P) OnBlockRemoval(block *kernel.Block) {
+	ctx, cancel := context.WithTimeout(context.Background(), n.cfg.P2P.ConnTimeout)
+	defer cancel()
+
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() {
+			continue
+		}
+
+		if err := n.pubsub.NotifyTransactionAdded(ctx, *tx); err != nil {
+			n.logger.Errorf("error notifying transaction %x from disconnected block %x: %s", tx.ID, block.Hash, err)
+		}
+	}
+}
+
 // OnTxAddition is triggered when a new transaction is added into the MemPool
 func (n *NodeP2P) OnTxAddition(tx *kernel.Transaction) {
 	ctx, cancel := context.WithTimeout(context.Background(), n.cfg.P2P.ConnTimeout)
@@ -10,6 +10,7 @@ import (
 type ChainObserver interface {
 	ID() string
 	OnBlockAddition(block *kernel.Block)
+	OnBlockRemoval(block *kernel.Block)
 	OnTxAddition(tx *kernel.Transaction)
 }
 
@@ -18,6 +19,7 @@ type ChainSubject interface {
 	Register(observer ChainObserver)
 	Unregister(observer ChainObserver)
 	NotifyBlockAdded(block *kernel.Block)
+	NotifyBlockRemoved(block *kernel.Block)
 	NotifyTxAdded(tx *kernel.Transaction)
 }
 
@@ -55,6 +57,15 @@ func (so *ChainSubjectController) NotifyBlockAdded(block *kernel.Block) {
 	}
 }
 
+// NotifyBlockRemoved notifies all observers that a block has been disconnected from the chain (reorganization)
+func (so *ChainSubjectController) NotifyBlockRemoved(block *kernel.Block) {
+	so.mu.Lock()
+	defer so.mu.Unlock()
+	for _, observer := range so.observers {
+		observer.OnBlockRemoval(block)
+	}
+}
+
 // NotifyTxAdded notifies all observers that a new transaction has been added
 func (so *ChainSubjectController) NotifyTxAdded(tx *kernel.Transaction) {
 	so.mu.Lock()
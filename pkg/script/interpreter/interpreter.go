@@ -1,8 +1,10 @@
 package interpreter
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"fmt"
+	"slices"
 	"strconv"
 
 	"golang.org/x/crypto/ripemd160"
@@ -14,6 +16,7 @@ import (
 	"github.com/yago-123/chainnet/pkg/kernel"
 	"github.com/yago-123/chainnet/pkg/script"
 
+	util_p2sh "github.com/yago-123/chainnet/pkg/util/p2sh"
 	util_script "github.com/yago-123/chainnet/pkg/util/script"
 )
 
@@ -22,6 +25,14 @@ const (
 	opDupMinStackLength            = 1
 	opHash160MinStackLength        = 1
 	opEqualVerifyMinStackLength    = 2
+	opEqualMinStackLength          = 2
+	opCheckMultiSigMinStackLength  = 1
+	opCheckLockTimeMinStackLength  = 1
+	opCheckSequenceMinStackLength  = 1
+	opDropMinStackLength           = 1
+	opSha256MinStackLength         = 1
+	opVerifyMinStackLength         = 1
+	opIfMinStackLength             = 1
 )
 
 // RPNInterpreter represents the interpreter for the Reverse Polish Notation (RPN) script
@@ -35,19 +46,21 @@ func NewScriptInterpreter(signer sign.Signature) *RPNInterpreter {
 	}
 }
 
-// GenerateScriptSig evaluates the scriptPubKey requirement and generates the scriptSig that will unlock the scriptPubKey
-func (rpn *RPNInterpreter) GenerateScriptSig(scriptPubKey string, pubKey, privKey []byte, tx *kernel.Transaction) (string, error) {
+// GenerateScriptSig evaluates the scriptPubKey requirement of the spent output and generates the scriptSig that will
+// unlock it. The inputIdx is the index of the input of the transaction that spends the output and sigType decides which
+// parts of the transaction are committed by the signature
+func (rpn *RPNInterpreter) GenerateScriptSig(spentOutput kernel.TxOutput, pubKey, privKey []byte, tx *kernel.Transaction, inputIdx uint, sigType kernel.SignatureType) (string, error) {
 	var scriptSig [][]byte
 
 	// converts script pub key into list of tokens and list of strings
-	scriptTokens, _, err := script.StringToScript(scriptPubKey)
+	scriptTokens, scriptString, err := script.StringToScript(spentOutput.ScriptPubKey)
 	if err != nil {
 		return "", err
 	}
 
-	signature, err := rpn.signer.Sign(tx.AssembleForSigning(), privKey)
+	signature, err := rpn.signInput(tx, inputIdx, spentOutput, sigType, privKey)
 	if err != nil {
-		return "", fmt.Errorf("couldn't sign transaction: %w", err)
+		return "", err
 	}
 
 	scriptType := script.DetermineScriptType(scriptTokens)
@@ -57,6 +70,58 @@ func (rpn *RPNInterpreter) GenerateScriptSig(scriptPubKey string, pubKey, privKe
 		scriptSig = append(scriptSig, signature)
 	case script.P2PKH:
 		scriptSig = append(scriptSig, signature, pubKey)
+	case script.P2SH:
+		// the redeem script goes last so that is the first element evaluated by the scriptPubKey
+		redeemScript := script.NewRedeemScript(pubKey)
+		scriptHash, errHash := util_p2sh.HashScript([]byte(redeemScript))
+		if errHash != nil {
+			return "", errHash
+		}
+
+		if scriptString[1] != string(scriptHash) {
+			return "", fmt.Errorf("redeem script of the public key does not match the script hash")
+		}
+
+		scriptSig = append(scriptSig, signature, []byte(redeemScript))
+	case script.MultiSig:
+		// generates the partial scriptSig of the key, partial scriptSigs are put together via CombineMultiSigScriptSigs
+		_, pubKeys, errMultiSig := script.ExtractMultiSigParams(scriptTokens, scriptString)
+		if errMultiSig != nil {
+			return "", errMultiSig
+		}
+
+		if !slices.ContainsFunc(pubKeys, func(key []byte) bool { return bytes.Equal(key, pubKey) }) {
+			return "", fmt.Errorf("public key is not part of the multisig script")
+		}
+
+		scriptSig = append(scriptSig, signature)
+	case script.TimeLock, script.RelativeTimeLock:
+		// the lock time is checked against the lock time of the transaction (or the sequence of the input), which is
+		// covered by the signature
+		_, lockedPubKey, errTimeLock := script.ExtractTimeLockParams(scriptTokens, scriptString)
+		if errTimeLock != nil {
+			return "", errTimeLock
+		}
+
+		if !bytes.Equal(lockedPubKey, pubKey) {
+			return "", fmt.Errorf("public key does not match the time locked public key")
+		}
+
+		scriptSig = append(scriptSig, signature)
+	case script.HTLC:
+		// the sender takes the funds back via the refund branch, the receiver must use GenerateHTLCClaimScriptSig
+		params, errHTLC := script.ExtractHTLCParams(scriptTokens, scriptString)
+		if errHTLC != nil {
+			return "", errHTLC
+		}
+
+		if !bytes.Equal(params.SenderPubKey, pubKey) {
+			return "", fmt.Errorf("public key is not the sender of the HTLC, claiming the funds requires the preimage")
+		}
+
+		scriptSig = append(scriptSig, signature, []byte(strconv.FormatBool(false)))
+	case script.NullData:
+		return "", fmt.Errorf("null data outputs are unspendable")
 	case script.UndefinedScriptType:
 		return "", fmt.Errorf("undefined script type %d", scriptType)
 	default:
@@ -66,25 +131,160 @@ func (rpn *RPNInterpreter) GenerateScriptSig(scriptPubKey string, pubKey, privKe
 	return util_script.EncodeScriptSig(scriptSig), nil
 }
 
-// VerifyScriptPubKey verifies the scriptPubKey by reconstructing the script and evaluating it
-func (rpn *RPNInterpreter) VerifyScriptPubKey(scriptPubKey string, scriptSig string, tx *kernel.Transaction) (bool, error) { //nolint:gocognit // allow this function to be complex
+// GenerateHTLCClaimScriptSig generates the scriptSig that allows the receiver of a HTLC output to unlock the funds by
+// revealing the preimage of the hash lock
+func (rpn *RPNInterpreter) GenerateHTLCClaimScriptSig(spentOutput kernel.TxOutput, preimage, pubKey, privKey []byte, tx *kernel.Transaction, inputIdx uint, sigType kernel.SignatureType) (string, error) {
+	scriptTokens, scriptString, err := script.StringToScript(spentOutput.ScriptPubKey)
+	if err != nil {
+		return "", err
+	}
+
+	params, err := script.ExtractHTLCParams(scriptTokens, scriptString)
+	if err != nil {
+		return "", err
+	}
+
+	if !bytes.Equal(params.ReceiverPubKey, pubKey) {
+		return "", fmt.Errorf("public key is not the receiver of the HTLC")
+	}
+
+	hashedPreimage := sha256.Sum256(preimage)
+	if !bytes.Equal(hashedPreimage[:], params.HashLock) {
+		return "", fmt.Errorf("preimage does not match the hash lock")
+	}
+
+	signature, err := rpn.signInput(tx, inputIdx, spentOutput, sigType, privKey)
+	if err != nil {
+		return "", err
+	}
+
+	return util_script.EncodeScriptSig([][]byte{signature, preimage, []byte(strconv.FormatBool(true))}), nil
+}
+
+// CombineMultiSigScriptSigs puts together the partial scriptSigs (one signature each) generated by the owners of the
+// keys of a multisig output. The signatures are sorted following the order of the public keys in the script, returns
+// error if there are not enough valid signatures
+func (rpn *RPNInterpreter) CombineMultiSigScriptSigs(spentOutput kernel.TxOutput, partialScriptSigs []string, tx *kernel.Transaction, inputIdx uint) (string, error) {
+	scriptTokens, scriptString, err := script.StringToScript(spentOutput.ScriptPubKey)
+	if err != nil {
+		return "", err
+	}
+
+	m, pubKeys, err := script.ExtractMultiSigParams(scriptTokens, scriptString)
+	if err != nil {
+		return "", err
+	}
+
+	signatures := [][]byte{}
+	for _, partialScriptSig := range partialScriptSigs {
+		partialSignatures, errDecode := util_script.DecodeScriptSig(partialScriptSig)
+		if errDecode != nil {
+			return "", fmt.Errorf("couldn't decode partial scriptSig: %w", errDecode)
+		}
+
+		signatures = append(signatures, partialSignatures...)
+	}
+
+	// match each public key with one of the signatures, in the same order in which are evaluated
+	scriptSig := [][]byte{}
+	for _, pubKey := range pubKeys {
+		for _, sig := range signatures {
+			valid, errVerify := rpn.verifyInputSignature(sig, pubKey, tx, inputIdx, spentOutput)
+			if errVerify != nil || !valid {
+				continue
+			}
+
+			scriptSig = append(scriptSig, sig)
+			break
+		}
+
+		if uint(len(scriptSig)) == m {
+			return util_script.EncodeScriptSig(scriptSig), nil
+		}
+	}
+
+	return "", fmt.Errorf("not enough valid signatures, got %d, want %d", len(scriptSig), m)
+}
+
+// VerifyScriptPubKey verifies the scriptPubKey of the spent output by reconstructing the script and evaluating it. In
+// the case of P2SH the last element of the scriptSig is the redeem script: the scriptPubKey checks its hash and then the
+// redeem script is evaluated with the rest of the scriptSig elements. The inputIdx is the index of the input of the
+// transaction that contains the scriptSig
+func (rpn *RPNInterpreter) VerifyScriptPubKey(spentOutput kernel.TxOutput, scriptSig string, tx *kernel.Transaction, inputIdx uint) (bool, error) {
 	stack := script.NewStack()
 
 	// converts script pub key into list of tokens and list of strings
-	scriptTokens, scriptString, err := script.StringToScript(scriptPubKey)
+	scriptTokens, scriptString, err := script.StringToScript(spentOutput.ScriptPubKey)
 	if err != nil {
 		return false, err
 	}
 
 	// iterate over the scriptSig and push values to the stack
-	for _, element := range util_script.DecodeScriptSig(scriptSig) {
+	scriptSigElements, err := util_script.DecodeScriptSig(scriptSig)
+	if err != nil {
+		return false, err
+	}
+
+	for _, element := range scriptSigElements {
 		stack.Push(string(element))
 	}
 
 	// start evaluation of scriptPubKey
+	if err = rpn.evaluate(stack, scriptTokens, scriptString, tx, inputIdx, spentOutput); err != nil {
+		return false, err
+	}
+
+	if script.DetermineScriptType(scriptTokens) == script.P2SH {
+		// the hash of the redeem script must match the script hash
+		if stack.Len() < 1 || stack.Pop() != strconv.FormatBool(true) {
+			return false, nil
+		}
+
+		redeemTokens, redeemString, errRedeem := script.StringToScript(string(scriptSigElements[len(scriptSigElements)-1]))
+		if errRedeem != nil {
+			return false, errRedeem
+		}
+
+		if script.DetermineScriptType(redeemTokens) == script.P2SH {
+			return false, fmt.Errorf("redeem script can't be a P2SH script")
+		}
+
+		if err = rpn.evaluate(stack, redeemTokens, redeemString, tx, inputIdx, spentOutput); err != nil {
+			return false, fmt.Errorf("error evaluating redeem script: %w", err)
+		}
+	}
+
+	if stack.Len() != 1 {
+		return false, fmt.Errorf("invalid stack length after script execution")
+	}
+
+	return stack.Pop() == strconv.FormatBool(true), nil
+}
+
+// evaluate runs the script tokens over the stack provided, inputIdx is the index of the input of the transaction that
+// is unlocking the spent output
+func (rpn *RPNInterpreter) evaluate(stack *script.Stack, scriptTokens script.Script, scriptString []string, tx *kernel.Transaction, inputIdx uint, spentOutput kernel.TxOutput) error { //nolint:gocognit // allow this function to be complex
+	var err error
+
+	// conditions contains the result of the OP_IF branches that are open, tokens are only executed if all are true
+	conditions := []bool{}
+
 	for index, token := range scriptTokens {
 		if token.IsUndefined() {
-			return false, fmt.Errorf("undefined token %s in position %d", scriptString[index], index)
+			return fmt.Errorf("undefined token %s in position %d", scriptString[index], index)
+		}
+
+		// flow control operators are processed even inside branches that are not executed
+		if token == script.OpIf || token == script.OpElse || token == script.OpEndIf {
+			conditions, err = processConditional(stack, token, conditions)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if slices.Contains(conditions, false) {
+			continue
 		}
 
 		if token.IsOperator() { //nolint:nestif // allow this nesting to be "complex"
@@ -92,7 +292,7 @@ func (rpn *RPNInterpreter) VerifyScriptPubKey(scriptPubKey string, scriptSig str
 			switch token { //nolint:exhaustive // only check operators
 			case script.OpChecksig:
 				if stack.Len() < opCheckSigVerifyMinStackLength {
-					return false, fmt.Errorf("invalid stack length for OP_CHECKSIG")
+					return fmt.Errorf("invalid stack length for OP_CHECKSIG")
 				}
 
 				var ret bool
@@ -100,14 +300,14 @@ func (rpn *RPNInterpreter) VerifyScriptPubKey(scriptPubKey string, scriptSig str
 				sig := stack.Pop()
 
 				// verify the signature
-				ret, err = rpn.signer.Verify([]byte(sig), tx.AssembleForSigning(), []byte(pubKey))
+				ret, err = rpn.verifyInputSignature([]byte(sig), []byte(pubKey), tx, inputIdx, spentOutput)
 				if err != nil {
-					return false, fmt.Errorf("couldn't verify signature: %w", err)
+					return fmt.Errorf("couldn't verify signature: %w", err)
 				}
 				stack.Push(strconv.FormatBool(ret))
 			case script.OpDup:
 				if stack.Len() < opDupMinStackLength {
-					return false, fmt.Errorf("invalid stack length for OP_DUP")
+					return fmt.Errorf("invalid stack length for OP_DUP")
 				}
 
 				val := stack.Pop()
@@ -115,7 +315,7 @@ func (rpn *RPNInterpreter) VerifyScriptPubKey(scriptPubKey string, scriptSig str
 				stack.Push(val)
 			case script.OpHash160:
 				if stack.Len() < opHash160MinStackLength {
-					return false, fmt.Errorf("invalid stack length for OP_HASH160")
+					return fmt.Errorf("invalid stack length for OP_HASH160")
 				}
 
 				var hashedVal []byte
@@ -126,21 +326,93 @@ func (rpn *RPNInterpreter) VerifyScriptPubKey(scriptPubKey string, scriptSig str
 				)
 				hashedVal, err = hasher.Hash([]byte(val))
 				if err != nil {
-					return false, fmt.Errorf("couldn't hash value: %w", err)
+					return fmt.Errorf("couldn't hash value: %w", err)
 				}
 
 				stack.Push(string(hashedVal))
 			case script.OpEqualVerify:
 				if stack.Len() < opEqualVerifyMinStackLength {
-					return false, fmt.Errorf("invalid stack length for OP_EQUALVERIFY")
+					return fmt.Errorf("invalid stack length for OP_EQUALVERIFY")
 				}
 
 				val1 := stack.Pop()
 				val2 := stack.Pop()
 
 				if val1 != val2 {
-					return false, fmt.Errorf("OP_EQUAL_VERIFY failed, values are not equal: %s != %s", val1, val2)
+					return fmt.Errorf("OP_EQUAL_VERIFY failed, values are not equal: %s != %s", val1, val2)
+				}
+			case script.OpEqual:
+				if stack.Len() < opEqualMinStackLength {
+					return fmt.Errorf("invalid stack length for OP_EQUAL")
+				}
+
+				val1 := stack.Pop()
+				val2 := stack.Pop()
+
+				stack.Push(strconv.FormatBool(val1 == val2))
+			case script.OpCheckMultiSig, script.OpCheckMultiSigVerify:
+				var ret bool
+				ret, err = rpn.checkMultiSig(stack, tx, inputIdx, spentOutput)
+				if err != nil {
+					return err
+				}
+
+				if token == script.OpCheckMultiSigVerify {
+					if !ret {
+						return fmt.Errorf("OP_CHECKMULTISIGVERIFY failed, signatures are not valid")
+					}
+					continue
+				}
+
+				stack.Push(strconv.FormatBool(ret))
+			case script.OpCheckLockTimeVerify:
+				if stack.Len() < opCheckLockTimeMinStackLength {
+					return fmt.Errorf("invalid stack length for OP_CHECKLOCKTIMEVERIFY")
+				}
+
+				// the lock time is left in the stack, scripts must drop it via OP_DROP
+				lockTime := stack.Pop()
+				stack.Push(lockTime)
+
+				if err = checkLockTime(lockTime, tx); err != nil {
+					return fmt.Errorf("OP_CHECKLOCKTIMEVERIFY failed: %w", err)
+				}
+			case script.OpCheckSequenceVerify:
+				if stack.Len() < opCheckSequenceMinStackLength {
+					return fmt.Errorf("invalid stack length for OP_CHECKSEQUENCEVERIFY")
+				}
+
+				// the sequence is left in the stack, scripts must drop it via OP_DROP
+				sequence := stack.Pop()
+				stack.Push(sequence)
+
+				if err = checkSequence(sequence, tx, inputIdx); err != nil {
+					return fmt.Errorf("OP_CHECKSEQUENCEVERIFY failed: %w", err)
+				}
+			case script.OpDrop:
+				if stack.Len() < opDropMinStackLength {
+					return fmt.Errorf("invalid stack length for OP_DROP")
+				}
+
+				stack.Pop()
+			case script.OpSha256:
+				if stack.Len() < opSha256MinStackLength {
+					return fmt.Errorf("invalid stack length for OP_SHA256")
+				}
+
+				hashedVal := sha256.Sum256([]byte(stack.Pop()))
+				stack.Push(string(hashedVal[:]))
+			case script.OpVerify:
+				if stack.Len() < opVerifyMinStackLength {
+					return fmt.Errorf("invalid stack length for OP_VERIFY")
+				}
+
+				if stack.Pop() != strconv.FormatBool(true) {
+					return fmt.Errorf("OP_VERIFY failed, value is not true")
 				}
+			case script.OpReturn:
+				// outputs marked with OP_RETURN are provably unspendable
+				return fmt.Errorf("OP_RETURN found, script is unspendable")
 			default:
 			}
 		}
@@ -150,9 +422,206 @@ func (rpn *RPNInterpreter) VerifyScriptPubKey(scriptPubKey string, scriptSig str
 		}
 	}
 
-	if stack.Len() != 1 {
-		return false, fmt.Errorf("invalid stack length after script execution")
+	if len(conditions) != 0 {
+		return fmt.Errorf("unbalanced conditional, OP_IF without OP_ENDIF")
+	}
+
+	return nil
+}
+
+// processConditional updates the conditions of the branches based on the flow control operator. OP_IF only pops the
+// condition from the stack if the branch in which is contained is being executed
+func processConditional(stack *script.Stack, token script.ScriptElement, conditions []bool) ([]bool, error) {
+	switch token { //nolint:exhaustive // only check flow control operators
+	case script.OpIf:
+		if slices.Contains(conditions, false) {
+			return append(conditions, false), nil
+		}
+
+		if stack.Len() < opIfMinStackLength {
+			return nil, fmt.Errorf("invalid stack length for OP_IF")
+		}
+
+		// booleans are represented in the stack as "true" and "false", any other value is rejected
+		condition := stack.Pop()
+		if condition != strconv.FormatBool(true) && condition != strconv.FormatBool(false) {
+			return nil, fmt.Errorf("invalid condition %q for OP_IF", condition)
+		}
+
+		return append(conditions, condition == strconv.FormatBool(true)), nil
+	case script.OpElse:
+		if len(conditions) == 0 {
+			return nil, fmt.Errorf("OP_ELSE without OP_IF")
+		}
+
+		conditions[len(conditions)-1] = !conditions[len(conditions)-1]
+		return conditions, nil
+	case script.OpEndIf:
+		if len(conditions) == 0 {
+			return nil, fmt.Errorf("OP_ENDIF without OP_IF")
+		}
+
+		return conditions[:len(conditions)-1], nil
+	default:
+		return nil, fmt.Errorf("%s is not a flow control operator", token.String())
+	}
+}
+
+// checkMultiSig pops the public keys and the signatures from the stack (n, pub keys, m and signatures) and checks that
+// the signatures belong to the public keys. Signatures must follow the same order as the public keys
+func (rpn *RPNInterpreter) checkMultiSig(stack *script.Stack, tx *kernel.Transaction, inputIdx uint, spentOutput kernel.TxOutput) (bool, error) {
+	n, err := popNumber(stack, script.MaxMultiSigPubKeys)
+	if err != nil {
+		return false, fmt.Errorf("invalid number of public keys for OP_CHECKMULTISIG: %w", err)
+	}
+
+	if stack.Len() < n+opCheckMultiSigMinStackLength {
+		return false, fmt.Errorf("invalid stack length for OP_CHECKMULTISIG")
+	}
+
+	pubKeys := make([]string, n)
+	for i := range n {
+		pubKeys[n-1-i] = stack.Pop()
+	}
+
+	m, err := popNumber(stack, n)
+	if err != nil {
+		return false, fmt.Errorf("invalid number of signatures for OP_CHECKMULTISIG: %w", err)
+	}
+
+	if m == 0 {
+		return false, fmt.Errorf("OP_CHECKMULTISIG requires at least one signature")
+	}
+
+	if stack.Len() < m {
+		return false, fmt.Errorf("invalid stack length for OP_CHECKMULTISIG")
+	}
+
+	sigs := make([]string, m)
+	for i := range m {
+		sigs[m-1-i] = stack.Pop()
+	}
+
+	// each signature must match one of the remaining public keys (in order)
+	keyIdx := uint(0)
+	for _, sig := range sigs {
+		matched := false
+		for ; keyIdx < n && !matched; keyIdx++ {
+			matched, err = rpn.verifyInputSignature([]byte(sig), []byte(pubKeys[keyIdx]), tx, inputIdx, spentOutput)
+			if err != nil {
+				return false, fmt.Errorf("couldn't verify signature: %w", err)
+			}
+		}
+
+		if !matched {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// signInput signs the input with index inputIdx of the transaction, which spends spentOutput. The signature type is
+// appended to the signature so that the verifier can reconstruct the same payload
+func (rpn *RPNInterpreter) signInput(tx *kernel.Transaction, inputIdx uint, spentOutput kernel.TxOutput, sigType kernel.SignatureType, privKey []byte) ([]byte, error) {
+	payload, err := tx.AssembleForSigning(inputIdx, spentOutput, sigType)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't assemble transaction for signing: %w", err)
+	}
+
+	signature, err := rpn.signer.Sign(payload, privKey)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't sign transaction: %w", err)
+	}
+
+	return append(signature, byte(sigType)), nil
+}
+
+// verifyInputSignature verifies the signature of the input with index inputIdx of the transaction, which spends
+// spentOutput. The last byte of the signature contains the signature type used to generate it
+func (rpn *RPNInterpreter) verifyInputSignature(sig, pubKey []byte, tx *kernel.Transaction, inputIdx uint, spentOutput kernel.TxOutput) (bool, error) {
+	if len(sig) == 0 {
+		return false, nil
+	}
+
+	// signatures with an unknown signature type are not valid, but are not considered an error either
+	sigType := kernel.SignatureType(sig[len(sig)-1])
+	if !sigType.IsValid() {
+		return false, nil
+	}
+
+	payload, err := tx.AssembleForSigning(inputIdx, spentOutput, sigType)
+	if err != nil {
+		return false, fmt.Errorf("couldn't assemble transaction for verifying: %w", err)
+	}
+
+	return rpn.signer.Verify(sig[:len(sig)-1], payload, pubKey)
+}
+
+// checkLockTime checks that the lock time of the transaction has reached the lock time required by the script. Both
+// lock times must be of the same kind (block height or Unix time), otherwise they can't be compared. The transaction
+// lock time is enforced by the validator, so the transaction can't be included in a block before the required one
+func checkLockTime(value string, tx *kernel.Transaction) error {
+	lockTime, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("value %q is not a number: %w", value, err)
+	}
+
+	if kernel.IsLockTimeHeight(uint(lockTime)) != kernel.IsLockTimeHeight(tx.LockTime) {
+		return fmt.Errorf("lock time %d and transaction lock time %d are not of the same kind", lockTime, tx.LockTime)
+	}
+
+	if uint(lockTime) > tx.LockTime {
+		return fmt.Errorf("transaction lock time %d is smaller than %d", tx.LockTime, lockTime)
+	}
+
+	return nil
+}
+
+// checkSequence checks that the relative lock time of the input being unlocked has reached the relative lock time
+// required by the script. Both must be of the same kind (blocks or seconds), otherwise they can't be compared. The
+// relative lock time of the input is enforced by the validator based on the height in which the output was confirmed
+func checkSequence(value string, tx *kernel.Transaction, inputIdx uint) error {
+	sequence, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("value %q is not a number: %w", value, err)
+	}
+
+	if inputIdx >= uint(len(tx.Vin)) {
+		return fmt.Errorf("input %d does not exist in the transaction", inputIdx)
+	}
+
+	inputSequence := tx.Vin[inputIdx].Sequence
+	if !tx.Vin[inputIdx].HasRelativeLockTime() {
+		return fmt.Errorf("input %d does not contain a relative lock time", inputIdx)
+	}
+
+	if kernel.IsSequenceLockTimeSeconds(uint(sequence)) != kernel.IsSequenceLockTimeSeconds(inputSequence) {
+		return fmt.Errorf("sequence %d and input sequence %d are not of the same kind", sequence, inputSequence)
+	}
+
+	if kernel.SequenceLockTime(uint(sequence)) > kernel.SequenceLockTime(inputSequence) {
+		return fmt.Errorf("input relative lock time %d is smaller than %d", kernel.SequenceLockTime(inputSequence), kernel.SequenceLockTime(uint(sequence)))
+	}
+
+	return nil
+}
+
+// popNumber pops a number from the stack making sure that is not bigger than maxValue
+func popNumber(stack *script.Stack, maxValue uint) (uint, error) {
+	if stack.Len() < 1 {
+		return 0, fmt.Errorf("stack is empty")
+	}
+
+	val := stack.Pop()
+	number, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("value %q is not a number: %w", val, err)
+	}
+
+	if number > uint64(maxValue) {
+		return 0, fmt.Errorf("number %d bigger than %d", number, maxValue)
 	}
 
-	return stack.Pop() == "true", nil
+	return uint(number), nil
 }
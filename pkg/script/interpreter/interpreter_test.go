@@ -3,9 +3,12 @@ package interpreter //nolint:testpackage // don't create separate package for te
 import (
 	"crypto/sha256"
 	"fmt"
+	"strings"
 	"testing"
 
 	util_p2pkh "github.com/yago-123/chainnet/pkg/util/p2pkh"
+	util_p2sh "github.com/yago-123/chainnet/pkg/util/p2sh"
+	util_script "github.com/yago-123/chainnet/pkg/util/script"
 
 	"github.com/yago-123/chainnet/pkg/crypto"
 	"github.com/yago-123/chainnet/pkg/crypto/hash"
@@ -15,8 +18,6 @@ import (
 	mockHash "github.com/yago-123/chainnet/tests/mocks/crypto/hash"
 	mockSign "github.com/yago-123/chainnet/tests/mocks/crypto/sign"
 
-	"github.com/btcsuite/btcutil/base58"
-
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -100,28 +101,45 @@ func TestRPNInterpreter_GenerateScriptSigWithErrors(t *testing.T) {
 
 	// generate the scriptSig with an invalid scriptPubKey
 	_, err = interpreter.GenerateScriptSig(
-		"invalid script",
+		spentOutput("invalid script"),
 		pubKey,
 		privKey,
 		tx1P2PK,
+		0,
+		kernel.SighashAll,
 	)
 	require.Error(t, err)
 
 	// generate the scriptSig with an empty private key
 	_, err = interpreter.GenerateScriptSig(
-		script.NewScript(script.P2PK, pubKey),
+		spentOutput(script.NewScript(script.P2PK, pubKey)),
 		[]byte{},
 		[]byte{},
 		tx1P2PK,
+		0,
+		kernel.SighashAll,
+	)
+	require.Error(t, err)
+
+	// generate the scriptSig for a data carrier output
+	_, err = interpreter.GenerateScriptSig(
+		spentOutput(script.NewNullDataScript([]byte("data"))),
+		pubKey,
+		privKey,
+		tx1P2PK,
+		0,
+		kernel.SighashAll,
 	)
 	require.Error(t, err)
 
 	// generate the scriptSig with an empty transaction
 	_, err = interpreter.GenerateScriptSig(
-		script.NewScript(script.P2PK, pubKey),
+		spentOutput(script.NewScript(script.P2PK, pubKey)),
 		pubKey,
 		privKey,
 		&kernel.Transaction{},
+		0,
+		kernel.SighashAll,
 	)
 	require.Error(t, err)
 }
@@ -135,45 +153,71 @@ func TestRPNInterpreter_VerifyScriptPubKeyWithErrors(t *testing.T) {
 
 	// generate real signature for testing purposes
 	realSignature, err := interpreter.GenerateScriptSig(
-		script.NewScript(script.P2PK, pubKey),
+		spentOutput(script.NewScript(script.P2PK, pubKey)),
 		pubKey,
 		privKey,
 		tx1P2PK,
+		0,
+		kernel.SighashAll,
 	)
 	require.NoError(t, err)
 
 	// check that invalid scripts are not accepted
 	valid, err := interpreter.VerifyScriptPubKey(
-		"invalid script",
+		spentOutput("invalid script"),
 		realSignature,
 		tx1P2PK,
+		0,
 	)
 	require.Error(t, err)
 	require.False(t, valid)
 
 	// check that wrong signatures are accepted but not valid
 	valid, err = interpreter.VerifyScriptPubKey(
-		script.NewScript(script.P2PK, pubKey),
-		"randomsignature",
+		spentOutput(script.NewScript(script.P2PK, pubKey)),
+		util_script.EncodeScriptSig([][]byte{[]byte("randomsignature")}),
 		tx1P2PK,
+		0,
 	)
 	require.NoError(t, err)
 	require.False(t, valid)
 
+	// check that malformed scriptSigs are not accepted
+	valid, err = interpreter.VerifyScriptPubKey(
+		spentOutput(script.NewScript(script.P2PK, pubKey)),
+		util_script.BinaryFormatMarker+"randomsignature",
+		tx1P2PK,
+		0,
+	)
+	require.Error(t, err)
+	require.False(t, valid)
+
 	// check that empty signatures are not accepted
 	valid, err = interpreter.VerifyScriptPubKey(
-		script.NewScript(script.P2PK, pubKey),
+		spentOutput(script.NewScript(script.P2PK, pubKey)),
 		"",
 		tx1P2PK,
+		0,
 	)
 	require.Error(t, err)
 	require.False(t, valid)
 
 	// check that empty transactions are not accepted
 	valid, err = interpreter.VerifyScriptPubKey(
-		script.NewScript(script.P2PK, pubKey),
+		spentOutput(script.NewScript(script.P2PK, pubKey)),
 		realSignature,
 		&kernel.Transaction{},
+		0,
+	)
+	require.Error(t, err)
+	require.False(t, valid)
+
+	// check that data carrier outputs can't be unlocked
+	valid, err = interpreter.VerifyScriptPubKey(
+		spentOutput(script.NewNullDataScript([]byte("data"))),
+		realSignature,
+		tx1P2PK,
+		0,
 	)
 	require.Error(t, err)
 	require.False(t, valid)
@@ -188,29 +232,33 @@ func TestRPNInterpreter_GenerationAndVerificationRealKeysP2PK(t *testing.T) {
 
 	// generate the scriptSig to unlock the input
 	signature, err := interpreter.GenerateScriptSig(
-		script.NewScript(script.P2PK, pubKey),
+		spentOutput(script.NewScript(script.P2PK, pubKey)),
 		pubKey,
 		privKey,
 		tx1P2PK,
+		0,
+		kernel.SighashAll,
 	)
 	require.NoError(t, err)
 
 	// check that the scriptSig generated is correct
 	valid, err := interpreter.VerifyScriptPubKey(
-		script.NewScript(script.P2PK, pubKey),
+		spentOutput(script.NewScript(script.P2PK, pubKey)),
 		signature,
 		tx1P2PK,
+		0,
 	)
 	require.NoError(t, err)
 	assert.True(t, valid)
 
 	// modify the scriptSig and check that is not correct anymore
-	modifiedScriptSig := []rune(signature)
-	modifiedScriptSig[0] = 'a'
+	modifiedScriptSig := []byte(signature)
+	modifiedScriptSig[2] ^= 0xff
 	valid, err = interpreter.VerifyScriptPubKey(
-		script.NewScript(script.P2PK, pubKey),
+		spentOutput(script.NewScript(script.P2PK, pubKey)),
 		string(modifiedScriptSig),
 		tx1P2PK,
+		0,
 	)
 	require.NoError(t, err)
 	assert.False(t, valid)
@@ -228,34 +276,437 @@ func TestRPNInterpreter_GenerationAndVerificationRealKeysP2PKH(t *testing.T) {
 
 	// generate the scriptSig to unlock the input
 	signature, err := interpreter.GenerateScriptSig(
-		script.NewScript(script.P2PKH, addressP2PKH),
+		spentOutput(script.NewScript(script.P2PKH, addressP2PKH)),
 		pubKey,
 		privKey,
 		tx1P2PKH,
+		0,
+		kernel.SighashAll,
 	)
 	require.NoError(t, err)
 
 	// check that the scriptSig generated is correct
 	valid, err := interpreter.VerifyScriptPubKey(
-		script.NewScript(script.P2PKH, addressP2PKH),
+		spentOutput(script.NewScript(script.P2PKH, addressP2PKH)),
 		signature,
 		tx1P2PKH,
+		0,
 	)
 	require.NoError(t, err)
 	assert.True(t, valid)
 
 	// modify the scriptSig and check that is not correct anymore
-	modifiedScriptSig := []rune(signature)
-	modifiedScriptSig[0] = 'a'
+	modifiedScriptSig := []byte(signature)
+	modifiedScriptSig[2] ^= 0xff
 	valid, err = interpreter.VerifyScriptPubKey(
-		script.NewScript(script.P2PKH, addressP2PKH),
+		spentOutput(script.NewScript(script.P2PKH, addressP2PKH)),
 		string(modifiedScriptSig),
 		tx1P2PKH,
+		0,
 	)
 	require.NoError(t, err)
 	assert.False(t, valid)
 }
 
+func TestRPNInterpreter_GenerationAndVerificationRealKeysP2SH(t *testing.T) {
+	signer := sign.NewECDSASignature()
+	interpreter := NewScriptInterpreter(crypto.NewHashedSignature(signer, hash.NewHasher(sha256.New())))
+
+	pubKey, privKey, err := signer.NewKeyPair()
+	require.NoError(t, err)
+
+	addressP2SH, err := util_p2sh.GenerateP2SHAddrFromScript([]byte(script.NewRedeemScript(pubKey)), 1)
+	require.NoError(t, err)
+	scriptPubKey := script.NewScript(script.P2SH, addressP2SH)
+
+	// generate the scriptSig to unlock the input
+	scriptSig, err := interpreter.GenerateScriptSig(spentOutput(scriptPubKey), pubKey, privKey, tx1P2PK, 0, kernel.SighashAll)
+	require.NoError(t, err)
+
+	valid, err := interpreter.VerifyScriptPubKey(spentOutput(scriptPubKey), scriptSig, tx1P2PK, 0)
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	// a different key can't generate a redeem script matching the script hash
+	otherPubKey, otherPrivKey, err := signer.NewKeyPair()
+	require.NoError(t, err)
+	_, err = interpreter.GenerateScriptSig(spentOutput(scriptPubKey), otherPubKey, otherPrivKey, tx1P2PK, 0, kernel.SighashAll)
+	require.Error(t, err)
+
+	// a redeem script that does not match the script hash is rejected
+	otherSig, err := interpreter.GenerateScriptSig(spentOutput(script.NewScript(script.P2PK, otherPubKey)), otherPubKey, otherPrivKey, tx1P2PK, 0, kernel.SighashAll)
+	require.NoError(t, err)
+	forgedScriptSig := util_script.EncodeScriptSig([][]byte{
+		decodeScriptSig(t, otherSig)[0], []byte(script.NewRedeemScript(otherPubKey)),
+	})
+	valid, err = interpreter.VerifyScriptPubKey(spentOutput(scriptPubKey), forgedScriptSig, tx1P2PK, 0)
+	require.NoError(t, err)
+	assert.False(t, valid)
+
+	// the redeem script matches but the signature does not
+	valid, err = interpreter.VerifyScriptPubKey(spentOutput(scriptPubKey), scriptSig, tx2P2PK, 0)
+	require.NoError(t, err)
+	assert.False(t, valid)
+}
+
+func TestRPNInterpreter_GenerationAndVerificationRealKeysMultiSig(t *testing.T) {
+	signer := sign.NewECDSASignature()
+	interpreter := NewScriptInterpreter(crypto.NewHashedSignature(signer, hash.NewHasher(sha256.New())))
+
+	pubKeys := [][]byte{}
+	privKeys := [][]byte{}
+	for range 3 {
+		pubKey, privKey, err := signer.NewKeyPair()
+		require.NoError(t, err)
+		pubKeys = append(pubKeys, pubKey)
+		privKeys = append(privKeys, privKey)
+	}
+
+	scriptPubKey := script.NewMultiSigScript(2, pubKeys)
+
+	// each key owner generates its partial scriptSig
+	partials := []string{}
+	for i := range pubKeys {
+		partial, err := interpreter.GenerateScriptSig(spentOutput(scriptPubKey), pubKeys[i], privKeys[i], tx1P2PK, 0, kernel.SighashAll)
+		require.NoError(t, err)
+		partials = append(partials, partial)
+	}
+
+	// keys that are not part of the script can't sign
+	otherPubKey, otherPrivKey, err := signer.NewKeyPair()
+	require.NoError(t, err)
+	_, err = interpreter.GenerateScriptSig(spentOutput(scriptPubKey), otherPubKey, otherPrivKey, tx1P2PK, 0, kernel.SighashAll)
+	require.Error(t, err)
+
+	// partial scriptSigs are combined regardless of the order in which are provided
+	scriptSig, err := interpreter.CombineMultiSigScriptSigs(spentOutput(scriptPubKey), []string{partials[2], partials[0]}, tx1P2PK, 0)
+	require.NoError(t, err)
+
+	valid, err := interpreter.VerifyScriptPubKey(spentOutput(scriptPubKey), scriptSig, tx1P2PK, 0)
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	// not enough signatures
+	_, err = interpreter.CombineMultiSigScriptSigs(spentOutput(scriptPubKey), []string{partials[1]}, tx1P2PK, 0)
+	require.Error(t, err)
+
+	// signatures provided in a different order than the public keys
+	reversed := util_script.EncodeScriptSig([][]byte{
+		decodeScriptSig(t, partials[2])[0], decodeScriptSig(t, partials[0])[0],
+	})
+	valid, err = interpreter.VerifyScriptPubKey(spentOutput(scriptPubKey), reversed, tx1P2PK, 0)
+	require.NoError(t, err)
+	assert.False(t, valid)
+
+	// signatures that don't belong to the transaction
+	valid, err = interpreter.VerifyScriptPubKey(spentOutput(scriptPubKey), scriptSig, tx2P2PK, 0)
+	require.NoError(t, err)
+	assert.False(t, valid)
+
+	// OP_CHECKMULTISIGVERIFY fails the script if the signatures are not valid
+	multiSigScript := script.NewMultiSigScript(1, pubKeys[:1])
+	verifyScript := multiSigScript[:len(multiSigScript)-1] + operator(script.OpCheckMultiSigVerify) + strings.TrimPrefix(script.NewScript(script.P2PK, pubKeys[1]), util_script.BinaryFormatMarker)
+	verifySigs := [][]byte{}
+	for _, i := range []int{1, 0} {
+		sig, errSig := interpreter.signInput(tx1P2PK, 0, spentOutput(verifyScript), kernel.SighashAll, privKeys[i])
+		require.NoError(t, errSig)
+		verifySigs = append(verifySigs, sig)
+	}
+	verifyScriptSig := util_script.EncodeScriptSig(verifySigs)
+	valid, err = interpreter.VerifyScriptPubKey(spentOutput(verifyScript), verifyScriptSig, tx1P2PK, 0)
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	_, err = interpreter.VerifyScriptPubKey(spentOutput(verifyScript), verifyScriptSig, tx2P2PK, 0)
+	require.Error(t, err)
+}
+
+func TestRPNInterpreter_GenerationAndVerificationRealKeysTimeLock(t *testing.T) {
+	signer := sign.NewECDSASignature()
+	interpreter := NewScriptInterpreter(crypto.NewHashedSignature(signer, hash.NewHasher(sha256.New())))
+
+	pubKey, privKey, err := signer.NewKeyPair()
+	require.NoError(t, err)
+
+	scriptPubKey := script.NewTimeLockScript(100, pubKey)
+	inputs := []kernel.TxInput{kernel.NewInput([]byte("transaction-1"), 1, "", string(pubKey))}
+	outputs := []kernel.TxOutput{kernel.NewOutput(50, script.P2PK, "pubKey-1")}
+
+	// transactions with a lock time equal or bigger than the script lock time can unlock the output
+	for _, lockTime := range []uint{100, 101} {
+		tx := kernel.NewTransactionWithLockTime(inputs, outputs, lockTime)
+		scriptSig, errSig := interpreter.GenerateScriptSig(spentOutput(scriptPubKey), pubKey, privKey, tx, 0, kernel.SighashAll)
+		require.NoError(t, errSig)
+
+		valid, errVerify := interpreter.VerifyScriptPubKey(spentOutput(scriptPubKey), scriptSig, tx, 0)
+		require.NoError(t, errVerify)
+		assert.True(t, valid)
+	}
+
+	// transactions with a smaller lock time, without lock time or with a time based lock time can't
+	for _, lockTime := range []uint{99, 0, kernel.LockTimeThreshold + 100} {
+		tx := kernel.NewTransactionWithLockTime(inputs, outputs, lockTime)
+		scriptSig, errSig := interpreter.GenerateScriptSig(spentOutput(scriptPubKey), pubKey, privKey, tx, 0, kernel.SighashAll)
+		require.NoError(t, errSig)
+
+		_, err = interpreter.VerifyScriptPubKey(spentOutput(scriptPubKey), scriptSig, tx, 0)
+		require.Error(t, err)
+	}
+
+	// the lock time of the transaction is covered by the signature
+	tx := kernel.NewTransactionWithLockTime(inputs, outputs, 100)
+	scriptSig, err := interpreter.GenerateScriptSig(spentOutput(scriptPubKey), pubKey, privKey, tx, 0, kernel.SighashAll)
+	require.NoError(t, err)
+
+	tx.LockTime = 200
+	valid, err := interpreter.VerifyScriptPubKey(spentOutput(scriptPubKey), scriptSig, tx, 0)
+	require.NoError(t, err)
+	assert.False(t, valid)
+
+	// only the owner of the time locked key can generate the scriptSig
+	otherPubKey, otherPrivKey, err := signer.NewKeyPair()
+	require.NoError(t, err)
+	_, err = interpreter.GenerateScriptSig(spentOutput(scriptPubKey), otherPubKey, otherPrivKey, tx, 0, kernel.SighashAll)
+	require.Error(t, err)
+}
+
+func TestRPNInterpreter_GenerationAndVerificationRealKeysRelativeTimeLock(t *testing.T) {
+	signer := sign.NewECDSASignature()
+	interpreter := NewScriptInterpreter(crypto.NewHashedSignature(signer, hash.NewHasher(sha256.New())))
+
+	pubKey, privKey, err := signer.NewKeyPair()
+	require.NoError(t, err)
+
+	scriptPubKey := script.NewRelativeTimeLockScript(10, pubKey)
+	newTx := func(sequence uint) *kernel.Transaction {
+		return kernel.NewTransaction(
+			[]kernel.TxInput{
+				kernel.NewInput([]byte("transaction-1"), 0, "", "pubKey-1"),
+				kernel.NewInputWithSequence([]byte("transaction-2"), 1, "", string(pubKey), sequence),
+			},
+			[]kernel.TxOutput{kernel.NewOutput(50, script.P2PK, "pubKey-1")},
+		)
+	}
+
+	// inputs with a relative lock time equal or bigger than the script one can unlock the output
+	for _, sequence := range []uint{10, 11} {
+		tx := newTx(sequence)
+		scriptSig, errSig := interpreter.GenerateScriptSig(spentOutput(scriptPubKey), pubKey, privKey, tx, 1, kernel.SighashAll)
+		require.NoError(t, errSig)
+
+		valid, errVerify := interpreter.VerifyScriptPubKey(spentOutput(scriptPubKey), scriptSig, tx, 1)
+		require.NoError(t, errVerify)
+		assert.True(t, valid)
+
+		// the sequence is checked against the input being unlocked
+		_, errVerify = interpreter.VerifyScriptPubKey(spentOutput(scriptPubKey), scriptSig, tx, 0)
+		require.Error(t, errVerify)
+		_, errVerify = interpreter.VerifyScriptPubKey(spentOutput(scriptPubKey), scriptSig, tx, 2)
+		require.Error(t, errVerify)
+	}
+
+	// inputs with smaller relative lock times, without them or in seconds can't
+	for _, sequence := range []uint{9, 0, kernel.SequenceLockTimeTypeFlag | 10} {
+		tx := newTx(sequence)
+		scriptSig, errSig := interpreter.GenerateScriptSig(spentOutput(scriptPubKey), pubKey, privKey, tx, 1, kernel.SighashAll)
+		require.NoError(t, errSig)
+
+		_, err = interpreter.VerifyScriptPubKey(spentOutput(scriptPubKey), scriptSig, tx, 1)
+		require.Error(t, err)
+	}
+}
+
+func TestRPNInterpreter_GenerationAndVerificationRealKeysHTLC(t *testing.T) {
+	signer := sign.NewECDSASignature()
+	interpreter := NewScriptInterpreter(crypto.NewHashedSignature(signer, hash.NewHasher(sha256.New())))
+
+	receiverPubKey, receiverPrivKey, err := signer.NewKeyPair()
+	require.NoError(t, err)
+	senderPubKey, senderPrivKey, err := signer.NewKeyPair()
+	require.NoError(t, err)
+
+	preimage := []byte("secret")
+	hashLock := sha256.Sum256(preimage)
+	scriptPubKey := script.NewHTLCScript(script.HTLCParams{
+		HashLock:       hashLock[:],
+		ReceiverPubKey: receiverPubKey,
+		LockTime:       100,
+		SenderPubKey:   senderPubKey,
+	})
+
+	inputs := []kernel.TxInput{kernel.NewInput([]byte("transaction-1"), 1, "", "pubKey-1")}
+	outputs := []kernel.TxOutput{kernel.NewOutput(50, script.P2PK, "pubKey-1")}
+	tx := kernel.NewTransaction(inputs, outputs)
+
+	// the receiver claims the funds revealing the preimage
+	scriptSig, err := interpreter.GenerateHTLCClaimScriptSig(spentOutput(scriptPubKey), preimage, receiverPubKey, receiverPrivKey, tx, 0, kernel.SighashAll)
+	require.NoError(t, err)
+
+	valid, err := interpreter.VerifyScriptPubKey(spentOutput(scriptPubKey), scriptSig, tx, 0)
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	// wrong preimages or keys can't claim the funds
+	_, err = interpreter.GenerateHTLCClaimScriptSig(spentOutput(scriptPubKey), []byte("wrong"), receiverPubKey, receiverPrivKey, tx, 0, kernel.SighashAll)
+	require.Error(t, err)
+	_, err = interpreter.GenerateHTLCClaimScriptSig(spentOutput(scriptPubKey), preimage, senderPubKey, senderPrivKey, tx, 0, kernel.SighashAll)
+	require.Error(t, err)
+	_, err = interpreter.GenerateScriptSig(spentOutput(scriptPubKey), receiverPubKey, receiverPrivKey, tx, 0, kernel.SighashAll)
+	require.Error(t, err)
+
+	elements := decodeScriptSig(t, scriptSig)
+	wrongPreimage := util_script.EncodeScriptSig([][]byte{elements[0], []byte("wrong"), elements[2]})
+	_, err = interpreter.VerifyScriptPubKey(spentOutput(scriptPubKey), wrongPreimage, tx, 0)
+	require.Error(t, err)
+
+	// the sender can only take the funds back once the lock time has been reached
+	refundTx := kernel.NewTransactionWithLockTime(inputs, outputs, 100)
+	refundScriptSig, err := interpreter.GenerateScriptSig(spentOutput(scriptPubKey), senderPubKey, senderPrivKey, refundTx, 0, kernel.SighashAll)
+	require.NoError(t, err)
+
+	valid, err = interpreter.VerifyScriptPubKey(spentOutput(scriptPubKey), refundScriptSig, refundTx, 0)
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	refundTx = kernel.NewTransactionWithLockTime(inputs, outputs, 99)
+	refundScriptSig, err = interpreter.GenerateScriptSig(spentOutput(scriptPubKey), senderPubKey, senderPrivKey, refundTx, 0, kernel.SighashAll)
+	require.NoError(t, err)
+
+	_, err = interpreter.VerifyScriptPubKey(spentOutput(scriptPubKey), refundScriptSig, refundTx, 0)
+	require.Error(t, err)
+}
+
+func TestRPNInterpreter_GenerationAndVerificationRealKeysSignatureTypes(t *testing.T) {
+	signer := sign.NewECDSASignature()
+	interpreter := NewScriptInterpreter(crypto.NewHashedSignature(signer, hash.NewHasher(sha256.New())))
+
+	pubKey, privKey, err := signer.NewKeyPair()
+	require.NoError(t, err)
+
+	scriptPubKey := script.NewScript(script.P2PK, pubKey)
+	newTx := func(inputs, outputs uint) *kernel.Transaction {
+		tx := &kernel.Transaction{}
+		for i := range inputs {
+			tx.Vin = append(tx.Vin, kernel.NewInput([]byte(fmt.Sprintf("transaction-%d", i)), i, "", string(pubKey)))
+		}
+		for i := range outputs {
+			tx.Vout = append(tx.Vout, kernel.NewOutput(10+i, script.P2PK, "pubKey-1"))
+		}
+		return tx
+	}
+
+	// verify checks if the scriptSig generated for the input of tx is still valid in modifiedTx
+	verify := func(sigType kernel.SignatureType, inputIdx uint, tx, modifiedTx *kernel.Transaction) bool {
+		scriptSig, errSig := interpreter.GenerateScriptSig(spentOutput(scriptPubKey), pubKey, privKey, tx, inputIdx, sigType)
+		require.NoError(t, errSig)
+
+		valid, errVerify := interpreter.VerifyScriptPubKey(spentOutput(scriptPubKey), scriptSig, modifiedTx, inputIdx)
+		require.NoError(t, errVerify)
+		return valid
+	}
+
+	// SIGHASH_ALL commits to every input and output
+	assert.True(t, verify(kernel.SighashAll, 0, newTx(2, 2), newTx(2, 2)))
+	assert.False(t, verify(kernel.SighashAll, 0, newTx(2, 2), newTx(3, 2)))
+	assert.False(t, verify(kernel.SighashAll, 0, newTx(2, 2), newTx(2, 1)))
+
+	// SIGHASH_NONE commits to the inputs, but not to the outputs
+	assert.True(t, verify(kernel.SighashNone, 0, newTx(2, 2), newTx(2, 1)))
+	assert.False(t, verify(kernel.SighashNone, 0, newTx(2, 2), newTx(3, 2)))
+
+	// SIGHASH_SINGLE commits to the output with the same index as the input
+	assert.True(t, verify(kernel.SighashSingle, 1, newTx(2, 2), newTx(2, 3)))
+	modifiedTx := newTx(2, 2)
+	modifiedTx.Vout[1].Amount = 1
+	assert.False(t, verify(kernel.SighashSingle, 1, newTx(2, 2), modifiedTx))
+	_, err = interpreter.GenerateScriptSig(spentOutput(scriptPubKey), pubKey, privKey, newTx(2, 1), 1, kernel.SighashSingle)
+	require.Error(t, err)
+
+	// SIGHASH_ANYONECANPAY allows others to add their own inputs (crowdfunding)
+	assert.True(t, verify(kernel.SighashAll|kernel.SighashAnyoneCanPay, 0, newTx(1, 2), newTx(3, 2)))
+	assert.False(t, verify(kernel.SighashAll|kernel.SighashAnyoneCanPay, 0, newTx(1, 2), newTx(3, 1)))
+	assert.True(t, verify(kernel.SighashNone|kernel.SighashAnyoneCanPay, 0, newTx(1, 2), newTx(3, 1)))
+
+	// the signature type can't be modified once the input has been signed
+	scriptSig, err := interpreter.GenerateScriptSig(spentOutput(scriptPubKey), pubKey, privKey, newTx(2, 2), 0, kernel.SighashAll)
+	require.NoError(t, err)
+	signature := decodeScriptSig(t, scriptSig)[0]
+	signature[len(signature)-1] = byte(kernel.SighashNone)
+	valid, err := interpreter.VerifyScriptPubKey(spentOutput(scriptPubKey), util_script.EncodeScriptSig([][]byte{signature}), newTx(2, 2), 0)
+	require.NoError(t, err)
+	assert.False(t, valid)
+
+	// unknown signature types
+	_, err = interpreter.GenerateScriptSig(spentOutput(scriptPubKey), pubKey, privKey, newTx(2, 2), 0, kernel.SignatureType(0x04))
+	require.Error(t, err)
+}
+
+func TestRPNInterpreter_SignaturesCommitToSpentOutput(t *testing.T) {
+	signer := sign.NewECDSASignature()
+	interpreter := NewScriptInterpreter(crypto.NewHashedSignature(signer, hash.NewHasher(sha256.New())))
+
+	pubKey, privKey, err := signer.NewKeyPair()
+	require.NoError(t, err)
+
+	// both inputs of tx2P2PK spend outputs locked to the same key
+	output := spentOutput(script.NewScript(script.P2PK, pubKey))
+	scriptSig, err := interpreter.GenerateScriptSig(output, pubKey, privKey, tx2P2PK, 0, kernel.SighashAll)
+	require.NoError(t, err)
+
+	valid, err := interpreter.VerifyScriptPubKey(output, scriptSig, tx2P2PK, 0)
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	// the signature can't be replayed in a different input
+	valid, err = interpreter.VerifyScriptPubKey(output, scriptSig, tx2P2PK, 1)
+	require.NoError(t, err)
+	assert.False(t, valid)
+
+	// the amount of the spent output is committed
+	modifiedOutput := output
+	modifiedOutput.Amount++
+	valid, err = interpreter.VerifyScriptPubKey(modifiedOutput, scriptSig, tx2P2PK, 0)
+	require.NoError(t, err)
+	assert.False(t, valid)
+}
+
+func TestRPNInterpreter_Conditionals(t *testing.T) {
+	interpreter := NewScriptInterpreter(crypto.NewHashedSignature(&mockSign.MockSign{}, &mockHash.FakeHashing{}))
+
+	number := func(value string) string {
+		return string(append([]byte{byte(script.Number), byte(len(value))}, value...))
+	}
+	branches := util_script.BinaryFormatMarker + operator(script.OpIf) + number("1") + operator(script.OpElse) + number("2") + operator(script.OpEndIf) + number("2") + operator(script.OpEqual)
+
+	// the condition selects the branch that is executed
+	valid, err := interpreter.VerifyScriptPubKey(spentOutput(branches), util_script.EncodeScriptSig([][]byte{[]byte("false")}), tx1P2PK, 0)
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	valid, err = interpreter.VerifyScriptPubKey(spentOutput(branches), util_script.EncodeScriptSig([][]byte{[]byte("true")}), tx1P2PK, 0)
+	require.NoError(t, err)
+	assert.False(t, valid)
+
+	// nested conditionals inside branches that are not executed don't consume the stack
+	nested := util_script.BinaryFormatMarker + operator(script.OpIf) + operator(script.OpIf) + number("1") + operator(script.OpEndIf) + operator(script.OpElse) + number("2") + operator(script.OpEndIf)
+	_, err = interpreter.VerifyScriptPubKey(spentOutput(nested), util_script.EncodeScriptSig([][]byte{[]byte("false")}), tx1P2PK, 0)
+	require.NoError(t, err)
+
+	// conditions must be booleans and conditionals must be balanced
+	_, err = interpreter.VerifyScriptPubKey(spentOutput(branches), util_script.EncodeScriptSig([][]byte{[]byte("1")}), tx1P2PK, 0)
+	require.Error(t, err)
+	_, err = interpreter.VerifyScriptPubKey(spentOutput(util_script.BinaryFormatMarker+operator(script.OpIf)+number("1")), util_script.EncodeScriptSig([][]byte{[]byte("true")}), tx1P2PK, 0)
+	require.Error(t, err)
+	_, err = interpreter.VerifyScriptPubKey(spentOutput(util_script.BinaryFormatMarker+number("1")+operator(script.OpEndIf)), util_script.EncodeScriptSig([][]byte{[]byte("true")}), tx1P2PK, 0)
+	require.Error(t, err)
+
+	// OP_VERIFY fails the script if the value is not true
+	_, err = interpreter.VerifyScriptPubKey(spentOutput(util_script.BinaryFormatMarker+operator(script.OpVerify)+number("1")), util_script.EncodeScriptSig([][]byte{[]byte("false")}), tx1P2PK, 0)
+	require.Error(t, err)
+	_, err = interpreter.VerifyScriptPubKey(spentOutput(util_script.BinaryFormatMarker+operator(script.OpVerify)+number("1")), util_script.EncodeScriptSig([][]byte{[]byte("true")}), tx1P2PK, 0)
+	require.NoError(t, err)
+}
+
 func TestRPNInterpreter_GenerateScriptSigP2PKMocked(t *testing.T) {
 	interpreter := NewScriptInterpreter(crypto.NewHashedSignature(&mockSign.MockSign{}, &mockHash.FakeHashing{}))
 
@@ -263,31 +714,37 @@ func TestRPNInterpreter_GenerateScriptSigP2PKMocked(t *testing.T) {
 	require.NoError(t, err)
 
 	signature, err := interpreter.GenerateScriptSig(
-		script.NewScript(script.P2PK, pubKey),
+		spentOutput(script.NewScript(script.P2PK, pubKey)),
 		pubKey,
 		privKey,
 		tx1P2PK,
+		0,
+		kernel.SighashAll,
 	)
 	require.NoError(t, err)
-	assert.Equal(t, base58.Encode([]byte(fmt.Sprintf("%s-hashed-signed", tx1P2PK.AssembleForSigning()))), signature)
+	assert.Equal(t, util_script.EncodeScriptSig([][]byte{mockedSignature(t, tx1P2PK, 0, spentOutput(script.NewScript(script.P2PK, pubKey)), kernel.SighashAll)}), signature)
 
 	signature, err = interpreter.GenerateScriptSig(
-		script.NewScript(script.P2PK, pubKey),
+		spentOutput(script.NewScript(script.P2PK, pubKey)),
 		pubKey,
 		privKey,
 		tx2P2PK,
+		0,
+		kernel.SighashAll,
 	)
 	require.NoError(t, err)
-	assert.Equal(t, base58.Encode([]byte(fmt.Sprintf("%s-hashed-signed", tx2P2PK.AssembleForSigning()))), signature)
+	assert.Equal(t, util_script.EncodeScriptSig([][]byte{mockedSignature(t, tx2P2PK, 0, spentOutput(script.NewScript(script.P2PK, pubKey)), kernel.SighashAll)}), signature)
 
 	signature, err = interpreter.GenerateScriptSig(
-		script.NewScript(script.P2PK, pubKey),
+		spentOutput(script.NewScript(script.P2PK, pubKey)),
 		pubKey,
 		privKey,
 		tx3P2PK,
+		0,
+		kernel.SighashAll,
 	)
 	require.NoError(t, err)
-	assert.Equal(t, base58.Encode([]byte(fmt.Sprintf("%s-hashed-signed", tx3P2PK.AssembleForSigning()))), signature)
+	assert.Equal(t, util_script.EncodeScriptSig([][]byte{mockedSignature(t, tx3P2PK, 0, spentOutput(script.NewScript(script.P2PK, pubKey)), kernel.SighashAll)}), signature)
 }
 
 func TestRPNInterpreter_GenerateScriptSigP2PKHMocked(t *testing.T) {
@@ -300,31 +757,37 @@ func TestRPNInterpreter_GenerateScriptSigP2PKHMocked(t *testing.T) {
 	require.NoError(t, err)
 
 	signature, err := interpreter.GenerateScriptSig(
-		script.NewScript(script.P2PKH, addressP2PKH),
+		spentOutput(script.NewScript(script.P2PKH, addressP2PKH)),
 		pubKey,
 		privKey,
 		tx1P2PK,
+		0,
+		kernel.SighashAll,
 	)
 	require.NoError(t, err)
-	assert.Equal(t, fmt.Sprintf("%s %s", base58.Encode([]byte(fmt.Sprintf("%s-hashed-signed", tx1P2PK.AssembleForSigning()))), base58.Encode(pubKey)), signature)
+	assert.Equal(t, util_script.EncodeScriptSig([][]byte{mockedSignature(t, tx1P2PK, 0, spentOutput(script.NewScript(script.P2PKH, addressP2PKH)), kernel.SighashAll), pubKey}), signature)
 
 	signature, err = interpreter.GenerateScriptSig(
-		script.NewScript(script.P2PKH, addressP2PKH),
+		spentOutput(script.NewScript(script.P2PKH, addressP2PKH)),
 		pubKey,
 		privKey,
 		tx2P2PK,
+		0,
+		kernel.SighashAll,
 	)
 	require.NoError(t, err)
-	assert.Equal(t, fmt.Sprintf("%s %s", base58.Encode([]byte(fmt.Sprintf("%s-hashed-signed", tx2P2PK.AssembleForSigning()))), base58.Encode(pubKey)), signature)
+	assert.Equal(t, util_script.EncodeScriptSig([][]byte{mockedSignature(t, tx2P2PK, 0, spentOutput(script.NewScript(script.P2PKH, addressP2PKH)), kernel.SighashAll), pubKey}), signature)
 
 	signature, err = interpreter.GenerateScriptSig(
-		script.NewScript(script.P2PKH, addressP2PKH),
+		spentOutput(script.NewScript(script.P2PKH, addressP2PKH)),
 		pubKey,
 		privKey,
 		tx3P2PK,
+		0,
+		kernel.SighashAll,
 	)
 	require.NoError(t, err)
-	assert.Equal(t, fmt.Sprintf("%s %s", base58.Encode([]byte(fmt.Sprintf("%s-hashed-signed", tx3P2PK.AssembleForSigning()))), base58.Encode(pubKey)), signature)
+	assert.Equal(t, util_script.EncodeScriptSig([][]byte{mockedSignature(t, tx3P2PK, 0, spentOutput(script.NewScript(script.P2PKH, addressP2PKH)), kernel.SighashAll), pubKey}), signature)
 }
 
 func TestRPNInterpreter_VerifyScriptPubKeyP2PKMocked(t *testing.T) {
@@ -334,25 +797,28 @@ func TestRPNInterpreter_VerifyScriptPubKeyP2PKMocked(t *testing.T) {
 	require.NoError(t, err)
 
 	valid, err := interpreter.VerifyScriptPubKey(
-		script.NewScript(script.P2PK, pubKey),
-		base58.Encode([]byte(fmt.Sprintf("%s-hashed-signed", tx1P2PK.AssembleForSigning()))),
+		spentOutput(script.NewScript(script.P2PK, pubKey)),
+		util_script.EncodeScriptSig([][]byte{mockedSignature(t, tx1P2PK, 0, spentOutput(script.NewScript(script.P2PK, pubKey)), kernel.SighashAll)}),
 		tx1P2PK,
+		0,
 	)
 	require.NoError(t, err)
 	assert.True(t, valid)
 
 	valid, err = interpreter.VerifyScriptPubKey(
-		script.NewScript(script.P2PK, pubKey),
-		base58.Encode([]byte(fmt.Sprintf("%s-hashed-signed", tx2P2PK.AssembleForSigning()))),
+		spentOutput(script.NewScript(script.P2PK, pubKey)),
+		util_script.EncodeScriptSig([][]byte{mockedSignature(t, tx2P2PK, 0, spentOutput(script.NewScript(script.P2PK, pubKey)), kernel.SighashAll)}),
 		tx2P2PK,
+		0,
 	)
 	require.NoError(t, err)
 	assert.True(t, valid)
 
 	valid, err = interpreter.VerifyScriptPubKey(
-		script.NewScript(script.P2PK, pubKey),
-		base58.Encode([]byte(fmt.Sprintf("%s-hashed-signed", tx3P2PK.AssembleForSigning()))),
+		spentOutput(script.NewScript(script.P2PK, pubKey)),
+		util_script.EncodeScriptSig([][]byte{mockedSignature(t, tx3P2PK, 0, spentOutput(script.NewScript(script.P2PK, pubKey)), kernel.SighashAll)}),
 		tx3P2PK,
+		0,
 	)
 	require.NoError(t, err)
 	assert.True(t, valid)
@@ -368,35 +834,65 @@ func TestRPNInterpreter_VerifyScriptPubKeyP2PKHMocked(t *testing.T) {
 	require.NoError(t, err)
 
 	valid, err := interpreter.VerifyScriptPubKey(
-		script.NewScript(script.P2PKH, addressP2PKH),
-		fmt.Sprintf("%s %s", base58.Encode([]byte(fmt.Sprintf("%s-hashed-signed", tx1P2PKH.AssembleForSigning()))), base58.Encode(pubKey)),
+		spentOutput(script.NewScript(script.P2PKH, addressP2PKH)),
+		util_script.EncodeScriptSig([][]byte{mockedSignature(t, tx1P2PKH, 0, spentOutput(script.NewScript(script.P2PKH, addressP2PKH)), kernel.SighashAll), pubKey}),
 		tx1P2PKH,
+		0,
 	)
 	require.NoError(t, err)
 	assert.True(t, valid)
 
 	valid, err = interpreter.VerifyScriptPubKey(
-		script.NewScript(script.P2PKH, addressP2PKH),
-		fmt.Sprintf("%s %s", base58.Encode([]byte(fmt.Sprintf("%s-hashed-signed", tx2P2PKH.AssembleForSigning()))), base58.Encode(pubKey)),
+		spentOutput(script.NewScript(script.P2PKH, addressP2PKH)),
+		util_script.EncodeScriptSig([][]byte{mockedSignature(t, tx2P2PKH, 0, spentOutput(script.NewScript(script.P2PKH, addressP2PKH)), kernel.SighashAll), pubKey}),
 		tx2P2PKH,
+		0,
 	)
 	require.NoError(t, err)
 	assert.True(t, valid)
 
 	valid, err = interpreter.VerifyScriptPubKey(
-		script.NewScript(script.P2PKH, addressP2PKH),
-		fmt.Sprintf("%s %s", base58.Encode([]byte(fmt.Sprintf("%s-hashed-signed", tx3P2PKH.AssembleForSigning()))), base58.Encode(pubKey)),
+		spentOutput(script.NewScript(script.P2PKH, addressP2PKH)),
+		util_script.EncodeScriptSig([][]byte{mockedSignature(t, tx3P2PKH, 0, spentOutput(script.NewScript(script.P2PKH, addressP2PKH)), kernel.SighashAll), pubKey}),
 		tx3P2PKH,
+		0,
 	)
 	require.NoError(t, err)
 	assert.True(t, valid)
 
 	// verify scriptSig with different pubKey than expected
 	valid, err = interpreter.VerifyScriptPubKey(
-		script.NewScript(script.P2PKH, addressP2PKH),
-		fmt.Sprintf("%s %s", base58.Encode([]byte(fmt.Sprintf("%s-hashed-signed", tx3P2PKH.AssembleForSigning()))), base58.Encode([]byte("differentpubkey"))),
+		spentOutput(script.NewScript(script.P2PKH, addressP2PKH)),
+		util_script.EncodeScriptSig([][]byte{mockedSignature(t, tx3P2PKH, 0, spentOutput(script.NewScript(script.P2PKH, addressP2PKH)), kernel.SighashAll), []byte("differentpubkey")}),
 		tx1P2PKH,
+		0,
 	)
 	require.Error(t, err)
 	assert.False(t, valid)
 }
+
+// spentOutput returns the output with the scriptPubKey provided that is spent by the transactions of the tests
+func spentOutput(scriptPubKey string) kernel.TxOutput {
+	return kernel.TxOutput{Amount: 50, ScriptPubKey: scriptPubKey}
+}
+
+// mockedSignature returns the signature generated by the mocked signer for the input of the transaction
+func mockedSignature(t *testing.T, tx *kernel.Transaction, inputIdx uint, output kernel.TxOutput, sigType kernel.SignatureType) []byte {
+	payload, err := tx.AssembleForSigning(inputIdx, output, sigType)
+	require.NoError(t, err)
+
+	return append([]byte(fmt.Sprintf("%s-hashed-signed", payload)), byte(sigType))
+}
+
+// operator returns the serialized operator provided
+func operator(op script.ScriptElement) string {
+	return string([]byte{byte(op)})
+}
+
+// decodeScriptSig returns the arguments of the scriptSig provided
+func decodeScriptSig(t *testing.T, scriptSig string) [][]byte {
+	elements, err := util_script.DecodeScriptSig(scriptSig)
+	require.NoError(t, err)
+
+	return elements
+}
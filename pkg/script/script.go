@@ -2,17 +2,25 @@ package script
 
 import (
 	"bytes"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/btcsuite/btcutil/base58"
 	util_p2pkh "github.com/yago-123/chainnet/pkg/util/p2pkh"
+	util_p2sh "github.com/yago-123/chainnet/pkg/util/p2sh"
+	util_script "github.com/yago-123/chainnet/pkg/util/script"
 )
 
 type ScriptType uint //nolint:revive // ScriptType is a type for script types
 
 const (
-	MinLengthOfLiteral = 2
+	// MaxMultiSigPubKeys is the max number of public keys allowed in a multisig script
+	MaxMultiSigPubKeys = 20
+	// minMultiSigScriptLength is the length of the smallest multisig script (m <pub key> n OP_CHECKMULTISIG)
+	minMultiSigScriptLength = 4
 )
 
 type Script []ScriptElement
@@ -22,6 +30,22 @@ type ScriptElement uint //nolint:revive // ScriptElement is a type for script el
 const (
 	P2PK ScriptType = iota
 	P2PKH
+	P2SH
+	// MultiSig represents bare m-of-n multisig scripts. The script structure depends on the number of public keys, so
+	// is not part of scriptStructure
+	MultiSig
+	// TimeLock represents P2PK scripts that can't be spent until the lock time has been reached, the lock time is not
+	// part of the arguments of NewScript so is not part of scriptStructure either
+	TimeLock
+	// RelativeTimeLock represents P2PK scripts that can't be spent until the relative lock time has passed since the
+	// output was confirmed, same as TimeLock is not part of scriptStructure
+	RelativeTimeLock
+	// HTLC represents hash time locked contracts: can be unlocked by the receiver revealing the preimage of the hash
+	// lock or by the sender once the lock time has been reached (refund). Not part of scriptStructure either
+	HTLC
+	// NullData represents provably unspendable outputs that carry arbitrary data (OP_RETURN <data>), these outputs
+	// never enter the UTXO set
+	NullData
 
 	UndefinedScriptType
 	// ...
@@ -30,32 +54,96 @@ const (
 var scriptStructure = map[ScriptType]Script{ //nolint:gochecknoglobals // must be a global variable
 	P2PK:                {PubKey, OpChecksig},
 	P2PKH:               {OpDup, OpHash160, PubKeyHash, OpEqualVerify, OpChecksig},
+	P2SH:                {OpHash160, ScriptHash, OpEqual},
+	NullData:            {OpReturn, Data},
 	UndefinedScriptType: {Undefined},
 }
 
+// timeLockStructure is the structure of the TimeLock scripts: <lock time> OP_CHECKLOCKTIMEVERIFY OP_DROP <pub key> OP_CHECKSIG
+var timeLockStructure = Script{Number, OpCheckLockTimeVerify, OpDrop, PubKey, OpChecksig} //nolint:gochecknoglobals // must be a global variable
+
+// relativeTimeLockStructure is the structure of the RelativeTimeLock scripts: <sequence> OP_CHECKSEQUENCEVERIFY OP_DROP <pub key> OP_CHECKSIG
+var relativeTimeLockStructure = Script{Number, OpCheckSequenceVerify, OpDrop, PubKey, OpChecksig} //nolint:gochecknoglobals // must be a global variable
+
+// htlcStructure is the structure of the HTLC scripts:
+// OP_IF OP_SHA256 <hash lock> OP_EQUALVERIFY <receiver pub key>
+// OP_ELSE <lock time> OP_CHECKLOCKTIMEVERIFY OP_DROP <sender pub key>
+// OP_ENDIF OP_CHECKSIG
+var htlcStructure = Script{ //nolint:gochecknoglobals // must be a global variable
+	OpIf, OpSha256, Hash, OpEqualVerify, PubKey,
+	OpElse, Number, OpCheckLockTimeVerify, OpDrop, PubKey,
+	OpEndIf, OpChecksig,
+}
+
+// HTLCParams contains the arguments of a HTLC script
+type HTLCParams struct {
+	// HashLock is the SHA-256 hash of the preimage that the receiver must reveal
+	HashLock []byte
+	// ReceiverPubKey is the public key that can unlock the output revealing the preimage
+	ReceiverPubKey []byte
+	// LockTime is the lock time from which the sender can take the funds back
+	LockTime uint
+	// SenderPubKey is the public key that can unlock the output once the lock time has been reached
+	SenderPubKey []byte
+}
+
 // scripTypeStrings is a map that contains the string representation of the script types
 var scripTypeStrings = map[string]ScriptType{ //nolint:gochecknoglobals // it's OK to be a global variable
 	"P2PK":  P2PK,
 	"P2PKH": P2PKH,
+	"P2SH":  P2SH,
+	// MultiSig is not included because requires the list of public keys and the number of signatures
+	// TimeLock and RelativeTimeLock are not included because require the lock time
+	// HTLC is not included because requires the hash lock, the lock time and the public keys of both parties
+	// NullData is not included because does not pay to any address
 }
 
+// Script elements. Scripts are serialized as the binary format marker (see util_script.BinaryFormatMarker) followed by
+// a sequence of opcodes (one byte with the value of the element), literals are followed by the length of the data
+// (uvarint) and the data itself. The values are part of the serialization format, so new elements must not change the
+// value of the existing ones
 const (
 	// Special elements
 	PubKey ScriptElement = iota
 	PubKeyHash
 	Signature
+	ScriptHash
+	Number
+	Hash
+	Data
 
 	// Operators
 	OpChecksig
 	OpDup
 	OpHash160
 	OpEqualVerify
+	OpEqual
+	OpCheckMultiSig
+	OpCheckMultiSigVerify
+	OpCheckLockTimeVerify
+	OpCheckSequenceVerify
+	OpDrop
+	OpSha256
+	OpVerify
+	OpIf
+	OpElse
+	OpEndIf
+	OpReturn
 
 	Undefined
 )
 
 const (
+	// scriptSeparator is used to separate the elements of disassembled scripts
 	scriptSeparator = " "
+
+	// UndefinedScript is the serialized script returned when the arguments provided do not form a valid script, it is
+	// rejected when parsed so can never be unlocked
+	UndefinedScript = util_script.BinaryFormatMarker + string(rune(Undefined))
+
+	// minLengthOfLegacyLiteral is the length of the smallest literal in the legacy format: 1 byte for declaring the
+	// type of literal and at least 1 byte for the base58 value
+	minLengthOfLegacyLiteral = 2
 )
 
 var operatorNames = [...]string{ //nolint:gochecknoglobals // must be a global variable
@@ -63,12 +151,28 @@ var operatorNames = [...]string{ //nolint:gochecknoglobals // must be a global v
 	"PUB_KEY",
 	"PUB_KEY_HASH",
 	"SIGNATURE",
+	"SCRIPT_HASH",
+	"NUMBER",
+	"HASH",
+	"DATA",
 
 	// operations
 	"OP_CHECKSIG",
 	"OP_DUP",
 	"OP_HASH160",
 	"OP_EQUALVERIFY",
+	"OP_EQUAL",
+	"OP_CHECKMULTISIG",
+	"OP_CHECKMULTISIGVERIFY",
+	"OP_CHECKLOCKTIMEVERIFY",
+	"OP_CHECKSEQUENCEVERIFY",
+	"OP_DROP",
+	"OP_SHA256",
+	"OP_VERIFY",
+	"OP_IF",
+	"OP_ELSE",
+	"OP_ENDIF",
+	"OP_RETURN",
 
 	// undefined
 	"UNDEFINED",
@@ -93,12 +197,12 @@ func (op ScriptElement) OutsideBoundaries() bool {
 // IsLiteral checks if the element is of literal type
 func (op ScriptElement) IsLiteral() bool {
 	// todo() extend with more other special cases
-	return op >= PubKey && op <= Signature
+	return op >= PubKey && op <= Data
 }
 
 // IsOperator checks if the element is an operator
 func (op ScriptElement) IsOperator() bool {
-	return op >= OpChecksig && op <= OpEqualVerify
+	return op >= OpChecksig && op <= OpReturn
 }
 
 func (op ScriptElement) IsUndefined() bool {
@@ -121,100 +225,254 @@ func (op ScriptElement) ToUint() uint {
 
 // NewScript generates a new script based on the type and the argument provided. The argument content changes
 // based on the script type. In the case of P2PK the argument will be the public key, in the case of P2PKH the
-// argument will be the P2PKH address
+// argument will be the P2PKH address and in the case of P2SH the argument will be the P2SH address
 func NewScript(scriptType ScriptType, address []byte) string {
 	// if there is no public key, return undefined directly
 	if len(address) == 0 {
-		return scriptStructure[UndefinedScriptType].String(address)
+		return UndefinedScript
 	}
 
 	// generate script based on type
-	script := scriptStructure[scriptType]
+	script, ok := scriptStructure[scriptType]
+	if !ok {
+		return UndefinedScript
+	}
+
+	return script.Serialize(address)
+}
+
+// NewMultiSigScript generates a bare multisig script that can be unlocked with m signatures of the public keys provided:
+// m <pub key 1> ... <pub key n> n OP_CHECKMULTISIG
+func NewMultiSigScript(m uint, pubKeys [][]byte) string {
+	if m == 0 || m > uint(len(pubKeys)) || len(pubKeys) > MaxMultiSigPubKeys {
+		return UndefinedScript
+	}
+
+	builder := newBuilder().addLiteral(Number, []byte(strconv.FormatUint(uint64(m), 10)))
+	for _, pubKey := range pubKeys {
+		if len(pubKey) == 0 {
+			return UndefinedScript
+		}
+
+		builder.addLiteral(PubKey, pubKey)
+	}
+
+	return builder.
+		addLiteral(Number, []byte(strconv.Itoa(len(pubKeys)))).
+		addOperator(OpCheckMultiSig).
+		script()
+}
+
+// NewTimeLockScript generates a P2PK script that can't be unlocked until the lock time (block height or Unix time) has
+// been reached by the spending transaction: <lock time> OP_CHECKLOCKTIMEVERIFY OP_DROP <pub key> OP_CHECKSIG
+func NewTimeLockScript(lockTime uint, pubKey []byte) string {
+	return newLockScript(OpCheckLockTimeVerify, lockTime, pubKey)
+}
+
+// NewRelativeTimeLockScript generates a P2PK script that can't be unlocked until the relative lock time contained in
+// the sequence has passed since the output was confirmed: <sequence> OP_CHECKSEQUENCEVERIFY OP_DROP <pub key> OP_CHECKSIG
+func NewRelativeTimeLockScript(sequence uint, pubKey []byte) string {
+	return newLockScript(OpCheckSequenceVerify, sequence, pubKey)
+}
 
-	// todo() the render will switch to a hex string eventually
-	// render script to string
-	return script.String(address)
+// NewHTLCScript generates a hash time locked contract script. The receiver can unlock the output revealing the
+// preimage of the SHA-256 hash lock, while the sender can take the funds back once the lock time has been reached
+func NewHTLCScript(params HTLCParams) string {
+	if len(params.HashLock) == 0 || len(params.ReceiverPubKey) == 0 || len(params.SenderPubKey) == 0 || params.LockTime == 0 {
+		return UndefinedScript
+	}
+
+	return newBuilder().
+		addOperator(OpIf).
+		addOperator(OpSha256).
+		addLiteral(Hash, params.HashLock).
+		addOperator(OpEqualVerify).
+		addLiteral(PubKey, params.ReceiverPubKey).
+		addOperator(OpElse).
+		addLiteral(Number, []byte(strconv.FormatUint(uint64(params.LockTime), 10))).
+		addOperator(OpCheckLockTimeVerify).
+		addOperator(OpDrop).
+		addLiteral(PubKey, params.SenderPubKey).
+		addOperator(OpEndIf).
+		addOperator(OpChecksig).
+		script()
 }
 
-// String returns the string representation of the script. The argument content changes based on the script type,
+// NewNullDataScript generates a provably unspendable script that carries the data provided: OP_RETURN <data>
+func NewNullDataScript(data []byte) string {
+	return NewScript(NullData, data)
+}
+
+// newLockScript generates a P2PK script locked by the lock operator provided (OP_CHECKLOCKTIMEVERIFY or
+// OP_CHECKSEQUENCEVERIFY)
+func newLockScript(lockOperator ScriptElement, lockTime uint, pubKey []byte) string {
+	if lockTime == 0 || len(pubKey) == 0 {
+		return UndefinedScript
+	}
+
+	return newBuilder().
+		addLiteral(Number, []byte(strconv.FormatUint(uint64(lockTime), 10))).
+		addOperator(lockOperator).
+		addOperator(OpDrop).
+		addLiteral(PubKey, pubKey).
+		addOperator(OpChecksig).
+		script()
+}
+
+// Serialize returns the binary representation of the script. The argument content changes based on the script type,
 // in the case of P2PK the argument arg will be the public key, in the case of P2PKH the argument will be the P2PKH
-// address
-func (s Script) String(arg []byte) string {
-	var err error
-	var rendered []string
-	var pubKeyHash []byte
+// address and in the case of P2SH the argument will be the P2SH address
+func (s Script) Serialize(arg []byte) string {
+	builder := newBuilder()
 
 	for _, element := range s {
-		toRender := ""
-
 		if element.OutsideBoundaries() {
-			return Undefined.String()
+			return UndefinedScript
 		}
 
-		// render special cases adding the preffix so we can later know which type of literal was written. This
-		// includes arg, pubHashKey, signature...
-		if element.IsLiteral() {
-			literalRendered := []byte{}
+		if !element.IsLiteral() {
+			builder.addOperator(element)
+			continue
+		}
 
-			if element == PubKey {
-				literalRendered = arg
+		// fill the literals with the argument provided
+		switch element { //nolint:exhaustive // only literals can be filled from the argument
+		case PubKey, Data:
+			builder.addLiteral(element, arg)
+		case PubKeyHash:
+			pubKeyHash, _, err := util_p2pkh.ExtractPubKeyHashedFromP2PKHAddr(arg)
+			if err != nil {
+				// an error may happen if the checksum is invalid or the address is not a P2PKH address
+				return UndefinedScript
 			}
 
-			if element == PubKeyHash {
-				pubKeyHash, _, err = util_p2pkh.ExtractPubKeyHashedFromP2PKHAddr(arg)
-				if err != nil {
-					// an error may happen if the checksum is invalid or the address is not a P2PKH address
-					return Undefined.String()
-				}
-
-				literalRendered = pubKeyHash
+			builder.addLiteral(element, pubKeyHash)
+		case ScriptHash:
+			scriptHash, _, err := util_p2sh.ExtractScriptHashFromP2SHAddr(arg)
+			if err != nil {
+				// an error may happen if the checksum is invalid or the address is not a P2SH address
+				return UndefinedScript
 			}
 
-			toRender = fmt.Sprintf("%c%s", element, base58.Encode(literalRendered))
+			builder.addLiteral(element, scriptHash)
+		default:
+			// the rest of literals can't be derived from the argument
+			return UndefinedScript
 		}
+	}
 
-		// render operators
-		if !element.IsLiteral() {
-			toRender = element.String()
+	return builder.script()
+}
+
+// StringToScript parses a serialized script into the Script type and the array of literals (like pub key, hash pub
+// key, etc). The operators have an empty literal in the same position. Scripts containing unknown opcodes or
+// truncated literals are rejected. Scripts serialized in the legacy format are parsed too
+func StringToScript(scriptPubKey string) (Script, []string, error) {
+	if scriptPubKey != "" && !util_script.IsBinaryFormat(scriptPubKey) {
+		scriptTokens, scriptString := legacyStringToScript(scriptPubKey)
+		return scriptTokens, scriptString, nil
+	}
+
+	scriptTokens := []ScriptElement{}
+	scriptString := []string{}
+
+	data := []byte(strings.TrimPrefix(scriptPubKey, util_script.BinaryFormatMarker))
+	for pos := 0; pos < len(data); {
+		token := ScriptElement(data[pos])
+		if token.OutsideBoundaries() {
+			return nil, nil, fmt.Errorf("undefined opcode 0x%02x in position %d", data[pos], pos)
 		}
+		pos++
 
-		// if no element has been rendered, return Undefined
-		if toRender == "" {
-			return Undefined.String()
+		literal := ""
+		if token.IsLiteral() {
+			length, read := binary.Uvarint(data[pos:])
+			if read <= 0 {
+				return nil, nil, fmt.Errorf("invalid length of %s in position %d", token.String(), pos)
+			}
+			pos += read
+
+			if length > uint64(len(data)-pos) {
+				return nil, nil, fmt.Errorf("%s in position %d exceeds the script length", token.String(), pos)
+			}
+
+			literal = string(data[pos : pos+int(length)])
+			pos += int(length)
 		}
 
-		rendered = append(rendered, toRender)
+		scriptTokens = append(scriptTokens, token)
+		scriptString = append(scriptString, literal)
 	}
 
-	return strings.Join(rendered, " ")
+	return scriptTokens, scriptString, nil
 }
 
-// StringToScript converts a script pub key string into Script type and array of literals (like pub key, hash pub key, etc)
-func StringToScript(scriptPubKey string) (Script, []string, error) {
+// legacyStringToScript parses a script serialized in the legacy format: elements separated by spaces, in which
+// operators are written by name and literals are prefixed by the byte of the literal type followed by the base58 value
+func legacyStringToScript(scriptPubKey string) (Script, []string) {
 	scriptTokens := []ScriptElement{}
 	scriptString := []string{}
 
 	for _, element := range strings.Split(scriptPubKey, scriptSeparator) {
-		var token ScriptElement
-		var literal string
+		if len(element) >= minLengthOfLegacyLiteral {
+			token := ScriptElement(element[0])
+			if !token.OutsideBoundaries() && token.IsLiteral() {
+				scriptTokens = append(scriptTokens, token)
+				scriptString = append(scriptString, string(base58.Decode(element[1:])))
+				continue
+			}
+		}
+
+		scriptTokens = append(scriptTokens, ConvertToScriptElement(element))
+		scriptString = append(scriptString, "")
+	}
+
+	return scriptTokens, scriptString
+}
+
+// Disassemble returns the human-readable representation of a serialized script: operators are rendered by name and
+// literals in hexadecimal. If the script can't be parsed, [error] is rendered instead
+func Disassemble(serialized string) string {
+	if serialized == UndefinedScript {
+		return Undefined.String()
+	}
 
-		if token, literal = tryExtractTokenLiteral(element); token != Undefined {
-			scriptTokens = append(scriptTokens, token)
-			scriptString = append(scriptString, string(base58.Decode(literal)))
+	scriptTokens, scriptString, err := StringToScript(serialized)
+	if err != nil {
+		return "[error]"
+	}
+
+	rendered := make([]string, 0, len(scriptTokens))
+	for i, token := range scriptTokens {
+		if token.IsLiteral() {
+			rendered = append(rendered, hex.EncodeToString([]byte(scriptString[i])))
 			continue
 		}
 
-		token = ConvertToScriptElement(element)
-		scriptTokens = append(scriptTokens, token)
-		// in case of simple tokens string does not add any additional unit of information (for now at least)
-		scriptString = append(scriptString, "")
+		rendered = append(rendered, token.String())
 	}
 
-	return scriptTokens, scriptString, nil
+	return strings.Join(rendered, scriptSeparator)
 }
 
 // DetermineScriptType tries to derive the script type based on a set of elements that form a script
 func DetermineScriptType(script Script) ScriptType {
+	if isMultiSig(script) {
+		return MultiSig
+	}
+
+	if scriptsMatch(timeLockStructure, script) {
+		return TimeLock
+	}
+
+	if scriptsMatch(relativeTimeLockStructure, script) {
+		return RelativeTimeLock
+	}
+
+	if scriptsMatch(htlcStructure, script) {
+		return HTLC
+	}
+
 	for k, v := range scriptStructure {
 		if scriptsMatch(v, script) {
 			return k
@@ -227,6 +485,7 @@ func DetermineScriptType(script Script) ScriptType {
 // DetermineScriptTypeFromStringType returns the script type based on a string representation. For example:
 // - "P2PK" -> P2PK
 // - "P2PKH" -> P2PKH
+// - "P2SH" -> P2SH
 func ReturnScriptTypeFromStringType(scriptType string) ScriptType {
 	typ, ok := scripTypeStrings[scriptType]
 	if !ok {
@@ -247,6 +506,34 @@ func CanBeUnlockedWith(scriptPubKey string, publicKey []byte, version byte) bool
 	// determine the script type
 	scriptType := DetermineScriptType(script)
 
+	// multisig scripts have variable length, can only be unlocked by a single key if one signature is required
+	if scriptType == MultiSig {
+		m, pubKeys, errMultiSig := ExtractMultiSigParams(script, literals)
+		if errMultiSig != nil || m != 1 {
+			return false
+		}
+
+		for _, pubKey := range pubKeys {
+			if bytes.Equal(pubKey, publicKey) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	// the spending transaction must also contain a lock time (or input sequence) equal or bigger than the one in the script
+	if scriptType == TimeLock || scriptType == RelativeTimeLock {
+		_, pubKey, errTimeLock := ExtractTimeLockParams(script, literals)
+		return errTimeLock == nil && bytes.Equal(pubKey, publicKey)
+	}
+
+	// both parties of the contract can unlock the output, the receiver revealing the preimage and the sender via refund
+	if scriptType == HTLC {
+		params, errHTLC := ExtractHTLCParams(script, literals)
+		return errHTLC == nil && (bytes.Equal(params.ReceiverPubKey, publicKey) || bytes.Equal(params.SenderPubKey, publicKey))
+	}
+
 	// ensure that the number of literals matches the number of elements in the script. This helps prevent
 	// out-of-bounds access in each script type below. Although this is already handled inside the StringToScript function,
 	// we include this check as an extra precaution.
@@ -273,7 +560,15 @@ func CanBeUnlockedWith(scriptPubKey string, publicKey []byte, version byte) bool
 
 		// check if the values match
 		return bytes.Equal([]byte(literals[2]), pubKeyHashed)
-	case UndefinedScriptType:
+	case P2SH:
+		// wallets only know how to unlock P2SH outputs whose redeem script is the default one for the public key
+		scriptHash, errHash := util_p2sh.HashScript([]byte(NewRedeemScript(publicKey)))
+		if errHash != nil {
+			return false
+		}
+
+		return bytes.Equal([]byte(literals[1]), scriptHash)
+	case MultiSig, TimeLock, RelativeTimeLock, HTLC, NullData, UndefinedScriptType:
 	default:
 		break
 	}
@@ -281,6 +576,113 @@ func CanBeUnlockedWith(scriptPubKey string, publicKey []byte, version byte) bool
 	return false
 }
 
+// ExtractMultiSigParams returns the number of signatures required and the public keys of a multisig script
+func ExtractMultiSigParams(script Script, literals []string) (uint, [][]byte, error) {
+	if !isMultiSig(script) || len(script) != len(literals) {
+		return 0, nil, fmt.Errorf("script is not a multisig script")
+	}
+
+	m, err := strconv.ParseUint(literals[0], 10, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid number of signatures %q: %w", literals[0], err)
+	}
+
+	n, err := strconv.ParseUint(literals[len(literals)-2], 10, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid number of public keys %q: %w", literals[len(literals)-2], err)
+	}
+
+	pubKeys := [][]byte{}
+	for _, pubKey := range literals[1 : len(literals)-2] {
+		pubKeys = append(pubKeys, []byte(pubKey))
+	}
+
+	if n != uint64(len(pubKeys)) || n > MaxMultiSigPubKeys || m == 0 || m > n {
+		return 0, nil, fmt.Errorf("invalid multisig script: %d-of-%d with %d public keys", m, n, len(pubKeys))
+	}
+
+	return uint(m), pubKeys, nil
+}
+
+// ExtractTimeLockParams returns the lock time (or sequence) and the public key of a TimeLock or RelativeTimeLock script
+func ExtractTimeLockParams(script Script, literals []string) (uint, []byte, error) {
+	if !(scriptsMatch(timeLockStructure, script) || scriptsMatch(relativeTimeLockStructure, script)) || len(script) != len(literals) {
+		return 0, nil, fmt.Errorf("script is not a time lock script")
+	}
+
+	lockTime, err := strconv.ParseUint(literals[0], 10, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid lock time %q: %w", literals[0], err)
+	}
+
+	return uint(lockTime), []byte(literals[3]), nil
+}
+
+// ExtractHTLCParams returns the hash lock, the lock time and the public keys of a HTLC script
+func ExtractHTLCParams(script Script, literals []string) (HTLCParams, error) {
+	if !scriptsMatch(htlcStructure, script) || len(script) != len(literals) {
+		return HTLCParams{}, fmt.Errorf("script is not a HTLC script")
+	}
+
+	lockTime, err := strconv.ParseUint(literals[6], 10, 64)
+	if err != nil {
+		return HTLCParams{}, fmt.Errorf("invalid lock time %q: %w", literals[6], err)
+	}
+
+	return HTLCParams{
+		HashLock:       []byte(literals[2]),
+		ReceiverPubKey: []byte(literals[4]),
+		LockTime:       uint(lockTime),
+		SenderPubKey:   []byte(literals[9]),
+	}, nil
+}
+
+// ExtractNullData returns the data carried by a NullData script
+func ExtractNullData(script Script, literals []string) ([]byte, error) {
+	if !scriptsMatch(scriptStructure[NullData], script) || len(script) != len(literals) {
+		return nil, fmt.Errorf("script is not a null data script")
+	}
+
+	return []byte(literals[1]), nil
+}
+
+// IsUnspendable checks whether the scriptPubKey can never be unlocked (starts with OP_RETURN), these outputs must
+// not be added to the UTXO set
+func IsUnspendable(scriptPubKey string) bool {
+	if !util_script.IsBinaryFormat(scriptPubKey) {
+		first, _, _ := strings.Cut(scriptPubKey, scriptSeparator)
+		return ConvertToScriptElement(first) == OpReturn
+	}
+
+	body := scriptPubKey[len(util_script.BinaryFormatMarker):]
+	return len(body) > 0 && ScriptElement(body[0]) == OpReturn
+}
+
+// isMultiSig checks whether the script follows the multisig structure: m <pub key 1> ... <pub key n> n OP_CHECKMULTISIG
+func isMultiSig(script Script) bool {
+	if len(script) < minMultiSigScriptLength || len(script)-3 > MaxMultiSigPubKeys {
+		return false
+	}
+
+	if script[0] != Number || script[len(script)-2] != Number || script[len(script)-1] != OpCheckMultiSig {
+		return false
+	}
+
+	for _, element := range script[1 : len(script)-2] {
+		if element != PubKey {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewRedeemScript returns the redeem script used by wallets for receiving P2SH payments with a single key, which is
+// the P2PK script of the public key wrapped into P2SH
+func NewRedeemScript(pubKey []byte) string {
+	return NewScript(P2PK, pubKey)
+}
+
 // scriptsMatch checks if two scripts contain the same script elements in the same order
 func scriptsMatch(script1, script2 Script) bool {
 	if len(script1) != len(script2) {
@@ -296,20 +698,30 @@ func scriptsMatch(script1, script2 Script) bool {
 	return true
 }
 
-// tryExtractTokenLiteral tries to converts keys, hash keys etc to script.Literal type
-func tryExtractTokenLiteral(data string) (ScriptElement, string) {
-	// if data have less than 2 elements, it means that there is no possible literal
-	// must be at least 1 byte for declaring the type + 1 type for the unit of information
-	if len(data) < MinLengthOfLiteral {
-		return Undefined, ""
-	}
+// builder serializes scripts element by element
+type builder struct {
+	buf []byte
+}
 
-	opcodeByte := data[0]
-	token := ScriptElement(uint(opcodeByte))
+func newBuilder() *builder {
+	return &builder{buf: []byte(util_script.BinaryFormatMarker)}
+}
 
-	if !token.OutsideBoundaries() && token.IsLiteral() {
-		return token, data[1:]
-	}
+// addOperator appends the opcode of the operator
+func (b *builder) addOperator(op ScriptElement) *builder {
+	b.buf = append(b.buf, byte(op))
+	return b
+}
+
+// addLiteral appends the opcode of the literal followed by the length of the data and the data itself
+func (b *builder) addLiteral(element ScriptElement, data []byte) *builder {
+	b.buf = append(b.buf, byte(element))
+	b.buf = binary.AppendUvarint(b.buf, uint64(len(data)))
+	b.buf = append(b.buf, data...)
+	return b
+}
 
-	return Undefined, ""
+// script returns the serialized script
+func (b *builder) script() string {
+	return string(b.buf)
 }
@@ -3,10 +3,13 @@ package script //nolint:testpackage // don't create separate package for tests
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	util_p2pkh "github.com/yago-123/chainnet/pkg/util/p2pkh"
+	util_p2sh "github.com/yago-123/chainnet/pkg/util/p2sh"
+	util_script "github.com/yago-123/chainnet/pkg/util/script"
 
 	"github.com/btcsuite/btcutil/base58"
 	"github.com/stretchr/testify/require"
@@ -22,9 +25,9 @@ func TestNewScript_P2PK(t *testing.T) {
 		args args
 		want string
 	}{
-		{"regular script generation for P2PK", args{scriptType: P2PK, pubKey: []byte("public-key")}, fmt.Sprintf("%c%s OP_CHECKSIG", PubKey, base58.Encode([]byte("public-key")))},
-		{"generation of P2PK with empty public key", args{scriptType: P2PK, pubKey: []byte{}}, Undefined.String()},
-		{"P2PK with pubkey equal to PubKey token identifier", args{scriptType: P2PK, pubKey: []byte(fmt.Sprintf("%d", PubKey))}, fmt.Sprintf("%cq OP_CHECKSIG", PubKey)},
+		{"regular script generation for P2PK", args{scriptType: P2PK, pubKey: []byte("public-key")}, serialized(literal(PubKey, []byte("public-key")), operator(OpChecksig))},
+		{"generation of P2PK with empty public key", args{scriptType: P2PK, pubKey: []byte{}}, UndefinedScript},
+		{"P2PK with pubkey equal to PubKey token identifier", args{scriptType: P2PK, pubKey: []byte(fmt.Sprintf("%d", PubKey))}, serialized(literal(PubKey, []byte("0")), operator(OpChecksig))},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -51,9 +54,9 @@ func TestNewScript_P2PKH(t *testing.T) {
 		args args
 		want string
 	}{
-		{"regular script generation for P2PKH", args{scriptType: P2PKH, addressP2PKH: addressP2PKH}, fmt.Sprintf("OP_DUP OP_HASH160 %c%s OP_EQUALVERIFY OP_CHECKSIG", PubKeyHash, base58.Encode(pubKeyHash))},
-		{"generation of P2PKH with empty public key", args{scriptType: P2PKH, addressP2PKH: []byte{}}, Undefined.String()},
-		{"generation of P2PKH with short P2PKH address (trim 1 character)", args{scriptType: P2PKH, addressP2PKH: addressP2PKH[:24]}, Undefined.String()},
+		{"regular script generation for P2PKH", args{scriptType: P2PKH, addressP2PKH: addressP2PKH}, serialized(operator(OpDup), operator(OpHash160), literal(PubKeyHash, pubKeyHash), operator(OpEqualVerify), operator(OpChecksig))},
+		{"generation of P2PKH with empty public key", args{scriptType: P2PKH, addressP2PKH: []byte{}}, UndefinedScript},
+		{"generation of P2PKH with short P2PKH address (trim 1 character)", args{scriptType: P2PKH, addressP2PKH: addressP2PKH[:24]}, UndefinedScript},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -64,6 +67,186 @@ func TestNewScript_P2PKH(t *testing.T) {
 	}
 }
 
+func TestNewScript_P2SH(t *testing.T) {
+	redeemScript := NewRedeemScript([]byte("public-key"))
+	addressP2SH, err := util_p2sh.GenerateP2SHAddrFromScript([]byte(redeemScript), 1)
+	require.NoError(t, err)
+
+	scriptHash, err := util_p2sh.HashScript([]byte(redeemScript))
+	require.NoError(t, err)
+
+	scriptPubKey := NewScript(P2SH, addressP2SH)
+	assert.Equal(t, fmt.Sprintf("OP_HASH160 %x OP_EQUAL", scriptHash), Disassemble(scriptPubKey))
+	assert.Equal(t, UndefinedScript, NewScript(P2SH, addressP2SH[:24]))
+
+	// the script type is recognized and only the owner of the redeem script can unlock it
+	tokens, _, err := StringToScript(scriptPubKey)
+	require.NoError(t, err)
+	assert.Equal(t, P2SH, DetermineScriptType(tokens))
+	assert.Equal(t, P2SH, ReturnScriptTypeFromStringType("P2SH"))
+	assert.True(t, CanBeUnlockedWith(scriptPubKey, []byte("public-key"), 1))
+	assert.False(t, CanBeUnlockedWith(scriptPubKey, []byte("public-key-2"), 1))
+}
+
+func TestNewMultiSigScript(t *testing.T) {
+	pubKeys := [][]byte{[]byte("pubkey-1"), []byte("pubkey-2"), []byte("pubkey-3")}
+
+	scriptPubKey := NewMultiSigScript(2, pubKeys)
+	assert.Equal(t, fmt.Sprintf("%x %x %x %x %x OP_CHECKMULTISIG",
+		[]byte("2"),
+		pubKeys[0],
+		pubKeys[1],
+		pubKeys[2],
+		[]byte("3"),
+	), Disassemble(scriptPubKey))
+
+	tokens, literals, err := StringToScript(scriptPubKey)
+	require.NoError(t, err)
+	assert.Equal(t, MultiSig, DetermineScriptType(tokens))
+
+	m, keys, err := ExtractMultiSigParams(tokens, literals)
+	require.NoError(t, err)
+	assert.Equal(t, uint(2), m)
+	assert.Equal(t, pubKeys, keys)
+
+	// only 1-of-n scripts can be unlocked with a single key
+	assert.False(t, CanBeUnlockedWith(scriptPubKey, pubKeys[0], 1))
+	assert.True(t, CanBeUnlockedWith(NewMultiSigScript(1, pubKeys), pubKeys[0], 1))
+	assert.False(t, CanBeUnlockedWith(NewMultiSigScript(1, pubKeys), []byte("pubkey-4"), 1))
+
+	// invalid number of signatures or keys
+	assert.Equal(t, UndefinedScript, NewMultiSigScript(0, pubKeys))
+	assert.Equal(t, UndefinedScript, NewMultiSigScript(4, pubKeys))
+	assert.Equal(t, UndefinedScript, NewMultiSigScript(1, [][]byte{}))
+	assert.Equal(t, UndefinedScript, NewMultiSigScript(1, make([][]byte, MaxMultiSigPubKeys+1)))
+
+	// the number of keys declared must match the keys contained
+	tokens, literals, err = StringToScript(serialized(literal(Number, []byte("1")), literal(PubKey, pubKeys[0]), literal(Number, []byte("2")), operator(OpCheckMultiSig)))
+	require.NoError(t, err)
+	assert.Equal(t, MultiSig, DetermineScriptType(tokens))
+	_, _, err = ExtractMultiSigParams(tokens, literals)
+	require.Error(t, err)
+}
+
+func TestNewTimeLockScript(t *testing.T) {
+	pubKey := []byte("pubkey-1")
+
+	scriptPubKey := NewTimeLockScript(100, pubKey)
+	assert.Equal(t, fmt.Sprintf("%x OP_CHECKLOCKTIMEVERIFY OP_DROP %x OP_CHECKSIG",
+		[]byte("100"),
+		pubKey,
+	), Disassemble(scriptPubKey))
+
+	tokens, literals, err := StringToScript(scriptPubKey)
+	require.NoError(t, err)
+	assert.Equal(t, TimeLock, DetermineScriptType(tokens))
+
+	lockTime, key, err := ExtractTimeLockParams(tokens, literals)
+	require.NoError(t, err)
+	assert.Equal(t, uint(100), lockTime)
+	assert.Equal(t, pubKey, key)
+
+	assert.True(t, CanBeUnlockedWith(scriptPubKey, pubKey, 1))
+	assert.False(t, CanBeUnlockedWith(scriptPubKey, []byte("pubkey-2"), 1))
+
+	// scripts without lock time or public key are not valid
+	assert.Equal(t, UndefinedScript, NewTimeLockScript(0, pubKey))
+	assert.Equal(t, UndefinedScript, NewTimeLockScript(100, []byte{}))
+
+	_, _, err = ExtractTimeLockParams(Script{PubKey, OpChecksig}, []string{string(pubKey), ""})
+	require.Error(t, err)
+}
+
+func TestNewRelativeTimeLockScript(t *testing.T) {
+	pubKey := []byte("pubkey-1")
+
+	scriptPubKey := NewRelativeTimeLockScript(10, pubKey)
+	assert.Equal(t, fmt.Sprintf("%x OP_CHECKSEQUENCEVERIFY OP_DROP %x OP_CHECKSIG",
+		[]byte("10"),
+		pubKey,
+	), Disassemble(scriptPubKey))
+
+	tokens, literals, err := StringToScript(scriptPubKey)
+	require.NoError(t, err)
+	assert.Equal(t, RelativeTimeLock, DetermineScriptType(tokens))
+
+	sequence, key, err := ExtractTimeLockParams(tokens, literals)
+	require.NoError(t, err)
+	assert.Equal(t, uint(10), sequence)
+	assert.Equal(t, pubKey, key)
+
+	assert.True(t, CanBeUnlockedWith(scriptPubKey, pubKey, 1))
+	assert.False(t, CanBeUnlockedWith(scriptPubKey, []byte("pubkey-2"), 1))
+	assert.Equal(t, UndefinedScript, NewRelativeTimeLockScript(0, pubKey))
+}
+
+func TestNewHTLCScript(t *testing.T) {
+	params := HTLCParams{
+		HashLock:       []byte("hash-lock"),
+		ReceiverPubKey: []byte("pubkey-receiver"),
+		LockTime:       100,
+		SenderPubKey:   []byte("pubkey-sender"),
+	}
+
+	scriptPubKey := NewHTLCScript(params)
+	assert.Equal(t, fmt.Sprintf("OP_IF OP_SHA256 %x OP_EQUALVERIFY %x OP_ELSE %x OP_CHECKLOCKTIMEVERIFY OP_DROP %x OP_ENDIF OP_CHECKSIG",
+		params.HashLock,
+		params.ReceiverPubKey,
+		[]byte("100"),
+		params.SenderPubKey,
+	), Disassemble(scriptPubKey))
+
+	tokens, literals, err := StringToScript(scriptPubKey)
+	require.NoError(t, err)
+	assert.Equal(t, HTLC, DetermineScriptType(tokens))
+
+	extracted, err := ExtractHTLCParams(tokens, literals)
+	require.NoError(t, err)
+	assert.Equal(t, params, extracted)
+
+	// both parties can unlock the output
+	assert.True(t, CanBeUnlockedWith(scriptPubKey, params.ReceiverPubKey, 1))
+	assert.True(t, CanBeUnlockedWith(scriptPubKey, params.SenderPubKey, 1))
+	assert.False(t, CanBeUnlockedWith(scriptPubKey, []byte("pubkey-other"), 1))
+
+	// all arguments are required
+	assert.Equal(t, UndefinedScript, NewHTLCScript(HTLCParams{ReceiverPubKey: params.ReceiverPubKey, LockTime: 100, SenderPubKey: params.SenderPubKey}))
+	assert.Equal(t, UndefinedScript, NewHTLCScript(HTLCParams{HashLock: params.HashLock, ReceiverPubKey: params.ReceiverPubKey, SenderPubKey: params.SenderPubKey}))
+
+	_, err = ExtractHTLCParams(Script{PubKey, OpChecksig}, []string{"pubkey", ""})
+	require.Error(t, err)
+}
+
+func TestNewNullDataScript(t *testing.T) {
+	data := []byte("document-digest")
+
+	scriptPubKey := NewNullDataScript(data)
+	assert.Equal(t, fmt.Sprintf("OP_RETURN %x", data), Disassemble(scriptPubKey))
+	assert.True(t, IsUnspendable(scriptPubKey))
+
+	tokens, literals, err := StringToScript(scriptPubKey)
+	require.NoError(t, err)
+	assert.Equal(t, NullData, DetermineScriptType(tokens))
+
+	extracted, err := ExtractNullData(tokens, literals)
+	require.NoError(t, err)
+	assert.Equal(t, data, extracted)
+
+	// nobody can unlock the output
+	assert.False(t, CanBeUnlockedWith(scriptPubKey, data, 1))
+
+	// empty data is not allowed and regular scripts are spendable
+	assert.Equal(t, UndefinedScript, NewNullDataScript([]byte{}))
+	assert.False(t, IsUnspendable(NewScript(P2PK, []byte("pubkey"))))
+
+	// outputs created with the legacy format are unspendable too
+	assert.True(t, IsUnspendable("OP_RETURN "+legacyLiteral(Data, data)))
+	assert.False(t, IsUnspendable(legacyLiteral(PubKey, []byte("pubkey"))+" OP_CHECKSIG"))
+
+	_, err = ExtractNullData(Script{PubKey, OpChecksig}, []string{"pubkey", ""})
+	require.Error(t, err)
+}
+
 func TestCanBeUnlockedWithForP2PK(t *testing.T) {
 	type args struct {
 		scriptPubKey string
@@ -126,7 +309,15 @@ func TestStringToScript(t *testing.T) {
 		want1   []string
 		wantErr bool
 	}{
-		{"", args{NewScript(P2PK, []byte("pubkey1"))}, Script([]ScriptElement{PubKey, OpChecksig}), []string{"pubkey1", ""}, false},
+		{"regular P2PK script", args{NewScript(P2PK, []byte("pubkey1"))}, Script([]ScriptElement{PubKey, OpChecksig}), []string{"pubkey1", ""}, false},
+		{"empty script", args{""}, Script([]ScriptElement{}), []string{}, false},
+		{"undefined script", args{UndefinedScript}, nil, nil, true},
+		{"unknown opcode", args{serialized(string([]byte{0xfe}))}, nil, nil, true},
+		{"literal without length", args{serialized(string([]byte{byte(PubKey)}))}, nil, nil, true},
+		{"literal longer than the script", args{serialized(string([]byte{byte(PubKey), 10, 'a'}))}, nil, nil, true},
+		{"legacy P2PK script", args{legacyLiteral(PubKey, []byte("pubkey1")) + " OP_CHECKSIG"}, Script([]ScriptElement{PubKey, OpChecksig}), []string{"pubkey1", ""}, false},
+		{"legacy P2PKH script", args{"OP_DUP OP_HASH160 " + legacyLiteral(PubKeyHash, []byte("hash")) + " OP_EQUALVERIFY OP_CHECKSIG"}, Script([]ScriptElement{OpDup, OpHash160, PubKeyHash, OpEqualVerify, OpChecksig}), []string{"", "", "hash", "", ""}, false},
+		{"legacy text script", args{"random script"}, Script([]ScriptElement{Undefined, Undefined}), []string{"", ""}, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -145,28 +336,32 @@ func TestStringToScript(t *testing.T) {
 	}
 }
 
-func Test_tryExtractTokenLiteral(t *testing.T) {
-	type args struct {
-		data string
-	}
-	tests := []struct {
-		name  string
-		args  args
-		want  ScriptElement
-		want1 string
-	}{
-		{"extract regular literal", args{data: fmt.Sprintf("%c%s", PubKey, "pubkey1")}, PubKey, "pubkey1"},
-		{"extract literal with less than 2 elements", args{data: fmt.Sprintf("%d", PubKey)}, Undefined, ""},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, got1 := tryExtractTokenLiteral(tt.args.data)
-			if got != tt.want {
-				t.Errorf("tryExtractTokenLiteral() got = %v, want %v", got, tt.want)
-			}
-			if got1 != tt.want1 {
-				t.Errorf("tryExtractTokenLiteral() got1 = %v, want %v", got1, tt.want1)
-			}
-		})
-	}
+func TestDisassemble(t *testing.T) {
+	assert.Equal(t, fmt.Sprintf("%x OP_CHECKSIG", "pubkey1"), Disassemble(NewScript(P2PK, []byte("pubkey1"))))
+	assert.Equal(t, "UNDEFINED", Disassemble(UndefinedScript))
+	assert.Equal(t, "[error]", Disassemble(serialized(string([]byte{byte(PubKey), 10, 'a'}))))
+	assert.Empty(t, Disassemble(""))
+
+	// scripts serialized in the legacy format are disassembled too
+	assert.Equal(t, fmt.Sprintf("%x OP_CHECKSIG", "pubkey1"), Disassemble(legacyLiteral(PubKey, []byte("pubkey1"))+" OP_CHECKSIG"))
+}
+
+// serialized returns the script formed by the serialized elements provided
+func serialized(elements ...string) string {
+	return util_script.BinaryFormatMarker + strings.Join(elements, "")
+}
+
+// legacyLiteral returns the literal provided serialized in the legacy format
+func legacyLiteral(element ScriptElement, data []byte) string {
+	return string(rune(element)) + base58.Encode(data)
+}
+
+// literal returns the serialized literal provided, the length of the data must fit in one byte
+func literal(element ScriptElement, data []byte) string {
+	return string(append([]byte{byte(element), byte(len(data))}, data...))
+}
+
+// operator returns the serialized operator provided
+func operator(op ScriptElement) string {
+	return string([]byte{byte(op)})
 }
@@ -1,13 +1,16 @@
 package storage
 
 import (
+	"bytes"
 	"fmt"
+	"math/big"
 	"time"
 
 	cerror "github.com/yago-123/chainnet/pkg/errs"
 
 	"github.com/yago-123/chainnet/pkg/encoding"
 	"github.com/yago-123/chainnet/pkg/kernel"
+	"github.com/yago-123/chainnet/pkg/util"
 
 	boltdb "github.com/boltdb/bolt"
 )
@@ -15,12 +18,16 @@ import (
 const (
 	BoltDBCreationMode = 0600
 	BoltDBTimeout      = 5 * time.Second
+
+	// ChainWorkBucketSuffix is appended to the header bucket name for storing the cumulative work of each header
+	ChainWorkBucketSuffix = "-chainwork"
 )
 
 type BoltDB struct {
-	db           *boltdb.DB
-	blockBucket  string
-	headerBucket string
+	db              *boltdb.DB
+	blockBucket     string
+	headerBucket    string
+	chainWorkBucket string
 
 	encoding encoding.Encoding
 }
@@ -32,10 +39,11 @@ func NewBoltDB(dbFile, blockBucket, headerBucket string, encoding encoding.Encod
 	}
 
 	return &BoltDB{
-		db:           db,
-		blockBucket:  blockBucket,
-		headerBucket: headerBucket,
-		encoding:     encoding,
+		db:              db,
+		blockBucket:     blockBucket,
+		headerBucket:    headerBucket,
+		chainWorkBucket: headerBucket + ChainWorkBucketSuffix,
+		encoding:        encoding,
 	}, nil
 }
 
@@ -125,11 +133,130 @@ func (bolt *BoltDB) PersistHeader(blockHash []byte, blockHeader kernel.BlockHead
 			return fmt.Errorf("error writing last block hash %s: %w", string(blockHash), err)
 		}
 
-		return nil
+		return bolt.persistChainWork(tx, blockHash, blockHeader)
 	})
 	return err
 }
 
+// persistChainWork stores the cumulative work of the header and updates LastChainWorkKey. If the work of the previous
+// header is not known (headers persisted before the work was tracked) only the work of the header itself is counted,
+// the chain takes care of persisting again the headers in that case
+func (bolt *BoltDB) persistChainWork(tx *boltdb.Tx, blockHash []byte, blockHeader kernel.BlockHeader) error {
+	bucket, err := tx.CreateBucketIfNotExists([]byte(bolt.chainWorkBucket))
+	if err != nil {
+		return fmt.Errorf("error creating chain work bucket: %w", err)
+	}
+
+	work := util.CalculateBlockWork(&blockHeader)
+	if prevWork := bucket.Get(blockHeader.PrevBlockHash); !blockHeader.IsGenesisHeader() && len(prevWork) > 0 {
+		work.Add(work, new(big.Int).SetBytes(prevWork))
+	}
+
+	if err = bucket.Put(blockHash, work.Bytes()); err != nil {
+		return fmt.Errorf("error writing chain work %s: %w", string(blockHash), err)
+	}
+
+	if err = bucket.Put([]byte(LastChainWorkKey), work.Bytes()); err != nil {
+		return fmt.Errorf("error writing last chain work %s: %w", string(blockHash), err)
+	}
+
+	return nil
+}
+
+func (bolt *BoltDB) PersistChainWork(blockHash []byte, work *big.Int) error {
+	return bolt.db.Update(func(tx *boltdb.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(bolt.chainWorkBucket))
+		if err != nil {
+			return fmt.Errorf("error creating chain work bucket: %w", err)
+		}
+
+		if err = bucket.Put(blockHash, work.Bytes()); err != nil {
+			return fmt.Errorf("error writing chain work %s: %w", string(blockHash), err)
+		}
+
+		// the last chain work must keep matching the last header, which is not modified here
+		exists, headerBucket := bucketExists(bolt.headerBucket, tx)
+		if !exists || !bytes.Equal(headerBucket.Get([]byte(LastBlockHashKey)), blockHash) {
+			return nil
+		}
+
+		if err = bucket.Put([]byte(LastChainWorkKey), work.Bytes()); err != nil {
+			return fmt.Errorf("error writing last chain work %s: %w", string(blockHash), err)
+		}
+
+		return nil
+	})
+}
+
+func (bolt *BoltDB) RemoveLastBlock(block kernel.Block) error {
+	prevBlock, err := bolt.RetrieveBlockByHash(block.Header.PrevBlockHash)
+	if err != nil {
+		return fmt.Errorf("error retrieving previous block %s: %w", string(block.Header.PrevBlockHash), err)
+	}
+
+	dataBlock, err := bolt.encoding.SerializeBlock(*prevBlock)
+	if err != nil {
+		return fmt.Errorf("error serializing block %s: %w", string(block.Header.PrevBlockHash), err)
+	}
+
+	dataHeader, err := bolt.encoding.SerializeHeader(*prevBlock.Header)
+	if err != nil {
+		return fmt.Errorf("error serializing block header: %w", err)
+	}
+
+	return bolt.db.Update(func(tx *boltdb.Tx) error {
+		existsBlocks, blockBucket := bucketExists(bolt.blockBucket, tx)
+		existsHeaders, headerBucket := bucketExists(bolt.headerBucket, tx)
+		if !existsBlocks || !existsHeaders {
+			return cerror.ErrStorageElementNotFound
+		}
+
+		// only the tip can be removed, otherwise the chain stored would contain gaps
+		if !bytes.Equal(headerBucket.Get([]byte(LastBlockHashKey)), block.Hash) {
+			return fmt.Errorf("block %s is not the last block", string(block.Hash))
+		}
+
+		// delete the block so it can't be retrieved (nor its transactions) as part of the chain anymore
+		if err = blockBucket.Delete(block.Hash); err != nil {
+			return fmt.Errorf("error deleting block %s: %w", string(block.Hash), err)
+		}
+
+		if err = headerBucket.Delete(block.Hash); err != nil {
+			return fmt.Errorf("error deleting header %s: %w", string(block.Hash), err)
+		}
+
+		// move the keys pointing to the last block back to the previous block
+		if err = blockBucket.Put([]byte(LastBlockKey), dataBlock); err != nil {
+			return fmt.Errorf("error writing last block %s: %w", string(block.Header.PrevBlockHash), err)
+		}
+
+		if err = headerBucket.Put([]byte(LastHeaderKey), dataHeader); err != nil {
+			return fmt.Errorf("error writing last header %s: %w", string(block.Header.PrevBlockHash), err)
+		}
+
+		if err = headerBucket.Put([]byte(LastBlockHashKey), block.Header.PrevBlockHash); err != nil {
+			return fmt.Errorf("error writing last block hash %s: %w", string(block.Header.PrevBlockHash), err)
+		}
+
+		exists, workBucket := bucketExists(bolt.chainWorkBucket, tx)
+		if !exists {
+			return nil
+		}
+
+		if err = workBucket.Delete(block.Hash); err != nil {
+			return fmt.Errorf("error deleting chain work %s: %w", string(block.Hash), err)
+		}
+
+		if prevWork := workBucket.Get(block.Header.PrevBlockHash); len(prevWork) > 0 {
+			if err = workBucket.Put([]byte(LastChainWorkKey), prevWork); err != nil {
+				return fmt.Errorf("error writing last chain work %s: %w", string(block.Header.PrevBlockHash), err)
+			}
+		}
+
+		return nil
+	})
+}
+
 func (bolt *BoltDB) GetLastBlock() (*kernel.Block, error) {
 	var err error
 	var lastBlock []byte
@@ -310,6 +437,38 @@ func (bolt *BoltDB) RetrieveHeaderByHash(hash []byte) (*kernel.BlockHeader, erro
 	return bolt.encoding.DeserializeHeader(headerBytes)
 }
 
+func (bolt *BoltDB) RetrieveChainWorkByHash(hash []byte) (*big.Int, error) {
+	return bolt.retrieveChainWork(hash)
+}
+
+func (bolt *BoltDB) GetLastChainWork() (*big.Int, error) {
+	return bolt.retrieveChainWork([]byte(LastChainWorkKey))
+}
+
+func (bolt *BoltDB) retrieveChainWork(key []byte) (*big.Int, error) {
+	var workBytes []byte
+
+	err := bolt.db.View(func(tx *boltdb.Tx) error {
+		exists, bucket := bucketExists(bolt.chainWorkBucket, tx)
+		if !exists {
+			return cerror.ErrStorageElementNotFound
+		}
+
+		workBytes = bucket.Get(key)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(workBytes) == 0 {
+		return nil, cerror.ErrStorageElementNotFound
+	}
+	return new(big.Int).SetBytes(workBytes), nil
+}
+
 func (bolt *BoltDB) Typ() string {
 	return "BoltDB"
 }
@@ -330,6 +489,12 @@ func (bolt *BoltDB) OnBlockAddition(block *kernel.Block) {
 	// }()
 }
 
+// OnBlockRemoval is called when a block is disconnected from the chain. The chain already removed the block via
+// RemoveLastBlock so it can abort the reorganization if that fails, nothing else to do here
+func (bolt *BoltDB) OnBlockRemoval(_ *kernel.Block) {
+	// do nothing
+}
+
 // OnTxAddition is called when a new tx is added to the mempool via the observer pattern
 func (bolt *BoltDB) OnTxAddition(_ *kernel.Transaction) {
 	// do nothing
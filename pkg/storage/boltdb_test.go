@@ -1,12 +1,14 @@
 package storage //nolint:testpackage // don't create separate package for tests
 
 import (
+	"math/big"
 	"os"
 	"testing"
 
 	cerror "github.com/yago-123/chainnet/pkg/errs"
 
 	"github.com/yago-123/chainnet/pkg/encoding"
+	"github.com/yago-123/chainnet/pkg/kernel"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -34,4 +36,111 @@ func TestBoltDB_NotFound(t *testing.T) {
 
 	_, err = bolt.RetrieveHeaderByHash([]byte(""))
 	assert.Equal(t, cerror.ErrStorageElementNotFound, err)
+
+	_, err = bolt.RetrieveChainWorkByHash([]byte(""))
+	assert.Equal(t, cerror.ErrStorageElementNotFound, err)
+
+	_, err = bolt.GetLastChainWork()
+	assert.Equal(t, cerror.ErrStorageElementNotFound, err)
+}
+
+func TestBoltDB_ChainWork(t *testing.T) {
+	defer os.Remove(MockStorageFile)
+
+	bolt, err := NewBoltDB(MockStorageFile, "block-bucket", "header-bucket", encoding.NewGobEncoder())
+	require.NoError(t, err)
+	defer bolt.Close()
+
+	genesis := kernel.BlockHeader{Version: []byte(kernel.BlockVersionLeadingZerosTarget), Height: 0, Target: 2}
+	header1 := kernel.BlockHeader{Version: []byte(kernel.BlockVersionLeadingZerosTarget), PrevBlockHash: []byte("genesis"), Height: 1, Target: 3}
+
+	// the work of each header is added to the work of the previous one
+	require.NoError(t, bolt.PersistHeader([]byte("genesis"), genesis))
+	require.NoError(t, bolt.PersistHeader([]byte("header-1"), header1))
+
+	work, err := bolt.RetrieveChainWorkByHash([]byte("genesis"))
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(4), work)
+
+	work, err = bolt.RetrieveChainWorkByHash([]byte("header-1"))
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(12), work)
+
+	work, err = bolt.GetLastChainWork()
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(12), work)
+
+	// backfilling the work of a previous header does not move the last header keys
+	require.NoError(t, bolt.PersistChainWork([]byte("genesis"), big.NewInt(5)))
+	work, err = bolt.RetrieveChainWorkByHash([]byte("genesis"))
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(5), work)
+	work, err = bolt.GetLastChainWork()
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(12), work)
+	lastHash, err := bolt.GetLastBlockHash()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("header-1"), lastHash)
+
+	// unless the header is the last one
+	require.NoError(t, bolt.PersistChainWork([]byte("header-1"), big.NewInt(13)))
+	work, err = bolt.GetLastChainWork()
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(13), work)
+
+	// persisting again the previous header moves back the last chain work
+	require.NoError(t, bolt.PersistHeader([]byte("genesis"), genesis))
+	work, err = bolt.GetLastChainWork()
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(4), work)
+}
+
+func TestBoltDB_RemoveLastBlock(t *testing.T) {
+	defer os.Remove(MockStorageFile)
+
+	bolt, err := NewBoltDB(MockStorageFile, "block-bucket", "header-bucket", encoding.NewGobEncoder())
+	require.NoError(t, err)
+	defer bolt.Close()
+
+	genesis := kernel.NewBlock(
+		&kernel.BlockHeader{Version: []byte(kernel.BlockVersionLeadingZerosTarget), Height: 0, Target: 2},
+		[]*kernel.Transaction{}, []byte("genesis"),
+	)
+	block1 := kernel.NewBlock(
+		&kernel.BlockHeader{Version: []byte(kernel.BlockVersionLeadingZerosTarget), PrevBlockHash: []byte("genesis"), Height: 1, Target: 3},
+		[]*kernel.Transaction{}, []byte("block-1"),
+	)
+
+	for _, block := range []*kernel.Block{genesis, block1} {
+		require.NoError(t, bolt.PersistHeader(block.Hash, *block.Header))
+		require.NoError(t, bolt.PersistBlock(*block))
+	}
+
+	// only the last block can be removed
+	require.Error(t, bolt.RemoveLastBlock(*genesis))
+	require.NoError(t, bolt.RemoveLastBlock(*block1))
+
+	// the removed block is not stored anymore and the last keys point to the previous block again
+	_, err = bolt.RetrieveBlockByHash([]byte("block-1"))
+	assert.Equal(t, cerror.ErrStorageElementNotFound, err)
+	_, err = bolt.RetrieveHeaderByHash([]byte("block-1"))
+	assert.Equal(t, cerror.ErrStorageElementNotFound, err)
+	_, err = bolt.RetrieveChainWorkByHash([]byte("block-1"))
+	assert.Equal(t, cerror.ErrStorageElementNotFound, err)
+
+	lastBlock, err := bolt.GetLastBlock()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("genesis"), lastBlock.Hash)
+
+	lastHeader, err := bolt.GetLastHeader()
+	require.NoError(t, err)
+	assert.Equal(t, uint(0), lastHeader.Height)
+
+	lastHash, err := bolt.GetLastBlockHash()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("genesis"), lastHash)
+
+	work, err := bolt.GetLastChainWork()
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(4), work)
 }
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"math/big"
 	"sync/atomic"
 	"time"
 
@@ -19,6 +20,7 @@ type metrics struct {
 	retrievedGenesisHeader uint64
 	retrievedBlockByHash   uint64
 	retrievedHeaderByHash  uint64
+	retrievedChainWork     uint64
 	onBlockAddition        uint64
 
 	persistedBlocksTime        int64
@@ -30,6 +32,7 @@ type metrics struct {
 	retrievedGenesisHeaderTime int64
 	retrievedBlockByHashTime   int64
 	retrievedHeaderByHashTime  int64
+	retrievedChainWorkTime     int64
 	onBlockAdditionTime        int64
 }
 
@@ -59,6 +62,17 @@ func (ms *MeteredStorage) PersistHeader(blockHash []byte, blockHeader kernel.Blo
 	return ms.inner.PersistHeader(blockHash, blockHeader)
 }
 
+func (ms *MeteredStorage) PersistChainWork(blockHash []byte, work *big.Int) error {
+	startTime := time.Now()
+	defer recordTimeAsync(&ms.persistedHeaders, &ms.persistedHeadersTime, startTime)
+
+	return ms.inner.PersistChainWork(blockHash, work)
+}
+
+func (ms *MeteredStorage) RemoveLastBlock(block kernel.Block) error {
+	return ms.inner.RemoveLastBlock(block)
+}
+
 func (ms *MeteredStorage) GetLastBlock() (*kernel.Block, error) {
 	startTime := time.Now()
 	defer recordTimeAsync(&ms.retrievedLastBlock, &ms.retrievedLastBlockTime, startTime)
@@ -108,6 +122,20 @@ func (ms *MeteredStorage) RetrieveHeaderByHash(hash []byte) (*kernel.BlockHeader
 	return ms.inner.RetrieveHeaderByHash(hash)
 }
 
+func (ms *MeteredStorage) RetrieveChainWorkByHash(hash []byte) (*big.Int, error) {
+	startTime := time.Now()
+	defer recordTimeAsync(&ms.retrievedChainWork, &ms.retrievedChainWorkTime, startTime)
+
+	return ms.inner.RetrieveChainWorkByHash(hash)
+}
+
+func (ms *MeteredStorage) GetLastChainWork() (*big.Int, error) {
+	startTime := time.Now()
+	defer recordTimeAsync(&ms.retrievedChainWork, &ms.retrievedChainWorkTime, startTime)
+
+	return ms.inner.GetLastChainWork()
+}
+
 func (ms *MeteredStorage) Typ() string {
 	return ms.inner.Typ()
 }
@@ -123,6 +151,10 @@ func (ms *MeteredStorage) OnBlockAddition(block *kernel.Block) {
 	ms.inner.OnBlockAddition(block)
 }
 
+func (ms *MeteredStorage) OnBlockRemoval(block *kernel.Block) {
+	ms.inner.OnBlockRemoval(block)
+}
+
 func (ms *MeteredStorage) OnTxAddition(tx *kernel.Transaction) {
 	ms.inner.OnTxAddition(tx)
 }
@@ -169,6 +201,10 @@ func (ms *MeteredStorage) RegisterMetrics(register *prometheus.Registry) {
 		return float64(atomic.LoadUint64(&ms.retrievedHeaderByHash))
 	})
 
+	monitor.NewMetric(register, monitor.Counter, "storage_num_retrieved_chain_work", "Number of retrieved chain work", func() float64 {
+		return float64(atomic.LoadUint64(&ms.retrievedChainWork))
+	})
+
 	monitor.NewMetric(register, monitor.Counter, "storage_num_on_block_addition", "Number of on block addition", func() float64 {
 		return float64(atomic.LoadUint64(&ms.onBlockAddition))
 	})
@@ -209,6 +245,10 @@ func (ms *MeteredStorage) RegisterMetrics(register *prometheus.Registry) {
 		return float64(atomic.LoadInt64(&ms.retrievedHeaderByHashTime))
 	})
 
+	monitor.NewMetric(register, monitor.Counter, "storage_retrieved_chain_work_time", "Nanoseconds taken to retrieve chain work", func() float64 {
+		return float64(atomic.LoadInt64(&ms.retrievedChainWorkTime))
+	})
+
 	monitor.NewMetric(register, monitor.Counter, "storage_on_block_addition_time", "Nanoseconds taken to on block addition", func() float64 {
 		return float64(atomic.LoadInt64(&ms.onBlockAdditionTime))
 	})
@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"math/big"
+
 	"github.com/yago-123/chainnet/pkg/kernel"
 )
 
@@ -11,6 +13,8 @@ const (
 	LastHeaderKey  = "lastheader"
 	// LastBlockHashKey is updated when persisting a new block header
 	LastBlockHashKey = "lastblockhash"
+	// LastChainWorkKey is updated when persisting a new block header, contains the cumulative work of the last header
+	LastChainWorkKey = "lastchainwork"
 
 	StorageObserverID = "storage-observer"
 )
@@ -21,8 +25,16 @@ type Storage interface {
 	// PersistHeader stores a new header and updates LastHeaderKey and LastBlockHashKey. The latter key
 	// is updated in this function because as soon as the header is written the block has been commited
 	// to the chain, even if the block itself has not been persisted yet. Refer to GetLastBlockHash
-	// function for additional information
+	// function for additional information. The cumulative work of the header (work of the previous header plus the
+	// work of this header) is persisted too, together with LastChainWorkKey
 	PersistHeader(blockHash []byte, blockHeader kernel.BlockHeader) error
+	// PersistChainWork stores the cumulative work of an already persisted header without moving the last header
+	// keys. LastChainWorkKey is only updated if the header is the last one
+	PersistChainWork(blockHash []byte, work *big.Int) error
+	// RemoveLastBlock deletes the block, header and cumulative work of the last block and moves LastBlockKey,
+	// LastHeaderKey, LastBlockHashKey and LastChainWorkKey back to the previous block. Used when the tip is
+	// disconnected from the chain during a reorganization
+	RemoveLastBlock(block kernel.Block) error
 	// GetLastBlock retrieves the block information contained in LastBlockKey
 	GetLastBlock() (*kernel.Block, error)
 	// GetLastHeader retrieves the header of the last block. The last header represents the latest block
@@ -43,12 +55,19 @@ type Storage interface {
 	RetrieveBlockByHash(hash []byte) (*kernel.Block, error)
 	// RetrieveHeaderByHash retrieves the block header that corresponds to the block hash
 	RetrieveHeaderByHash(hash []byte) (*kernel.BlockHeader, error)
+	// RetrieveChainWorkByHash retrieves the cumulative work of the chain ending with the block hash
+	RetrieveChainWorkByHash(hash []byte) (*big.Int, error)
+	// GetLastChainWork retrieves the cumulative work of the chain ending with the last header
+	GetLastChainWork() (*big.Int, error)
 	// Typ returns the type of storage used
 	Typ() string
 	// ID returns the key StorageObserverID used for running Observer code
 	ID() string
 	// OnBlockAddition called when a new block is added to the chain, in the case of storage must be async
 	OnBlockAddition(block *kernel.Block)
+	// OnBlockRemoval called when a block is disconnected from the chain during a reorganization, the block has
+	// already been removed via RemoveLastBlock
+	OnBlockRemoval(block *kernel.Block)
 	// OnTxAddition called when a new tx is added to the mempool, in the case of storage must be async
 	OnTxAddition(block *kernel.Transaction)
 	// Close finishes the connection with the DB
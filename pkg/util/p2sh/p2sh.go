@@ -0,0 +1,87 @@
+package utilp2sh
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	"golang.org/x/crypto/ripemd160"
+
+	"github.com/yago-123/chainnet/pkg/crypto"
+	"github.com/yago-123/chainnet/pkg/crypto/hash"
+)
+
+const (
+	P2SHAddressLength    = 1 + 20 + 4 // version + scriptHash + checksum
+	P2SHScriptHashLength = 20
+)
+
+// HashScript returns the hash of the redeem script (SHA-256 + RIPEMD-160), this is the value contained in the
+// scriptPubKey of P2SH outputs
+func HashScript(redeemScript []byte) ([]byte, error) {
+	hasherP2SH := crypto.NewMultiHash(
+		[]hash.Hashing{hash.NewHasher(sha256.New()), hash.NewHasher(ripemd160.New())},
+	)
+
+	scriptHash, err := hasherP2SH.Hash(redeemScript)
+	if err != nil {
+		return []byte{}, fmt.Errorf("could not hash the redeem script: %w", err)
+	}
+
+	return scriptHash, nil
+}
+
+// GenerateP2SHAddrFromScript generates a P2SH address from a redeem script (including a checksum for error detection).
+// Returns the P2SH address as raw bytes (version + script hash + checksum)
+func GenerateP2SHAddrFromScript(redeemScript []byte, version byte) ([]byte, error) {
+	hasherP2SH := crypto.NewMultiHash(
+		[]hash.Hashing{hash.NewHasher(sha256.New()), hash.NewHasher(ripemd160.New())},
+	)
+
+	// hash the redeem script
+	scriptHash, err := HashScript(redeemScript)
+	if err != nil {
+		return []byte{}, err
+	}
+
+	// add the version to the script hash in order to hash again and obtain the checksum
+	versionedPayload := append([]byte{version}, scriptHash...)
+	// todo() checksum must be a double SHA-256 hash, instead of SHA-256 + RIPEMD-160, but for now is OK
+	checksum, err := hasherP2SH.Hash(versionedPayload)
+	if err != nil {
+		return []byte{}, fmt.Errorf("could not hash the versioned payload: %w", err)
+	}
+
+	// add checksum to generate address
+	payload := append(versionedPayload, checksum[:4]...) //nolint:gocritic // we need to append the checksum to the payload
+
+	return payload, nil
+}
+
+// ExtractScriptHashFromP2SHAddr extracts the redeem script hash from a P2SH address
+func ExtractScriptHashFromP2SHAddr(address []byte) ([]byte, byte, error) {
+	hasherP2SH := crypto.NewMultiHash(
+		[]hash.Hashing{hash.NewHasher(sha256.New()), hash.NewHasher(ripemd160.New())},
+	)
+
+	if len(address) != P2SHAddressLength {
+		return nil, 0, fmt.Errorf("invalid P2SH address length: got %d, want %d", len(address), P2SHAddressLength)
+	}
+
+	version := address[0]
+	scriptHash := address[1 : len(address)-4]
+
+	// verify the checksum
+	checksum := address[len(address)-4:]
+	versionedPayload := append([]byte{version}, scriptHash...)
+	calculatedChecksum, err := hasherP2SH.Hash(versionedPayload)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not hash the versioned payload: %w", err)
+	}
+
+	if !bytes.Equal(checksum, calculatedChecksum[:4]) {
+		return nil, 0, fmt.Errorf("error validating checksum, expected %x, got %x", checksum, calculatedChecksum[:4])
+	}
+
+	return scriptHash, version, nil
+}
@@ -0,0 +1,34 @@
+package utilp2sh //nolint:testpackage // don't create separate package for tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateAndExtractP2SHAddr(t *testing.T) {
+	redeemScript := []byte("redeem-script")
+
+	address, err := GenerateP2SHAddrFromScript(redeemScript, 1)
+	require.NoError(t, err)
+	require.Len(t, address, P2SHAddressLength)
+
+	scriptHash, version, err := ExtractScriptHashFromP2SHAddr(address)
+	require.NoError(t, err)
+	assert.Equal(t, byte(1), version)
+	assert.Len(t, scriptHash, P2SHScriptHashLength)
+
+	expectedHash, err := HashScript(redeemScript)
+	require.NoError(t, err)
+	assert.Equal(t, expectedHash, scriptHash)
+
+	// wrong length
+	_, _, err = ExtractScriptHashFromP2SHAddr(address[:P2SHAddressLength-1])
+	require.Error(t, err)
+
+	// wrong checksum
+	address[len(address)-1]++
+	_, _, err = ExtractScriptHashFromP2SHAddr(address)
+	require.Error(t, err)
+}
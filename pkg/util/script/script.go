@@ -1,28 +1,75 @@
 package script
 
 import (
+	"encoding/binary"
+	"fmt"
 	"strings"
 
 	"github.com/btcsuite/btcutil/base58"
 )
 
 const (
-	// ScriptSigSeparator represents the value used to join script sig arguments into a single string
-	ScriptSigSeparator = " "
+	// BinaryFormatMarker prefixes the scripts and scriptSigs serialized in binary format. Older versions serialized
+	// them as text (base58 values separated by spaces), which can never start with this byte. The legacy format is
+	// still parsed so that the transactions created before the binary format remain valid
+	BinaryFormatMarker = "\xff"
+
+	// legacyScriptSigSeparator separates the arguments of scriptSigs serialized in the legacy format
+	legacyScriptSigSeparator = " "
 )
 
+// IsBinaryFormat checks whether the script or scriptSig provided is serialized in binary format
+func IsBinaryFormat(serialized string) bool {
+	return strings.HasPrefix(serialized, BinaryFormatMarker)
+}
+
+// EncodeScriptSig serializes the scriptSig arguments, each argument is preceded by its length (uvarint)
 func EncodeScriptSig(scriptSig [][]byte) string {
-	ret := []string{}
+	ret := []byte(BinaryFormatMarker)
 	for _, val := range scriptSig {
-		ret = append(ret, base58.Encode(val))
+		ret = binary.AppendUvarint(ret, uint64(len(val)))
+		ret = append(ret, val...)
+	}
+
+	return string(ret)
+}
+
+// DecodeScriptSig returns the arguments of a serialized scriptSig, malformed scriptSigs are rejected. ScriptSigs
+// serialized in the legacy format are decoded too
+func DecodeScriptSig(scriptSig string) ([][]byte, error) {
+	ret := [][]byte{}
+
+	if scriptSig == "" {
+		return ret, nil
+	}
+
+	if !IsBinaryFormat(scriptSig) {
+		return decodeLegacyScriptSig(scriptSig), nil
+	}
+
+	data := []byte(scriptSig[len(BinaryFormatMarker):])
+	for pos := 0; pos < len(data); {
+		length, read := binary.Uvarint(data[pos:])
+		if read <= 0 {
+			return nil, fmt.Errorf("invalid length of scriptSig argument in position %d", pos)
+		}
+		pos += read
+
+		if length > uint64(len(data)-pos) {
+			return nil, fmt.Errorf("scriptSig argument in position %d exceeds the scriptSig length", pos)
+		}
+
+		ret = append(ret, data[pos:pos+int(length)])
+		pos += int(length)
 	}
 
-	return strings.Join(ret, ScriptSigSeparator)
+	return ret, nil
 }
 
-func DecodeScriptSig(scriptSig string) [][]byte {
+// decodeLegacyScriptSig returns the arguments of a scriptSig serialized in the legacy format
+func decodeLegacyScriptSig(scriptSig string) [][]byte {
 	ret := [][]byte{}
-	for _, val := range strings.Split(scriptSig, ScriptSigSeparator) {
+	for _, val := range strings.Split(scriptSig, legacyScriptSigSeparator) {
 		ret = append(ret, base58.Decode(val))
 	}
 
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/big"
 	"sync"
 
+	"github.com/yago-123/chainnet/pkg/common"
 	"github.com/yago-123/chainnet/pkg/crypto/hash"
 	"github.com/yago-123/chainnet/pkg/kernel"
 )
@@ -20,6 +22,16 @@ const (
 	MinimumTarget        = uint(1)
 	MaximumTarget        = uint(255)
 
+	// HashBits is the number of bits of the hashes compared against compact targets
+	HashBits = 256
+	// MaxTargetAdjustmentFactor limits how much the compact target can change in a single retarget
+	MaxTargetAdjustmentFactor = 4
+
+	compactMantissaMask = 0x007fffff
+	compactSignBit      = 0x00800000
+	compactMantissaSize = 3
+	compactExponentBits = 24
+
 	MinLengthHash = 16
 	MaxLengthHash = 256
 )
@@ -108,14 +120,138 @@ func IsFirstNBitsZero(arr []byte, n uint) bool {
 	return true
 }
 
-// CalculateMiningTarget calculates the new mining target based on the time required for mining the blocks
-// vs. the time expected to mine the blocks:
+// PowLimit is the easiest target allowed, equivalent to a single leading zero bit (MinimumTarget)
+var PowLimit = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), HashBits-1), big.NewInt(1)) //nolint:gochecknoglobals // constant
+
+// InitialCompactTarget is the compact target used by the first blocks of the chain
+var InitialCompactTarget = BigToCompact(PowLimit) //nolint:gochecknoglobals // constant
+
+// CompactToBig decodes a target encoded in compact form. The most significant byte is the number of bytes of the
+// target and the 3 remaining bytes are the most significant bytes of the target. Negative targets are invalid and
+// decoded as 0, which can't be met by any hash
+func CompactToBig(compact uint32) *big.Int {
+	if compact&compactSignBit != 0 {
+		return big.NewInt(0)
+	}
+
+	size := uint(compact >> compactExponentBits)
+	mantissa := big.NewInt(int64(compact & compactMantissaMask))
+	if size <= compactMantissaSize {
+		return mantissa.Rsh(mantissa, NumBitsInByte*(compactMantissaSize-size))
+	}
+
+	return mantissa.Lsh(mantissa, NumBitsInByte*(size-compactMantissaSize))
+}
+
+// BigToCompact encodes a target in compact form, only the 3 most significant bytes of the target are kept
+func BigToCompact(target *big.Int) uint32 {
+	if target.Sign() <= 0 {
+		return 0
+	}
+
+	size := uint((target.BitLen() + NumBitsInByte - 1) / NumBitsInByte)
+	var mantissa uint64
+	if size <= compactMantissaSize {
+		mantissa = target.Uint64() << (NumBitsInByte * (compactMantissaSize - size))
+	} else {
+		mantissa = new(big.Int).Rsh(target, NumBitsInByte*(size-compactMantissaSize)).Uint64()
+	}
+
+	// the mantissa is signed, if the sign bit is set move one byte to the exponent
+	if mantissa&compactSignBit != 0 {
+		mantissa >>= NumBitsInByte
+		size++
+	}
+
+	return uint32(mantissa) | uint32(size)<<compactExponentBits //nolint:gosec // size is at most 33
+}
+
+// HeaderTarget returns the 256-bit target of the header regardless of the header version. Leading zero bits
+// targets are converted to the biggest number with that amount of leading zero bits
+func HeaderTarget(bh *kernel.BlockHeader) *big.Int {
+	if bh.HasCompactTarget() {
+		return CompactToBig(uint32(bh.Target)) //nolint:gosec // compact targets are 32 bits long
+	}
+
+	if bh.Target >= HashBits {
+		return big.NewInt(0)
+	}
+
+	target := new(big.Int).Lsh(big.NewInt(1), HashBits-bh.Target)
+	return target.Sub(target, big.NewInt(1))
+}
+
+// HashMeetsTarget checks if the hash, interpreted as a big endian number, is equal or smaller than the target
+func HashMeetsTarget(hash []byte, target *big.Int) bool {
+	return new(big.Int).SetBytes(hash).Cmp(target) <= 0
+}
+
+// IsProofOfWorkValid checks if the hash meets the target of the header, taking into account the header version
+func IsProofOfWorkValid(hash []byte, bh *kernel.BlockHeader) bool {
+	if bh.HasCompactTarget() {
+		return HashMeetsTarget(hash, HeaderTarget(bh))
+	}
+
+	return IsFirstNBitsZero(hash, bh.Target)
+}
+
+// CalculateBlockWork returns the expected number of hashes required for mining a block with the header provided,
+// calculated as 2^256 / (target + 1). For leading zero bits targets the work is 2^target
+func CalculateBlockWork(bh *kernel.BlockHeader) *big.Int {
+	target := HeaderTarget(bh)
+	if target.Sign() <= 0 {
+		return big.NewInt(0)
+	}
+
+	work := new(big.Int).Lsh(big.NewInt(1), HashBits)
+	return work.Div(work, target.Add(target, big.NewInt(1)))
+}
+
+// CalculateBlockSubsidy returns the reward that corresponds to the block height (without fees). The reward is halved
+// every HalvingInterval blocks, after MaxNumberHalvings halvings the reward is 0 to avoid dealing with shifting bugs
+func CalculateBlockSubsidy(height uint) uint {
+	halvings := height / common.HalvingInterval
+	if halvings >= common.MaxNumberHalvings {
+		return 0
+	}
+
+	return uint(common.InitialCoinbaseReward >> halvings)
+}
+
+// CalculateMiningTarget calculates the new compact target in proportion to the time required for mining the blocks
+// vs. the time expected to mine the blocks (target * actual / expected). The adjustment is limited to a factor of
+// MaxTargetAdjustmentFactor in each direction and the target can't be easier than PowLimit
+func CalculateMiningTarget(currentTarget *big.Int, targetTimeSpan float64, actualTimeSpan int64) uint32 {
+	expected := int64(targetTimeSpan)
+	if expected <= 0 {
+		return BigToCompact(currentTarget)
+	}
+
+	actual := min(max(actualTimeSpan, expected/MaxTargetAdjustmentFactor), expected*MaxTargetAdjustmentFactor)
+
+	newTarget := new(big.Int).Mul(currentTarget, big.NewInt(actual))
+	newTarget.Div(newTarget, big.NewInt(expected))
+
+	if newTarget.Cmp(PowLimit) > 0 {
+		newTarget.Set(PowLimit)
+	}
+
+	// a zero target could never be met
+	if newTarget.Sign() <= 0 {
+		newTarget.SetInt64(1)
+	}
+
+	return BigToCompact(newTarget)
+}
+
+// CalculateLegacyMiningTarget calculates the new leading zero bits target based on the time required for mining the
+// blocks vs. the time expected to mine the blocks:
 //   - if required > expected -> decrease the target by 1 unit
 //   - if required < expected -> increase the target by 1 unit
 //   - if required = expected -> do not change the target
 //
 // The mechanism used is simplified to prevent high fluctuations.
-func CalculateMiningTarget(currentTarget uint, targetTimeSpan float64, actualTimeSpan int64) uint {
+func CalculateLegacyMiningTarget(currentTarget uint, targetTimeSpan float64, actualTimeSpan int64) uint {
 	// determine the adjustment factor based on the actual and expected time spans
 	timeAdjustmentFactor := float64(actualTimeSpan) / targetTimeSpan
 
@@ -1,8 +1,11 @@
 package util_test
 
 import (
+	"math/big"
 	"testing"
 
+	"github.com/yago-123/chainnet/pkg/common"
+	"github.com/yago-123/chainnet/pkg/kernel"
 	"github.com/yago-123/chainnet/pkg/util"
 
 	"github.com/stretchr/testify/assert"
@@ -26,7 +29,7 @@ func TestIsFirstNBytesZero(t *testing.T) {
 	require.False(t, util.IsFirstNBitsZero(hash, 10))
 }
 
-func TestCalculateMiningDifficulty(t *testing.T) {
+func TestCalculateLegacyMiningTarget(t *testing.T) {
 	type args struct {
 		currentTarget  uint
 		targetTimeSpan float64
@@ -95,14 +98,97 @@ func TestCalculateMiningDifficulty(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := util.CalculateMiningTarget(tt.args.currentTarget, tt.args.targetTimeSpan, tt.args.actualTimeSpan); got != tt.want {
-				t.Errorf("CalculateMiningDifficulty() = %v, want %v", got, tt.want)
+			if got := util.CalculateLegacyMiningTarget(tt.args.currentTarget, tt.args.targetTimeSpan, tt.args.actualTimeSpan); got != tt.want {
+				t.Errorf("CalculateLegacyMiningTarget() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
+func TestCompactTarget(t *testing.T) {
+	// values taken from Bitcoin
+	target := util.CompactToBig(0x1d00ffff)
+	expected, _ := new(big.Int).SetString("00000000ffff0000000000000000000000000000000000000000000000000000", 16)
+	assert.Equal(t, expected, target)
+	assert.Equal(t, uint32(0x1d00ffff), util.BigToCompact(target))
+
+	// small targets are encoded in the mantissa
+	assert.Equal(t, big.NewInt(0x12), util.CompactToBig(0x01120000))
+	assert.Equal(t, uint32(0x01120000), util.BigToCompact(big.NewInt(0x12)))
+	assert.Equal(t, big.NewInt(0x1234), util.CompactToBig(0x02123400))
+
+	// the mantissa is signed, if the most significant bit is set the exponent is increased
+	assert.Equal(t, uint32(0x02008000), util.BigToCompact(big.NewInt(0x80)))
+	assert.Equal(t, big.NewInt(0x80), util.CompactToBig(0x02008000))
+
+	// negative and zero targets
+	assert.Equal(t, big.NewInt(0), util.CompactToBig(0x04923456))
+	assert.Equal(t, uint32(0), util.BigToCompact(big.NewInt(0)))
+
+	// only the 3 most significant bytes are kept
+	assert.Equal(t, uint32(0x04123456), util.BigToCompact(big.NewInt(0x12345678)))
+	assert.Equal(t, big.NewInt(0x12345600), util.CompactToBig(0x04123456))
+}
+
+func TestHeaderTarget(t *testing.T) {
+	// leading zero bits targets are converted to the biggest number with that amount of leading zero bits
+	legacy := &kernel.BlockHeader{Version: []byte(kernel.BlockVersionLeadingZerosTarget), Target: 8}
+	expected := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 248), big.NewInt(1))
+	assert.Equal(t, expected, util.HeaderTarget(legacy))
+	assert.Equal(t, big.NewInt(256), util.CalculateBlockWork(legacy))
+
+	compact := &kernel.BlockHeader{Version: []byte(kernel.BlockVersionCompactTarget), Target: 0x1d00ffff}
+	assert.Equal(t, util.CompactToBig(0x1d00ffff), util.HeaderTarget(compact))
+
+	// the first block with compact target keeps the difficulty of the previous leading zero bits target
+	assert.Equal(t, util.PowLimit, util.HeaderTarget(&kernel.BlockHeader{Target: 1}))
+	assert.Equal(t, uint32(0x207fffff), util.InitialCompactTarget)
+}
+
+func TestIsProofOfWorkValid(t *testing.T) {
+	hash := make([]byte, 32)
+	hash[1] = 0x01
+
+	legacy := &kernel.BlockHeader{Version: []byte(kernel.BlockVersionLeadingZerosTarget), Target: 15}
+	assert.True(t, util.IsProofOfWorkValid(hash, legacy))
+	legacy.Target = 16
+	assert.False(t, util.IsProofOfWorkValid(hash, legacy))
+
+	// the hash 0x0001... is smaller than 0x0002..., but not smaller than 0x0000ff...
+	compact := &kernel.BlockHeader{Version: []byte(kernel.BlockVersionCompactTarget), Target: 0x20020000}
+	assert.True(t, util.IsProofOfWorkValid(hash, compact))
+	compact.Target = 0x1f00ffff
+	assert.False(t, util.IsProofOfWorkValid(hash, compact))
+}
+
+func TestCalculateMiningTarget(t *testing.T) {
+	current := util.CompactToBig(0x1d00ffff)
+
+	// no adjustment needed
+	assert.Equal(t, uint32(0x1d00ffff), util.CalculateMiningTarget(current, 600, 600))
+
+	// the target changes in proportion to the time spent
+	assert.Equal(t, util.BigToCompact(new(big.Int).Div(current, big.NewInt(2))), util.CalculateMiningTarget(current, 600, 300))
+	assert.Equal(t, util.BigToCompact(new(big.Int).Mul(current, big.NewInt(2))), util.CalculateMiningTarget(current, 600, 1200))
+	assert.Equal(t, util.BigToCompact(new(big.Int).Div(new(big.Int).Mul(current, big.NewInt(610)), big.NewInt(600))), util.CalculateMiningTarget(current, 600, 610))
+
+	// the adjustment is limited in both directions
+	assert.Equal(t, util.BigToCompact(new(big.Int).Div(current, big.NewInt(4))), util.CalculateMiningTarget(current, 600, 1))
+	assert.Equal(t, util.BigToCompact(new(big.Int).Mul(current, big.NewInt(4))), util.CalculateMiningTarget(current, 600, 100000))
+
+	// the target can't be easier than the pow limit
+	assert.Equal(t, util.InitialCompactTarget, util.CalculateMiningTarget(util.PowLimit, 600, 1200))
+}
+
 func TestIsValidHash(t *testing.T) {
 	hash := "0000006484ffdc39a5ba6cebae9e398878f24bcab93f4c32acf81e246fa2474b"
 	assert.True(t, util.IsValidHash([]byte(hash)))
 }
+
+func TestCalculateBlockSubsidy(t *testing.T) {
+	assert.Equal(t, uint(common.InitialCoinbaseReward), util.CalculateBlockSubsidy(0))
+	assert.Equal(t, uint(common.InitialCoinbaseReward), util.CalculateBlockSubsidy(common.HalvingInterval-1))
+	assert.Equal(t, uint(common.InitialCoinbaseReward/2), util.CalculateBlockSubsidy(common.HalvingInterval))
+	assert.Equal(t, uint(common.InitialCoinbaseReward/4), util.CalculateBlockSubsidy(2*common.HalvingInterval))
+	assert.Equal(t, uint(0), util.CalculateBlockSubsidy(common.MaxNumberHalvings*common.HalvingInterval))
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/yago-123/chainnet/config"
 
 	"github.com/yago-123/chainnet/pkg/kernel"
+	"github.com/yago-123/chainnet/pkg/script"
 )
 
 const UTXOSObserverID = "utxos-observer"
@@ -19,6 +20,12 @@ const UTXOSObserverID = "utxos-observer"
 type UTXOSet struct {
 	mu    sync.Mutex
 	utxos map[string]kernel.UTXO
+	// spentByBlock contains the UTXOs spent by each block (key is the block hash). Required for restoring
+	// the outputs when a block is disconnected from the chain during a reorganization
+	spentByBlock map[string][]kernel.UTXO
+	// spentOrder keeps the order in which blocks were added so only the undo data of the latest
+	// MaxReorgDepth blocks is kept
+	spentOrder []string
 
 	logger *logrus.Logger
 	cfg    *config.Config
@@ -26,10 +33,12 @@ type UTXOSet struct {
 
 func NewUTXOSet(cfg *config.Config) *UTXOSet {
 	return &UTXOSet{
-		mu:     sync.Mutex{},
-		utxos:  make(map[string]kernel.UTXO),
-		logger: cfg.Logger,
-		cfg:    cfg,
+		mu:           sync.Mutex{},
+		utxos:        make(map[string]kernel.UTXO),
+		spentByBlock: make(map[string][]kernel.UTXO),
+		spentOrder:   []string{},
+		logger:       cfg.Logger,
+		cfg:          cfg,
 	}
 }
 
@@ -38,6 +47,7 @@ func (u *UTXOSet) AddBlock(block *kernel.Block) error {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
+	spent := []kernel.UTXO{}
 	for _, tx := range block.Transactions {
 		// invalidate inputs used in the block
 		for _, input := range tx.Vin {
@@ -46,22 +56,32 @@ func (u *UTXOSet) AddBlock(block *kernel.Block) error {
 				continue
 			}
 
-			_, ok := u.utxos[input.UniqueTxoKey()]
+			utxo, ok := u.utxos[input.UniqueTxoKey()]
 			if !ok {
 				// if the utxo is not found, return error (impossible scenario in theory)
 				return fmt.Errorf("transaction %s not found in the UTXO set", tx.ID)
 			}
 
+			// keep track of the utxo spent in case the block is disconnected later on
+			spent = append(spent, utxo)
+
 			// delete the utxo from the set
 			delete(u.utxos, input.UniqueTxoKey())
 		}
 
 		// add new outputs to the set
 		for index, output := range tx.Vout {
+			// data carrier outputs (OP_RETURN) can't be spent, there is no need to keep them in the set
+			if script.IsUnspendable(output.ScriptPubKey) {
+				continue
+			}
+
 			utxo := kernel.UTXO{
-				TxID:   tx.ID,
-				OutIdx: uint(index),
-				Output: output,
+				TxID:     tx.ID,
+				OutIdx:   uint(index),
+				Output:   output,
+				Height:   block.Header.Height,
+				Coinbase: tx.IsCoinbase(),
 			}
 
 			// store utxo in the set
@@ -69,9 +89,73 @@ func (u *UTXOSet) AddBlock(block *kernel.Block) error {
 		}
 	}
 
+	u.storeSpentOutputs(block.Hash, spent)
+
+	return nil
+}
+
+// RemoveBlock reverts the changes introduced by AddBlock: restores the outputs spent by the block and removes the
+// outputs created by it. Only the latest MaxReorgDepth blocks can be removed
+func (u *UTXOSet) RemoveBlock(block *kernel.Block) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	spent, ok := u.spentByBlock[string(block.Hash)]
+	if !ok {
+		return fmt.Errorf("unable to find spent outputs for block %x", block.Hash)
+	}
+
+	// restore the outputs spent by the block first, some of them may have been created in the same block
+	for _, utxo := range spent {
+		u.utxos[utxo.UniqueKey()] = utxo
+	}
+
+	// remove the outputs created by the block
+	for _, tx := range block.Transactions {
+		for index := range tx.Vout {
+			utxo := kernel.UTXO{TxID: tx.ID, OutIdx: uint(index)}
+			delete(u.utxos, utxo.UniqueKey())
+		}
+	}
+
+	// the undo data is no longer needed, if the block is added again it will be generated again
+	delete(u.spentByBlock, string(block.Hash))
+	for i := len(u.spentOrder) - 1; i >= 0; i-- {
+		if u.spentOrder[i] == string(block.Hash) {
+			u.spentOrder = append(u.spentOrder[:i], u.spentOrder[i+1:]...)
+			break
+		}
+	}
+
 	return nil
 }
 
+// storeSpentOutputs keeps the outputs spent by a block, discarding the oldest undo data once the number of blocks
+// tracked goes beyond the maximum reorganization depth
+func (u *UTXOSet) storeSpentOutputs(blockHash []byte, spent []kernel.UTXO) {
+	u.spentByBlock[string(blockHash)] = spent
+	u.spentOrder = append(u.spentOrder, string(blockHash))
+
+	for uint(len(u.spentOrder)) > u.cfg.Chain.MaxReorgDepth {
+		delete(u.spentByBlock, u.spentOrder[0])
+		u.spentOrder = u.spentOrder[1:]
+	}
+}
+
+// RetrieveUTXO returns the unspent output spent by the input provided, along with the height and type (coinbase or
+// not) of the transaction that created it
+func (u *UTXOSet) RetrieveUTXO(input kernel.TxInput) (kernel.UTXO, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	utxo, ok := u.utxos[input.UniqueTxoKey()]
+	if !ok {
+		return kernel.UTXO{}, fmt.Errorf("input %s not found in the UTXO set", input.UniqueTxoKey())
+	}
+
+	return utxo, nil
+}
+
 // RetrieveInputsBalance from the inputs provided
 func (u *UTXOSet) RetrieveInputsBalance(inputs []kernel.TxInput) (uint, error) {
 	u.mu.Lock()
@@ -104,6 +188,12 @@ func (u *UTXOSet) OnBlockAddition(block *kernel.Block) {
 	}
 }
 
+// OnBlockRemoval is called when a block is disconnected from the blockchain via the observer pattern. The chain
+// already reverted the block via RemoveBlock so it can abort the reorganization if that fails
+func (u *UTXOSet) OnBlockRemoval(_ *kernel.Block) {
+	// do nothing
+}
+
 // OnTxAddition is called when a new tx is added to the mempool via the observer pattern
 func (u *UTXOSet) OnTxAddition(_ *kernel.Transaction) {
 	// do nothing
@@ -14,6 +14,8 @@ import (
 )
 
 var b1 = &kernel.Block{ //nolint:gochecknoglobals // ignore linter in this case
+	Hash:   []byte("block-1"),
+	Header: &kernel.BlockHeader{Height: 1},
 	Transactions: []*kernel.Transaction{
 		{
 			ID: []byte("coinbase-transaction-block-1"),
@@ -28,6 +30,8 @@ var b1 = &kernel.Block{ //nolint:gochecknoglobals // ignore linter in this case
 }
 
 var b2 = &kernel.Block{ //nolint:gochecknoglobals // ignore linter in this case
+	Hash:   []byte("block-2"),
+	Header: &kernel.BlockHeader{Height: 2},
 	Transactions: []*kernel.Transaction{
 		{
 			ID: []byte("coinbase-transaction-block-2"),
@@ -65,6 +69,8 @@ var b2 = &kernel.Block{ //nolint:gochecknoglobals // ignore linter in this case
 }
 
 var b3 = &kernel.Block{ //nolint:gochecknoglobals // ignore linter in this case
+	Hash:   []byte("block-3"),
+	Header: &kernel.BlockHeader{Height: 3},
 	Transactions: []*kernel.Transaction{
 		{
 			ID: []byte("coinbase-transaction-block-3"),
@@ -110,6 +116,8 @@ func TestUTXOSet_AddBlock(t *testing.T) {
 	assert.Equal(t, uint(0), val.OutIdx)
 	assert.Equal(t, "bob", val.Output.PubKey)
 	assert.Equal(t, uint(50), val.Output.Amount)
+	assert.Equal(t, uint(2), val.Height)
+	assert.True(t, val.Coinbase)
 
 	val, ok = utxos.utxos[fmt.Sprintf("%x-%d", "transaction-1-block-2", 1)]
 	require.True(t, ok)
@@ -128,6 +136,36 @@ func TestUTXOSet_AddBlock(t *testing.T) {
 	assert.Equal(t, uint(0), val.OutIdx)
 	assert.Equal(t, "dave", val.Output.PubKey)
 	assert.Equal(t, uint(25), val.Output.Amount)
+	assert.Equal(t, uint(3), val.Height)
+	assert.False(t, val.Coinbase)
+}
+
+func TestUTXOSet_AddBlockWithDataCarrierOutputs(t *testing.T) {
+	utxos := NewUTXOSet(config.NewConfig())
+
+	block := &kernel.Block{
+		Hash:   []byte("block-1"),
+		Header: &kernel.BlockHeader{Height: 1},
+		Transactions: []*kernel.Transaction{
+			{
+				ID:  []byte("coinbase-transaction-block-1"),
+				Vin: []kernel.TxInput{kernel.NewCoinbaseInput()},
+				Vout: []kernel.TxOutput{
+					kernel.NewCoinbaseOutput(50, script.P2PK, "alice"),
+					kernel.NewDataOutput([]byte("data")), // <- never added to the set
+				},
+			},
+		},
+	}
+
+	require.NoError(t, utxos.AddBlock(block))
+	require.Len(t, utxos.utxos, 1)
+
+	_, ok := utxos.utxos[fmt.Sprintf("%x-%d", "coinbase-transaction-block-1", 1)]
+	assert.False(t, ok)
+
+	require.NoError(t, utxos.RemoveBlock(block))
+	require.Empty(t, utxos.utxos)
 }
 
 // same as TestUTXOSet_AddBlock but with OnBlockAddition method
@@ -223,3 +261,53 @@ func TestUTXOSet_AddBlockWithInvalidInput(t *testing.T) {
 	// add block that references input not present in the UTXO set
 	require.Error(t, utxos.AddBlock(b2))
 }
+
+func TestUTXOSet_RemoveBlock(t *testing.T) {
+	utxos := NewUTXOSet(config.NewConfig())
+
+	require.NoError(t, utxos.AddBlock(b1))
+	require.NoError(t, utxos.AddBlock(b2))
+	require.NoError(t, utxos.AddBlock(b3))
+
+	// remove the last block and make sure that the outputs spent by it are restored
+	require.NoError(t, utxos.RemoveBlock(b3))
+	require.Len(t, utxos.utxos, 3)
+
+	val, ok := utxos.utxos[fmt.Sprintf("%x-%d", "transaction-1-block-2", 0)]
+	require.True(t, ok)
+	assert.Equal(t, "mike", val.Output.PubKey)
+	assert.Equal(t, uint(25), val.Output.Amount)
+
+	_, ok = utxos.utxos[fmt.Sprintf("%x-%d", "coinbase-transaction-block-3", 0)]
+	require.False(t, ok)
+	_, ok = utxos.utxos[fmt.Sprintf("%x-%d", "transaction-1-block-3", 0)]
+	require.False(t, ok)
+
+	// remove the next block and make sure that the set matches the first block only
+	require.NoError(t, utxos.RemoveBlock(b2))
+	require.Len(t, utxos.utxos, 1)
+
+	val, ok = utxos.utxos[fmt.Sprintf("%x-%d", "coinbase-transaction-block-1", 0)]
+	require.True(t, ok)
+	assert.Equal(t, "alice", val.Output.PubKey)
+
+	// the block can be added again after being removed
+	require.NoError(t, utxos.AddBlock(b2))
+	require.Len(t, utxos.utxos, 3)
+}
+
+func TestUTXOSet_RemoveBlockWithoutSpentOutputs(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Chain.MaxReorgDepth = 1
+	utxos := NewUTXOSet(cfg)
+
+	require.NoError(t, utxos.AddBlock(b1))
+	require.NoError(t, utxos.AddBlock(b2))
+
+	// the undo data of the first block is discarded given that only one block can be disconnected
+	require.NoError(t, utxos.RemoveBlock(b2))
+	require.Error(t, utxos.RemoveBlock(b1))
+
+	// blocks never added can't be removed
+	require.Error(t, utxos.RemoveBlock(b3))
+}
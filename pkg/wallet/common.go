@@ -7,6 +7,7 @@ import (
 	"github.com/yago-123/chainnet/pkg/kernel"
 	"github.com/yago-123/chainnet/pkg/script"
 	util_p2pkh "github.com/yago-123/chainnet/pkg/util/p2pkh"
+	util_p2sh "github.com/yago-123/chainnet/pkg/util/p2sh"
 )
 
 // GenerateInputs set up the inputs for the transaction and returns the total balance of the UTXOs that are going to be
@@ -81,6 +82,16 @@ func GenerateOutputs(scriptType script.ScriptType, targetAmounts []uint, address
 			changeAddress = string(changeAddressArray)
 		}
 
+		// calculate the address for P2SH, the change is locked with the default redeem script of the change receiver
+		if scriptType == script.P2SH {
+			changeAddressArray, err := util_p2sh.GenerateP2SHAddrFromScript([]byte(script.NewRedeemScript(changeReceiverPubKey)), changeReceiverVersion)
+			if err != nil {
+				return []kernel.TxOutput{}, err
+			}
+
+			changeAddress = string(changeAddressArray)
+		}
+
 		txOutput = append(txOutput, kernel.NewOutput(totalBalance-txFee-totalTargetAmount, scriptType, changeAddress))
 	}
 
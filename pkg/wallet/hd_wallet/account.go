@@ -266,7 +266,12 @@ func (hda *Account) GenerateNewTransaction(scriptType script.ScriptType, address
 		totalTargetAmount += amount
 	}
 
-	inputs, totalBalance, err := common.GenerateInputs(common.SDKUTXOsToKernel(utxos), totalTargetAmount+txFee)
+	kernelUtxos, err := common.SDKUTXOsToKernel(utxos)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, err
+	}
+
+	inputs, totalBalance, err := common.GenerateInputs(kernelUtxos, totalTargetAmount+txFee)
 	if err != nil {
 		return &sdkv1beta.Transaction{}, err
 	}
@@ -284,36 +289,50 @@ func (hda *Account) GenerateNewTransaction(scriptType script.ScriptType, address
 
 	// unlock the funds from the UTXOs
 	sdkTx := common.KernelTransactionToSDK(*tx)
-	sdkTxPtr, err := hda.UnlockTxFunds(&sdkTx, utxos)
+	sdkTxPtr, err := hda.UnlockTxFunds(&sdkTx, utxos, kernel.SighashAll)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, err
+	}
+
+	unlockedTx, err := common.SDKTransactionToKernel(sdkTxPtr)
 	if err != nil {
 		return &sdkv1beta.Transaction{}, err
 	}
 
 	// generate tx hash
-	txHash, err := util.CalculateTxHash(common.SDKTransactionToKernel(sdkTxPtr), hda.consensusHasher)
+	txHash, err := util.CalculateTxHash(unlockedTx, hda.consensusHasher)
 	if err != nil {
 		return &sdkv1beta.Transaction{}, err
 	}
 
 	// assign the tx hash
 	sdkTxPtr.ID = txHash
+	unlockedTx.SetID(txHash)
 
 	// perform simple validations (light validator) before broadcasting the transaction
-	if err = hda.validator.ValidateTxLight(common.SDKTransactionToKernel(sdkTxPtr)); err != nil {
+	if err = hda.validator.ValidateTxLight(unlockedTx); err != nil {
 		return &sdkv1beta.Transaction{}, fmt.Errorf("error validating transaction: %w", err)
 	}
 
 	return sdkTxPtr, nil
 }
 
-// UnlockTxFunds unlocks the funds from the UTXOs by generating the scriptSigs for the inputs
-func (hda *Account) UnlockTxFunds(tx *sdkv1beta.Transaction, utxos []sdkv1beta.UTXO) (*sdkv1beta.Transaction, error) {
+// UnlockTxFunds unlocks the funds from the UTXOs by generating the scriptSigs for the inputs, the signature type
+// decides which parts of the transaction are committed by the signatures
+func (hda *Account) UnlockTxFunds(tx *sdkv1beta.Transaction, utxos []sdkv1beta.UTXO, sigType kernel.SignatureType) (*sdkv1beta.Transaction, error) {
 	// precompute wallets for lookup
 	wallets := append(hda.externalWallets, hda.internalWallets...) //nolint:gocritic // simpler to use a single append
 
 	// map UTXOs so that can be easily accessed for generating the scriptSigs for the inputs
-	kernelTx := common.SDKTransactionToKernel(tx)
-	kernelUtxos := common.SDKUTXOsToKernel(utxos)
+	kernelTx, err := common.SDKTransactionToKernel(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	kernelUtxos, err := common.SDKUTXOsToKernel(utxos)
+	if err != nil {
+		return nil, err
+	}
 
 	utxoMap := make(map[string]*kernel.UTXO)
 	for _, utxo := range kernelUtxos {
@@ -335,7 +354,7 @@ func (hda *Account) UnlockTxFunds(tx *sdkv1beta.Transaction, utxos []sdkv1beta.U
 		for _, wallet := range wallets {
 			if script.CanBeUnlockedWith(utxo.Output.ScriptPubKey, wallet.PublicKey(), wallet.Version()) {
 				// generate the unlocking script
-				scriptSig, err := hda.interpreter.GenerateScriptSig(utxo.Output.ScriptPubKey, wallet.PublicKey(), wallet.PrivateKey(), kernelTx)
+				scriptSig, err := hda.interpreter.GenerateScriptSig(utxo.Output, wallet.PublicKey(), wallet.PrivateKey(), kernelTx, uint(i), sigType)
 				if err != nil {
 					return nil, fmt.Errorf("couldn't generate scriptSig for input with ID %x and index %d: %w", vin.Txid, vin.Vout, err)
 				}
@@ -348,7 +367,7 @@ func (hda *Account) UnlockTxFunds(tx *sdkv1beta.Transaction, utxos []sdkv1beta.U
 		}
 
 		if !unlocked {
-			return nil, fmt.Errorf("couldn't unlock funds for input with ID %x, index %d and scriptPubKey %s", vin.Txid, vin.Vout, utxo.Output.ScriptPubKey)
+			return nil, fmt.Errorf("couldn't unlock funds for input with ID %x, index %d and scriptPubKey %s", vin.Txid, vin.Vout, script.Disassemble(utxo.Output.ScriptPubKey))
 		}
 	}
 
@@ -1,11 +1,16 @@
 package wallet
 
 import (
+	"encoding/hex"
+	"fmt"
+
 	sdkv1beta "github.com/yago-123/chainnet-sdk-go/v1beta"
 	"github.com/yago-123/chainnet/pkg/kernel"
 )
 
 // These functions are needed in order to access
+// The SDK types hold the scripts in the same format used by the API (hex-encoded), while the kernel types hold the
+// serialized scripts
 
 func KernelTransactionToSDK(tx kernel.Transaction) sdkv1beta.Transaction {
 	inputs := make([]sdkv1beta.TxInput, 0, len(tx.Vin))
@@ -13,8 +18,9 @@ func KernelTransactionToSDK(tx kernel.Transaction) sdkv1beta.Transaction {
 		inputs = append(inputs, sdkv1beta.TxInput{
 			Txid:      input.Txid,
 			Vout:      input.Vout,
-			ScriptSig: input.ScriptSig,
+			ScriptSig: hex.EncodeToString([]byte(input.ScriptSig)),
 			PubKey:    input.PubKey,
+			Sequence:  input.Sequence,
 		})
 	}
 
@@ -22,62 +28,87 @@ func KernelTransactionToSDK(tx kernel.Transaction) sdkv1beta.Transaction {
 	for _, output := range tx.Vout {
 		outputs = append(outputs, sdkv1beta.TxOutput{
 			Amount:       output.Amount,
-			ScriptPubKey: output.ScriptPubKey,
+			ScriptPubKey: hex.EncodeToString([]byte(output.ScriptPubKey)),
 			PubKey:       output.PubKey,
 		})
 	}
 
 	return sdkv1beta.Transaction{
-		ID:   tx.ID,
-		Vin:  inputs,
-		Vout: outputs,
+		ID:       tx.ID,
+		Vin:      inputs,
+		Vout:     outputs,
+		LockTime: tx.LockTime,
+		Version:  tx.Version,
 	}
 }
 
-func SDKTransactionToKernel(tx *sdkv1beta.Transaction) *kernel.Transaction {
+func SDKTransactionToKernel(tx *sdkv1beta.Transaction) (*kernel.Transaction, error) {
 	inputs := make([]kernel.TxInput, 0, len(tx.Vin))
 	for _, input := range tx.Vin {
+		scriptSig, err := hex.DecodeString(input.ScriptSig)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding scriptSig of input with ID %x and index %d: %w", input.Txid, input.Vout, err)
+		}
+
 		inputs = append(inputs, kernel.TxInput{
 			Txid:      input.Txid,
 			Vout:      input.Vout,
-			ScriptSig: input.ScriptSig,
+			ScriptSig: string(scriptSig),
 			PubKey:    input.PubKey,
+			Sequence:  input.Sequence,
 		})
 	}
 
 	outputs := make([]kernel.TxOutput, 0, len(tx.Vout))
-	for _, output := range tx.Vout {
+	for i, output := range tx.Vout {
+		scriptPubKey, err := hex.DecodeString(output.ScriptPubKey)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding scriptPubKey of output %d: %w", i, err)
+		}
+
 		outputs = append(outputs, kernel.TxOutput{
 			Amount:       output.Amount,
-			ScriptPubKey: output.ScriptPubKey,
+			ScriptPubKey: string(scriptPubKey),
 			PubKey:       output.PubKey,
 		})
 	}
 
 	return &kernel.Transaction{
-		ID:   tx.ID,
-		Vin:  inputs,
-		Vout: outputs,
-	}
+		ID:       tx.ID,
+		Vin:      inputs,
+		Vout:     outputs,
+		LockTime: tx.LockTime,
+		Version:  tx.Version,
+	}, nil
 }
 
-func SDKUTXOToKernel(utxo sdkv1beta.UTXO) *kernel.UTXO {
+func SDKUTXOToKernel(utxo sdkv1beta.UTXO) (*kernel.UTXO, error) {
+	scriptPubKey, err := hex.DecodeString(utxo.Output.ScriptPubKey)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding scriptPubKey of UTXO with ID %x and index %d: %w", utxo.TxID, utxo.OutIdx, err)
+	}
+
 	return &kernel.UTXO{
 		TxID:   utxo.TxID,
 		OutIdx: utxo.OutIdx,
 		Output: kernel.TxOutput{
 			Amount:       utxo.Output.Amount,
-			ScriptPubKey: utxo.Output.ScriptPubKey,
+			ScriptPubKey: string(scriptPubKey),
 			PubKey:       utxo.Output.PubKey,
 		},
-	}
+	}, nil
 }
 
-func SDKUTXOsToKernel(utxos []sdkv1beta.UTXO) []*kernel.UTXO {
+func SDKUTXOsToKernel(utxos []sdkv1beta.UTXO) ([]*kernel.UTXO, error) {
 	ret := make([]*kernel.UTXO, 0, len(utxos))
 	for _, utxo := range utxos {
-		ret = append(ret, SDKUTXOToKernel(utxo))
+		kernelUTXO, err := SDKUTXOToKernel(utxo)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, kernelUTXO)
 	}
 
-	return ret
+	return ret, nil
 }
@@ -0,0 +1,31 @@
+package wallet //nolint:testpackage // don't create separate package for tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yago-123/chainnet/pkg/kernel"
+)
+
+func TestSDKTransactionConversion(t *testing.T) {
+	tx := kernel.NewTransactionWithLockTime(
+		[]kernel.TxInput{kernel.NewInputWithSequence([]byte("txid"), 1, "scriptSig", "pubKey", 10)},
+		[]kernel.TxOutput{{Amount: 10, ScriptPubKey: "scriptPubKey", PubKey: "pubKey"}},
+		100,
+	)
+	tx.SetID([]byte("id"))
+
+	sdkTx := KernelTransactionToSDK(*tx)
+	assert.Equal(t, uint(100), sdkTx.LockTime)
+	assert.Equal(t, uint(10), sdkTx.Vin[0].Sequence)
+	assert.Equal(t, kernel.TxVersionCanonicalSerialization, sdkTx.Version)
+
+	converted, err := SDKTransactionToKernel(&sdkTx)
+	require.NoError(t, err)
+	assert.Equal(t, tx.ID, converted.ID)
+	assert.Equal(t, tx.Vin, converted.Vin)
+	assert.Equal(t, tx.Vout, converted.Vout)
+	assert.Equal(t, tx.LockTime, converted.LockTime)
+	assert.Equal(t, tx.Version, converted.Version)
+}
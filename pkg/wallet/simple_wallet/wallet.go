@@ -9,6 +9,7 @@ import (
 	common "github.com/yago-123/chainnet/pkg/wallet"
 
 	util_p2pkh "github.com/yago-123/chainnet/pkg/util/p2pkh"
+	util_p2sh "github.com/yago-123/chainnet/pkg/util/p2sh"
 
 	sdkv1beta "github.com/yago-123/chainnet-sdk-go/v1beta"
 	"github.com/yago-123/chainnet/pkg/consensus"
@@ -107,6 +108,14 @@ func (w *Wallet) GetAddresses() ([][]byte, error) {
 
 	addresses = append(addresses, address)
 
+	// retrieve P2SH address
+	address, err = w.GetP2SHAddress()
+	if err != nil {
+		return [][]byte{}, fmt.Errorf("could not get wallet address for P2SH: %w", err)
+	}
+
+	addresses = append(addresses, address)
+
 	// validate that are between the allowed ranges
 	for _, addr := range addresses {
 		if !util.IsValidAddress(addr) {
@@ -166,6 +175,19 @@ func (w *Wallet) GetWalletTxs() ([]*sdkv1beta.Transaction, error) {
 	return txs, nil
 }
 
+// GetTransaction retrieves the transaction with the given ID, either confirmed or waiting in the node mempool
+func (w *Wallet) GetTransaction(txID []byte) (*sdkv1beta.Transaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), w.cfg.Timeout())
+	defer cancel()
+
+	tx, err := w.nodeClient.GetTransactionByID(ctx, txID)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, fmt.Errorf("could not get transaction %x: %w", txID, err)
+	}
+
+	return tx, nil
+}
+
 // CheckAddressIsActive checks if there has been any transaction related to any of the addresses of the wallet
 func (w *Wallet) CheckIfWalletIsActive() (bool, error) {
 	addresses, err := w.GetAddresses()
@@ -194,7 +216,12 @@ func (w *Wallet) CheckIfWalletIsActive() (bool, error) {
 // GenerateNewTransaction creates a transaction using wallet-owned SDK types.
 func (w *Wallet) GenerateNewTransaction(scriptType script.ScriptType, addresses []byte, targetAmount uint, txFee uint, utxos []sdkv1beta.UTXO) (*sdkv1beta.Transaction, error) {
 	// create the inputs necessary for the transaction
-	inputs, totalBalance, err := common.GenerateInputs(common.SDKUTXOsToKernel(utxos), targetAmount+txFee)
+	kernelUtxos, err := common.SDKUTXOsToKernel(utxos)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, err
+	}
+
+	inputs, totalBalance, err := common.GenerateInputs(kernelUtxos, targetAmount+txFee)
 	if err != nil {
 		return &sdkv1beta.Transaction{}, err
 	}
@@ -213,43 +240,292 @@ func (w *Wallet) GenerateNewTransaction(scriptType script.ScriptType, addresses
 
 	// unlock the funds from the UTXOs
 	sdkTx := common.KernelTransactionToSDK(*tx)
-	sdkTxPtr, err := w.UnlockTxFunds(&sdkTx, utxos)
+	sdkTxPtr, err := w.UnlockTxFunds(&sdkTx, utxos, kernel.SighashAll)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, err
+	}
+
+	return w.completeTransaction(sdkTxPtr)
+}
+
+// GenerateHTLCTransaction creates a transaction that locks the target amount in a HTLC output. The receiver can claim
+// the funds revealing the preimage of the hash lock (SHA-256), otherwise the wallet can take them back once the lock
+// time has been reached
+func (w *Wallet) GenerateHTLCTransaction(receiverPubKey, hashLock []byte, lockTime, targetAmount, txFee uint, utxos []sdkv1beta.UTXO) (*sdkv1beta.Transaction, error) {
+	htlcScript := script.NewHTLCScript(script.HTLCParams{
+		HashLock:       hashLock,
+		ReceiverPubKey: receiverPubKey,
+		LockTime:       lockTime,
+		SenderPubKey:   w.publicKey,
+	})
+	if htlcScript == script.UndefinedScript {
+		return &sdkv1beta.Transaction{}, fmt.Errorf("invalid HTLC arguments")
+	}
+
+	kernelUtxos, err := common.SDKUTXOsToKernel(utxos)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, err
+	}
+
+	inputs, totalBalance, err := common.GenerateInputs(kernelUtxos, targetAmount+txFee)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, err
+	}
+
+	// the output is tracked by the receiver, which is the one expected to unlock it
+	outputs := []kernel.TxOutput{{Amount: targetAmount, ScriptPubKey: htlcScript, PubKey: string(receiverPubKey)}}
+	if change := totalBalance - targetAmount - txFee; change > 0 {
+		outputs = append(outputs, kernel.NewOutput(change, script.P2PK, string(w.publicKey)))
+	}
+
+	sdkTx := common.KernelTransactionToSDK(*kernel.NewTransaction(inputs, outputs))
+	sdkTxPtr, err := w.UnlockTxFunds(&sdkTx, utxos, kernel.SighashAll)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, err
+	}
+
+	return w.completeTransaction(sdkTxPtr)
+}
+
+// GenerateDataTransaction creates a transaction that publishes the data provided in a zero amount OP_RETURN output,
+// the inputs only pay the tx fee and the rest goes back to the wallet as change
+func (w *Wallet) GenerateDataTransaction(data []byte, txFee uint, utxos []sdkv1beta.UTXO) (*sdkv1beta.Transaction, error) {
+	if len(data) == 0 {
+		return &sdkv1beta.Transaction{}, fmt.Errorf("no data to publish")
+	}
+
+	kernelUtxos, err := common.SDKUTXOsToKernel(utxos)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, err
+	}
+
+	inputs, totalBalance, err := common.GenerateInputs(kernelUtxos, txFee)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, err
+	}
+
+	outputs := []kernel.TxOutput{kernel.NewDataOutput(data)}
+	if change := totalBalance - txFee; change > 0 {
+		outputs = append(outputs, kernel.NewOutput(change, script.P2PK, string(w.publicKey)))
+	}
+
+	sdkTx := common.KernelTransactionToSDK(*kernel.NewTransaction(inputs, outputs))
+	sdkTxPtr, err := w.UnlockTxFunds(&sdkTx, utxos, kernel.SighashAll)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, err
+	}
+
+	return w.completeTransaction(sdkTxPtr)
+}
+
+// ClaimHTLC creates a transaction that moves the funds of a HTLC output to the wallet revealing the preimage of the
+// hash lock. The wallet must be the receiver of the HTLC
+func (w *Wallet) ClaimHTLC(htlcUTXO sdkv1beta.UTXO, preimage []byte, txFee uint) (*sdkv1beta.Transaction, error) {
+	if htlcUTXO.Amount() <= txFee {
+		return &sdkv1beta.Transaction{}, fmt.Errorf("HTLC amount %d can't pay the tx fee %d", htlcUTXO.Amount(), txFee)
+	}
+
+	kernelUTXO, err := common.SDKUTXOToKernel(htlcUTXO)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, err
+	}
+
+	kernelTx := kernel.NewTransaction(
+		[]kernel.TxInput{kernel.NewInput(kernelUTXO.TxID, kernelUTXO.OutIdx, "", kernelUTXO.Output.PubKey)},
+		[]kernel.TxOutput{kernel.NewOutput(kernelUTXO.Amount()-txFee, script.P2PK, string(w.publicKey))},
+	)
+
+	scriptSig, err := w.interpreter.GenerateHTLCClaimScriptSig(kernelUTXO.Output, preimage, w.publicKey, w.privateKey, kernelTx, 0, kernel.SighashAll)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, fmt.Errorf("couldn't generate scriptSig for HTLC with ID %x and index %d: %w", htlcUTXO.TxID, htlcUTXO.OutIdx, err)
+	}
+	kernelTx.Vin[0].UnlockWith(scriptSig)
+
+	sdkTx := common.KernelTransactionToSDK(*kernelTx)
+	return w.completeTransaction(&sdkTx)
+}
+
+// RefundHTLC creates a transaction that moves the funds of a HTLC output back to the wallet once the lock time of the
+// HTLC has been reached. The wallet must be the sender of the HTLC
+func (w *Wallet) RefundHTLC(htlcUTXO sdkv1beta.UTXO, txFee uint) (*sdkv1beta.Transaction, error) {
+	if htlcUTXO.Amount() <= txFee {
+		return &sdkv1beta.Transaction{}, fmt.Errorf("HTLC amount %d can't pay the tx fee %d", htlcUTXO.Amount(), txFee)
+	}
+
+	kernelUTXO, err := common.SDKUTXOToKernel(htlcUTXO)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, err
+	}
+
+	scriptTokens, scriptString, err := script.StringToScript(kernelUTXO.Output.ScriptPubKey)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, err
+	}
+
+	params, err := script.ExtractHTLCParams(scriptTokens, scriptString)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, fmt.Errorf("output with ID %x and index %d is not a HTLC: %w", htlcUTXO.TxID, htlcUTXO.OutIdx, err)
+	}
+
+	// the lock time of the transaction must reach the one of the HTLC so that OP_CHECKLOCKTIMEVERIFY succeeds
+	kernelTx := kernel.NewTransactionWithLockTime(
+		[]kernel.TxInput{kernel.NewInput(kernelUTXO.TxID, kernelUTXO.OutIdx, "", kernelUTXO.Output.PubKey)},
+		[]kernel.TxOutput{kernel.NewOutput(kernelUTXO.Amount()-txFee, script.P2PK, string(w.publicKey))},
+		params.LockTime,
+	)
+
+	scriptSig, err := w.interpreter.GenerateScriptSig(kernelUTXO.Output, w.publicKey, w.privateKey, kernelTx, 0, kernel.SighashAll)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, fmt.Errorf("couldn't generate scriptSig for HTLC with ID %x and index %d: %w", htlcUTXO.TxID, htlcUTXO.OutIdx, err)
+	}
+	kernelTx.Vin[0].UnlockWith(scriptSig)
+
+	sdkTx := common.KernelTransactionToSDK(*kernelTx)
+	return w.completeTransaction(&sdkTx)
+}
+
+// BumpFee creates a replacement for a transaction that has not been confirmed yet, paying the new fee provided. The
+// fee increase is subtracted from the change output, the rest of the transaction remains the same so that the
+// replacement spends the same inputs as the original
+// todo(): add new inputs when the change output can't cover the fee increase
+func (w *Wallet) BumpFee(tx *sdkv1beta.Transaction, newFee uint, utxos []sdkv1beta.UTXO) (*sdkv1beta.Transaction, error) {
+	kernelTx, err := common.SDKTransactionToKernel(tx)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, err
+	}
+
+	kernelUtxos, err := common.SDKUTXOsToKernel(utxos)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, err
+	}
+
+	// calculate the fee paid by the original transaction
+	inputBalance := uint(0)
+	for _, vin := range kernelTx.Vin {
+		found := false
+		for _, utxo := range kernelUtxos {
+			if utxo.EqualInput(vin) {
+				inputBalance += utxo.Amount()
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return &sdkv1beta.Transaction{}, fmt.Errorf("input with ID %x and index %d does not belong to the wallet", vin.Txid, vin.Vout)
+		}
+	}
+
+	outputBalance := uint(0)
+	for _, vout := range kernelTx.Vout {
+		outputBalance += vout.Amount
+	}
+
+	if outputBalance > inputBalance {
+		return &sdkv1beta.Transaction{}, fmt.Errorf("outputs balance %d exceeds inputs balance %d", outputBalance, inputBalance)
+	}
+
+	currentFee := inputBalance - outputBalance
+	if newFee <= currentFee {
+		return &sdkv1beta.Transaction{}, fmt.Errorf("new fee %d must be higher than the current fee %d", newFee, currentFee)
+	}
+
+	changeIdx, err := w.changeOutputIndex(kernelTx)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, err
+	}
+
+	increase := newFee - currentFee
+	if kernelTx.Vout[changeIdx].Amount < increase {
+		return &sdkv1beta.Transaction{}, fmt.Errorf("change %d can't cover the fee increase %d", kernelTx.Vout[changeIdx].Amount, increase)
+	}
+
+	kernelTx.Vout[changeIdx].Amount -= increase
+	if kernelTx.Vout[changeIdx].Amount == 0 {
+		kernelTx.Vout = append(kernelTx.Vout[:changeIdx], kernelTx.Vout[changeIdx+1:]...)
+	}
+
+	// the signatures of the original transaction do not commit to the new outputs
+	for i := range kernelTx.Vin {
+		kernelTx.Vin[i].ScriptSig = ""
+	}
+
+	sdkTx := common.KernelTransactionToSDK(*kernelTx)
+	sdkTxPtr, err := w.UnlockTxFunds(&sdkTx, utxos, kernel.SighashAll)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, err
+	}
+
+	return w.completeTransaction(sdkTxPtr)
+}
+
+// changeOutputIndex returns the index of the last output of the transaction paying to one of the wallet addresses
+func (w *Wallet) changeOutputIndex(tx *kernel.Transaction) (int, error) {
+	addresses, err := w.GetAddresses()
+	if err != nil {
+		return 0, fmt.Errorf("could not get wallet addresses: %w", err)
+	}
+
+	for i := len(tx.Vout) - 1; i >= 0; i-- {
+		for _, address := range addresses {
+			if tx.Vout[i].PubKey == string(address) {
+				return i, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("transaction %x does not contain change output to pay the fee increase", tx.ID)
+}
+
+// completeTransaction assigns the hash to a transaction whose funds have been unlocked and performs simple validations
+// before the transaction is broadcasted
+func (w *Wallet) completeTransaction(sdkTx *sdkv1beta.Transaction) (*sdkv1beta.Transaction, error) {
+	kernelTx, err := common.SDKTransactionToKernel(sdkTx)
 	if err != nil {
 		return &sdkv1beta.Transaction{}, err
 	}
 
 	// generate tx hash
-	txHash, err := util.CalculateTxHash(common.SDKTransactionToKernel(sdkTxPtr), w.consensusHasher)
+	txHash, err := util.CalculateTxHash(kernelTx, w.consensusHasher)
 	if err != nil {
 		return &sdkv1beta.Transaction{}, err
 	}
 
 	// assign the tx hash
-	sdkTxPtr.ID = txHash
+	sdkTx.ID = txHash
+	kernelTx.SetID(txHash)
 
 	// perform simple validations (light validator) before broadcasting the transaction
-	if err = w.validator.ValidateTxLight(common.SDKTransactionToKernel(sdkTxPtr)); err != nil {
+	if err = w.validator.ValidateTxLight(kernelTx); err != nil {
 		return &sdkv1beta.Transaction{}, fmt.Errorf("error validating transaction: %w", err)
 	}
 
-	return sdkTxPtr, nil
+	return sdkTx, nil
 }
 
 // UnlockTxFunds take a tx that is being built and unlocks the UTXOs from which the input funds are going to
-// be used
-func (w *Wallet) UnlockTxFunds(tx *sdkv1beta.Transaction, utxos []sdkv1beta.UTXO) (*sdkv1beta.Transaction, error) {
+// be used. The signature type decides which parts of the transaction are committed by the signatures (see
+// kernel.SignatureType)
+func (w *Wallet) UnlockTxFunds(tx *sdkv1beta.Transaction, utxos []sdkv1beta.UTXO, sigType kernel.SignatureType) (*sdkv1beta.Transaction, error) {
 	// todo() for now, this only applies to P2PK, be able to extend once pkg/script/interpreter.go is created
-	kernelTx := common.SDKTransactionToKernel(tx)
-	kernelUtxos := common.SDKUTXOsToKernel(utxos)
+	kernelTx, err := common.SDKTransactionToKernel(tx)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, err
+	}
+
+	kernelUtxos, err := common.SDKUTXOsToKernel(utxos)
+	if err != nil {
+		return &sdkv1beta.Transaction{}, err
+	}
 
 	scriptSigs := []string{}
-	for _, vin := range kernelTx.Vin {
+	for i, vin := range kernelTx.Vin {
 		unlocked := false
 
 		for _, utxo := range kernelUtxos {
 			if utxo.EqualInput(vin) {
 				// todo(): modify to allow multiple inputs with different scriptPubKeys owners (multiple wallets)
-				scriptSig, err := w.interpreter.GenerateScriptSig(utxo.Output.ScriptPubKey, w.publicKey, w.privateKey, kernelTx)
+				scriptSig, err := w.interpreter.GenerateScriptSig(utxo.Output, w.publicKey, w.privateKey, kernelTx, uint(i), sigType)
 				if err != nil {
 					return &sdkv1beta.Transaction{}, fmt.Errorf("couldn't generate scriptSig for input with ID %x and index %d: %w", vin.Txid, vin.Vout, err)
 				}
@@ -299,3 +575,8 @@ func (w *Wallet) GetP2PKAddress() []byte {
 func (w *Wallet) GetP2PKHAddress() ([]byte, error) {
 	return util_p2pkh.GenerateP2PKHAddrFromPubKey(w.publicKey, w.version)
 }
+
+// GetP2SHAddress returns the P2SH address of the default redeem script of the wallet (P2PK script of the public key)
+func (w *Wallet) GetP2SHAddress() ([]byte, error) {
+	return util_p2sh.GenerateP2SHAddrFromScript([]byte(script.NewRedeemScript(w.publicKey)), w.version)
+}
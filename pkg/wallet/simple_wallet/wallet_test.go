@@ -1,28 +1,52 @@
 package simplewallet //nolint:testpackage // don't create separate package for tests
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
 	"testing"
 
 	sdkv1beta "github.com/yago-123/chainnet-sdk-go/v1beta"
+	"github.com/yago-123/chainnet/config"
 	"github.com/yago-123/chainnet/pkg/consensus/validator"
 	"github.com/yago-123/chainnet/pkg/encoding"
 	"github.com/yago-123/chainnet/pkg/kernel"
 	"github.com/yago-123/chainnet/pkg/script"
+	rpnInter "github.com/yago-123/chainnet/pkg/script/interpreter"
+	util_script "github.com/yago-123/chainnet/pkg/util/script"
 	walletcommon "github.com/yago-123/chainnet/pkg/wallet"
 	mockHash "github.com/yago-123/chainnet/tests/mocks/crypto/hash"
 	mockSign "github.com/yago-123/chainnet/tests/mocks/crypto/sign"
 
-	"github.com/btcsuite/btcutil/base58"
-
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 var utxos = []sdkv1beta.UTXO{ //nolint:gochecknoglobals // data that is used across all test funcs
-	{TxID: []byte("random-id-0"), OutIdx: 1, Output: sdkv1beta.TxOutput{Amount: 1, ScriptPubKey: kernel.NewOutput(1, script.P2PK, "pubkey-2").ScriptPubKey, PubKey: "pubkey-2"}},
-	{TxID: []byte("random-id-1"), OutIdx: 3, Output: sdkv1beta.TxOutput{Amount: 2, ScriptPubKey: kernel.NewOutput(2, script.P2PK, "pubkey-2").ScriptPubKey, PubKey: "pubkey-2"}},
-	{TxID: []byte("random-id-2"), OutIdx: 1, Output: sdkv1beta.TxOutput{Amount: 5, ScriptPubKey: kernel.NewOutput(5, script.P2PK, "pubkey-2").ScriptPubKey, PubKey: "pubkey-2"}},
-	{TxID: []byte("random-id-3"), OutIdx: 8, Output: sdkv1beta.TxOutput{Amount: 5, ScriptPubKey: kernel.NewOutput(5, script.P2PK, "pubkey-2").ScriptPubKey, PubKey: "pubkey-2"}},
+	{TxID: []byte("random-id-0"), OutIdx: 1, Output: sdkv1beta.TxOutput{Amount: 1, ScriptPubKey: hexScript(kernel.NewOutput(1, script.P2PK, "pubkey-2").ScriptPubKey), PubKey: "pubkey-2"}},
+	{TxID: []byte("random-id-1"), OutIdx: 3, Output: sdkv1beta.TxOutput{Amount: 2, ScriptPubKey: hexScript(kernel.NewOutput(2, script.P2PK, "pubkey-2").ScriptPubKey), PubKey: "pubkey-2"}},
+	{TxID: []byte("random-id-2"), OutIdx: 1, Output: sdkv1beta.TxOutput{Amount: 5, ScriptPubKey: hexScript(kernel.NewOutput(5, script.P2PK, "pubkey-2").ScriptPubKey), PubKey: "pubkey-2"}},
+	{TxID: []byte("random-id-3"), OutIdx: 8, Output: sdkv1beta.TxOutput{Amount: 5, ScriptPubKey: hexScript(kernel.NewOutput(5, script.P2PK, "pubkey-2").ScriptPubKey), PubKey: "pubkey-2"}},
+}
+
+// hexScript encodes the script in the format used by the SDK types
+func hexScript(s string) string {
+	return hex.EncodeToString([]byte(s))
+}
+
+// expectedScriptSig returns the scriptSig generated by the mocked signer for the input with index inputIdx when
+// spending all the utxos into outputs
+func expectedScriptSig(inputIdx uint, outputs ...kernel.TxOutput) string {
+	kernelUtxos, _ := walletcommon.SDKUTXOsToKernel(utxos)
+
+	inputs := []kernel.TxInput{}
+	for _, utxo := range kernelUtxos {
+		inputs = append(inputs, kernel.NewInput(utxo.TxID, utxo.OutIdx, "", utxo.Output.PubKey))
+	}
+
+	payload, _ := kernel.NewTransaction(inputs, outputs).AssembleForSigning(inputIdx, kernelUtxos[inputIdx].Output, kernel.SighashAll)
+	signature := append(append(payload, []byte("-signed")...), byte(kernel.SighashAll))
+	return hexScript(util_script.EncodeScriptSig([][]byte{signature}))
 }
 
 func TestWallet_SendTransaction(t *testing.T) {
@@ -33,7 +57,7 @@ func TestWallet_SendTransaction(t *testing.T) {
 		On("NewKeyPair").
 		Return([]byte("pubkey-2"), []byte("privkey-2"), nil)
 
-	wallet, err := NewWallet(walletcommon.ClientConfig{}, 1, validator.NewLightValidator(&mockHash.FakeHashing{}), &signer, &mockHash.FakeHashing{}, encoding.NewProtobufEncoder())
+	wallet, err := NewWallet(walletcommon.ClientConfig{}, 1, validator.NewLightValidator(config.NewConfig(), &mockHash.FakeHashing{}), &signer, &mockHash.FakeHashing{}, encoding.NewProtobufEncoder())
 	require.NoError(t, err)
 
 	// send transaction with a target amount bigger than utxos amount
@@ -64,21 +88,22 @@ func TestWallet_SendTransactionCheckOutputTx(t *testing.T) {
 		On("NewKeyPair").
 		Return([]byte("pubkey-2"), []byte("privkey-2"), nil)
 
-	wallet, err := NewWallet(walletcommon.ClientConfig{}, 1, validator.NewLightValidator(hasher), &signer, hasher, encoding.NewProtobufEncoder())
+	wallet, err := NewWallet(walletcommon.ClientConfig{}, 1, validator.NewLightValidator(config.NewConfig(), hasher), &signer, hasher, encoding.NewProtobufEncoder())
 	require.NoError(t, err)
 	// send transaction with correct target and empty tx fee
 	tx, err := wallet.GenerateNewTransaction(script.P2PK, []byte("pubkey-1"), 10, 0, utxos)
 	expectedTx := &sdkv1beta.Transaction{
-		ID: tx.ID,
+		ID:      tx.ID,
+		Version: kernel.TxVersionCanonicalSerialization,
 		Vin: []sdkv1beta.TxInput{
-			{Txid: []byte("random-id-0"), Vout: 1, ScriptSig: base58.Encode([]byte("Inputs:random-id-01random-id-13random-id-21random-id-38Outputs:10\x00KozLnpdoo68 OP_CHECKSIGpubkey-13\x00KozLnpdoo69 OP_CHECKSIGpubkey-2-signed")), PubKey: "pubkey-2"},
-			{Txid: []byte("random-id-1"), Vout: 3, ScriptSig: base58.Encode([]byte("Inputs:random-id-01random-id-13random-id-21random-id-38Outputs:10\x00KozLnpdoo68 OP_CHECKSIGpubkey-13\x00KozLnpdoo69 OP_CHECKSIGpubkey-2-signed")), PubKey: "pubkey-2"},
-			{Txid: []byte("random-id-2"), Vout: 1, ScriptSig: base58.Encode([]byte("Inputs:random-id-01random-id-13random-id-21random-id-38Outputs:10\x00KozLnpdoo68 OP_CHECKSIGpubkey-13\x00KozLnpdoo69 OP_CHECKSIGpubkey-2-signed")), PubKey: "pubkey-2"},
-			{Txid: []byte("random-id-3"), Vout: 8, ScriptSig: base58.Encode([]byte("Inputs:random-id-01random-id-13random-id-21random-id-38Outputs:10\x00KozLnpdoo68 OP_CHECKSIGpubkey-13\x00KozLnpdoo69 OP_CHECKSIGpubkey-2-signed")), PubKey: "pubkey-2"},
+			{Txid: []byte("random-id-0"), Vout: 1, ScriptSig: expectedScriptSig(0, kernel.NewOutput(10, script.P2PK, "pubkey-1"), kernel.NewOutput(3, script.P2PK, "pubkey-2")), PubKey: "pubkey-2"},
+			{Txid: []byte("random-id-1"), Vout: 3, ScriptSig: expectedScriptSig(1, kernel.NewOutput(10, script.P2PK, "pubkey-1"), kernel.NewOutput(3, script.P2PK, "pubkey-2")), PubKey: "pubkey-2"},
+			{Txid: []byte("random-id-2"), Vout: 1, ScriptSig: expectedScriptSig(2, kernel.NewOutput(10, script.P2PK, "pubkey-1"), kernel.NewOutput(3, script.P2PK, "pubkey-2")), PubKey: "pubkey-2"},
+			{Txid: []byte("random-id-3"), Vout: 8, ScriptSig: expectedScriptSig(3, kernel.NewOutput(10, script.P2PK, "pubkey-1"), kernel.NewOutput(3, script.P2PK, "pubkey-2")), PubKey: "pubkey-2"},
 		},
 		Vout: []sdkv1beta.TxOutput{
-			{Amount: 10, ScriptPubKey: kernel.NewOutput(10, script.P2PK, "pubkey-1").ScriptPubKey, PubKey: "pubkey-1"},
-			{Amount: 3, ScriptPubKey: kernel.NewOutput(3, script.P2PK, "pubkey-2").ScriptPubKey, PubKey: "pubkey-2"},
+			{Amount: 10, ScriptPubKey: hexScript(kernel.NewOutput(10, script.P2PK, "pubkey-1").ScriptPubKey), PubKey: "pubkey-1"},
+			{Amount: 3, ScriptPubKey: hexScript(kernel.NewOutput(3, script.P2PK, "pubkey-2").ScriptPubKey), PubKey: "pubkey-2"},
 		},
 	}
 	require.NoError(t, err)
@@ -87,18 +112,193 @@ func TestWallet_SendTransactionCheckOutputTx(t *testing.T) {
 	// send transaction with correct target and some tx fee
 	tx, err = wallet.GenerateNewTransaction(script.P2PK, []byte("pubkey-3"), 10, 2, utxos)
 	expectedTx2 := &sdkv1beta.Transaction{
-		ID: tx.ID,
+		ID:      tx.ID,
+		Version: kernel.TxVersionCanonicalSerialization,
 		Vin: []sdkv1beta.TxInput{
-			{Txid: []byte("random-id-0"), Vout: 1, ScriptSig: base58.Encode([]byte("Inputs:random-id-01random-id-13random-id-21random-id-38Outputs:10\x00KozLnpdoo6A OP_CHECKSIGpubkey-31\x00KozLnpdoo69 OP_CHECKSIGpubkey-2-signed")), PubKey: "pubkey-2"},
-			{Txid: []byte("random-id-1"), Vout: 3, ScriptSig: base58.Encode([]byte("Inputs:random-id-01random-id-13random-id-21random-id-38Outputs:10\x00KozLnpdoo6A OP_CHECKSIGpubkey-31\x00KozLnpdoo69 OP_CHECKSIGpubkey-2-signed")), PubKey: "pubkey-2"},
-			{Txid: []byte("random-id-2"), Vout: 1, ScriptSig: base58.Encode([]byte("Inputs:random-id-01random-id-13random-id-21random-id-38Outputs:10\x00KozLnpdoo6A OP_CHECKSIGpubkey-31\x00KozLnpdoo69 OP_CHECKSIGpubkey-2-signed")), PubKey: "pubkey-2"},
-			{Txid: []byte("random-id-3"), Vout: 8, ScriptSig: base58.Encode([]byte("Inputs:random-id-01random-id-13random-id-21random-id-38Outputs:10\x00KozLnpdoo6A OP_CHECKSIGpubkey-31\x00KozLnpdoo69 OP_CHECKSIGpubkey-2-signed")), PubKey: "pubkey-2"},
+			{Txid: []byte("random-id-0"), Vout: 1, ScriptSig: expectedScriptSig(0, kernel.NewOutput(10, script.P2PK, "pubkey-3"), kernel.NewOutput(1, script.P2PK, "pubkey-2")), PubKey: "pubkey-2"},
+			{Txid: []byte("random-id-1"), Vout: 3, ScriptSig: expectedScriptSig(1, kernel.NewOutput(10, script.P2PK, "pubkey-3"), kernel.NewOutput(1, script.P2PK, "pubkey-2")), PubKey: "pubkey-2"},
+			{Txid: []byte("random-id-2"), Vout: 1, ScriptSig: expectedScriptSig(2, kernel.NewOutput(10, script.P2PK, "pubkey-3"), kernel.NewOutput(1, script.P2PK, "pubkey-2")), PubKey: "pubkey-2"},
+			{Txid: []byte("random-id-3"), Vout: 8, ScriptSig: expectedScriptSig(3, kernel.NewOutput(10, script.P2PK, "pubkey-3"), kernel.NewOutput(1, script.P2PK, "pubkey-2")), PubKey: "pubkey-2"},
 		},
 		Vout: []sdkv1beta.TxOutput{
-			{Amount: 10, ScriptPubKey: kernel.NewOutput(10, script.P2PK, "pubkey-3").ScriptPubKey, PubKey: "pubkey-3"},
-			{Amount: 1, ScriptPubKey: kernel.NewOutput(1, script.P2PK, "pubkey-2").ScriptPubKey, PubKey: "pubkey-2"},
+			{Amount: 10, ScriptPubKey: hexScript(kernel.NewOutput(10, script.P2PK, "pubkey-3").ScriptPubKey), PubKey: "pubkey-3"},
+			{Amount: 1, ScriptPubKey: hexScript(kernel.NewOutput(1, script.P2PK, "pubkey-2").ScriptPubKey), PubKey: "pubkey-2"},
 		},
 	}
 	require.NoError(t, err)
 	assert.Equal(t, expectedTx2, tx)
 }
+
+func TestWallet_GenerateAndClaimHTLC(t *testing.T) {
+	hasher := &mockHash.FakeHashing{}
+	signer := mockSign.MockSign{}
+	signer.
+		On("NewKeyPair").
+		Return([]byte("pubkey-2"), []byte("privkey-2"), nil)
+
+	wallet, err := NewWallet(walletcommon.ClientConfig{}, 1, validator.NewLightValidator(config.NewConfig(), hasher), &signer, hasher, encoding.NewProtobufEncoder())
+	require.NoError(t, err)
+
+	preimage := []byte("secret")
+	hashLock := sha256.Sum256(preimage)
+
+	// lock the funds in a HTLC in which pubkey-2 can claim them (the wallet is both sender and receiver)
+	tx, err := wallet.GenerateHTLCTransaction([]byte("pubkey-2"), hashLock[:], 100, 10, 1, utxos)
+	require.NoError(t, err)
+	require.Len(t, tx.Vout, 2)
+	assert.Equal(t, hexScript(script.NewHTLCScript(script.HTLCParams{
+		HashLock:       hashLock[:],
+		ReceiverPubKey: []byte("pubkey-2"),
+		LockTime:       100,
+		SenderPubKey:   []byte("pubkey-2"),
+	})), tx.Vout[0].ScriptPubKey)
+	assert.Equal(t, uint(10), tx.Vout[0].Amount)
+	assert.Equal(t, uint(2), tx.Vout[1].Amount)
+
+	// invalid HTLC arguments
+	_, err = wallet.GenerateHTLCTransaction([]byte("pubkey-2"), []byte{}, 100, 10, 1, utxos)
+	require.Error(t, err)
+
+	// claim the funds revealing the preimage
+	htlcUTXO := sdkv1beta.UTXO{TxID: tx.ID, OutIdx: 0, Output: tx.Vout[0]}
+	claimTx, err := wallet.ClaimHTLC(htlcUTXO, preimage, 1)
+	require.NoError(t, err)
+	require.Len(t, claimTx.Vin, 1)
+	assert.Equal(t, uint(9), claimTx.Vout[0].Amount)
+	scriptSig, err := hex.DecodeString(claimTx.Vin[0].ScriptSig)
+	require.NoError(t, err)
+	elements, err := util_script.DecodeScriptSig(string(scriptSig))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), elements[1])
+
+	// wrong preimage or fee bigger than the HTLC amount
+	_, err = wallet.ClaimHTLC(htlcUTXO, []byte("wrong"), 1)
+	require.Error(t, err)
+	_, err = wallet.ClaimHTLC(htlcUTXO, preimage, 10)
+	require.Error(t, err)
+}
+
+func TestWallet_RefundHTLC(t *testing.T) {
+	hasher := &mockHash.FakeHashing{}
+	signer := mockSign.MockSign{}
+	signer.
+		On("NewKeyPair").
+		Return([]byte("pubkey-2"), []byte("privkey-2"), nil)
+
+	wallet, err := NewWallet(walletcommon.ClientConfig{}, 1, validator.NewLightValidator(config.NewConfig(), hasher), &signer, hasher, encoding.NewProtobufEncoder())
+	require.NoError(t, err)
+
+	hashLock := sha256.Sum256([]byte("secret"))
+
+	// lock the funds in a HTLC in which pubkey-1 can claim them, the wallet can take them back from height 100
+	tx, err := wallet.GenerateHTLCTransaction([]byte("pubkey-1"), hashLock[:], 100, 10, 1, utxos)
+	require.NoError(t, err)
+
+	htlcUTXO := sdkv1beta.UTXO{TxID: tx.ID, OutIdx: 0, Output: tx.Vout[0]}
+	refundTx, err := wallet.RefundHTLC(htlcUTXO, 1)
+	require.NoError(t, err)
+	require.Len(t, refundTx.Vin, 1)
+	assert.Equal(t, uint(100), refundTx.LockTime)
+	assert.Equal(t, uint(9), refundTx.Vout[0].Amount)
+
+	// the lock time must reach the node so that the scriptSig can be verified by the interpreter
+	kernelTx, err := walletcommon.SDKTransactionToKernel(refundTx)
+	require.NoError(t, err)
+	kernelUTXO, err := walletcommon.SDKUTXOToKernel(htlcUTXO)
+	require.NoError(t, err)
+
+	interpreter := rpnInter.NewScriptInterpreter(&signer)
+	valid, err := interpreter.VerifyScriptPubKey(kernelUTXO.Output, kernelTx.Vin[0].ScriptSig, kernelTx, 0)
+	require.NoError(t, err)
+	assert.True(t, valid)
+	assert.True(t, kernelTx.IsFinal(100, 0))
+	assert.False(t, kernelTx.IsFinal(99, 0))
+
+	// the refund can't be spent before the lock time of the HTLC
+	kernelTx.LockTime = 99
+	valid, err = interpreter.VerifyScriptPubKey(kernelUTXO.Output, kernelTx.Vin[0].ScriptSig, kernelTx, 0)
+	require.Error(t, err)
+	assert.False(t, valid)
+
+	// only the sender can take the funds back
+	receiverSigner := mockSign.MockSign{}
+	receiverSigner.
+		On("NewKeyPair").
+		Return([]byte("pubkey-1"), []byte("privkey-1"), nil)
+
+	receiver, err := NewWallet(walletcommon.ClientConfig{}, 1, validator.NewLightValidator(config.NewConfig(), hasher), &receiverSigner, hasher, encoding.NewProtobufEncoder())
+	require.NoError(t, err)
+	_, err = receiver.RefundHTLC(htlcUTXO, 1)
+	require.Error(t, err)
+
+	// fee bigger than the HTLC amount or output that is not a HTLC
+	_, err = wallet.RefundHTLC(htlcUTXO, 10)
+	require.Error(t, err)
+	_, err = wallet.RefundHTLC(sdkv1beta.UTXO{TxID: tx.ID, OutIdx: 1, Output: tx.Vout[1]}, 1)
+	require.Error(t, err)
+}
+
+func TestWallet_GenerateDataTransaction(t *testing.T) {
+	hasher := &mockHash.FakeHashing{}
+	signer := mockSign.MockSign{}
+	signer.
+		On("NewKeyPair").
+		Return([]byte("pubkey-2"), []byte("privkey-2"), nil)
+
+	wallet, err := NewWallet(walletcommon.ClientConfig{}, 1, validator.NewLightValidator(config.NewConfig(), hasher), &signer, hasher, encoding.NewProtobufEncoder())
+	require.NoError(t, err)
+
+	digest := sha256.Sum256([]byte("document"))
+
+	// the inputs only cover the fee, the rest goes back as change
+	tx, err := wallet.GenerateDataTransaction(digest[:], 2, utxos)
+	require.NoError(t, err)
+	require.Len(t, tx.Vin, 2)
+	require.Len(t, tx.Vout, 2)
+	assert.Equal(t, uint(0), tx.Vout[0].Amount)
+	assert.Equal(t, hexScript(script.NewNullDataScript(digest[:])), tx.Vout[0].ScriptPubKey)
+	assert.Equal(t, uint(1), tx.Vout[1].Amount)
+
+	// no data or data bigger than the max data carrier size
+	_, err = wallet.GenerateDataTransaction([]byte{}, 1, utxos)
+	require.Error(t, err)
+	_, err = wallet.GenerateDataTransaction([]byte(strings.Repeat("a", config.DefaultMaxDataCarrierSize+1)), 1, utxos)
+	require.Error(t, err)
+}
+
+func TestWallet_BumpFee(t *testing.T) {
+	hasher := &mockHash.FakeHashing{}
+	signer := mockSign.MockSign{}
+	signer.
+		On("NewKeyPair").
+		Return([]byte("pubkey-2"), []byte("privkey-2"), nil)
+
+	wallet, err := NewWallet(walletcommon.ClientConfig{}, 1, validator.NewLightValidator(config.NewConfig(), hasher), &signer, hasher, encoding.NewProtobufEncoder())
+	require.NoError(t, err)
+
+	tx, err := wallet.GenerateNewTransaction(script.P2PK, []byte("pubkey-1"), 10, 0, utxos)
+	require.NoError(t, err)
+
+	// the fee increase is paid by the change output
+	bumpedTx, err := wallet.BumpFee(tx, 1, utxos)
+	require.NoError(t, err)
+	assert.NotEqual(t, tx.ID, bumpedTx.ID)
+	assert.Equal(t, tx.Vin[0].Txid, bumpedTx.Vin[0].Txid)
+	require.Len(t, bumpedTx.Vout, 2)
+	assert.Equal(t, uint(10), bumpedTx.Vout[0].Amount)
+	assert.Equal(t, uint(2), bumpedTx.Vout[1].Amount)
+	assert.Equal(t, expectedScriptSig(0, kernel.NewOutput(10, script.P2PK, "pubkey-1"), kernel.NewOutput(2, script.P2PK, "pubkey-2")), bumpedTx.Vin[0].ScriptSig)
+
+	// the change output is removed if the fee increase consumes it completely
+	bumpedTx, err = wallet.BumpFee(bumpedTx, 3, utxos)
+	require.NoError(t, err)
+	require.Len(t, bumpedTx.Vout, 1)
+
+	// the new fee must be higher than the current one and covered by the change
+	_, err = wallet.BumpFee(tx, 0, utxos)
+	require.Error(t, err)
+	_, err = wallet.BumpFee(tx, 4, utxos)
+	require.Error(t, err)
+	_, err = wallet.BumpFee(bumpedTx, 4, utxos)
+	require.Error(t, err)
+}
@@ -67,13 +67,16 @@ func TestSDK_SubmitTransactionsMineBlockAndReadBack(t *testing.T) {
 
 	chainSubject := observer.NewChainSubject()
 	netSubject := observer.NewNetSubject()
-	memPool := mempool.NewMemPool(100)
+	memPool := mempool.NewMemPool(config.DefaultMaxMempoolSize, 0)
 	utxoSet := utxoset.NewUTXOSet(cfg)
 	chainExplorer := explorer.NewChainExplorer(store, hasher)
+	lightValidator := validator.NewLightValidator(cfg, hasher)
 	heavyValidator := validator.NewHeavyValidator(
 		cfg,
-		validator.NewLightValidator(hasher),
+		lightValidator,
 		chainExplorer,
+		utxoSet,
+		mempool.NewMemPoolExplorer(memPool),
 		signer,
 		hasher,
 	)
@@ -85,6 +88,7 @@ func TestSDK_SubmitTransactionsMineBlockAndReadBack(t *testing.T) {
 		utxoSet,
 		hasher,
 		heavyValidator,
+		lightValidator,
 		chainSubject,
 		encoding.NewGobEncoder(),
 	)
@@ -105,7 +109,7 @@ func TestSDK_SubmitTransactionsMineBlockAndReadBack(t *testing.T) {
 	fundingBlock2, err := blockMiner.MineBlock()
 	require.NoError(t, err)
 
-	router := network.NewHTTPRouter(cfg, chainExplorer, netSubject)
+	router := network.NewHTTPRouter(cfg, chainExplorer, mempool.NewMemPoolExplorer(memPool), netSubject)
 	require.NoError(t, router.Start())
 	t.Cleanup(func() { require.NoError(t, router.Stop()) })
 
@@ -181,6 +185,8 @@ func newSDKTestConfig(t *testing.T) *config.Config {
 	cfg.Wallet.ServerAddress = "127.0.0.1"
 	cfg.Wallet.ServerPort = uint(port)
 	cfg.Wallet.RequestTimeout = 5 * time.Second
+	// the funding coinbases are spent right after being mined
+	cfg.Chain.CoinbaseMaturity = 1
 
 	return cfg
 }
@@ -221,7 +227,7 @@ func newSignedSpendTx(
 		},
 	)
 
-	scriptSig, err := interpreter.NewScriptInterpreter(signer).GenerateScriptSig(sourceOutput.ScriptPubKey, pubKey, privKey, tx)
+	scriptSig, err := interpreter.NewScriptInterpreter(signer).GenerateScriptSig(sourceOutput, pubKey, privKey, tx, 0, kernel.SighashAll)
 	require.NoError(t, err)
 	tx.Vin[0].ScriptSig = scriptSig
 
@@ -2,6 +2,8 @@
 package storage
 
 import (
+	"math/big"
+
 	"github.com/yago-123/chainnet/pkg/kernel"
 
 	"github.com/stretchr/testify/mock"
@@ -20,6 +22,15 @@ func (ms *MockStorage) PersistHeader(_ []byte, _ kernel.BlockHeader) error {
 	return nil
 }
 
+func (ms *MockStorage) PersistChainWork(_ []byte, _ *big.Int) error {
+	return nil
+}
+
+func (ms *MockStorage) RemoveLastBlock(block kernel.Block) error {
+	args := ms.Called(block)
+	return args.Error(0)
+}
+
 func (ms *MockStorage) GetLastBlock() (*kernel.Block, error) {
 	args := ms.Called()
 	return args.Get(0).(*kernel.Block), args.Error(1)
@@ -55,6 +66,16 @@ func (ms *MockStorage) RetrieveHeaderByHash(hash []byte) (*kernel.BlockHeader, e
 	return args.Get(0).(*kernel.BlockHeader), args.Error(1)
 }
 
+func (ms *MockStorage) RetrieveChainWorkByHash(hash []byte) (*big.Int, error) {
+	args := ms.Called(hash)
+	return args.Get(0).(*big.Int), args.Error(1)
+}
+
+func (ms *MockStorage) GetLastChainWork() (*big.Int, error) {
+	args := ms.Called()
+	return args.Get(0).(*big.Int), args.Error(1)
+}
+
 func (ms *MockStorage) Typ() string {
 	return "mock"
 }
@@ -67,6 +88,10 @@ func (ms *MockStorage) OnBlockAddition(block *kernel.Block) {
 	ms.Called(block)
 }
 
+func (ms *MockStorage) OnBlockRemoval(block *kernel.Block) {
+	ms.Called(block)
+}
+
 func (ms *MockStorage) OnTxAddition(tx *kernel.Transaction) {
 	ms.Called(tx)
 }